@@ -4,8 +4,10 @@ import (
 	"github.com/coreos/pkg/capnslog"
 	"immortality-demo/config"
 	"immortality-demo/pkg/app"
+	_ "immortality-demo/pkg/driver/ceph"
+	_ "immortality-demo/pkg/driver/lvm"
+	_ "immortality-demo/pkg/driver/three_par"
 	"immortality-demo/pkg/gredis"
-	"immortality-demo/service/workerpool"
 )
 
 var ulog = capnslog.NewPackageLogger("immortality", "main")
@@ -23,5 +25,4 @@ func init() {
 func main() {
 	agent := app.NewImmortalityAgent()
 	agent.Start()
-	workerpool.NewWorkPool(10)
 }
@@ -0,0 +1,26 @@
+package app
+
+import (
+	"github.com/gin-gonic/gin"
+	"immortality-demo/config"
+	"immortality-demo/pkg/data"
+)
+
+// OperatorOnly rejects the callers whose X-User-Id is not one of
+// config.Config.Operators, it guards the APIs acting on every user
+func OperatorOnly() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if userId := c.GetHeader("X-User-Id"); userId != "" {
+			for _, operator := range config.Config.Operators {
+				if userId == operator {
+					c.Next()
+					return
+				}
+			}
+		}
+		ulog.Warningf("%s %s refused to user %q", c.Request.Method, c.Request.URL.Path, c.GetHeader("X-User-Id"))
+		appG := Gin{C: c}
+		appG.ErrorResponse(data.ErrOperatorOnly)
+		c.Abort()
+	}
+}
@@ -2,7 +2,9 @@ package app
 
 import (
 	"github.com/gin-gonic/gin"
+	"immortality-demo/pkg/data"
 	e "immortality-demo/pkg/error"
+	"net/http"
 )
 
 type Response struct {
@@ -34,6 +36,15 @@ func (g *Gin) Response(httpCode int, status string, message string, data interfa
 	})
 }
 
+// ErrorResponse setting gin.JSON from err, business logic errors keep their own http code
+func (g *Gin) ErrorResponse(err error) {
+	if bErr, ok := err.(data.BusinessLogicError); ok {
+		g.Response(bErr.HttpCode, bErr.ErrorCode, bErr.Message, nil)
+		return
+	}
+	g.Response(http.StatusInternalServerError, e.ERROR, err.Error(), nil)
+}
+
 type CommonError struct {
 	Errors map[string]interface{} `json:"errors"`
 }
@@ -3,18 +3,32 @@ package app
 import (
 	"context"
 	"immortality-demo/config"
+	"immortality-demo/pkg/driver"
 	"immortality-demo/routers"
+	"immortality-demo/service/array_service"
+	"immortality-demo/service/disk_service"
+	"immortality-demo/service/strategy_service"
+	"immortality-demo/service/workerpool"
 	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
 	"sync"
 	"syscall"
+	"time"
 )
 
+// workers is the number of jobs the agent runs at the same time
+const workers = 10
+
 type ImmortalityAgent struct {
 	context    context.Context
 	cancelFunc context.CancelFunc
 	waitGroup  sync.WaitGroup
+	pool       *workerpool.WorkerPool
+	collector  *array_service.Collector
+	strategies *strategy_service.Runner
+	sweeper    *disk_service.Sweeper
 }
 
 func NewImmortalityAgent() *ImmortalityAgent {
@@ -33,6 +47,14 @@ func (agent *ImmortalityAgent) Start() {
 	ulog.Info("Starting immortality agent service...")
 	apiCtxt, _ := context.WithCancel(agent.context)
 	go startAPIServer(apiCtxt, agent, errinfo)
+	agent.pool = workerpool.NewWorkPool(workers, time.Duration(config.Config.JobTimeout)*time.Second)
+	agent.startCollector()
+	agent.strategies = strategy_service.NewRunner()
+	agent.strategies.Start()
+	if config.Config.SnapshotSweepPeriod > 0 {
+		agent.sweeper = disk_service.NewSweeper(time.Duration(config.Config.SnapshotSweepPeriod) * time.Second)
+		agent.sweeper.Start()
+	}
 	select {
 	case s := <-signals:
 		ulog.Infof("Received system singal %s to abort service...", s)
@@ -63,7 +85,35 @@ func startAPIServer(ctxt context.Context, agent *ImmortalityAgent, errinfo chan
 	}
 }
 
+// startCollector refreshes the capacity of the arrays every ThreeParJobPeriod seconds
+func (agent *ImmortalityAgent) startCollector() {
+	period, err := strconv.Atoi(config.Config.ThreeParJobPeriod)
+	if err != nil || period <= 0 {
+		ulog.Errorf("invalid ThreeParJobPeriod %q, capacity collector not started", config.Config.ThreeParJobPeriod)
+		return
+	}
+	agent.collector = array_service.NewCollector(time.Duration(period) * time.Second)
+	agent.collector.Start()
+}
+
+// Stop shuts the API server down first so no new jobs come in, then drains the
+// worker pool and closes the volume drivers
 func (agent *ImmortalityAgent) Stop() {
 	agent.cancelFunc()
+	if agent.collector != nil {
+		agent.collector.Stop()
+	}
+	if agent.strategies != nil {
+		agent.strategies.Stop()
+	}
+	if agent.sweeper != nil {
+		agent.sweeper.Stop()
+	}
+	if agent.pool != nil {
+		agent.pool.Stop(time.Duration(config.Config.ShutdownTimeout) * time.Second)
+	}
+	ctxt, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	driver.Close(ctxt)
 	agent.waitGroup.Wait()
 }
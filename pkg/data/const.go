@@ -9,6 +9,7 @@ const ProjectDiskQuota = 500
 const ProjectImageQuota = 50
 const ProjectDiskTotalSizeQuota = 32768 // GB
 const VmInstanceDataDiskQuota = 15
+const StrategyDiskQuota = 500 // disks bound to one snapshot strategy
 
 var MaxDate = time.Unix(253402214400, 0) // UTC 9999-12-31 00:00:00
 
@@ -85,6 +86,33 @@ var SnapshotStatusMap2 = map[string]int8{
 	"Error":     4,
 }
 
+// Snapshot.SnapshotType, automatic snapshots are taken and removed by the
+// snapshot strategies
+const (
+	SnapshotTypeManual = 0
+	SnapshotTypeAuto   = 1
+)
+
+var SnapshotTypeMap = map[int]string{
+	0: "Manual",
+	1: "Auto",
+}
+
+const (
+	StrategyStatusDisabled int32 = 0
+	StrategyStatusEnabled  int32 = 1
+)
+
+var StrategyStatusMap = map[int32]string{
+	0: "Disabled",
+	1: "Enabled",
+}
+
+var StrategyStatusMap2 = map[string]int32{
+	"Disabled": 0,
+	"Enabled":  1,
+}
+
 var CategoryMap = map[string]int8{
 	"hdd":        1,
 	"hybrid-hdd": 2,
@@ -122,6 +150,26 @@ const (
 	QuotaTypeTotalSize  int8 = 7
 )
 
+var QuotaTypeMap = map[int8]string{
+	1: "Project",
+	2: "User",
+	3: "VmInstance",
+	4: "Disk",
+	5: "Snapshot",
+	6: "Image",
+	7: "TotalSize",
+}
+
+var QuotaTypeMap2 = map[string]int8{
+	"Project":    1,
+	"User":       2,
+	"VmInstance": 3,
+	"Disk":       4,
+	"Snapshot":   5,
+	"Image":      6,
+	"TotalSize":  7,
+}
+
 const (
 	ChargeTypePrepayed   int8 = 1
 	ChargeTypePayAsYouGo int8 = 2
@@ -187,9 +235,14 @@ var ImageFormatMap = map[int8]string{
 	2: "raw",
 }
 
+var ImageFormatMap2 = map[string]int8{
+	"qcow2": 1,
+	"raw":   2,
+}
+
 const (
 	OSTypeLinux   int8 = 1
-	OSTypeWindows int8 = 1
+	OSTypeWindows int8 = 2
 )
 
 var OSTypeMap = map[int8]string{
@@ -227,6 +280,8 @@ const (
 	HPE3PAR_MAX_DISK_SIZE = 65536 // 64T
 	HPE3PAR_MAX_ID_LEN    = 27
 	CEPH                  = "ceph"
+	LVM                   = "lvm"
+	FAKE                  = "fake"
 )
 
 const (
@@ -262,6 +317,59 @@ const (
 	JobDispatchQueue = "IMMORTALITY_ASYNCHRONOUS_JOBS"
 )
 
+// SnapshotEventChannel is the redis channel the snapshot events are published on
+const (
+	SnapshotEventChannel = "IMMORTALITY_SNAPSHOT_EVENTS"
+	SnapshotEventExpired = "SnapshotExpired"
+)
+
+const (
+	ActionCreateDisk     = "CreateDisk"
+	ActionCreateDisks    = "CreateDisks"
+	ActionDeleteDisk     = "DeleteDisk"
+	ActionDeleteDisks    = "DeleteDisks"
+	ActionCreateSnapshot = "CreateSnapshot"
+	ActionDeleteSnapshot = "DeleteSnapshot"
+	ActionCreateImage    = "CreateImage"
+	ActionDeleteImage    = "DeleteImage"
+	ActionResetDisk      = "ResetDisk"
+	ActionReInitDisk     = "ReInitDisk"
+	ActionResizeDisk     = "ResizeDisk"
+	ActionResizeDisks    = "ResizeDisks"
+	ActionExport         = "Export"
+	ActionCancelExport   = "CancelExport"
+	ActionAddDiskQoS     = "AddDiskQoS"
+	ActionRemoveDiskQoS  = "RemoveDiskQoS"
+	ActionUpdateDiskQoS  = "UpdateDiskQoS"
+)
+
+const (
+	JobStatePending   int8 = 1
+	JobStateRunning   int8 = 2
+	JobStateFailed    int8 = 3
+	JobStateSucceeded int8 = 4
+	JobStateDead      int8 = 5
+	JobStateTimeout   int8 = 6
+)
+
+var JobStateMap = map[int8]string{
+	1: "pending",
+	2: "running",
+	3: "failed",
+	4: "succeeded",
+	5: "dead",
+	6: "timeout",
+}
+
+var JobStateMap2 = map[string]int8{
+	"pending":   1,
+	"running":   2,
+	"failed":    3,
+	"succeeded": 4,
+	"dead":      5,
+	"timeout":   6,
+}
+
 const (
 	DISK_IS_SHARE_YES = 1
 	DISK_IS_SHARE_NO  = 2
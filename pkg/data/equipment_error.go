@@ -85,3 +85,24 @@ var ErrInvalidThreeParId = BusinessLogicError{
 	Message:            "The specified ThreeParId is invalid",
 	DescriptionChinese: "指定的 ThreeParId 不存在",
 }
+
+var ErrInvalidArrayTLS = BusinessLogicError{
+	HttpCode:           400,
+	ErrorCode:          "UniCloudStorage-InvalidArrayTLS",
+	Message:            "The specified CaBundle, CertSha256 or Insecure is invalid",
+	DescriptionChinese: "指定的 CaBundle、CertSha256 或 Insecure 无效，请查阅文档。",
+}
+
+var ErrArrayCertificate = BusinessLogicError{
+	HttpCode:           400,
+	ErrorCode:          "UniCloudStorage-ArrayCertificate",
+	Message:            "The certificate of the array failed verification",
+	DescriptionChinese: "存储阵列的证书校验失败",
+}
+
+var ErrArrayUnreachable = BusinessLogicError{
+	HttpCode:           502,
+	ErrorCode:          "UniCloudStorage-ArrayUnreachable",
+	Message:            "Failed to log in to the array with the specified address and credentials",
+	DescriptionChinese: "无法使用指定的地址和账号登录存储阵列",
+}
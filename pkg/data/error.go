@@ -50,8 +50,8 @@ var ErrDiskNotSystem = BusinessLogicError{
 var ErrDiskQuotaExceeded = BusinessLogicError{
 	HttpCode:           403,
 	ErrorCode:          "UniCloudStorage-DiskQuotaExceeded",
-	Message:            "Quota of disk per project(500) exceeded",
-	DescriptionChinese: "每用户最多创建500个硬盘，如有特殊需求请提交工单",
+	Message:            "Quota of disk per project exceeded",
+	DescriptionChinese: "用户硬盘数量超出配额，如有特殊需求请提交工单",
 }
 
 var ErrDiskReInitBadStatus = BusinessLogicError{
@@ -89,6 +89,13 @@ var ErrDiskResizingBadStatus = BusinessLogicError{
 	DescriptionChinese: "云盘只能在 Available 或 In-use 状态才可以扩容",
 }
 
+var ErrDiskSnapshottingBadStatus = BusinessLogicError{
+	HttpCode:           403,
+	ErrorCode:          "UniCloudStorage-DiskSnapshottingBadStatus",
+	Message:            "Snapshot can only be created when disk is Available or In-use",
+	DescriptionChinese: "云盘只能在 Available 或 In-use 状态才可以创建快照",
+}
+
 var ErrDiskExportBadStatus = BusinessLogicError{
 	HttpCode:           403,
 	ErrorCode:          "UniCloudStorage-DiskExportBadStatus",
@@ -110,11 +117,18 @@ var ErrDiskExportingBadStatus = BusinessLogicError{
 	DescriptionChinese: "云盘只能在 Available 或 In-use 状态才可以被导出",
 }
 
+var ErrDiskCancelExportingBadStatus = BusinessLogicError{
+	HttpCode:           403,
+	ErrorCode:          "UniCloudStorage-DiskCancelExportingBadStatus",
+	Message:            "Disk export can only be cancelled when Available or In-use",
+	DescriptionChinese: "云盘只能在 Available 或 In-use 状态才可以取消导出",
+}
+
 var ErrDiskTotalSizeQuotaExceeded = BusinessLogicError{
 	HttpCode:           403,
 	ErrorCode:          "UniCloudStorage-DiskTotalSizeQuotaExceeded",
-	Message:            "Quota of total disk size per project(32768 GB) exceeded",
-	DescriptionChinese: "用户硬盘配额为32768 GB，如有特殊需求请提交工单",
+	Message:            "Quota of total disk size per project exceeded",
+	DescriptionChinese: "用户硬盘总容量超出配额，如有特殊需求请提交工单",
 }
 
 var ErrFieldActionWrongValue = BusinessLogicError{
@@ -137,6 +151,13 @@ var ErrInvalidUser = BusinessLogicError{
 	DescriptionChinese: "请求的磁盘不属于该用户",
 }
 
+var ErrOperatorOnly = BusinessLogicError{
+	HttpCode:           403,
+	ErrorCode:          "UniCloudStorage-OperatorOnly",
+	Message:            "The API is only open to operators",
+	DescriptionChinese: "该接口仅对运维人员开放",
+}
+
 var ErrFieldArchitectureWrongValue = BusinessLogicError{
 	HttpCode:           400,
 	ErrorCode:          "UniCloudStorage-FieldArchitectureWrongValue",
@@ -399,8 +420,8 @@ var ErrFieldVolumeSizeWrongValue = BusinessLogicError{
 var ErrImageQuotaExceeded = BusinessLogicError{
 	HttpCode:           403,
 	ErrorCode:          "UniCloudStorage-ImageQuotaExceeded",
-	Message:            "Quota of image per project(50) exceeded",
-	DescriptionChinese: "每个用户最多创建50个镜像，如有特殊需求请提交工单。",
+	Message:            "Quota of image per project exceeded",
+	DescriptionChinese: "用户镜像数量超出配额，如有特殊需求请提交工单。",
 }
 
 var ErrInitSourceNotFound = BusinessLogicError{
@@ -536,6 +557,13 @@ var ErrZoneNotAvailable = BusinessLogicError{
 	DescriptionChinese: "指定的可用区不存在或者不提供此服务",
 }
 
+var ErrNoStorageAvailable = BusinessLogicError{
+	HttpCode:           403,
+	ErrorCode:          "UniCloudStorage-NoStorageAvailable",
+	Message:            "No storage in the specified pod has enough free capacity for the requested category",
+	DescriptionChinese: "指定的 Pod 中没有满足该类型且容量充足的存储",
+}
+
 var ErrJsonDecode = BusinessLogicError{
 	HttpCode:           500,
 	ErrorCode:          "UniCloudStorage-DecodeJsonError",
@@ -56,4 +56,10 @@ var ErrServerInternalCache = BusinessLogicError{
 	ErrorCode:          "UniCloudStorage-ServerInternalCache",
 	Message:            "Internal cache error",
 	DescriptionChinese: "服务器内部 Cache 错误.",
-}
\ No newline at end of file
+}
+var ErrServerInternalImageService = BusinessLogicError{
+	HttpCode:           http.StatusServiceUnavailable,
+	ErrorCode:          "UniCloudStorage-ImageServiceUnavailable",
+	Message:            "Internal image service visit error",
+	DescriptionChinese: "服务器内部访问镜像服务错误.",
+}
@@ -0,0 +1,15 @@
+package data
+
+var ErrInvalidRequestId = BusinessLogicError{
+	HttpCode:           404,
+	ErrorCode:          "UniCloudStorage-InvalidRequestId",
+	Message:            "The specified RequestId is invalid",
+	DescriptionChinese: "指定的 RequestId 不存在",
+}
+
+var ErrFieldJobStateWrongValue = BusinessLogicError{
+	HttpCode:           400,
+	ErrorCode:          "UniCloudStorage-FieldJobStateWrongValue",
+	Message:            "Invalid value for state",
+	DescriptionChinese: "字段 state 错误，请查阅文档。",
+}
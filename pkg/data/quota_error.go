@@ -0,0 +1,29 @@
+package data
+
+var ErrLackOfRequiredFieldSubjectId = BusinessLogicError{
+	HttpCode:           400,
+	ErrorCode:          "UniCloudStorage-LackOfRequiredFieldSubjectId",
+	Message:            "Missing required field SubjectId",
+	DescriptionChinese: "缺少必要的字段 SubjectId，请查阅文档。",
+}
+
+var ErrInvalidQuotaSubjectType = BusinessLogicError{
+	HttpCode:           400,
+	ErrorCode:          "UniCloudStorage-InvalidQuotaSubjectType",
+	Message:            "The specified SubjectType is invalid",
+	DescriptionChinese: "指定的 SubjectType 无效，请查阅文档。",
+}
+
+var ErrInvalidQuotaObjectType = BusinessLogicError{
+	HttpCode:           400,
+	ErrorCode:          "UniCloudStorage-InvalidQuotaObjectType",
+	Message:            "The specified ObjectType is invalid for the SubjectType",
+	DescriptionChinese: "指定的 ObjectType 对该 SubjectType 无效，请查阅文档。",
+}
+
+var ErrFieldAllowWrongValue = BusinessLogicError{
+	HttpCode:           400,
+	ErrorCode:          "UniCloudStorage-FieldAllowWrongValue",
+	Message:            "Invalid value for Allow",
+	DescriptionChinese: "字段 Allow 错误，请查阅文档。",
+}
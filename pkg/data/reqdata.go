@@ -83,6 +83,21 @@ type DeleteSnapshotRequest struct {
 	SnapshotId   string `json:"snapshot_id"`
 	StorageType  string `json:"storage_type"`
 	ScheduleInfo string `json:"schedule_info"`
+	// ExpireAt is set when the snapshot is deleted for expiring, in RFC3339,
+	// SnapshotEventExpired is published once it is gone
+	ExpireAt string `json:"expire_at,omitempty"`
+	UserId   string `json:"user_id,omitempty"`
+}
+
+// SnapshotEvent is published on SnapshotEventChannel
+type SnapshotEvent struct {
+	Event      string `json:"Event"`
+	SnapshotId string `json:"SnapshotId"`
+	DiskId     string `json:"DiskId"`
+	UserId     string `json:"UserId"`
+	ExpireAt   string `json:"ExpireAt"`
+	RequestId  string `json:"RequestId"`
+	Time       string `json:"Time"`
 }
 
 type ReInitDiskRequest struct {
@@ -147,6 +162,9 @@ type ExportDiskResponse struct {
 	Lun            int
 	ThreeParWWN    string
 	ThreeParDataIP string
+	// Target is how the compute node reaches a disk without a WWN,
+	// the rbd map descriptor for ceph
+	Target string
 }
 
 type GetSystemCapacityRequest struct {
@@ -154,6 +172,22 @@ type GetSystemCapacityRequest struct {
 	ScheduleInfo string `json:"schedule_info"`
 }
 
+// SystemCapacity is what GetSystemCapacity reports, in the shape of the 3PAR
+// GET /capacity response. Drivers without such device types map their pools on them.
+type SystemCapacity struct {
+	AllCapacity DeviceCapacity `json:"allCapacity"`
+	FCCapacity  DeviceCapacity `json:"FCCapacity"`
+	NLCapacity  DeviceCapacity `json:"NLCapacity"`
+	SSDCapacity DeviceCapacity `json:"SSDCapacity"`
+}
+
+type DeviceCapacity struct {
+	TotalMiB               float64 `json:"totalMiB"`
+	FreeMiB                float64 `json:"freeMiB"`
+	FailedCapacityMiB      float64 `json:"failedCapacityMiB"`
+	UnavailableCapacityMiB float64 `json:"unavailableCapacityMiB"`
+}
+
 type GetSystemUtilizationRequest struct {
 	RequestID    string
 	StorageType  string
@@ -3,6 +3,10 @@ package data
 type ScheduleParam struct {
 	DiskCategory string
 	PodId        string
+	// StorageType is 3par when empty
+	StorageType string
+	// Size of the disk in GiB, the array must fit it below its reserve
+	Size int64
 }
 
 type ScheduleResult struct {
@@ -60,8 +60,8 @@ var (
 	ErrSnapshotQuotaExceeded = BusinessLogicError{
 		HttpCode:           403,
 		ErrorCode:          "UniCloudStorage-SnapshotQuotaExceeded",
-		Message:            "Quota of snapshot per disk(64) exceeded",
-		DescriptionChinese: "一块硬盘最多创建64份快照",
+		Message:            "Quota of snapshot per disk exceeded",
+		DescriptionChinese: "硬盘快照数量超出配额",
 	}
 
 	ErrInvalidLengthOfSnapshotId = BusinessLogicError{
@@ -91,4 +91,18 @@ var (
 		Message:            "Snapshot does not belong to specified user",
 		DescriptionChinese: "快照不属于指定的用户",
 	}
+
+	ErrSnapshotDeletingBadStatus = BusinessLogicError{
+		HttpCode:           400,
+		ErrorCode:          "UniCloudStorage-SnapshotDeletingBadStatus",
+		Message:            "Only Available or Error snapshots can be deleted",
+		DescriptionChinese: "只能删除状态为可用或错误的快照",
+	}
+
+	ErrFieldExpireAtWrongValue = BusinessLogicError{
+		HttpCode:           400,
+		ErrorCode:          "UniCloudStorage-FieldExpireAtWrongValue",
+		Message:            "Invalid value for ExpireAt, it must be a future time in RFC3339",
+		DescriptionChinese: "字段 ExpireAt 错误，须为 RFC3339 格式的未来时间",
+	}
 )
@@ -59,6 +59,27 @@ var ErrFieldDurationWrongValue = BusinessLogicError{
 	DescriptionChinese: "字段 Duration 错误，请查阅文档。",
 }
 
+var ErrFieldHoursWrongValue = BusinessLogicError{
+	HttpCode:           400,
+	ErrorCode:          "UniCloudStorage-FieldHoursWrongValue",
+	Message:            "Invalid value for Hours",
+	DescriptionChinese: "字段 Hours 错误，请查阅文档。",
+}
+
+var ErrFieldWeeksWrongValue = BusinessLogicError{
+	HttpCode:           400,
+	ErrorCode:          "UniCloudStorage-FieldWeeksWrongValue",
+	Message:            "Invalid value for Weeks",
+	DescriptionChinese: "字段 Weeks 错误，请查阅文档。",
+}
+
+var ErrFieldSnapshotQuotaWrongValue = BusinessLogicError{
+	HttpCode:           400,
+	ErrorCode:          "UniCloudStorage-FieldSnapshotQuotaWrongValue",
+	Message:            "Invalid value for SnapshotQuota",
+	DescriptionChinese: "字段 SnapshotQuota 错误，请查阅文档。",
+}
+
 //conflict
 var ErrConflictStrategyId = BusinessLogicError{
 	HttpCode:           400,
@@ -20,20 +20,29 @@ type ThreePar struct {
 	UpdatedAt          time.Time    `json:"updated_at"`
 	DeletedAt          sql.NullTime `json:"deleted_at"`
 	Deleted            int          `json:"deleted"`
+	// Https is 1 when the WSAPI is reached over https, plain http otherwise
+	Https int `json:"https"`
+	// CaBundle holds the PEM certificates the WSAPI certificate is verified
+	// against, the system roots if empty
+	CaBundle string `json:"ca_bundle"`
+	// CertSha256 pins the SHA-256 fingerprint of the WSAPI certificate in hex
+	CertSha256 string `json:"cert_sha256"`
+	// Insecure is 1 when the WSAPI certificate is not verified at all
+	Insecure int `json:"insecure"`
 }
 
 func (t *ThreePar) Insert(db XODB) error {
 	var err error
 
 	const sqlStr = `INSERT INTO 3par (` +
-		`three_par_id, ipv4_addr_management, ipv4_addr_ssh, ipv4_addr_controller, username, password, launch_time, in_charge, contact, created_at, updated_at, deleted_at, deleted` +
+		`three_par_id, ipv4_addr_management, ipv4_addr_ssh, ipv4_addr_controller, username, password, launch_time, in_charge, contact, created_at, updated_at, deleted_at, deleted, https, ca_bundle, cert_sha256, insecure` +
 		`) VALUES (` +
-		`?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?` +
+		`?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?` +
 		`)`
 
 	// run query
-	XOLog(sqlStr, t.ThreeParId, t.Ipv4AddrManagement, t.Ipv4AddrSSH, t.Ipv4AddrController, t.Username, t.Password, t.LaunchTime, t.InCharge, t.Contact, t.CreatedAt, t.UpdatedAt, t.DeletedAt, t.Deleted)
-	_, err = db.Exec(sqlStr, t.ThreeParId, t.Ipv4AddrManagement, t.Ipv4AddrSSH, t.Ipv4AddrController, t.Username, t.Password, t.LaunchTime, t.InCharge, t.Contact, t.CreatedAt, t.UpdatedAt, t.DeletedAt, t.Deleted)
+	XOLog(sqlStr, t.ThreeParId, t.Ipv4AddrManagement, t.Ipv4AddrSSH, t.Ipv4AddrController, t.Username, t.Password, t.LaunchTime, t.InCharge, t.Contact, t.CreatedAt, t.UpdatedAt, t.DeletedAt, t.Deleted, t.Https, t.CaBundle, t.CertSha256, t.Insecure)
+	_, err = db.Exec(sqlStr, t.ThreeParId, t.Ipv4AddrManagement, t.Ipv4AddrSSH, t.Ipv4AddrController, t.Username, t.Password, t.LaunchTime, t.InCharge, t.Contact, t.CreatedAt, t.UpdatedAt, t.DeletedAt, t.Deleted, t.Https, t.CaBundle, t.CertSha256, t.Insecure)
 	if err != nil {
 		return err
 	}
@@ -45,13 +54,14 @@ func (t *ThreePar) Update(db XODB) error {
 	var err error
 
 	const sqlStr = `UPDATE 3par SET ipv4_addr_management = ?, ipv4_addr_ssh = ?, ipv4_addr_controller = ?, username = ?, password = ?, ` +
-		`launch_time = ?, in_charge = ?, contact = ?, created_at = ?, updated_at = ?, deleted_at = ?, deleted = ? WHERE three_par_id = ?`
+		`launch_time = ?, in_charge = ?, contact = ?, created_at = ?, updated_at = ?, deleted_at = ?, deleted = ?, ` +
+		`https = ?, ca_bundle = ?, cert_sha256 = ?, insecure = ? WHERE three_par_id = ?`
 
 	// run query
 	XOLog(sqlStr, t.Ipv4AddrManagement, t.Ipv4AddrSSH, t.Ipv4AddrController, t.Username, t.Password,
-		t.LaunchTime, t.InCharge, t.Contact, t.CreatedAt, t.UpdatedAt, t.DeletedAt, t.Deleted, t.ThreeParId)
+		t.LaunchTime, t.InCharge, t.Contact, t.CreatedAt, t.UpdatedAt, t.DeletedAt, t.Deleted, t.Https, t.CaBundle, t.CertSha256, t.Insecure, t.ThreeParId)
 	_, err = db.Exec(sqlStr, t.Ipv4AddrManagement, t.Ipv4AddrSSH, t.Ipv4AddrController, t.Username, t.Password,
-		t.LaunchTime, t.InCharge, t.Contact, t.CreatedAt, t.UpdatedAt, t.DeletedAt, t.Deleted, t.ThreeParId)
+		t.LaunchTime, t.InCharge, t.Contact, t.CreatedAt, t.UpdatedAt, t.DeletedAt, t.Deleted, t.Https, t.CaBundle, t.CertSha256, t.Insecure, t.ThreeParId)
 	if err != nil {
 		return err
 	}
@@ -80,7 +90,8 @@ func ThreePars(db XODB) ([]*ThreePar, error) {
 
 	// sql query
 	const sqlStr = `SELECT ` +
-		`three_par_id, ipv4_addr_management, ipv4_addr_ssh, ipv4_addr_controller, username, password, launch_time, in_charge, contact, created_at, updated_at, deleted_at, deleted ` +
+		`three_par_id, ipv4_addr_management, ipv4_addr_ssh, ipv4_addr_controller, username, password, launch_time, in_charge, contact, created_at, updated_at, deleted_at, deleted, ` +
+		`https, ca_bundle, cert_sha256, insecure ` +
 		`FROM 3par WHERE deleted = 0 `
 
 	// run query
@@ -98,7 +109,8 @@ func ThreePars(db XODB) ([]*ThreePar, error) {
 
 		// scan
 		err = q.Scan(&d.ThreeParId, &d.Ipv4AddrManagement, &d.Ipv4AddrSSH, &d.Ipv4AddrController,
-			&d.Username, &d.Password, &d.LaunchTime, &d.InCharge, &d.Contact, &d.CreatedAt, &d.UpdatedAt, &d.DeletedAt, &d.Deleted)
+			&d.Username, &d.Password, &d.LaunchTime, &d.InCharge, &d.Contact, &d.CreatedAt, &d.UpdatedAt, &d.DeletedAt, &d.Deleted,
+			&d.Https, &d.CaBundle, &d.CertSha256, &d.Insecure)
 		if err != nil {
 			return nil, err
 		}
@@ -114,7 +126,8 @@ func ThreeParByThreeParID(db XODB, threeParId string) (*ThreePar, error) {
 
 	// sql query
 	const sqlStr = `SELECT ` +
-		`three_par_id, ipv4_addr_management, ipv4_addr_ssh, ipv4_addr_controller, username, password, launch_time, in_charge, contact, created_at, updated_at, deleted_at, deleted ` +
+		`three_par_id, ipv4_addr_management, ipv4_addr_ssh, ipv4_addr_controller, username, password, launch_time, in_charge, contact, created_at, updated_at, deleted_at, deleted, ` +
+		`https, ca_bundle, cert_sha256, insecure ` +
 		`FROM 3par ` +
 		`WHERE three_par_id = ? AND deleted = 0`
 
@@ -123,7 +136,8 @@ func ThreeParByThreeParID(db XODB, threeParId string) (*ThreePar, error) {
 	d := ThreePar{}
 
 	err = db.QueryRow(sqlStr, threeParId).Scan(&d.ThreeParId, &d.Ipv4AddrManagement, &d.Ipv4AddrSSH, &d.Ipv4AddrController,
-		&d.Username, &d.Password, &d.LaunchTime, &d.InCharge, &d.Contact, &d.CreatedAt, &d.UpdatedAt, &d.DeletedAt, &d.Deleted)
+		&d.Username, &d.Password, &d.LaunchTime, &d.InCharge, &d.Contact, &d.CreatedAt, &d.UpdatedAt, &d.DeletedAt, &d.Deleted,
+		&d.Https, &d.CaBundle, &d.CertSha256, &d.Insecure)
 	if err != nil {
 		return nil, err
 	}
@@ -2,6 +2,7 @@
 package db_model
 
 import (
+	"database/sql"
 	"time"
 )
 
@@ -17,6 +18,31 @@ type ThreeParInfo struct {
 	UpdateAt           time.Time `json:"update_at"`
 	PodId              string    `json:"pod_id"`
 	Reserve            int       `json:"reserve"`
+	// StorageType is 3par or ceph, for ceph clusters ThreeParId holds the fsid
+	StorageType string `json:"storage_type"`
+	// Category is the only disk category a ceph cluster serves, empty for
+	// 3PAR arrays which serve them all
+	Category string `json:"category"`
+	// Stale is set when the last poll of the array failed
+	Stale    bool         `json:"stale"`
+	PolledAt sql.NullTime `json:"polled_at"`
+}
+
+// Insert inserts the ThreeParInfo to the database.
+func (c *ThreeParInfo) Insert(db XODB) error {
+	var err error
+
+	// sql insert query
+	const sqlstr = `INSERT INTO 3par_info (` +
+		`three_par_id, hdd_usage, hdd_total, hdd_usage_percent, ssd_usage, ssd_total, ssd_usage_percent, hybrid_usage_percent, update_at, pod_id, reserve, storage_type, category, stale, polled_at` +
+		`) VALUES (` +
+		`?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?` +
+		`)`
+
+	// run query
+	XOLog(sqlstr, c.ThreeParId, c.HDDUsage, c.HDDTotal, c.HDDUsagePercent, c.SSDUsage, c.SSDTotal, c.SSDUsagePercent, c.HybridUsagePercent, c.UpdateAt, c.PodId, c.Reserve, c.StorageType, c.Category, c.Stale, c.PolledAt)
+	_, err = db.Exec(sqlstr, c.ThreeParId, c.HDDUsage, c.HDDTotal, c.HDDUsagePercent, c.SSDUsage, c.SSDTotal, c.SSDUsagePercent, c.HybridUsagePercent, c.UpdateAt, c.PodId, c.Reserve, c.StorageType, c.Category, c.Stale, c.PolledAt)
+	return err
 }
 
 // Update updates the Disk in the database.
@@ -25,12 +51,13 @@ func (c *ThreeParInfo) Update(db XODB) error {
 
 	// sql query
 	const sqlstr = `UPDATE 3par_info SET ` +
-		`hdd_usage = ?, hdd_total = ?, hdd_usage_percent = ?, ssd_usage = ?, ssd_total = ?, ssd_usage_percent = ?, hybrid_usage_percent = ?, update_at = ?` +
+		`hdd_usage = ?, hdd_total = ?, hdd_usage_percent = ?, ssd_usage = ?, ssd_total = ?, ssd_usage_percent = ?, hybrid_usage_percent = ?, update_at = ?, ` +
+		`storage_type = ?, category = ?, stale = ?, polled_at = ?` +
 		` WHERE three_par_id = ?`
 
 	// run query
-	XOLog(sqlstr, c.HDDUsage, c.HDDTotal, c.HDDUsagePercent, c.SSDUsage, c.SSDTotal, c.SSDUsagePercent, c.HybridUsagePercent, c.UpdateAt, c.ThreeParId)
-	_, err = db.Exec(sqlstr, c.HDDUsage, c.HDDTotal, c.HDDUsagePercent, c.SSDUsage, c.SSDTotal, c.SSDUsagePercent, c.HybridUsagePercent, c.UpdateAt, c.ThreeParId)
+	XOLog(sqlstr, c.HDDUsage, c.HDDTotal, c.HDDUsagePercent, c.SSDUsage, c.SSDTotal, c.SSDUsagePercent, c.HybridUsagePercent, c.UpdateAt, c.StorageType, c.Category, c.Stale, c.PolledAt, c.ThreeParId)
+	_, err = db.Exec(sqlstr, c.HDDUsage, c.HDDTotal, c.HDDUsagePercent, c.SSDUsage, c.SSDTotal, c.SSDUsagePercent, c.HybridUsagePercent, c.UpdateAt, c.StorageType, c.Category, c.Stale, c.PolledAt, c.ThreeParId)
 	return err
 }
 
@@ -63,3 +90,46 @@ func GetThreeParInfos(db XODB) ([]*ThreeParInfo, error) {
 
 	return res, nil
 }
+
+// ThreeParInfos returns the capacity records of all arrays
+func ThreeParInfos(db XODB) ([]*ThreeParInfo, error) {
+	var err error
+
+	// sql query
+	const sqlstr = `SELECT ` +
+		`three_par_id, hdd_usage, hdd_total, hdd_usage_percent, ssd_usage, ssd_total, ssd_usage_percent, hybrid_usage_percent, update_at, pod_id, reserve, storage_type, category, stale, polled_at ` +
+		`FROM 3par_info`
+
+	// run query
+	XOLog(sqlstr)
+	q, err := db.Query(sqlstr)
+	if err != nil {
+		return nil, err
+	}
+	defer q.Close()
+
+	// load results
+	var res []*ThreeParInfo
+	for q.Next() {
+		info := ThreeParInfo{}
+		// scan
+		err = q.Scan(&info.ThreeParId, &info.HDDUsage, &info.HDDTotal, &info.HDDUsagePercent, &info.SSDUsage, &info.SSDTotal, &info.SSDUsagePercent, &info.HybridUsagePercent, &info.UpdateAt, &info.PodId, &info.Reserve, &info.StorageType, &info.Category, &info.Stale, &info.PolledAt)
+		if err != nil {
+			return nil, err
+		}
+
+		res = append(res, &info)
+	}
+
+	return res, q.Err()
+}
+
+// MarkThreeParInfoStale flags the capacity record of the array as out of date
+func MarkThreeParInfoStale(db XODB, threeParId string) error {
+	const sqlstr = `UPDATE 3par_info SET stale = 1, update_at = ? WHERE three_par_id = ?`
+
+	now := time.Now()
+	XOLog(sqlstr, now, threeParId)
+	_, err := db.Exec(sqlstr, now, threeParId)
+	return err
+}
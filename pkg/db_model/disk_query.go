@@ -0,0 +1,187 @@
+package db_model
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DiskFilter describes the conditions of a disk list query. Zero values are
+// ignored. Marker is the id of the last disk of the previous page.
+type DiskFilter struct {
+	UserID      string
+	Status      int8
+	Category    int8
+	StorageType string
+	ClusterID   string
+	Marker      int
+	Limit       int
+}
+
+// DisksByFilter retrieves a page of disks ordered by id.
+func DisksByFilter(db XODB, f DiskFilter) ([]*Disk, error) {
+	conds := []string{"deleted = 0", "id > ?"}
+	args := []interface{}{f.Marker}
+	if f.UserID != "" {
+		conds = append(conds, "user_id = ?")
+		args = append(args, f.UserID)
+	}
+	if f.Status != 0 {
+		conds = append(conds, "status = ?")
+		args = append(args, f.Status)
+	}
+	if f.Category != 0 {
+		conds = append(conds, "category = ?")
+		args = append(args, f.Category)
+	}
+	if f.StorageType != "" {
+		conds = append(conds, "storage_type = ?")
+		args = append(args, f.StorageType)
+	}
+	if f.ClusterID != "" {
+		conds = append(conds, "cluster_id = ?")
+		args = append(args, f.ClusterID)
+	}
+	args = append(args, f.Limit)
+
+	sqlStr := `SELECT ` +
+		`id, disk_id, status_orig, status, disk_type, name, region, zone, category, size, description, from_snapshot, user_id, cluster_id, ` +
+		`from_image, storage_type, is_share, qos, three_par_wwn, three_par_status, created_at, updated_at, deleted_at, deleted ` +
+		`FROM disk WHERE ` + strings.Join(conds, " AND ") + ` ORDER BY id LIMIT ?`
+
+	// run query
+	XOLog(sqlStr, args...)
+	rows, err := db.Query(sqlStr, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var disks []*Disk
+	for rows.Next() {
+		d := &Disk{
+			_exists: true,
+		}
+
+		err = rows.Scan(&d.Id, &d.DiskID, &d.StatusOrig, &d.Status, &d.DiskType, &d.Name, &d.Region, &d.Zone, &d.Category,
+			&d.Size, &d.Description, &d.FromSnapshot, &d.UserID, &d.ClusterID, &d.FromImage,
+			&d.StorageType, &d.IsShare, &d.Qos, &d.ThreeParWWN, &d.ThreeParStatus,
+			&d.CreatedAt, &d.UpdatedAt, &d.DeletedAt, &d.Deleted)
+		if err != nil {
+			return nil, err
+		}
+		disks = append(disks, d)
+	}
+
+	return disks, rows.Err()
+}
+
+// AttachesByDiskIDs retrieves the live attachments of the disks, keyed by disk id.
+func AttachesByDiskIDs(db XODB, diskIDs []string) (map[string]*Attach, error) {
+	attaches := make(map[string]*Attach)
+	if len(diskIDs) == 0 {
+		return attaches, nil
+	}
+
+	sqlStr := fmt.Sprintf(`SELECT `+
+		`id, disk_id, instance_id, cvk_name, cvk_name_orig, attach_status, created_at, updated_at, deleted_at, is_deleted `+
+		`FROM attach `+
+		`WHERE is_deleted = 0 AND disk_id in (%s)`, placeholders(len(diskIDs)))
+	args := stringArgs(diskIDs)
+
+	// run query
+	XOLog(sqlStr, args...)
+	rows, err := db.Query(sqlStr, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		d := &Attach{
+			_exists: true,
+		}
+		err = rows.Scan(&d.Id, &d.DiskId, &d.InstanceId, &d.CvkName, &d.CvkNameOrig, &d.AttachStatus, &d.CreateAt, &d.UpdateAt, &d.DeleteAt, &d.IsDeleted)
+		if err != nil {
+			return nil, err
+		}
+		attaches[d.DiskId] = d
+	}
+
+	return attaches, rows.Err()
+}
+
+// ExportsByDiskIDs retrieves the live exports of the disks, keyed by disk id.
+func ExportsByDiskIDs(db XODB, diskIDs []string) (map[string][]*Export, error) {
+	exports := make(map[string][]*Export)
+	if len(diskIDs) == 0 {
+		return exports, nil
+	}
+
+	sqlStr := fmt.Sprintf(`SELECT `+
+		`id, disk_id, cvk_name, iqn, cvk_lun, target, status, created_at, updated_at, deleted_at, is_deleted `+
+		`FROM export `+
+		`WHERE is_deleted = 0 AND disk_id in (%s)`, placeholders(len(diskIDs)))
+	args := stringArgs(diskIDs)
+
+	// run query
+	XOLog(sqlStr, args...)
+	rows, err := db.Query(sqlStr, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		d := &Export{
+			_exists: true,
+		}
+		err = rows.Scan(&d.Id, &d.DiskId, &d.CvkName, &d.Iqn, &d.CvkLun, &d.Target, &d.Status, &d.CreateAt, &d.UpdateAt, &d.DeleteAt, &d.IsDeleted)
+		if err != nil {
+			return nil, err
+		}
+		exports[d.DiskId] = append(exports[d.DiskId], d)
+	}
+
+	return exports, rows.Err()
+}
+
+// CountDisksByCluster counts the disks in the given status per cluster.
+func CountDisksByCluster(db XODB, status int8) (map[string]int, error) {
+	const sqlStr = `SELECT cluster_id, COUNT(*) FROM disk WHERE deleted = 0 AND status = ? GROUP BY cluster_id`
+
+	// run query
+	XOLog(sqlStr, status)
+	rows, err := db.Query(sqlStr, status)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := make(map[string]int)
+	for rows.Next() {
+		var clusterID string
+		var n int
+		if err = rows.Scan(&clusterID, &n); err != nil {
+			return nil, err
+		}
+		counts[clusterID] = n
+	}
+
+	return counts, rows.Err()
+}
+
+func placeholders(n int) string {
+	ps := make([]string, n)
+	for i := 0; i < n; i++ {
+		ps[i] = "?"
+	}
+	return strings.Join(ps, ",")
+}
+
+func stringArgs(s []string) []interface{} {
+	args := make([]interface{}, len(s))
+	for i, v := range s {
+		args[i] = v
+	}
+	return args
+}
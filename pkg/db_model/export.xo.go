@@ -13,6 +13,7 @@ type Export struct {
 	CvkName   string       `json:"cvk_name"`
 	Iqn       string       `json:"iqn"`
 	CvkLun    int          `json:"cvk_lun"`
+	Target    string       `json:"target"` // how the CVK reaches a disk without a WWN, e.g. the rbd map descriptor
 	Status    int          `json:"status"`
 	CreateAt  time.Time    `json:"created_at"`
 	UpdateAt  time.Time    `json:"updated_at"`
@@ -52,14 +53,14 @@ func (d *Export) Insert(db XODB) error {
 
 	// sql insert query
 	const sqlStr = `INSERT INTO export (` +
-		`disk_id, cvk_name, iqn, cvk_lun, status, created_at, updated_at, deleted_at, is_deleted` +
+		`disk_id, cvk_name, iqn, cvk_lun, target, status, created_at, updated_at, deleted_at, is_deleted` +
 		`) VALUES (` +
-		`?, ?, ?, ?, ?, ?, ?, ?, ?` +
+		`?, ?, ?, ?, ?, ?, ?, ?, ?, ?` +
 		`)`
 
 	// run query
-	XOLog(sqlStr, d.DiskId, d.CvkName, d.Iqn, d.CvkLun, d.Status, d.CreateAt, d.UpdateAt, d.DeleteAt, d.IsDeleted)
-	_, err = db.Exec(sqlStr, d.DiskId, d.CvkName, d.Iqn, d.CvkLun, d.Status, d.CreateAt, d.UpdateAt, d.DeleteAt, d.IsDeleted)
+	XOLog(sqlStr, d.DiskId, d.CvkName, d.Iqn, d.CvkLun, d.Target, d.Status, d.CreateAt, d.UpdateAt, d.DeleteAt, d.IsDeleted)
+	_, err = db.Exec(sqlStr, d.DiskId, d.CvkName, d.Iqn, d.CvkLun, d.Target, d.Status, d.CreateAt, d.UpdateAt, d.DeleteAt, d.IsDeleted)
 	if err != nil {
 		return err
 	}
@@ -86,12 +87,12 @@ func (d *Export) Update(db XODB) error {
 
 	// sql query
 	const sqlStr = `UPDATE export SET ` +
-		`disk_id = ?, cvk_name = ?, iqn = ?, cvk_lun = ?, status = ?, created_at = ?, updated_at = ?, deleted_at = ?, is_deleted = ? ` +
+		`disk_id = ?, cvk_name = ?, iqn = ?, cvk_lun = ?, target = ?, status = ?, created_at = ?, updated_at = ?, deleted_at = ?, is_deleted = ? ` +
 		`WHERE id = ?`
 
 	// run query
-	XOLog(sqlStr, d.DiskId, d.CvkName, d.Iqn, d.CvkLun, d.Status, d.CreateAt, d.UpdateAt, d.DeleteAt, d.IsDeleted, d.Id)
-	_, err = db.Exec(sqlStr, d.DiskId, d.CvkName, d.Iqn, d.CvkLun, d.Status, d.CreateAt, d.UpdateAt, d.DeleteAt, d.IsDeleted, d.Id)
+	XOLog(sqlStr, d.DiskId, d.CvkName, d.Iqn, d.CvkLun, d.Target, d.Status, d.CreateAt, d.UpdateAt, d.DeleteAt, d.IsDeleted, d.Id)
+	_, err = db.Exec(sqlStr, d.DiskId, d.CvkName, d.Iqn, d.CvkLun, d.Target, d.Status, d.CreateAt, d.UpdateAt, d.DeleteAt, d.IsDeleted, d.Id)
 	return err
 }
 
@@ -130,7 +131,7 @@ func GetExport(db XODB, DiskId, CvkName string) (*Export, error) {
 
 	// sql query
 	const sqlStr = `SELECT ` +
-		`id, disk_id, cvk_name, iqn, cvk_lun, status, created_at, updated_at, deleted_at, is_deleted ` +
+		`id, disk_id, cvk_name, iqn, cvk_lun, target, status, created_at, updated_at, deleted_at, is_deleted ` +
 		`FROM export ` +
 		`WHERE is_deleted = 0 AND disk_id = ? AND cvk_name = ?`
 
@@ -141,7 +142,7 @@ func GetExport(db XODB, DiskId, CvkName string) (*Export, error) {
 	}
 
 	err = db.QueryRow(sqlStr, DiskId, CvkName).
-		Scan(&d.Id, &d.DiskId, &d.CvkName, &d.Iqn, &d.CvkLun, &d.Status, &d.CreateAt, &d.UpdateAt, &d.DeleteAt, &d.IsDeleted)
+		Scan(&d.Id, &d.DiskId, &d.CvkName, &d.Iqn, &d.CvkLun, &d.Target, &d.Status, &d.CreateAt, &d.UpdateAt, &d.DeleteAt, &d.IsDeleted)
 	if err != nil {
 		return nil, err
 	}
@@ -155,7 +156,7 @@ func GetExport3(db XODB, DiskId, CvkName string) (*Export, error) {
 
 	// sql query
 	const sqlStr = `SELECT ` +
-		`id, disk_id, cvk_name, iqn, cvk_lun, status, created_at, updated_at, deleted_at, is_deleted ` +
+		`id, disk_id, cvk_name, iqn, cvk_lun, target, status, created_at, updated_at, deleted_at, is_deleted ` +
 		`FROM export ` +
 		`WHERE is_deleted = 0 AND cvk_lun != 254 AND disk_id = ? AND cvk_name = ?`
 
@@ -166,7 +167,7 @@ func GetExport3(db XODB, DiskId, CvkName string) (*Export, error) {
 	}
 
 	err = db.QueryRow(sqlStr, DiskId, CvkName).
-		Scan(&d.Id, &d.DiskId, &d.CvkName, &d.Iqn, &d.CvkLun, &d.Status, &d.CreateAt, &d.UpdateAt, &d.DeleteAt, &d.IsDeleted)
+		Scan(&d.Id, &d.DiskId, &d.CvkName, &d.Iqn, &d.CvkLun, &d.Target, &d.Status, &d.CreateAt, &d.UpdateAt, &d.DeleteAt, &d.IsDeleted)
 	if err != nil {
 		return nil, err
 	}
@@ -179,7 +180,7 @@ func GetExport4(db XODB, lun int, DiskId, CvkName string) (*Export, error) {
 
 	// sql query
 	const sqlStr = `SELECT ` +
-		`id, disk_id, cvk_name, iqn, cvk_lun, status, created_at, updated_at, deleted_at, is_deleted ` +
+		`id, disk_id, cvk_name, iqn, cvk_lun, target, status, created_at, updated_at, deleted_at, is_deleted ` +
 		`FROM export ` +
 		`WHERE is_deleted = 0 AND disk_id = ? AND cvk_name = ? AND cvk_lun = ?`
 
@@ -190,7 +191,7 @@ func GetExport4(db XODB, lun int, DiskId, CvkName string) (*Export, error) {
 	}
 
 	err = db.QueryRow(sqlStr, DiskId, CvkName, lun).
-		Scan(&d.Id, &d.DiskId, &d.CvkName, &d.Iqn, &d.CvkLun, &d.Status, &d.CreateAt, &d.UpdateAt, &d.DeleteAt, &d.IsDeleted)
+		Scan(&d.Id, &d.DiskId, &d.CvkName, &d.Iqn, &d.CvkLun, &d.Target, &d.Status, &d.CreateAt, &d.UpdateAt, &d.DeleteAt, &d.IsDeleted)
 	if err != nil {
 		return nil, err
 	}
@@ -203,7 +204,7 @@ func GetExports(db XODB, diskID string) ([]*Export, error) {
 
 	// sql query
 	const sqlStr = `SELECT ` +
-		`id, disk_id, cvk_name, iqn, cvk_lun, status, created_at, updated_at, deleted_at, is_deleted ` +
+		`id, disk_id, cvk_name, iqn, cvk_lun, target, status, created_at, updated_at, deleted_at, is_deleted ` +
 		`FROM export ` +
 		`WHERE is_deleted = 0 AND disk_id = ?`
 
@@ -223,7 +224,7 @@ func GetExports(db XODB, diskID string) ([]*Export, error) {
 		}
 
 		// scan
-		err = q.Scan(&d.Id, &d.DiskId, &d.CvkName, &d.Iqn, &d.CvkLun, &d.Status, &d.CreateAt, &d.UpdateAt, &d.DeleteAt, &d.IsDeleted)
+		err = q.Scan(&d.Id, &d.DiskId, &d.CvkName, &d.Iqn, &d.CvkLun, &d.Target, &d.Status, &d.CreateAt, &d.UpdateAt, &d.DeleteAt, &d.IsDeleted)
 		if err != nil {
 			return nil, err
 		}
@@ -234,3 +235,40 @@ func GetExports(db XODB, diskID string) ([]*Export, error) {
 	return res, nil
 
 }
+
+// ExportsByCvkNameForUpdate locks and returns the live exports of a CVK
+func ExportsByCvkNameForUpdate(db XODB, cvkName string) ([]*Export, error) {
+	var err error
+
+	// sql query
+	const sqlStr = `SELECT ` +
+		`id, disk_id, cvk_name, iqn, cvk_lun, target, status, created_at, updated_at, deleted_at, is_deleted ` +
+		`FROM export ` +
+		`WHERE is_deleted = 0 AND cvk_name = ? FOR UPDATE`
+
+	// run query
+	XOLog(sqlStr, cvkName)
+	q, err := db.Query(sqlStr, cvkName)
+	if err != nil {
+		return nil, err
+	}
+	defer q.Close()
+
+	// load results
+	res := []*Export{}
+	for q.Next() {
+		d := Export{
+			_exists: true,
+		}
+
+		// scan
+		err = q.Scan(&d.Id, &d.DiskId, &d.CvkName, &d.Iqn, &d.CvkLun, &d.Target, &d.Status, &d.CreateAt, &d.UpdateAt, &d.DeleteAt, &d.IsDeleted)
+		if err != nil {
+			return nil, err
+		}
+
+		res = append(res, &d)
+	}
+
+	return res, q.Err()
+}
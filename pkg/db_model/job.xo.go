@@ -0,0 +1,174 @@
+// Package db_model contains the types for schema 'immortality'.
+package db_model
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// Job represents a row from 'immortality.job', one per enqueued action.
+type Job struct {
+	Id         int          `json:"id"`
+	RequestID  string       `json:"request_id"` // request_id
+	UserID     string       `json:"user_id"`    // user_id
+	Action     string       `json:"action"`     // action
+	Payload    string       `json:"payload"`    // payload
+	State      int8         `json:"state"`      // state
+	Attempts   int          `json:"attempts"`   // attempts
+	LastError  string       `json:"last_error"` // last_error
+	CreatedAt  time.Time    `json:"created_at"`
+	UpdatedAt  time.Time    `json:"updated_at"`
+	StartedAt  sql.NullTime `json:"started_at"`
+	FinishedAt sql.NullTime `json:"finished_at"`
+
+	// xo fields
+	_exists bool
+}
+
+// Exists determines if the Job exists in the database.
+func (j *Job) Exists() bool {
+	return j._exists
+}
+
+// Save saves the Job to the database.
+func (j *Job) Save(db XODB) error {
+	if j.Exists() {
+		return j.Update(db)
+	}
+	return j.Insert(db)
+}
+
+// Insert inserts the Job to the database.
+func (j *Job) Insert(db XODB) error {
+	var err error
+
+	// if already exist, bail
+	if j._exists {
+		return errors.New("insert failed: already exists")
+	}
+
+	// sql insert query
+	const sqlStr = `INSERT INTO job (` +
+		`request_id, user_id, action, payload, state, attempts, last_error, created_at, updated_at, started_at, finished_at` +
+		`) VALUES (` +
+		`?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?` +
+		`)`
+
+	// run query
+	XOLog(sqlStr, j.RequestID, j.UserID, j.Action, j.Payload, j.State, j.Attempts, j.LastError, j.CreatedAt, j.UpdatedAt, j.StartedAt, j.FinishedAt)
+	res, err := db.Exec(sqlStr, j.RequestID, j.UserID, j.Action, j.Payload, j.State, j.Attempts, j.LastError, j.CreatedAt, j.UpdatedAt, j.StartedAt, j.FinishedAt)
+	if err != nil {
+		return err
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+
+	// set primary key and existence
+	j.Id = int(id)
+	j._exists = true
+
+	return nil
+}
+
+// Update updates the Job in the database.
+func (j *Job) Update(db XODB) error {
+	var err error
+
+	// if doesn't exist, bail
+	if !j._exists {
+		return errors.New("update failed: does not exist")
+	}
+
+	// sql query
+	const sqlStr = `UPDATE job SET ` +
+		`request_id = ?, user_id = ?, action = ?, payload = ?, state = ?, attempts = ?, last_error = ?, ` +
+		`created_at = ?, updated_at = ?, started_at = ?, finished_at = ? ` +
+		`WHERE id = ?`
+
+	// run query
+	XOLog(sqlStr, j.RequestID, j.UserID, j.Action, j.Payload, j.State, j.Attempts, j.LastError, j.CreatedAt, j.UpdatedAt, j.StartedAt, j.FinishedAt, j.Id)
+	_, err = db.Exec(sqlStr, j.RequestID, j.UserID, j.Action, j.Payload, j.State, j.Attempts, j.LastError, j.CreatedAt, j.UpdatedAt, j.StartedAt, j.FinishedAt, j.Id)
+	return err
+}
+
+const jobColumns = `id, request_id, user_id, action, payload, state, attempts, last_error, created_at, updated_at, started_at, finished_at `
+
+func scanJob(row interface{ Scan(...interface{}) error }) (*Job, error) {
+	j := Job{
+		_exists: true,
+	}
+	err := row.Scan(&j.Id, &j.RequestID, &j.UserID, &j.Action, &j.Payload, &j.State, &j.Attempts, &j.LastError,
+		&j.CreatedAt, &j.UpdatedAt, &j.StartedAt, &j.FinishedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &j, nil
+}
+
+// JobByRequestID retrieves a row from 'immortality.job' as a Job.
+func JobByRequestID(db XODB, requestID string) (*Job, error) {
+	// sql query
+	const sqlStr = `SELECT ` + jobColumns +
+		`FROM job WHERE request_id = ?`
+
+	// run query
+	XOLog(sqlStr, requestID)
+	return scanJob(db.QueryRow(sqlStr, requestID))
+}
+
+// JobsByState retrieves the latest jobs of the user in the state, 0 means any state.
+func JobsByState(db XODB, userID string, state int8, limit int) ([]*Job, error) {
+	sqlStr := `SELECT ` + jobColumns + `FROM job WHERE user_id = ?`
+	args := []interface{}{userID}
+	if state != 0 {
+		sqlStr += ` AND state = ?`
+		args = append(args, state)
+	}
+	sqlStr += ` ORDER BY id DESC LIMIT ?`
+	args = append(args, limit)
+
+	// run query
+	XOLog(sqlStr, args...)
+	rows, err := db.Query(sqlStr, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var jobs []*Job
+	for rows.Next() {
+		j, err := scanJob(rows)
+		if err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, j)
+	}
+	return jobs, rows.Err()
+}
+
+// MarkJobRunning counts a new attempt of the job.
+func MarkJobRunning(db XODB, requestID string) (err error) {
+	now := time.Now()
+	XOLog("UPDATE job SET updated_at = ?, started_at = ?, state = 2, attempts = attempts + 1 WHERE request_id = ?", now, now, requestID)
+	_, err = db.Exec("UPDATE job SET updated_at = ?, started_at = ?, state = 2, attempts = attempts + 1 WHERE request_id = ?", now, now, requestID)
+	return err
+}
+
+// MarkJobFinished records the final state of the job and the error of the last attempt.
+func MarkJobFinished(db XODB, requestID string, state int8, lastError string) (err error) {
+	now := time.Now()
+	XOLog("UPDATE job SET updated_at = ?, finished_at = ?, state = ?, last_error = ? WHERE request_id = ?", now, now, state, lastError, requestID)
+	_, err = db.Exec("UPDATE job SET updated_at = ?, finished_at = ?, state = ?, last_error = ? WHERE request_id = ?", now, now, state, lastError, requestID)
+	return err
+}
+
+// MarkJobRetrying puts the job back to pending with the error of the failed attempt.
+func MarkJobRetrying(db XODB, requestID string, lastError string) (err error) {
+	XOLog("UPDATE job SET updated_at = ?, state = 1, last_error = ? WHERE request_id = ?", time.Now(), lastError, requestID)
+	_, err = db.Exec("UPDATE job SET updated_at = ?, state = 1, last_error = ? WHERE request_id = ?", time.Now(), lastError, requestID)
+	return err
+}
@@ -1,6 +1,9 @@
 package db_model
 
-import "time"
+import (
+	"database/sql"
+	"time"
+)
 
 type DiskWithUser struct {
 	Disk
@@ -29,6 +32,19 @@ func MarkDiskStatus(db XODB, status int8, diskId string) (err error) {
 	return err
 }
 
+// MarkDiskStatusFrom moves the disk to status only if it is still in from,
+// the disk may have been changed since the caller read it
+func MarkDiskStatusFrom(db XODB, status, from int8, diskId string) (err error) {
+	_, err = db.Exec("UPDATE disk SET updated_at = ?, status = ? WHERE disk_id = ? AND status = ? AND deleted = 0", time.Now(), status, diskId, from)
+	return err
+}
+
+// RestoreDiskStatusFrom gives the disk its status_orig back if it is still in from
+func RestoreDiskStatusFrom(db XODB, from int8, diskId string) (err error) {
+	_, err = db.Exec("UPDATE disk SET updated_at = ?, status = status_orig WHERE disk_id = ? AND status = ? AND deleted = 0", time.Now(), diskId, from)
+	return err
+}
+
 func MarkDiskResized(db XODB, status int8, size int64, diskId string) (err error) {
 	XOLog("UPDATE disk SET updated_at = ?, status = ?, size = ? WHERE disk_id = ? AND deleted = 0", time.Now(), status, size, diskId)
 	_, err = db.Exec("UPDATE disk SET updated_at = ?, status = ?, size = ? WHERE disk_id = ? AND deleted = 0", time.Now(), status, size, diskId)
@@ -67,6 +83,11 @@ func MarkSnapshotAvailable(db XODB, snapshotId string) (err error) {
 	return err
 }
 
+func MarkSnapshotError(db XODB, snapshotId string) (err error) {
+	_, err = db.Exec("UPDATE snapshot SET updated_at = ?, status = 4 WHERE snapshot_id = ? AND deleted = 0", time.Now(), snapshotId)
+	return err
+}
+
 func MarkImageAvailable(db XODB, imageId string) (err error) {
 	_, err = db.Exec("UPDATE image SET updated_at = ?, status = 2 WHERE image_id = ? AND deleted = 0", time.Now(), imageId)
 	return err
@@ -85,6 +106,19 @@ func UpdateExportIsDeleted(db XODB, diskId, cvkName string, updateTime, deleteTi
 	return err
 }
 
+func MarkExportStatus(db XODB, status int, diskId, cvkName string) (err error) {
+	_, err = db.Exec("UPDATE export SET updated_at = ?, status = ? WHERE is_deleted = 0 AND cvk_lun != 254 AND disk_id = ? AND cvk_name = ?",
+		time.Now(), status, diskId, cvkName)
+	return err
+}
+
+// MarkExportStatusFrom moves the export to status only if it is still in from
+func MarkExportStatusFrom(db XODB, status, from int, diskId, cvkName string) (err error) {
+	_, err = db.Exec("UPDATE export SET updated_at = ?, status = ? WHERE is_deleted = 0 AND status = ? AND disk_id = ? AND cvk_name = ?",
+		time.Now(), status, from, diskId, cvkName)
+	return err
+}
+
 func UpdateAttachIsDeleted(db XODB, diskId, instanceId string, updateTime, deleteTime time.Time) (err error) {
 	XOLog("UPDATE attach SET is_deleted = ?, updated_at = ?, deleted_at = ?"+
 		" WHERE is_deleted = 0 AND disk_id = ? AND instance_id = ?", 1, updateTime, deleteTime, diskId, instanceId)
@@ -100,3 +134,77 @@ func UpdateAttachIsDeleted2(db XODB, diskId string, updateTime, deleteTime time.
 		" WHERE is_deleted = 0 AND disk_id = ?", 1, updateTime, deleteTime, diskId)
 	return err
 }
+
+func MarkSnapshotDeleting(db XODB, snapshotId string) (err error) {
+	_, err = db.Exec("UPDATE snapshot SET updated_at = ?, status = 3 WHERE snapshot_id = ? AND deleted = 0", time.Now(), snapshotId)
+	return err
+}
+
+func MarkSnapshotStatus(db XODB, status int8, snapshotId string) (err error) {
+	_, err = db.Exec("UPDATE snapshot SET updated_at = ?, status = ? WHERE snapshot_id = ? AND deleted = 0", time.Now(), status, snapshotId)
+	return err
+}
+
+// MarkSnapshotStatusFrom moves the snapshot to status only if it is still in from
+func MarkSnapshotStatusFrom(db XODB, status, from int8, snapshotId string) (err error) {
+	_, err = db.Exec("UPDATE snapshot SET updated_at = ?, status = ? WHERE snapshot_id = ? AND status = ? AND deleted = 0", time.Now(), status, snapshotId, from)
+	return err
+}
+
+func MarkSnapshotDeleted(db XODB, snapshotId string) (err error) {
+	_, err = db.Exec("UPDATE snapshot SET deleted = 1, deleted_at = ? WHERE snapshot_id = ? AND deleted = 0", time.Now(), snapshotId)
+	return err
+}
+
+// DiskCountFromSnapshot counts the disks created from the snapshot, which can
+// not be deleted while there are any
+func DiskCountFromSnapshot(db XODB, snapshotId string) (count int64, err error) {
+	err = db.QueryRow("SELECT Count(*) FROM disk WHERE from_snapshot = ? AND deleted = 0", snapshotId).
+		Scan(&count)
+
+	return
+}
+
+func SetSnapshotExpireAt(db XODB, expireAt sql.NullTime, snapshotId string) (err error) {
+	_, err = db.Exec("UPDATE snapshot SET updated_at = ?, expire_at = ? WHERE snapshot_id = ? AND deleted = 0", time.Now(), expireAt, snapshotId)
+	return err
+}
+
+// DiskUsageByUserId counts the disks of the user and sums their size in bytes
+func DiskUsageByUserId(db XODB, userId string) (count, size int64, err error) {
+	err = db.QueryRow("SELECT Count(*), COALESCE(SUM(size), 0) FROM disk WHERE user_id = ? AND deleted = 0", userId).
+		Scan(&count, &size)
+
+	return
+}
+
+// DiskUsageByUserIdForUpdate is DiskUsageByUserId with the disks locked until
+// the transaction ends, so that usage is counted by one transaction at a time
+func DiskUsageByUserIdForUpdate(db XODB, userId string) (count, size int64, err error) {
+	const sqlStr = "SELECT Count(*), COALESCE(SUM(size), 0) FROM disk WHERE user_id = ? AND deleted = 0 FOR UPDATE"
+	XOLog(sqlStr, userId)
+	err = db.QueryRow(sqlStr, userId).Scan(&count, &size)
+
+	return
+}
+
+func SnapshotCountByDiskId(db XODB, diskId string) (count int64, err error) {
+	err = db.QueryRow("SELECT Count(*) FROM snapshot WHERE disk_id = ? AND deleted = 0", diskId).
+		Scan(&count)
+
+	return
+}
+
+func ImageCountByUserId(db XODB, userId string) (count int64, err error) {
+	err = db.QueryRow("SELECT Count(*) FROM image WHERE user_id = ? AND deleted = 0", userId).
+		Scan(&count)
+
+	return
+}
+
+func AttachCountByInstanceId(db XODB, instanceId string) (count int64, err error) {
+	err = db.QueryRow("SELECT Count(*) FROM attach WHERE instance_id = ? AND is_deleted = 0", instanceId).
+		Scan(&count)
+
+	return
+}
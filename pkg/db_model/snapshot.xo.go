@@ -305,3 +305,43 @@ func LastAutoSnapshotByDiskId(db XODB, diskId string) (*Snapshot, error) {
 
 	return &s, nil
 }
+
+// ExpiredSnapshots returns up to limit Available or Error snapshots whose
+// expire_at is at or before now, the earliest first. Snapshots some disk was
+// created from are left out, they can not be deleted.
+func ExpiredSnapshots(db XODB, now time.Time, limit int) ([]*Snapshot, error) {
+	// sql query
+	const sqlStr = `SELECT id, snapshot_id, status, disk_id, name, region, zone, category, ` +
+		`size, description, user_id, cluster_id, storage_type, snapshot_type, ` +
+		`created_at, updated_at, deleted_at, deleted, expire_at FROM snapshot ` +
+		`WHERE expire_at IS NOT NULL AND expire_at <= ? AND status IN (2, 4) AND deleted = 0 ` +
+		`AND NOT EXISTS (SELECT 1 FROM disk WHERE disk.from_snapshot = snapshot.snapshot_id AND disk.deleted = 0) ` +
+		`ORDER BY expire_at LIMIT ?`
+
+	// run query
+	XOLog(sqlStr, now, limit)
+	result, err := db.Query(sqlStr, now, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer result.Close()
+
+	// load results
+	var res []*Snapshot
+	for result.Next() {
+		s := Snapshot{
+			_exists: true,
+		}
+		// scan
+		err = result.Scan(&s.Id, &s.SnapshotID, &s.Status, &s.DiskID, &s.Name, &s.Region, &s.Zone, &s.Category,
+			&s.Size, &s.Description, &s.UserID, &s.ClusterID, &s.StorageType, &s.SnapshotType,
+			&s.CreatedAt, &s.UpdatedAt, &s.DeletedAt, &s.Deleted, &s.ExpireAt)
+		if err != nil {
+			return nil, err
+		}
+
+		res = append(res, &s)
+	}
+
+	return res, nil
+}
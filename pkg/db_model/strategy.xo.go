@@ -120,3 +120,72 @@ func StrategyExistByStrategyId(db XODB, strategyId string) (bool, error) {
 
 	return false, err
 }
+
+// Strategies returns every strategy, with the given status if status >= 0
+func Strategies(db XODB, status int32) ([]*Strategy, error) {
+	var err error
+
+	// sql query
+	const sqlstr = `SELECT ` +
+		`strategy_id, strategy_name, user_id, hours, weeks, duration, status, disk_quota, create_at, update_at ` +
+		`FROM snapshot_strategy ` +
+		`WHERE ? < 0 OR status = ? ORDER BY create_at`
+
+	// run query
+	XOLog(sqlstr, status, status)
+	q, err := db.Query(sqlstr, status, status)
+	if err != nil {
+		return nil, err
+	}
+	defer q.Close()
+
+	// load results
+	var res []*Strategy
+	for q.Next() {
+		s := Strategy{}
+		// scan
+		err = q.Scan(&s.StrategyId, &s.StrategyName, &s.UserId, &s.Hours, &s.Weeks, &s.Duration, &s.Status, &s.DiskQuota,
+			&s.CreateTime, &s.UpdateTime)
+		if err != nil {
+			return nil, err
+		}
+
+		res = append(res, &s)
+	}
+
+	return res, nil
+}
+
+func StrategiesByUserId(db XODB, userId string) ([]*Strategy, error) {
+	var err error
+
+	// sql query
+	const sqlstr = `SELECT ` +
+		`strategy_id, strategy_name, user_id, hours, weeks, duration, status, disk_quota, create_at, update_at ` +
+		`FROM snapshot_strategy ` +
+		`WHERE user_id = ? ORDER BY create_at`
+
+	// run query
+	XOLog(sqlstr, userId)
+	q, err := db.Query(sqlstr, userId)
+	if err != nil {
+		return nil, err
+	}
+	defer q.Close()
+
+	// load results
+	var res []*Strategy
+	for q.Next() {
+		s := Strategy{}
+		// scan
+		err = q.Scan(&s.StrategyId, &s.StrategyName, &s.UserId, &s.Hours, &s.Weeks, &s.Duration, &s.Status, &s.DiskQuota,
+			&s.CreateTime, &s.UpdateTime)
+		if err != nil {
+			return nil, err
+		}
+
+		res = append(res, &s)
+	}
+
+	return res, nil
+}
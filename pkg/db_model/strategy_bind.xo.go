@@ -174,3 +174,33 @@ func GetDiskCountByStrategyId(db XODB, strategyId string) (count int, err error)
 
 	return
 }
+
+func BindsByStrategyId(db XODB, strategyId string) ([]*StrategyBind, error) {
+	var err error
+	// sql query
+	const sqlStr = `SELECT disk_id, strategy_id, auto_snapshot_quota, bind_at FROM snapshot_strategy_binding ` +
+		`WHERE strategy_id = ? ORDER BY bind_at`
+
+	// run query
+	XOLog(sqlStr, strategyId)
+	result, err := db.Query(sqlStr, strategyId)
+	if err != nil {
+		return nil, err
+	}
+	defer result.Close()
+
+	// load results
+	var res []*StrategyBind
+	for result.Next() {
+		bind := StrategyBind{}
+		// scan
+		err = result.Scan(&bind.DiskId, &bind.StrategyId, &bind.AutoSnapshotQuota, &bind.BindTime)
+		if err != nil {
+			return nil, err
+		}
+
+		res = append(res, &bind)
+	}
+
+	return res, nil
+}
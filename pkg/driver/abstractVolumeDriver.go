@@ -1,11 +1,13 @@
 package driver
 
 import (
+	"context"
 	"database/sql"
+	"immortality-demo/config"
+	"immortality-demo/pkg/gredis"
 	"immortality/service/compute"
 	"immortality/service/data"
 	"immortality/service/db_model"
-	"immortality/service/driver/three_par"
 	"immortality/service/handler/model"
 	"immortality/service/logger"
 	"strconv"
@@ -19,14 +21,18 @@ type AbstractVolumeDriver struct{}
 
 func (*AbstractVolumeDriver) Init() {}
 
-func (*AbstractVolumeDriver) CreateDisk(req data.CreateDiskRequest) (resp data.CreateDiskResponse, err error) {
+func (*AbstractVolumeDriver) CreateDisk(ctx context.Context, req data.CreateDiskRequest) (resp data.CreateDiskResponse, err error) {
+	if (req.SnapshotId != "" || req.ImageId != "") && !Supports(req.StorageType, CapClone) {
+		logger.Log.Errorf(req.RequestId, "storageType %s can not create disks from snapshots or images", req.StorageType)
+		return resp, data.ErrOpNotSupport
+	}
 
 	d, err := GetDriver(req.StorageType)
 	if err != nil {
 		logger.Log.Errorf(req.RequestId, "GetDriver err: %+v, storageType: %s", err, req.StorageType)
 		return resp, err
 	}
-	resp, err = d.CreateDisk(req)
+	resp, err = d.CreateDisk(ctx, req)
 	if err != nil {
 		logger.Log.Errorf(req.RequestId, "CreateDisk req: %+v err: %s ", req, err)
 		return resp, err
@@ -77,7 +83,7 @@ const timeout = 15
 //	return err
 //}
 
-func (h *AbstractVolumeDriver) CreateDisks(req data.CreateDisksRequest) (err error) {
+func (h *AbstractVolumeDriver) CreateDisks(ctx context.Context, req data.CreateDisksRequest) (err error) {
 
 	success := 0
 	lastErr := err
@@ -100,7 +106,7 @@ func (h *AbstractVolumeDriver) CreateDisks(req data.CreateDisksRequest) (err err
 			continue
 		}
 
-		_, err = h.CreateDisk(diskReq)
+		_, err = h.CreateDisk(ctx, diskReq)
 		if err != nil {
 			logger.Log.Errorf(req.RequestId, "Failed to create disk %s, err:%+v", diskReq.DiskId, err)
 			lastErr = err
@@ -135,18 +141,18 @@ func (h *AbstractVolumeDriver) CreateDisks(req data.CreateDisksRequest) (err err
 	return nil
 }
 
-func (*AbstractVolumeDriver) DeleteDisk(req data.DeleteDiskRequest) (err error) {
+func (*AbstractVolumeDriver) DeleteDisk(ctx context.Context, req data.DeleteDiskRequest) (err error) {
 	d, err := GetDriver(req.StorageType)
 	if err != nil {
 		logger.Log.Errorf(req.RequestId, "GetDriver err: %+v, storageType: %s", err, req.StorageType)
 		return err
 	}
-	err = d.DeleteDisk(req)
-	if err != nil && err != three_par.ErrVolumeDoesNotExist {
+	err = d.DeleteDisk(ctx, req)
+	if err != nil && err != ErrVolumeDoesNotExist {
 		logger.Log.Errorf(req.RequestId, "DeleteDisk req: %+v, err: %s", req, err)
 		return err
 	}
-	if err == three_par.ErrVolumeDoesNotExist {
+	if err == ErrVolumeDoesNotExist {
 		logger.Log.Warn1(req.RequestId, "DeleteDisk req: %+v, err: %s", req, err)
 		err = nil
 	}
@@ -188,10 +194,14 @@ func (d *DetachForDelete) notifyDetach() (err error) {
 }
 
 func (d *DetachForDelete) notifyUnmap() (err error) {
+	var ips []string
+	if d.threePar != nil {
+		ips = strings.Split(d.threePar.Ipv4AddrController, ",")
+	}
 	for _, export := range d.exports {
 		unmapDiskAO := compute.UnmapDiskAO{
 			DiskID: d.disk.DiskID,
-			IPs:    strings.Split(d.threePar.Ipv4AddrController, ","),
+			IPs:    ips,
 			Lun:    strconv.Itoa(export.CvkLun),
 			WWN:    d.disk.ThreeParWWN,
 		}
@@ -220,7 +230,7 @@ func (d *DetachForDelete) detach() (err error) {
 	return
 }
 
-func (d *DetachForDelete) cancelExport() (err error) {
+func (d *DetachForDelete) cancelExport(ctx context.Context) (err error) {
 	for _, export := range d.exports {
 		request := data.ExportDiskRequest{
 			RequestId:    d.requestID,
@@ -231,7 +241,7 @@ func (d *DetachForDelete) cancelExport() (err error) {
 			StorageType:  d.disk.StorageType,
 			ScheduleInfo: d.disk.ClusterID,
 		}
-		if err = AbsDriver.CancelExport(request, 1); err != nil {
+		if err = AbsDriver.CancelExport(ctx, request, 1); err != nil {
 			return
 		}
 	}
@@ -274,18 +284,21 @@ func newDetachForDelete(requestID, diskID string) (*DetachForDelete, error) {
 		h.attach = attach
 		h.exports = exports
 
-		threePar, err := db_model.ThreeParByThreeParID(data.Db, disk.ClusterID)
-		if err != nil {
-			logger.Log.Errorf(requestID, "ThreeParByThreeParID: %s, err: %+v", disk.ClusterID, err)
-			return nil, data.ErrServerInternalDB
+		// ceph disks are mapped through rbd and have no controller IPs
+		if disk.StorageType == data.HPE3PARA {
+			threePar, err := db_model.ThreeParByThreeParID(data.Db, disk.ClusterID)
+			if err != nil {
+				logger.Log.Errorf(requestID, "ThreeParByThreeParID: %s, err: %+v", disk.ClusterID, err)
+				return nil, data.ErrServerInternalDB
+			}
+			h.threePar = threePar
 		}
-		h.threePar = threePar
 	}
 
 	return &h, nil
 }
 
-func (d *DetachForDelete) DoDetach() (err error) {
+func (d *DetachForDelete) DoDetach(ctx context.Context) (err error) {
 	if d.disk.StatusOrig == data.DiskStatusInUse {
 		if err = d.notifyDetach(); err != nil {
 			logger.Log.Errorf(d.requestID,
@@ -305,7 +318,7 @@ func (d *DetachForDelete) DoDetach() (err error) {
 			return
 		}
 
-		if err = d.cancelExport(); err != nil {
+		if err = d.cancelExport(ctx); err != nil {
 			logger.Log.Errorf(d.requestID,
 				"failed to cancelExport, DetachForDelete: %+v, err: %+v", d, err)
 			return
@@ -315,7 +328,7 @@ func (d *DetachForDelete) DoDetach() (err error) {
 	return nil
 }
 
-func (h *AbstractVolumeDriver) DeleteDisks(req data.DeleteDisksRequest) (err error) {
+func (h *AbstractVolumeDriver) DeleteDisks(ctx context.Context, req data.DeleteDisksRequest) (err error) {
 
 	//FinishedAt := req.DeleteAt
 
@@ -328,13 +341,13 @@ func (h *AbstractVolumeDriver) DeleteDisks(req data.DeleteDisksRequest) (err err
 
 		d.at = req.DeleteAt
 
-		err = d.DoDetach()
+		err = d.DoDetach(ctx)
 		if err != nil {
 			logger.Log.Errorf(req.RequestId, "failed to Detach err: %+v", err)
 			return err
 		}
 
-		err = h.DeleteDisk(diskInfo)
+		err = h.DeleteDisk(ctx, diskInfo)
 		if err != nil {
 			logger.Log.Errorf(req.RequestId, "failed to Delete Disk:%s, err: %+v", diskInfo.DiskId, err)
 			return err
@@ -352,14 +365,14 @@ func (h *AbstractVolumeDriver) DeleteDisks(req data.DeleteDisksRequest) (err err
 	return nil
 }
 
-func (*AbstractVolumeDriver) CreateImage(req data.CreateImageRequest) (err error) {
+func (*AbstractVolumeDriver) CreateImage(ctx context.Context, req data.CreateImageRequest) (err error) {
 	d, err := GetDriver(req.StorageType)
 	if err != nil {
 		logger.Log.Errorf(req.RequestId, "GetDriver err: %+v, storageType: %s", err, req.StorageType)
 		return err
 	}
 
-	err = d.CreateImage(req)
+	err = d.CreateImage(ctx, req)
 	if err != nil {
 		logger.Log.Errorf(req.RequestId, "Create Image req: %+v, error: %+v", req, err)
 		return err
@@ -374,33 +387,38 @@ func (*AbstractVolumeDriver) CreateImage(req data.CreateImageRequest) (err error
 	return nil
 }
 
-func (*AbstractVolumeDriver) DeleteImage(req data.DeleteImageRequest) (err error) {
+func (*AbstractVolumeDriver) DeleteImage(ctx context.Context, req data.DeleteImageRequest) (err error) {
 	d, err := GetDriver(req.StorageType)
 	if err != nil {
 		logger.Log.Errorf(req.RequestId, "GetDriver err: %+v, storageType: %s", err, req.StorageType)
 		return err
 	}
 
-	err = d.DeleteImage(req)
-	if err != nil && err != three_par.ErrVolumeDoesNotExist {
+	err = d.DeleteImage(ctx, req)
+	if err != nil && err != ErrVolumeDoesNotExist {
 		logger.Log.Errorf(req.RequestId, "DeleteImage  req: %+v, error: %+v", req, err)
 		return err
 	}
-	if err == three_par.ErrVolumeDoesNotExist {
+	if err == ErrVolumeDoesNotExist {
 		logger.Log.Warn1(req.RequestId, "DeleteImage req: %+v, err: %s", req, err)
 		err = nil
 	}
 	return nil
 }
 
-func (*AbstractVolumeDriver) CreateSnapshot(req data.CreateSnapshotRequest) (err error) {
+func (*AbstractVolumeDriver) CreateSnapshot(ctx context.Context, req data.CreateSnapshotRequest) (err error) {
+	if !Supports(req.StorageType, CapSnapshot) {
+		logger.Log.Errorf(req.RequestId, "storageType %s does not support snapshots", req.StorageType)
+		return data.ErrOpNotSupport
+	}
+
 	d, err := GetDriver(req.StorageType)
 	if err != nil {
 		logger.Log.Errorf(req.RequestId, "GetDriver err: %+v, storageType: %s", err, req.StorageType)
 		return err
 	}
 
-	err = d.CreateSnapshot(req)
+	err = d.CreateSnapshot(ctx, req)
 	if err != nil {
 		logger.Log.Errorf(req.RequestId, "CreateSnapShot req: %+v, error: %+v", req, err)
 		return err
@@ -450,34 +468,55 @@ func (*AbstractVolumeDriver) CreateSnapshot(req data.CreateSnapshotRequest) (err
 
 }
 
-func (*AbstractVolumeDriver) DeleteSnapshot(req data.DeleteSnapshotRequest) (err error) {
+func (*AbstractVolumeDriver) DeleteSnapshot(ctx context.Context, req data.DeleteSnapshotRequest) (err error) {
 	d, err := GetDriver(req.StorageType)
 	if err != nil {
 		logger.Log.Errorf(req.RequestId, "GetDriver err: %+v, storageType: %s", err, req.StorageType)
 		return err
 	}
 
-	err = d.DeleteSnapshot(req)
-	if err != nil && err != three_par.ErrVolumeDoesNotExist {
+	err = d.DeleteSnapshot(ctx, req)
+	if err != nil && err != ErrVolumeDoesNotExist {
 		logger.Log.Errorf(req.RequestId, "DeleteSnapshot req: %+v, err: %+v", req, err)
 		return err
 	}
-	if err == three_par.ErrVolumeDoesNotExist {
+	if err == ErrVolumeDoesNotExist {
 		logger.Log.Warn1(req.RequestId, "DeleteSnapshot req: %+v, err: %s", req, err)
 		err = nil
 	}
+
+	err = db_model.MarkSnapshotDeleted(data.Db, req.SnapshotId)
+	if err != nil {
+		logger.Log.Error1(req.RequestId, "MarkSnapshotDeleted err", err)
+		return err
+	}
+
+	if req.ExpireAt != "" {
+		event := data.SnapshotEvent{
+			Event:      data.SnapshotEventExpired,
+			SnapshotId: req.SnapshotId,
+			DiskId:     req.DiskId,
+			UserId:     req.UserId,
+			ExpireAt:   req.ExpireAt,
+			RequestId:  req.RequestId,
+			Time:       time.Now().Format(time.RFC3339),
+		}
+		if err = gredis.Publish(data.SnapshotEventChannel, event); err != nil {
+			logger.Log.Errorf(req.RequestId, "failed to publish the expiry of snapshot %s: %v", req.SnapshotId, err)
+		}
+	}
 	return nil
 
 }
 
-func (*AbstractVolumeDriver) ReInitDisk(req data.ReInitDiskRequest) (err error) {
+func (*AbstractVolumeDriver) ReInitDisk(ctx context.Context, req data.ReInitDiskRequest) (err error) {
 	d, err := GetDriver(req.StorageType)
 	if err != nil {
 		logger.Log.Errorf(req.RequestId, "GetDriver err: %+v, storageType: %s", err, req.StorageType)
 		return err
 	}
 
-	resp, err := d.ReInitDisk(req)
+	resp, err := d.ReInitDisk(ctx, req)
 	if err != nil {
 		logger.Log.Errorf(req.RequestId, "ReInitDisk req: %+v, err: %+v", req, err)
 		return err
@@ -501,14 +540,14 @@ func (*AbstractVolumeDriver) ReInitDisk(req data.ReInitDiskRequest) (err error)
 
 }
 
-func (*AbstractVolumeDriver) ResetDisk(req data.ResetDiskRequest) (err error) {
+func (*AbstractVolumeDriver) ResetDisk(ctx context.Context, req data.ResetDiskRequest) (err error) {
 	d, err := GetDriver(req.StorageType)
 	if err != nil {
 		logger.Log.Error1(req.RequestId, "GetDriver err: %+v, storageType: %s", err, req.StorageType)
 		return err
 	}
 
-	err = d.ResetDisk(req)
+	err = d.ResetDisk(ctx, req)
 	if err != nil {
 		logger.Log.Errorf(req.RequestId, "ResetDisk req: %+v, err: %+v", req, err)
 		return err
@@ -531,14 +570,14 @@ func (*AbstractVolumeDriver) ResetDisk(req data.ResetDiskRequest) (err error) {
 
 }
 
-func (h *AbstractVolumeDriver) ResizeDisk(req data.ResizeDiskRequest) (err error) {
+func (h *AbstractVolumeDriver) ResizeDisk(ctx context.Context, req data.ResizeDiskRequest) (err error) {
 	d, err := GetDriver(req.StorageType)
 	if err != nil {
 		logger.Log.Errorf(req.RequestId, "GetDriver err: %+v, storageType: %s", err, req.StorageType)
 		return err
 	}
 
-	err = d.ResizeDisk(req)
+	err = d.ResizeDisk(ctx, req)
 	if err != nil {
 		logger.Log.Errorf(req.RequestId, "ResizeDisk req: %+v, err: %+v", req, err)
 		return err
@@ -585,9 +624,9 @@ func (h *AbstractVolumeDriver) ResizeDisk(req data.ResizeDiskRequest) (err error
 	return nil
 }
 
-func (h *AbstractVolumeDriver) ResizeDisks(req data.ResizeDisksRequest) (err error) {
+func (h *AbstractVolumeDriver) ResizeDisks(ctx context.Context, req data.ResizeDisksRequest) (err error) {
 	for _, resizeReq := range req.DisksReq {
-		err = h.ResizeDisk(resizeReq)
+		err = h.ResizeDisk(ctx, resizeReq)
 		if err != nil {
 			logger.Log.Errorf(req.RequestId, "Resize disk:%s failed, err:%+v", resizeReq.DiskId, err)
 			return nil
@@ -604,7 +643,7 @@ func (h *AbstractVolumeDriver) ResizeDisks(req data.ResizeDisksRequest) (err err
 	return nil
 }
 
-func (*AbstractVolumeDriver) Export(req data.ExportDiskRequest, retryTimes int) (resp data.ExportDiskResponse, err error) {
+func (*AbstractVolumeDriver) Export(ctx context.Context, req data.ExportDiskRequest, attempt int) (resp data.ExportDiskResponse, err error) {
 	export, err := db_model.GetExport3(data.Db, req.DiskId, req.CVKName)
 	if err != nil && err != sql.ErrNoRows {
 		logger.Log.Error1(req.RequestId, "check export error.", err)
@@ -615,7 +654,7 @@ func (*AbstractVolumeDriver) Export(req data.ExportDiskRequest, retryTimes int)
 		return resp, data.ErrInvalidExport
 	}
 	if export != nil && export.Status == data.ExportStatusExported {
-		resp.Lun = export.CvkLun
+		resp.Lun, resp.Target = export.CvkLun, export.Target
 		return resp, nil
 	}
 	if export != nil && export.Status == data.ExportStatusUnExportFail {
@@ -625,7 +664,7 @@ func (*AbstractVolumeDriver) Export(req data.ExportDiskRequest, retryTimes int)
 			logger.Log.Error1(req.RequestId, "Error update export to DB:", err)
 			return resp, err
 		}
-		resp.Lun = export.CvkLun
+		resp.Lun, resp.Target = export.CvkLun, export.Target
 		return resp, nil
 	}
 
@@ -635,14 +674,21 @@ func (*AbstractVolumeDriver) Export(req data.ExportDiskRequest, retryTimes int)
 		return resp, err
 	}
 
-	if !d.NeedExport(req) {
+	if !Supports(req.StorageType, CapExport) || !d.NeedExport(req) {
 		return resp, nil
 	}
 
-	resp, err = d.Export(req)
+	if Supports(req.StorageType, CapCvkLun) {
+		req.Lun, err = allocateLun(req)
+		if err != nil {
+			return resp, err
+		}
+	}
+
+	resp, err = d.Export(ctx, req)
 	if err != nil {
 		logger.Log.Errorf(req.RequestId, "Export req: %+v, err: %+v", req, err)
-		if retryTimes >= 10 {
+		if attempt >= config.RetryOf(data.ActionExport).MaxAttempts {
 			export.Status = data.ExportStatusExportFail
 			err = export.Save(data.Db)
 			if err != nil {
@@ -673,10 +719,10 @@ func (*AbstractVolumeDriver) Export(req data.ExportDiskRequest, retryTimes int)
 		}
 
 		logger.Log.Infof(req.RequestId, "Export lun is 254, exports again")
-		resp, err = d.Export(req)
+		resp, err = d.Export(ctx, req)
 		if err != nil {
 			logger.Log.Errorf(req.RequestId, "Export req: %+v, err: %+v", req, err)
-			if retryTimes >= 10 {
+			if attempt >= config.RetryOf(data.ActionExport).MaxAttempts {
 				export.Status = data.ExportStatusExportFail
 				err = export.Save(data.Db)
 				if err != nil {
@@ -695,6 +741,7 @@ func (*AbstractVolumeDriver) Export(req data.ExportDiskRequest, retryTimes int)
 
 	export.Status = data.ExportStatusExported
 	export.CvkLun = resp.Lun
+	export.Target = resp.Target
 	err = export.Save(data.Db)
 	if err != nil {
 		logger.Log.Error1(req.RequestId, "Error update export to DB:", err)
@@ -704,7 +751,63 @@ func (*AbstractVolumeDriver) Export(req data.ExportDiskRequest, retryTimes int)
 	return resp, nil
 }
 
-func (*AbstractVolumeDriver) CancelExport(req data.ExportDiskRequest, retryTimes int) (err error) {
+// allocateLun picks the lowest LUN not taken on the CVK and records it on the
+// export of the disk, a retried export keeps the LUN it got. The exports of the
+// CVK stay locked until the commit so concurrent exports can not pick the same LUN.
+func allocateLun(req data.ExportDiskRequest) (lun int, err error) {
+	tx, err := data.Db.Begin()
+	if err != nil {
+		logger.Log.Error1(req.RequestId, "Begin transaction error:", err)
+		return 0, data.ErrServerInternalDB
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	exports, err := db_model.ExportsByCvkNameForUpdate(tx, req.CVKName)
+	if err != nil {
+		logger.Log.Error1(req.RequestId, "ExportsByCvkNameForUpdate error:", err)
+		return 0, data.ErrServerInternalDB
+	}
+	var export *db_model.Export
+	taken := map[int]bool{}
+	for _, e := range exports {
+		if e.DiskId == req.DiskId {
+			export = e
+			continue
+		}
+		taken[e.CvkLun] = true
+	}
+	if export == nil {
+		logger.Log.Error1(req.RequestId, "cannot find export of disk", req.DiskId, "on", req.CVKName)
+		err = data.ErrInvalidExport
+		return
+	}
+
+	// LUNs start at 1, 0 is what an export has before it got one. 254 is skipped
+	// since the export flow takes it for the special LUN of 3PAR
+	lun = export.CvkLun
+	if lun == 0 {
+		for lun = 1; taken[lun] || lun == data.HEP_3PAR_SPECIAL_LUN; lun++ {
+		}
+		export.CvkLun = lun
+		export.UpdateAt = time.Now()
+		if err = export.Update(tx); err != nil {
+			logger.Log.Error1(req.RequestId, "Error update export to DB:", err)
+			return 0, data.ErrServerInternalDB
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		logger.Log.Error1(req.RequestId, "Commit transaction error:", err)
+		return 0, data.ErrServerInternalDB
+	}
+	return lun, nil
+}
+
+func (*AbstractVolumeDriver) CancelExport(ctx context.Context, req data.ExportDiskRequest, attempt int) (err error) {
 	//check
 	export, err := db_model.GetExport3(data.Db, req.DiskId, req.CVKName)
 	if err != nil && err != sql.ErrNoRows {
@@ -721,10 +824,10 @@ func (*AbstractVolumeDriver) CancelExport(req data.ExportDiskRequest, retryTimes
 			logger.Log.Errorf(req.RequestId, "GetDriver err: %+v, storageType: %s", err, req.StorageType)
 			return err
 		}
-		err = d.CancelExport(req)
+		err = d.CancelExport(ctx, req)
 		if err != nil {
 			logger.Log.Errorf(req.RequestId, "CancelExport req: %+v, err: %+v", req, err)
-			if retryTimes >= 10 {
+			if attempt >= config.RetryOf(data.ActionCancelExport).MaxAttempts {
 				export.Status = data.ExportStatusUnExportFail
 				err = export.Save(data.Db)
 				if err != nil {
@@ -754,10 +857,10 @@ func (*AbstractVolumeDriver) CancelExport(req data.ExportDiskRequest, retryTimes
 			logger.Log.Errorf(req.RequestId, "GetDriver err: %+v, storageType: %s", err, req.StorageType)
 			return err
 		}
-		err = d.CancelExport(req)
+		err = d.CancelExport(ctx, req)
 		if err != nil {
 			logger.Log.Errorf(req.RequestId, "CancelExport req: %+v, err: %+v", req, err)
-			if retryTimes >= 10 {
+			if attempt >= config.RetryOf(data.ActionCancelExport).MaxAttempts {
 				export2.Status = data.ExportStatusUnExportFail
 				err = export2.Save(data.Db)
 				if err != nil {
@@ -807,13 +910,18 @@ func (*AbstractVolumeDriver) CancelExport(req data.ExportDiskRequest, retryTimes
 
 }
 
-func (*AbstractVolumeDriver) AddDiskQoS(req data.DiskQoSRequest) (err error) {
+func (*AbstractVolumeDriver) AddDiskQoS(ctx context.Context, req data.DiskQoSRequest) (err error) {
+	if !Supports(req.StorageType, CapQoS) {
+		logger.Log.Warnf(req.RequestID, "storageType %s does not support QoS, skip AddDiskQoS", req.StorageType)
+		return nil
+	}
+
 	d, err := GetDriver(req.StorageType)
 	if err != nil {
 		logger.Log.Errorf(req.RequestID, "GetDriver err: %+v, storageType: %s", err, req.StorageType)
 		return err
 	}
-	if err = d.AddDiskQoS(req); err != nil {
+	if err = d.AddDiskQoS(ctx, req); err != nil {
 		logger.Log.Errorf(req.RequestID, "failed to add QoS for disk, req: %+v, err: %+v", req, err)
 		return err
 	}
@@ -821,13 +929,18 @@ func (*AbstractVolumeDriver) AddDiskQoS(req data.DiskQoSRequest) (err error) {
 	return
 }
 
-func (*AbstractVolumeDriver) RemoveDiskQoS(req data.DiskQoSRequest) (err error) {
+func (*AbstractVolumeDriver) RemoveDiskQoS(ctx context.Context, req data.DiskQoSRequest) (err error) {
+	if !Supports(req.StorageType, CapQoS) {
+		logger.Log.Warnf(req.RequestID, "storageType %s does not support QoS, skip RemoveDiskQoS", req.StorageType)
+		return nil
+	}
+
 	d, err := GetDriver(req.StorageType)
 	if err != nil {
 		logger.Log.Errorf(req.RequestID, "GetDriver err: %+v, storageType: %s", err, req.StorageType)
 		return err
 	}
-	if err = d.RemoveDiskQoS(req); err != nil {
+	if err = d.RemoveDiskQoS(ctx, req); err != nil {
 		logger.Log.Errorf(req.RequestID, "failed to remove QoS for disk, req: %+v, err: %+v", req, err)
 		return err
 	}
@@ -835,13 +948,18 @@ func (*AbstractVolumeDriver) RemoveDiskQoS(req data.DiskQoSRequest) (err error)
 	return
 }
 
-func (*AbstractVolumeDriver) UpdateDiskQoS(req data.DiskQoSRequest) (err error) {
+func (*AbstractVolumeDriver) UpdateDiskQoS(ctx context.Context, req data.DiskQoSRequest) (err error) {
+	if !Supports(req.StorageType, CapQoS) {
+		logger.Log.Warnf(req.RequestID, "storageType %s does not support QoS, skip UpdateDiskQoS", req.StorageType)
+		return nil
+	}
+
 	d, err := GetDriver(req.StorageType)
 	if err != nil {
 		logger.Log.Errorf(req.RequestID, "GetDriver err: %+v, storageType: %s", err, req.StorageType)
 		return err
 	}
-	if err = d.UpdateDiskQoS(req); err != nil {
+	if err = d.UpdateDiskQoS(ctx, req); err != nil {
 		logger.Log.Errorf(req.RequestID, "failed to remove QoS for disk, req: %+v, err: %+v", req, err)
 		return err
 	}
@@ -849,14 +967,18 @@ func (*AbstractVolumeDriver) UpdateDiskQoS(req data.DiskQoSRequest) (err error)
 	return
 }
 
-func (*AbstractVolumeDriver) GetSystemCapacity(req data.GetSystemCapacityRequest) (result string, err error) {
+func (*AbstractVolumeDriver) GetSystemCapacity(ctx context.Context, req data.GetSystemCapacityRequest) (result string, err error) {
+	if !Supports(req.StorageType, CapCapacity) {
+		return result, data.ErrOpNotSupport
+	}
+
 	d, err := GetDriver(req.StorageType)
 	if err != nil {
 		logger.Log.Error("GetDriver err: %+v, storageType: %s", err, req.StorageType)
 		return result, err
 	}
 
-	result, err = d.GetSystemCapacity(req)
+	result, err = d.GetSystemCapacity(ctx, req)
 	if err != nil {
 		logger.Log.Error("GetSystemCapacity req: %+v, err: %+v", req, err)
 		return result, err
@@ -864,14 +986,18 @@ func (*AbstractVolumeDriver) GetSystemCapacity(req data.GetSystemCapacityRequest
 	return result, nil
 }
 
-func (*AbstractVolumeDriver) GetSystemUtilization(req data.GetSystemUtilizationRequest) (ssd, hdd float64, err error) {
+func (*AbstractVolumeDriver) GetSystemUtilization(ctx context.Context, req data.GetSystemUtilizationRequest) (ssd, hdd float64, err error) {
+	if !Supports(req.StorageType, CapCapacity) {
+		return ssd, hdd, data.ErrOpNotSupport
+	}
+
 	d, err := GetDriver(req.StorageType)
 	if err != nil {
 		logger.Log.Error("GetDriver err: %+v, storageType: %s", err, req.StorageType)
 		return ssd, hdd, err
 	}
 
-	ssd, hdd, err = d.GetSystemUtilization(req)
+	ssd, hdd, err = d.GetSystemUtilization(ctx, req)
 	if err != nil {
 		logger.Log.Error("GetSystemCapacity req: %+v, err: %+v", req, err)
 		return ssd, hdd, err
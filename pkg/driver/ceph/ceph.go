@@ -6,6 +6,9 @@ import (
 	"immortality-demo/config"
 	"immortality-demo/pkg/logger"
 	"immortality-demo/pkg/util"
+	"strconv"
+	"strings"
+	"syscall"
 )
 
 type Pool string
@@ -15,15 +18,25 @@ const (
 	ImagePool Pool = "image"
 )
 
+// librbd throttles an image by these metadata keys, zero means unlimited
+const (
+	qosIopsLimitKey = "conf_rbd_qos_iops_limit"
+	qosBpsLimitKey  = "conf_rbd_qos_bps_limit"
+)
+
+var errNoEntry = rbd.RBDError(-int(syscall.ENOENT))
+
 type ceph struct {
 	ID       string
+	user     string
+	monHost  string
 	conn     *rados.Conn
 	diskCtx  *rados.IOContext
 	imageCtx *rados.IOContext
 }
 
 func newCeph(conf config.CephConfig) (c ceph, err error) {
-	c = ceph{}
+	c = ceph{user: conf.User}
 
 	c.conn, err = rados.NewConnWithUser(conf.User)
 	if err != nil {
@@ -51,6 +64,12 @@ func newCeph(conf config.CephConfig) (c ceph, err error) {
 		return
 	}
 
+	c.monHost, err = c.conn.GetConfigOption("mon_host")
+	if err != nil {
+		logger.Log.Error("ceph failed(mon_host):", err)
+		return
+	}
+
 	c.diskCtx, err = c.conn.OpenIOContext(string(DiskPool))
 	if err != nil {
 		logger.Log.Error("ceph failed(rbd):", err)
@@ -374,3 +393,112 @@ func (c ceph) createDiskFromImage(imageType, imageName, diskName string) (err er
 	}
 	return
 }
+
+// stat returns the size of the cluster and the space left in it, in KiB
+func (c ceph) stat() (stat rados.ClusterStat, err error) {
+	stat, err = c.conn.GetClusterStats()
+	if err != nil {
+		logger.Log.Error("ceph GetClusterStats error:", err)
+	}
+	return
+}
+
+// usage returns stat and the space taken by the disk and image pools, in KiB
+func (c ceph) usage() (stat rados.ClusterStat, poolsKb uint64, err error) {
+	stat, err = c.stat()
+	if err != nil {
+		return
+	}
+	for _, pool := range []Pool{DiskPool, ImagePool} {
+		var poolStat rados.PoolStat
+		poolStat, err = c.contextByPool(pool).GetPoolStats()
+		if err != nil {
+			logger.Log.Error("ceph GetPoolStats of", pool, "error:", err)
+			return
+		}
+		poolsKb += poolStat.Num_kb
+	}
+	return
+}
+
+// setQoS limits the iops and the bandwidth in bytes per second of the image
+func (c ceph) setQoS(pool Pool, name string, iops uint32, bps uint64) (err error) {
+	image, err := c.get(pool, name)
+	if err != nil {
+		return
+	}
+	err = image.Open()
+	if err != nil {
+		logger.Log.Error(err)
+		return
+	}
+	defer image.Close()
+
+	err = image.SetMetadata(qosIopsLimitKey, strconv.FormatUint(uint64(iops), 10))
+	if err != nil {
+		logger.Log.Error("ceph SetMetadata", qosIopsLimitKey, "of", name, "error:", err)
+		return
+	}
+	err = image.SetMetadata(qosBpsLimitKey, strconv.FormatUint(bps, 10))
+	if err != nil {
+		logger.Log.Error("ceph SetMetadata", qosBpsLimitKey, "of", name, "error:", err)
+	}
+	return
+}
+
+// removeQoS lifts the limits of the image, it is fine if none were set
+func (c ceph) removeQoS(pool Pool, name string) (err error) {
+	image, err := c.get(pool, name)
+	if err != nil {
+		return
+	}
+	err = image.Open()
+	if err != nil {
+		logger.Log.Error(err)
+		return
+	}
+	defer image.Close()
+
+	for _, key := range []string{qosIopsLimitKey, qosBpsLimitKey} {
+		err = image.RemoveMetadata(key)
+		if err == errNoEntry {
+			err = nil
+		}
+		if err != nil {
+			logger.Log.Error("ceph RemoveMetadata", key, "of", name, "error:", err)
+			return
+		}
+	}
+	return
+}
+
+// hasQoS tells whether limits were set on the image
+func (c ceph) hasQoS(pool Pool, name string) (limited bool, err error) {
+	image, err := c.get(pool, name)
+	if err != nil {
+		return
+	}
+	err = image.Open()
+	if err != nil {
+		logger.Log.Error(err)
+		return
+	}
+	defer image.Close()
+
+	_, err = image.GetMetadata(qosIopsLimitKey)
+	if err == errNoEntry {
+		return false, nil
+	}
+	if err != nil {
+		logger.Log.Error("ceph GetMetadata", qosIopsLimitKey, "of", name, "error:", err)
+		return
+	}
+	return true, nil
+}
+
+// mapDescriptor returns the image in the form qemu and the rbd tools of the
+// compute nodes take: rbd:pool/image:id=user:mon_host=mons
+func (c ceph) mapDescriptor(pool Pool, name string) string {
+	mons := strings.NewReplacer(" ", "", ":", `\:`, ",", `\;`).Replace(c.monHost)
+	return "rbd:" + string(pool) + "/" + name + ":id=" + c.user + ":mon_host=" + mons
+}
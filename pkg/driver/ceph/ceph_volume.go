@@ -1,18 +1,32 @@
 package ceph
 
 import (
+	"context"
+	"encoding/json"
 	"errors"
 	"github.com/ceph/go-ceph/rbd"
 	"immortality-demo/config"
 	"immortality-demo/pkg/data"
 	"immortality-demo/pkg/db_model"
+	"immortality-demo/pkg/driver"
 	"immortality-demo/pkg/logger"
+	"immortality-demo/pkg/util"
 )
 
 var (
 	ErrClusterNotFound = errors.New("ceph cluster not configured")
 )
 
+func init() {
+	driver.Register(data.CEPH, func() (driver.VolumeDriver, error) {
+		v, err := CreateCephVolumeDriver()
+		if err != nil {
+			return nil, err
+		}
+		return v, nil
+	}, driver.CapExport|driver.CapQoS|driver.CapSnapshot|driver.CapClone|driver.CapCapacity|driver.CapCvkLun)
+}
+
 type CephVolumeDriver struct {
 	// maps id -> ceph handle
 	//idMapping map[string]ceph
@@ -46,7 +60,7 @@ func CreateCephVolumeDriver() (v *CephVolumeDriver, err error) {
 	return cephVolumeDriver, nil
 }
 
-func (v *CephVolumeDriver) CreateDisk(req data.CreateDiskRequest) (resp data.CreateDiskResponse, err error) {
+func (v *CephVolumeDriver) CreateDisk(ctx context.Context, req data.CreateDiskRequest) (resp data.CreateDiskResponse, err error) {
 	//cephConfig, err := config.CephClusterByCategory(req.DiskCategory)
 	//if err != nil {
 	//	return resp, data.ErrZoneNotAvailable
@@ -55,11 +69,11 @@ func (v *CephVolumeDriver) CreateDisk(req data.CreateDiskRequest) (resp data.Cre
 	//clusterId := cephConfig.Id
 
 	if req.SnapshotId != "" {
-		err = v.createDiskFromSnapshot(req.DiskCategory, req.DiskId, req.SnapshotId)
+		err = v.createDiskFromSnapshot(ctx, req.DiskCategory, req.DiskId, req.SnapshotId)
 	} else if req.ImageId != "" {
-		err = v.createDiskFromImage(req.DiskCategory, req.DiskId, req.ImageType, req.ImageId, uint64(req.Size))
+		err = v.createDiskFromImage(ctx, req.DiskCategory, req.DiskId, req.ImageType, req.ImageId, uint64(req.Size))
 	} else {
-		err = v.createBlankDisk(req.DiskCategory, req.DiskId, uint64(req.Size))
+		err = v.createBlankDisk(ctx, req.DiskCategory, req.DiskId, uint64(req.Size))
 	}
 
 	if err != nil {
@@ -84,7 +98,7 @@ func (v *CephVolumeDriver) CreateDisk(req data.CreateDiskRequest) (resp data.Cre
 	return
 }
 
-func (v *CephVolumeDriver) createDiskFromSnapshot(category, diskId string, snapshotId string) (err error) {
+func (v *CephVolumeDriver) createDiskFromSnapshot(ctx context.Context, category, diskId string, snapshotId string) (err error) {
 	var (
 		snapshot *db_model.Snapshot
 		cluster  ceph
@@ -104,7 +118,7 @@ func (v *CephVolumeDriver) createDiskFromSnapshot(category, diskId string, snaps
 	return cluster.createDiskFromSnapshot(snapshot.DiskID, snapshot.SnapshotID, diskId)
 }
 
-func (v *CephVolumeDriver) createDiskFromImage(category, diskId, imageType, imageId string, size uint64) (err error) {
+func (v *CephVolumeDriver) createDiskFromImage(ctx context.Context, category, diskId, imageType, imageId string, size uint64) (err error) {
 	var (
 	//dbImage        *image_service.Image
 	//imageClusterId string
@@ -175,10 +189,14 @@ func (v *CephVolumeDriver) createDiskFromImage(category, diskId, imageType, imag
 		}
 	}()
 
+	if err = ctx.Err(); err != nil {
+		return
+	}
+
 	return ceph.resizeImage(DiskPool, diskId, size)
 }
 
-func (v *CephVolumeDriver) createBlankDisk(category, diskId string, size uint64) (err error) {
+func (v *CephVolumeDriver) createBlankDisk(ctx context.Context, category, diskId string, size uint64) (err error) {
 	var (
 		cluster ceph
 		image   *rbd.Image
@@ -200,7 +218,7 @@ func (v *CephVolumeDriver) createBlankDisk(category, diskId string, size uint64)
 	return
 }
 
-func (v *CephVolumeDriver) DeleteDisk(req data.DeleteDiskRequest) (err error) {
+func (v *CephVolumeDriver) DeleteDisk(ctx context.Context, req data.DeleteDiskRequest) (err error) {
 	var (
 		cluster ceph
 	)
@@ -221,7 +239,7 @@ func (v *CephVolumeDriver) DeleteDisk(req data.DeleteDiskRequest) (err error) {
 	return
 }
 
-func (v *CephVolumeDriver) CreateImage(req data.CreateImageRequest) (err error) {
+func (v *CephVolumeDriver) CreateImage(ctx context.Context, req data.CreateImageRequest) (err error) {
 	cluster, err := v.getClientByCategory(req.DiskCategory)
 	if err != nil {
 		logger.Log.Error1(req.RequestId, "hdd ceph cluster not configured", err)
@@ -280,7 +298,7 @@ func (v *CephVolumeDriver) CreateImage(req data.CreateImageRequest) (err error)
 	return
 }
 
-func (v *CephVolumeDriver) DeleteImage(req data.DeleteImageRequest) (err error) {
+func (v *CephVolumeDriver) DeleteImage(ctx context.Context, req data.DeleteImageRequest) (err error) {
 	cluster, err := v.getClientByCategory("hdd")
 	if err != nil {
 		logger.Log.Error1(req.RequestId, "Ceph HDD cluster not configured", err)
@@ -296,7 +314,7 @@ func (v *CephVolumeDriver) DeleteImage(req data.DeleteImageRequest) (err error)
 	return
 }
 
-func (v *CephVolumeDriver) CreateSnapshot(req data.CreateSnapshotRequest) (err error) {
+func (v *CephVolumeDriver) CreateSnapshot(ctx context.Context, req data.CreateSnapshotRequest) (err error) {
 	cluster, err := v.getClientByCategory(req.DiskCategory)
 	if err != nil {
 		logger.Log.Error1(req.RequestId, "Ceph cluster not configured for", req.ScheduleInfo, err)
@@ -320,7 +338,7 @@ func (v *CephVolumeDriver) CreateSnapshot(req data.CreateSnapshotRequest) (err e
 	return
 }
 
-func (v *CephVolumeDriver) DeleteSnapshot(req data.DeleteSnapshotRequest) (err error) {
+func (v *CephVolumeDriver) DeleteSnapshot(ctx context.Context, req data.DeleteSnapshotRequest) (err error) {
 	cluster, err := v.getClientByCategory(req.DiskCategory)
 	if err != nil {
 		logger.Log.Error1(req.RequestId, "Ceph cluster not configured for", req.ScheduleInfo, err)
@@ -336,7 +354,7 @@ func (v *CephVolumeDriver) DeleteSnapshot(req data.DeleteSnapshotRequest) (err e
 	return
 }
 
-func (v *CephVolumeDriver) ReInitDisk(req data.ReInitDiskRequest) (resp data.CreateDiskResponse, err error) {
+func (v *CephVolumeDriver) ReInitDisk(ctx context.Context, req data.ReInitDiskRequest) (resp data.CreateDiskResponse, err error) {
 	// 1. delete original disk
 	cluster, err := v.getClientByCategory(req.DiskCategory)
 	if err != nil {
@@ -351,14 +369,18 @@ func (v *CephVolumeDriver) ReInitDisk(req data.ReInitDiskRequest) (resp data.Cre
 		return
 	}
 
+	if err = ctx.Err(); err != nil {
+		return
+	}
+
 	// 2. create a new one
 	if req.ImageId != "" {
-		err = v.createDiskFromImage(req.ScheduleInfo, req.DiskId, req.ImageType, req.ImageId, req.Size)
+		err = v.createDiskFromImage(ctx, req.ScheduleInfo, req.DiskId, req.ImageType, req.ImageId, req.Size)
 	} else if req.SnapshotId != "" {
-		err = v.createDiskFromSnapshot(req.ScheduleInfo, req.DiskId,
+		err = v.createDiskFromSnapshot(ctx, req.ScheduleInfo, req.DiskId,
 			req.SnapshotId)
 	} else {
-		err = v.createBlankDisk(req.ScheduleInfo, req.DiskId, req.Size)
+		err = v.createBlankDisk(ctx, req.ScheduleInfo, req.DiskId, req.Size)
 	}
 	if err != nil {
 		logger.Log.Error1(req.RequestId, "Failed to recreate disk:", err)
@@ -368,7 +390,7 @@ func (v *CephVolumeDriver) ReInitDisk(req data.ReInitDiskRequest) (resp data.Cre
 	return
 }
 
-func (v *CephVolumeDriver) ResetDisk(req data.ResetDiskRequest) (err error) {
+func (v *CephVolumeDriver) ResetDisk(ctx context.Context, req data.ResetDiskRequest) (err error) {
 	cluster, err := v.getClientByCategory(req.DiskCategory)
 	if err != nil {
 		logger.Log.Error1(req.RequestId, "Ceph cluster not configured for", req.ScheduleInfo, err)
@@ -383,6 +405,9 @@ func (v *CephVolumeDriver) ResetDisk(req data.ResetDiskRequest) (err error) {
 
 	//Check the size between snap and disk,and if the snap size is smaller, make disk to resize.
 	if req.SnapSize < req.DiskSize {
+		if err = ctx.Err(); err != nil {
+			return
+		}
 		err = cluster.resizeImage(DiskPool, req.DiskId, req.DiskSize)
 		if err != nil {
 			logger.Log.Error1(req.RequestId, "Ceph ResizeImage error:", err)
@@ -399,7 +424,7 @@ func (v *CephVolumeDriver) ResetDisk(req data.ResetDiskRequest) (err error) {
 	return
 }
 
-func (v *CephVolumeDriver) ResizeDisk(req data.ResizeDiskRequest) (err error) {
+func (v *CephVolumeDriver) ResizeDisk(ctx context.Context, req data.ResizeDiskRequest) (err error) {
 	//defer func() {
 	//	err = db_model.MarkDiskStatus(data.Db, req.OriginalStatus, req.DiskId)
 	//}()
@@ -416,17 +441,29 @@ func (v *CephVolumeDriver) ResizeDisk(req data.ResizeDiskRequest) (err error) {
 		return
 	}
 
-	return
+	// the limits grow with the capacity, recalculate them if the disk has any
+	limited, err := cluster.hasQoS(DiskPool, req.DiskId)
+	if err != nil || !limited {
+		return
+	}
+	return v.UpdateDiskQoS(ctx, data.DiskQoSRequest{
+		RequestID:    req.RequestId,
+		DiskID:       req.DiskId,
+		DiskCategory: req.DiskCategory,
+		Size:         uint64(req.NewSize),
+		StorageType:  req.StorageType,
+		ScheduleInfo: req.ScheduleInfo,
+	})
 }
 
-//func (v *CephVolumeDriver) getClientById(id string) (ceph, error) {
-//	cluster, ok := v.idMapping[id]
-//	if !ok {
-//		return ceph{}, ErrClusterNotFound
-//	}
-//
-//	return cluster, nil
-//}
+func (v *CephVolumeDriver) getClientById(id string) (ceph, error) {
+	for _, cluster := range v.categoryMapping {
+		if cluster.ID == id {
+			return cluster, nil
+		}
+	}
+	return ceph{}, ErrClusterNotFound
+}
 
 func (v *CephVolumeDriver) getClientByCategory(category string) (ceph, error) {
 	cluster, ok := v.categoryMapping[category]
@@ -437,37 +474,191 @@ func (v *CephVolumeDriver) getClientByCategory(category string) (ceph, error) {
 	return cluster, nil
 }
 
+// NeedExport ceph disks are mapped by the compute nodes themselves, the export
+// hands them the rbd map descriptor and the LUN allocated to track the mapping
+// on the CVK, see CapCvkLun
 func (v *CephVolumeDriver) NeedExport(req data.ExportDiskRequest) (isNeed bool) {
-	return false
+	return true
 }
 
-func (v *CephVolumeDriver) Export(req data.ExportDiskRequest) (resp data.ExportDiskResponse, err error) {
+func (v *CephVolumeDriver) Export(ctx context.Context, req data.ExportDiskRequest) (resp data.ExportDiskResponse, err error) {
+	cluster, err := v.getClientById(req.ScheduleInfo)
+	if err != nil {
+		logger.Log.Error1(req.RequestId, "Ceph cluster not configured for", req.ScheduleInfo, err)
+		return
+	}
+
+	_, err = cluster.get(DiskPool, req.DiskId)
+	if err != nil {
+		logger.Log.Error1(req.RequestId, "Ceph get disk", req.DiskId, "error:", err)
+		return
+	}
+	if err = ctx.Err(); err != nil {
+		return
+	}
+
+	resp.Lun = req.Lun
+	resp.Target = cluster.mapDescriptor(DiskPool, req.DiskId)
 	return resp, nil
 }
 
-func (v *CephVolumeDriver) CancelExport(req data.ExportDiskRequest) (err error) {
+// CancelExport the compute node unmaps the disk, there is nothing to release on
+// the cluster and the LUN is freed with the export
+func (v *CephVolumeDriver) CancelExport(ctx context.Context, req data.ExportDiskRequest) (err error) {
 	return nil
 }
 
-func (v *CephVolumeDriver) GetSystemCapacity(req data.GetSystemCapacityRequest) (result string, err error) {
-	return result, nil
+// GetSystemCapacity reports the clusters in the shape of the 3PAR capacity,
+// ssd clusters as SSD, hybrid clusters as FC and hdd clusters as NL devices.
+// ScheduleInfo picks a cluster by fsid or category, all clusters are summed up without it.
+func (v *CephVolumeDriver) GetSystemCapacity(ctx context.Context, req data.GetSystemCapacityRequest) (result string, err error) {
+	clusters, err := v.getClients(req.ScheduleInfo)
+	if err != nil {
+		return result, err
+	}
+
+	var capacity data.SystemCapacity
+	for category, cluster := range clusters {
+		if err = ctx.Err(); err != nil {
+			return result, err
+		}
+		stat, err := cluster.stat()
+		if err != nil {
+			return result, err
+		}
+		for _, device := range []*data.DeviceCapacity{&capacity.AllCapacity, deviceOf(&capacity, category)} {
+			device.TotalMiB += float64(stat.Kb) / 1024
+			device.FreeMiB += float64(stat.Kb_avail) / 1024
+		}
+	}
+
+	jsonBytes, err := json.Marshal(capacity)
+	if err != nil {
+		return result, err
+	}
+	return string(jsonBytes), nil
 }
 
-func (v *CephVolumeDriver) GetSystemUtilization(req data.GetSystemUtilizationRequest) (ssd, hdd float64, err error) {
+// GetSystemUtilization returns the share of the clusters taken by the disk and
+// image pools, in the shape of the 3PAR utilization: ssd for the ssd clusters,
+// hdd for the hybrid ones like the hybrid HDD CPG. The hdd clusters have no
+// figure of their own there.
+func (v *CephVolumeDriver) GetSystemUtilization(ctx context.Context, req data.GetSystemUtilizationRequest) (ssd, hdd float64, err error) {
+	clusters, err := v.getClients(req.ScheduleInfo)
+	if err != nil {
+		return ssd, hdd, err
+	}
+
+	var ssdUsedKb, ssdKb, hybridUsedKb, hybridKb uint64
+	for category, cluster := range clusters {
+		if category != data.DiskCategorySSD && category != data.DiskCategoryHybridHDD {
+			continue
+		}
+		if err = ctx.Err(); err != nil {
+			return ssd, hdd, err
+		}
+		stat, poolsKb, err := cluster.usage()
+		if err != nil {
+			return ssd, hdd, err
+		}
+		if category == data.DiskCategorySSD {
+			ssdUsedKb += poolsKb
+			ssdKb += stat.Kb
+		} else {
+			hybridUsedKb += poolsKb
+			hybridKb += stat.Kb
+		}
+	}
+
+	if ssdKb > 0 {
+		ssd = float64(ssdUsedKb) / float64(ssdKb)
+	}
+	if hybridKb > 0 {
+		hdd = float64(hybridUsedKb) / float64(hybridKb)
+	}
 	return ssd, hdd, nil
 }
 
-func (v *CephVolumeDriver) AddDiskQoS(req data.DiskQoSRequest) (err error) {
-	logger.Log.Warnf(req.RequestID, "CephVolumeDriver.AddDiskQoS does not been implemented")
-	return nil
+// Clusters returns the fsid of the cluster of each category
+func (v *CephVolumeDriver) Clusters() map[string]string {
+	clusters := make(map[string]string, len(v.categoryMapping))
+	for category, cluster := range v.categoryMapping {
+		clusters[category] = cluster.ID
+	}
+	return clusters
 }
 
-func (v *CephVolumeDriver) RemoveDiskQoS(req data.DiskQoSRequest) (err error) {
-	logger.Log.Warnf(req.RequestID, "CephVolumeDriver.RemoveDiskQoS does not been implemented")
-	return nil
+// getClients returns the clusters by category, the one matching id by fsid or
+// category, or all of them if id is empty
+func (v *CephVolumeDriver) getClients(id string) (map[string]ceph, error) {
+	if id == "" {
+		return v.categoryMapping, nil
+	}
+	for category, cluster := range v.categoryMapping {
+		if cluster.ID == id || category == id {
+			return map[string]ceph{category: cluster}, nil
+		}
+	}
+	return nil, ErrClusterNotFound
 }
 
-func (v *CephVolumeDriver) UpdateDiskQoS(req data.DiskQoSRequest) (err error) {
-	logger.Log.Warnf(req.RequestID, "CephVolumeDriver.UpdateDiskQoS does not been implemented")
-	return nil
+func deviceOf(capacity *data.SystemCapacity, category string) *data.DeviceCapacity {
+	switch category {
+	case data.DiskCategorySSD:
+		return &capacity.SSDCapacity
+	case data.DiskCategoryHDD:
+		return &capacity.NLCapacity
+	}
+	return &capacity.FCCapacity
+}
+
+// AddDiskQoS 设定磁盘限速规则, the limits are kept in the image metadata and
+// follow the disk specs of the 3PAR driver
+func (v *CephVolumeDriver) AddDiskQoS(ctx context.Context, req data.DiskQoSRequest) (err error) {
+	cluster, err := v.getClientByCategory(req.DiskCategory)
+	if err != nil {
+		logger.Log.Error1(req.RequestID, "Ceph cluster not configured for", req.DiskCategory, err)
+		return
+	}
+
+	spec, ok := data.DiskCategoryToInstanceCode[req.DiskCategory]
+	if !ok {
+		logger.Log.Warnf(req.RequestID, "no disk spec for category, req: %+v", req)
+		return nil
+	}
+	// 计算 QoS
+	bwMaxLimitKB, ioMaxLimit, err := util.QosByCapacity(int64(req.Size>>30), spec)
+	if err != nil {
+		logger.Log.Errorf(req.RequestID, "failed to QosByCapacity, req: %+v, err: %+v", req, err)
+		return
+	}
+
+	if err = ctx.Err(); err != nil {
+		return
+	}
+	err = cluster.setQoS(DiskPool, req.DiskID, ioMaxLimit, bwMaxLimitKB*1024)
+	if err != nil {
+		logger.Log.Errorf(req.RequestID, "failed to set QoS, req: %+v, err: %+v", req, err)
+	}
+	return
+}
+
+// RemoveDiskQoS 移除磁盘限速规则
+func (v *CephVolumeDriver) RemoveDiskQoS(ctx context.Context, req data.DiskQoSRequest) (err error) {
+	cluster, err := v.getClientByCategory(req.DiskCategory)
+	if err != nil {
+		logger.Log.Error1(req.RequestID, "Ceph cluster not configured for", req.DiskCategory, err)
+		return
+	}
+
+	err = cluster.removeQoS(DiskPool, req.DiskID)
+	if err != nil {
+		logger.Log.Errorf(req.RequestID, "failed to remove QoS, req: %+v, err: %+v", req, err)
+	}
+	return
+}
+
+// UpdateDiskQoS 更新磁盘限速规则, setting the metadata again replaces the old limits
+func (v *CephVolumeDriver) UpdateDiskQoS(ctx context.Context, req data.DiskQoSRequest) (err error) {
+	return v.AddDiskQoS(ctx, req)
 }
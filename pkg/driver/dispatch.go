@@ -1,15 +1,24 @@
 package driver
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	. "immortality-demo/pkg/data"
 
 	"immortality-demo/pkg/logger"
 )
 
-func Dispatch(action string, payload string) (err error) {
+// ErrUnknownAction is returned by Dispatch for the actions it does not know, so
+// that such a job is not taken as done
+var ErrUnknownAction = errors.New("unknown action")
+
+// Dispatch runs the action, attempt counts from 1 and grows on every retry of the job.
+// The drivers give up once ctx is done and return its error.
+func Dispatch(ctx context.Context, action string, payload string, attempt int) (err error) {
 	switch action {
-	case "CreateDisk":
+	case ActionCreateDisk:
 		var req CreateDiskRequest
 		err := json.Unmarshal([]byte(payload), &req)
 		if err != nil {
@@ -17,10 +26,10 @@ func Dispatch(action string, payload string) (err error) {
 			return err
 		}
 		logger.Log.Infof(req.RequestId, "CreateDisk:%+v", req)
-		_, err = AbsDriver.CreateDisk(req)
+		_, err = AbsDriver.CreateDisk(ctx, req)
 		return err
 
-	case "CreateDisks":
+	case ActionCreateDisks:
 		var req CreateDisksRequest
 		err := json.Unmarshal([]byte(payload), &req)
 		if err != nil {
@@ -28,18 +37,18 @@ func Dispatch(action string, payload string) (err error) {
 			return err
 		}
 		logger.Log.Infof(req.RequestId, "CreateDisks:%+v", req)
-		return AbsDriver.CreateDisks(req)
+		return AbsDriver.CreateDisks(ctx, req)
 
-	case "DeleteDisk":
+	case ActionDeleteDisk:
 		var req DeleteDiskRequest
 		err := json.Unmarshal([]byte(payload), &req)
 		if err != nil {
 			logger.Log.Error("json Unmarshal failed! json:%s", payload)
 			return err
 		}
-		return AbsDriver.DeleteDisk(req)
+		return AbsDriver.DeleteDisk(ctx, req)
 
-	case "DeleteDisks":
+	case ActionDeleteDisks:
 		var req DeleteDisksRequest
 		err := json.Unmarshal([]byte(payload), &req)
 		if err != nil {
@@ -47,9 +56,9 @@ func Dispatch(action string, payload string) (err error) {
 			return err
 		}
 		logger.Log.Infof(req.RequestId, "DeleteDisks:%+v", req)
-		return AbsDriver.DeleteDisks(req)
+		return AbsDriver.DeleteDisks(ctx, req)
 
-	case "CreateSnapshot":
+	case ActionCreateSnapshot:
 		var req CreateSnapshotRequest
 		err := json.Unmarshal([]byte(payload), &req)
 		if err != nil {
@@ -57,9 +66,9 @@ func Dispatch(action string, payload string) (err error) {
 			return err
 		}
 
-		return AbsDriver.CreateSnapshot(req)
+		return AbsDriver.CreateSnapshot(ctx, req)
 
-	case "DeleteSnapshot":
+	case ActionDeleteSnapshot:
 		var req DeleteSnapshotRequest
 		err := json.Unmarshal([]byte(payload), &req)
 		if err != nil {
@@ -67,9 +76,9 @@ func Dispatch(action string, payload string) (err error) {
 			return err
 		}
 
-		return AbsDriver.DeleteSnapshot(req)
+		return AbsDriver.DeleteSnapshot(ctx, req)
 
-	case "CreateImage":
+	case ActionCreateImage:
 		var req CreateImageRequest
 		err := json.Unmarshal([]byte(payload), &req)
 		if err != nil {
@@ -77,9 +86,9 @@ func Dispatch(action string, payload string) (err error) {
 			return err
 		}
 
-		return AbsDriver.CreateImage(req)
+		return AbsDriver.CreateImage(ctx, req)
 
-	case "DeleteImage":
+	case ActionDeleteImage:
 		var req DeleteImageRequest
 		err := json.Unmarshal([]byte(payload), &req)
 		if err != nil {
@@ -87,9 +96,9 @@ func Dispatch(action string, payload string) (err error) {
 			return err
 		}
 
-		return AbsDriver.DeleteImage(req)
+		return AbsDriver.DeleteImage(ctx, req)
 
-	case "ResetDisk":
+	case ActionResetDisk:
 		var req ResetDiskRequest
 		err := json.Unmarshal([]byte(payload), &req)
 		if err != nil {
@@ -97,9 +106,9 @@ func Dispatch(action string, payload string) (err error) {
 			return err
 		}
 
-		return AbsDriver.ResetDisk(req)
+		return AbsDriver.ResetDisk(ctx, req)
 
-	case "ReInitDisk":
+	case ActionReInitDisk:
 
 		var req ReInitDiskRequest
 		err := json.Unmarshal([]byte(payload), &req)
@@ -107,71 +116,71 @@ func Dispatch(action string, payload string) (err error) {
 			logger.Log.Error("json Unmarshal failed! json:%s", payload)
 			return err
 		}
-		return AbsDriver.ReInitDisk(req)
+		return AbsDriver.ReInitDisk(ctx, req)
 
-	case "ResizeDisk":
+	case ActionResizeDisk:
 		var req ResizeDiskRequest
 		err := json.Unmarshal([]byte(payload), &req)
 		if err != nil {
 			logger.Log.Error("json Unmarshal failed! json:%s", payload)
 			return err
 		}
-		return AbsDriver.ResizeDisk(req)
+		return AbsDriver.ResizeDisk(ctx, req)
 
-	case "ResizeDisks":
+	case ActionResizeDisks:
 		var req ResizeDisksRequest
 		err := json.Unmarshal([]byte(payload), &req)
 		if err != nil {
 			logger.Log.Error("json Unmarshal failed! json:%s", payload)
 			return err
 		}
-		return AbsDriver.ResizeDisks(req)
+		return AbsDriver.ResizeDisks(ctx, req)
 
-	case "Export":
+	case ActionExport:
 		var req ExportDiskRequest
 		err := json.Unmarshal([]byte(payload), &req)
 		if err != nil {
 			logger.Log.Error("json Unmarshal failed! json:%s", payload)
 			return err
 		}
-		_, err = AbsDriver.Export(req, retryTimes)
+		_, err = AbsDriver.Export(ctx, req, attempt)
 		return err
 
-	case "CancelExport":
+	case ActionCancelExport:
 		var req ExportDiskRequest
 		err := json.Unmarshal([]byte(payload), &req)
 		if err != nil {
 			logger.Log.Error("json Unmarshal failed! json:%s", payload)
 			return err
 		}
-		return AbsDriver.CancelExport(req, retryTimes)
+		return AbsDriver.CancelExport(ctx, req, attempt)
 
-	case "AddDiskQoS":
+	case ActionAddDiskQoS:
 		var req DiskQoSRequest
 		err := json.Unmarshal([]byte(payload), &req)
 		if err != nil {
 			logger.Log.Error("json Unmarshal failed! json:%s", payload)
 			return err
 		}
-		return AbsDriver.AddDiskQoS(req)
+		return AbsDriver.AddDiskQoS(ctx, req)
 
-	case "RemoveDiskQoS":
+	case ActionRemoveDiskQoS:
 		var req DiskQoSRequest
 		err := json.Unmarshal([]byte(payload), &req)
 		if err != nil {
 			logger.Log.Error("json Unmarshal failed! json:%s", payload)
 			return err
 		}
-		return AbsDriver.RemoveDiskQoS(req)
+		return AbsDriver.RemoveDiskQoS(ctx, req)
 
-	case "UpdateDiskQoS":
+	case ActionUpdateDiskQoS:
 		var req DiskQoSRequest
 		err := json.Unmarshal([]byte(payload), &req)
 		if err != nil {
 			logger.Log.Error("json Unmarshal failed! json:%s", payload)
 			return err
 		}
-		return AbsDriver.UpdateDiskQoS(req)
+		return AbsDriver.UpdateDiskQoS(ctx, req)
 
 	case "ResizeDelivery":
 		//	var req DeliveryRequest
@@ -180,9 +189,10 @@ func Dispatch(action string, payload string) (err error) {
 		//		return err
 		//	}
 		//	return businesshandler.ResizeDeliveryHandler{}.Handle(&req)
-		//
-		//default:
-		//	logger.Log.Error("Wrong volume action [%s]", action)
+
+	default:
+		logger.Log.Errorf("", "Wrong volume action [%s]", action)
+		return fmt.Errorf("%w: %s", ErrUnknownAction, action)
 	}
 	return err
 }
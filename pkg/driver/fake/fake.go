@@ -0,0 +1,471 @@
+// Package fake is an in-memory VolumeDriver for tests. Importing it registers
+// Default under the storage type "fake", tests inject faults into it and look
+// at what the driver holds.
+package fake
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"immortality-demo/pkg/data"
+	"immortality-demo/pkg/driver"
+	"sync"
+	"time"
+)
+
+const StorageType = data.FAKE
+
+var (
+	ErrExists   = errors.New("fake: volume exists")
+	ErrNoSpace  = errors.New("fake: not enough capacity")
+	ErrExported = errors.New("fake: volume is exported")
+	ErrShrink   = errors.New("fake: volume can not shrink")
+)
+
+// Default is the driver registered under StorageType, 1TiB large
+var Default = New(1 << 40)
+
+func init() {
+	driver.Register(StorageType, func() (driver.VolumeDriver, error) {
+		return Default, nil
+	}, driver.CapExport|driver.CapQoS|driver.CapSnapshot|driver.CapClone|driver.CapCapacity)
+}
+
+type Volume struct {
+	ID       string
+	Category string
+	Size     uint64 // in byte
+	// Origin is the disk, snapshot or image the volume was made from
+	Origin string
+}
+
+// QoS is the disk size and category the limits were set for
+type QoS struct {
+	Category string
+	Size     uint64
+}
+
+// Fault is injected into an operation of the driver
+type Fault struct {
+	// Latency delays the operation, it gives up when the context is done
+	Latency time.Duration
+	Err     error
+	// Partial makes the operation take effect before Err is returned, like a
+	// backend failing after it did the work
+	Partial bool
+	// Times is how often the fault fires, 0 means every time
+	Times int
+}
+
+type export struct {
+	cvk string
+	lun int
+}
+
+type Driver struct {
+	mu        sync.Mutex
+	capacity  uint64
+	disks     map[string]*Volume
+	snapshots map[string]*Volume
+	images    map[string]*Volume
+	// exports of disk id by initiator
+	exports map[string]map[string]export
+	qos     map[string]QoS
+	faults  map[string]*Fault
+}
+
+func New(capacity uint64) *Driver {
+	d := &Driver{capacity: capacity}
+	d.Reset()
+	return d
+}
+
+// Reset drops every volume, export and fault
+func (d *Driver) Reset() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.disks = make(map[string]*Volume)
+	d.snapshots = make(map[string]*Volume)
+	d.images = make(map[string]*Volume)
+	d.exports = make(map[string]map[string]export)
+	d.qos = make(map[string]QoS)
+	d.faults = make(map[string]*Fault)
+}
+
+// Inject sets the fault of op, the name of a VolumeDriver method
+func (d *Driver) Inject(op string, fault Fault) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.faults[op] = &fault
+}
+
+func (d *Driver) Disk(id string) (Volume, bool) {
+	return d.lookup(d.disks, id)
+}
+
+func (d *Driver) Snapshot(id string) (Volume, bool) {
+	return d.lookup(d.snapshots, id)
+}
+
+func (d *Driver) Image(id string) (Volume, bool) {
+	return d.lookup(d.images, id)
+}
+
+// Lun returns the LUN the disk is exported under to the initiator
+func (d *Driver) Lun(diskId, initiator string) (int, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	e, ok := d.exports[diskId][initiator]
+	return e.lun, ok
+}
+
+func (d *Driver) QoSOf(diskId string) (QoS, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	q, ok := d.qos[diskId]
+	return q, ok
+}
+
+func (d *Driver) lookup(volumes map[string]*Volume, id string) (Volume, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	v, ok := volumes[id]
+	if !ok {
+		return Volume{}, false
+	}
+	return *v, true
+}
+
+// run applies the fault of op around apply, which runs under the lock
+func (d *Driver) run(ctx context.Context, op string, apply func() error) error {
+	d.mu.Lock()
+	fault, ok := d.faults[op]
+	var f Fault
+	if ok {
+		f = *fault
+		if fault.Times > 0 {
+			if fault.Times--; fault.Times == 0 {
+				delete(d.faults, op)
+			}
+		}
+	}
+	d.mu.Unlock()
+
+	if f.Latency > 0 {
+		timer := time.NewTimer(f.Latency)
+		defer timer.Stop()
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if f.Err != nil && !f.Partial {
+		return f.Err
+	}
+
+	d.mu.Lock()
+	err := apply()
+	d.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	return f.Err
+}
+
+func (d *Driver) used() (ssd, others uint64) {
+	for _, volumes := range []map[string]*Volume{d.disks, d.images} {
+		for _, v := range volumes {
+			if v.Category == data.DiskCategorySSD {
+				ssd += v.Size
+			} else {
+				others += v.Size
+			}
+		}
+	}
+	return
+}
+
+func (d *Driver) reserve(size uint64) error {
+	ssd, others := d.used()
+	if ssd+others+size > d.capacity {
+		return ErrNoSpace
+	}
+	return nil
+}
+
+// add creates volume id in volumes from origin, or blank if origin is empty
+func (d *Driver) add(volumes map[string]*Volume, id, category string, size uint64, origin string) error {
+	if _, ok := volumes[id]; ok {
+		return ErrExists
+	}
+	if origin != "" {
+		from, ok := d.snapshots[origin]
+		if !ok {
+			from, ok = d.images[origin]
+		}
+		if !ok {
+			from, ok = d.disks[origin]
+		}
+		if !ok {
+			return fmt.Errorf("fake: origin %s not found", origin)
+		}
+		if from.Size > size {
+			size = from.Size
+		}
+	}
+	if err := d.reserve(size); err != nil {
+		return err
+	}
+	volumes[id] = &Volume{ID: id, Category: category, Size: size, Origin: origin}
+	return nil
+}
+
+func (d *Driver) CreateDisk(ctx context.Context, req data.CreateDiskRequest) (resp data.CreateDiskResponse, err error) {
+	err = d.run(ctx, "CreateDisk", func() error {
+		origin := req.SnapshotId
+		if origin == "" {
+			origin = req.ImageId
+		}
+		return d.add(d.disks, req.DiskId, req.DiskCategory, req.Size, origin)
+	})
+	if err == nil {
+		resp.Wwn = "fake-" + req.DiskId
+	}
+	return resp, err
+}
+
+func (d *Driver) DeleteDisk(ctx context.Context, req data.DeleteDiskRequest) (err error) {
+	return d.run(ctx, "DeleteDisk", func() error {
+		if _, ok := d.disks[req.DiskId]; !ok {
+			return driver.ErrVolumeDoesNotExist
+		}
+		if len(d.exports[req.DiskId]) > 0 {
+			return ErrExported
+		}
+		delete(d.disks, req.DiskId)
+		delete(d.qos, req.DiskId)
+		return nil
+	})
+}
+
+func (d *Driver) CreateImage(ctx context.Context, req data.CreateImageRequest) (err error) {
+	return d.run(ctx, "CreateImage", func() error {
+		source, ok := d.disks[req.SourceDiskId]
+		if !ok {
+			return driver.ErrVolumeDoesNotExist
+		}
+		return d.add(d.images, req.ImageId, source.Category, req.Size, req.SourceDiskId)
+	})
+}
+
+func (d *Driver) DeleteImage(ctx context.Context, req data.DeleteImageRequest) (err error) {
+	return d.run(ctx, "DeleteImage", func() error {
+		if _, ok := d.images[req.ImageId]; !ok {
+			return driver.ErrVolumeDoesNotExist
+		}
+		delete(d.images, req.ImageId)
+		return nil
+	})
+}
+
+// CreateSnapshot snapshots take no capacity, like thin snapshots
+func (d *Driver) CreateSnapshot(ctx context.Context, req data.CreateSnapshotRequest) (err error) {
+	return d.run(ctx, "CreateSnapshot", func() error {
+		disk, ok := d.disks[req.DiskId]
+		if !ok {
+			return driver.ErrVolumeDoesNotExist
+		}
+		if _, ok = d.snapshots[req.SnapshotId]; ok {
+			return ErrExists
+		}
+		d.snapshots[req.SnapshotId] = &Volume{ID: req.SnapshotId, Category: disk.Category, Size: disk.Size, Origin: disk.ID}
+		return nil
+	})
+}
+
+func (d *Driver) DeleteSnapshot(ctx context.Context, req data.DeleteSnapshotRequest) (err error) {
+	return d.run(ctx, "DeleteSnapshot", func() error {
+		if _, ok := d.snapshots[req.SnapshotId]; !ok {
+			return driver.ErrVolumeDoesNotExist
+		}
+		delete(d.snapshots, req.SnapshotId)
+		return nil
+	})
+}
+
+func (d *Driver) ReInitDisk(ctx context.Context, req data.ReInitDiskRequest) (resp data.CreateDiskResponse, err error) {
+	err = d.run(ctx, "ReInitDisk", func() error {
+		disk, ok := d.disks[req.DiskId]
+		if !ok {
+			return driver.ErrVolumeDoesNotExist
+		}
+		origin := req.SnapshotId
+		if origin == "" {
+			origin = req.ImageId
+		}
+		delete(d.disks, req.DiskId)
+		if err := d.add(d.disks, req.DiskId, disk.Category, req.Size, origin); err != nil {
+			d.disks[req.DiskId] = disk
+			return err
+		}
+		return nil
+	})
+	if err == nil {
+		resp.Wwn = "fake-" + req.DiskId
+	}
+	return resp, err
+}
+
+func (d *Driver) ResetDisk(ctx context.Context, req data.ResetDiskRequest) (err error) {
+	return d.run(ctx, "ResetDisk", func() error {
+		disk, ok := d.disks[req.DiskId]
+		if !ok {
+			return driver.ErrVolumeDoesNotExist
+		}
+		snapshot, ok := d.snapshots[req.SnapshotId]
+		if !ok || snapshot.Origin != req.DiskId {
+			return fmt.Errorf("fake: snapshot %s of disk %s not found", req.SnapshotId, req.DiskId)
+		}
+		disk.Origin = snapshot.ID
+		return nil
+	})
+}
+
+func (d *Driver) ResizeDisk(ctx context.Context, req data.ResizeDiskRequest) (err error) {
+	return d.run(ctx, "ResizeDisk", func() error {
+		disk, ok := d.disks[req.DiskId]
+		if !ok {
+			return driver.ErrVolumeDoesNotExist
+		}
+		size := uint64(req.NewSize)
+		if size < disk.Size {
+			return ErrShrink
+		}
+		if err := d.reserve(size - disk.Size); err != nil {
+			return err
+		}
+		disk.Size = size
+		return nil
+	})
+}
+
+func (d *Driver) NeedExport(req data.ExportDiskRequest) (isNeed bool) {
+	return true
+}
+
+// Export hands out the lowest LUN free on the CVK, exporting again returns the same LUN
+func (d *Driver) Export(ctx context.Context, req data.ExportDiskRequest) (resp data.ExportDiskResponse, err error) {
+	err = d.run(ctx, "Export", func() error {
+		if _, ok := d.disks[req.DiskId]; !ok {
+			return driver.ErrVolumeDoesNotExist
+		}
+		if e, ok := d.exports[req.DiskId][req.Iqn]; ok {
+			resp.Lun = e.lun
+			return nil
+		}
+
+		taken := map[int]bool{}
+		for _, initiators := range d.exports {
+			for _, e := range initiators {
+				if e.cvk == req.CVKName {
+					taken[e.lun] = true
+				}
+			}
+		}
+		lun := 1
+		for taken[lun] {
+			lun++
+		}
+		if d.exports[req.DiskId] == nil {
+			d.exports[req.DiskId] = make(map[string]export)
+		}
+		d.exports[req.DiskId][req.Iqn] = export{cvk: req.CVKName, lun: lun}
+		resp.Lun = lun
+		return nil
+	})
+	if err == nil {
+		resp.Target = "fake://" + req.DiskId
+	}
+	return resp, err
+}
+
+func (d *Driver) CancelExport(ctx context.Context, req data.ExportDiskRequest) (err error) {
+	return d.run(ctx, "CancelExport", func() error {
+		delete(d.exports[req.DiskId], req.Iqn)
+		if len(d.exports[req.DiskId]) == 0 {
+			delete(d.exports, req.DiskId)
+		}
+		return nil
+	})
+}
+
+// GetSystemCapacity reports the whole capacity for every device type, the
+// categories share it
+func (d *Driver) GetSystemCapacity(ctx context.Context, req data.GetSystemCapacityRequest) (result string, err error) {
+	var capacity data.SystemCapacity
+	err = d.run(ctx, "GetSystemCapacity", func() error {
+		ssd, others := d.used()
+		device := data.DeviceCapacity{
+			TotalMiB: float64(d.capacity) / (1 << 20),
+			FreeMiB:  float64(d.capacity-ssd-others) / (1 << 20),
+		}
+		capacity = data.SystemCapacity{AllCapacity: device, FCCapacity: device, NLCapacity: device, SSDCapacity: device}
+		return nil
+	})
+	if err != nil {
+		return result, err
+	}
+
+	jsonBytes, err := json.Marshal(capacity)
+	if err != nil {
+		return result, err
+	}
+	return string(jsonBytes), nil
+}
+
+func (d *Driver) GetSystemUtilization(ctx context.Context, req data.GetSystemUtilizationRequest) (ssd, hdd float64, err error) {
+	err = d.run(ctx, "GetSystemUtilization", func() error {
+		ssdUsed, others := d.used()
+		ssd = float64(ssdUsed) / float64(d.capacity)
+		hdd = float64(others) / float64(d.capacity)
+		return nil
+	})
+	return ssd, hdd, err
+}
+
+func (d *Driver) AddDiskQoS(ctx context.Context, req data.DiskQoSRequest) (err error) {
+	return d.run(ctx, "AddDiskQoS", func() error {
+		if _, ok := d.disks[req.DiskID]; !ok {
+			return driver.ErrVolumeDoesNotExist
+		}
+		d.qos[req.DiskID] = QoS{Category: req.DiskCategory, Size: req.Size}
+		return nil
+	})
+}
+
+func (d *Driver) RemoveDiskQoS(ctx context.Context, req data.DiskQoSRequest) (err error) {
+	return d.run(ctx, "RemoveDiskQoS", func() error {
+		delete(d.qos, req.DiskID)
+		return nil
+	})
+}
+
+func (d *Driver) UpdateDiskQoS(ctx context.Context, req data.DiskQoSRequest) (err error) {
+	return d.run(ctx, "UpdateDiskQoS", func() error {
+		if _, ok := d.disks[req.DiskID]; !ok {
+			return driver.ErrVolumeDoesNotExist
+		}
+		d.qos[req.DiskID] = QoS{Category: req.DiskCategory, Size: req.Size}
+		return nil
+	})
+}
@@ -0,0 +1,90 @@
+package fake
+
+import (
+	"context"
+	"errors"
+	"immortality-demo/pkg/data"
+	"immortality-demo/pkg/driver"
+	"testing"
+	"time"
+)
+
+func TestRegistered(t *testing.T) {
+	d, err := driver.GetDriver(StorageType)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d != Default {
+		t.Fatalf("expected the default fake driver, got %T", d)
+	}
+}
+
+func TestDiskLifecycle(t *testing.T) {
+	d := New(10 << 30)
+	ctx := context.Background()
+
+	if _, err := d.CreateDisk(ctx, data.CreateDiskRequest{DiskId: "disk-1", Size: 4 << 30}); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.CreateSnapshot(ctx, data.CreateSnapshotRequest{DiskId: "disk-1", SnapshotId: "snap-1"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.CreateDisk(ctx, data.CreateDiskRequest{DiskId: "disk-2", SnapshotId: "snap-1", Size: 1 << 30}); err != nil {
+		t.Fatal(err)
+	}
+	if disk, _ := d.Disk("disk-2"); disk.Size != 4<<30 || disk.Origin != "snap-1" {
+		t.Fatalf("expected a 4GiB clone of snap-1, got %+v", disk)
+	}
+	if _, err := d.CreateDisk(ctx, data.CreateDiskRequest{DiskId: "disk-3", Size: 4 << 30}); err != ErrNoSpace {
+		t.Fatalf("expected ErrNoSpace, got %v", err)
+	}
+
+	first, err := d.Export(ctx, data.ExportDiskRequest{DiskId: "disk-1", CVKName: "cvk-1", Iqn: "iqn-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, _ := d.Export(ctx, data.ExportDiskRequest{DiskId: "disk-2", CVKName: "cvk-1", Iqn: "iqn-1"})
+	again, _ := d.Export(ctx, data.ExportDiskRequest{DiskId: "disk-1", CVKName: "cvk-1", Iqn: "iqn-1"})
+	if first.Lun != 1 || second.Lun != 2 || again.Lun != 1 {
+		t.Fatalf("unexpected LUNs %d %d %d", first.Lun, second.Lun, again.Lun)
+	}
+	if err = d.DeleteDisk(ctx, data.DeleteDiskRequest{DiskId: "disk-1"}); err != ErrExported {
+		t.Fatalf("expected ErrExported, got %v", err)
+	}
+	_ = d.CancelExport(ctx, data.ExportDiskRequest{DiskId: "disk-1", CVKName: "cvk-1", Iqn: "iqn-1"})
+	if err = d.DeleteDisk(ctx, data.DeleteDiskRequest{DiskId: "disk-1"}); err != nil {
+		t.Fatal(err)
+	}
+	if err = d.DeleteDisk(ctx, data.DeleteDiskRequest{DiskId: "disk-1"}); err != driver.ErrVolumeDoesNotExist {
+		t.Fatalf("expected ErrVolumeDoesNotExist, got %v", err)
+	}
+}
+
+func TestFaults(t *testing.T) {
+	d := New(10 << 30)
+	ctx := context.Background()
+	boom := errors.New("boom")
+
+	d.Inject("CreateDisk", Fault{Err: boom, Times: 1})
+	if _, err := d.CreateDisk(ctx, data.CreateDiskRequest{DiskId: "disk-1", Size: 1 << 30}); err != boom {
+		t.Fatalf("expected boom, got %v", err)
+	}
+	if _, ok := d.Disk("disk-1"); ok {
+		t.Fatal("disk created despite the fault")
+	}
+
+	d.Inject("CreateDisk", Fault{Err: boom, Partial: true, Times: 1})
+	if _, err := d.CreateDisk(ctx, data.CreateDiskRequest{DiskId: "disk-1", Size: 1 << 30}); err != boom {
+		t.Fatalf("expected boom, got %v", err)
+	}
+	if _, ok := d.Disk("disk-1"); !ok {
+		t.Fatal("partial failure did not create the disk")
+	}
+
+	d.Inject("ResizeDisk", Fault{Latency: time.Second})
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	if err := d.ResizeDisk(ctx, data.ResizeDiskRequest{DiskId: "disk-1", NewSize: 2 << 30}); err != context.DeadlineExceeded {
+		t.Fatalf("expected DeadlineExceeded, got %v", err)
+	}
+}
@@ -0,0 +1,124 @@
+package lvm
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"immortality-demo/pkg/logger"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// Executor runs a command and returns what it printed, tests replace it with a fake
+type Executor interface {
+	Exec(ctx context.Context, name string, args ...string) (string, error)
+}
+
+type cmdExecutor struct{}
+
+func (cmdExecutor) Exec(ctx context.Context, name string, args ...string) (string, error) {
+	logger.Log.Debug("starting execute cmd:", name, strings.Join(args, " "))
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("%s %s: %v: %s", name, strings.Join(args, " "), err, strings.TrimSpace(stderr.String()))
+	}
+	return string(output), nil
+}
+
+// lvm manages the thin volumes of one thin pool. Disks, snapshots and images
+// are all thin volumes named by their id, snapshots and images are thin
+// snapshots of a disk and new disks are writable thin snapshots of them.
+type lvm struct {
+	vg   string
+	pool string
+	exec Executor
+}
+
+func (l *lvm) lv(name string) string {
+	return l.vg + "/" + name
+}
+
+func (l *lvm) device(name string) string {
+	return "/dev/" + l.vg + "/" + name
+}
+
+func inBytes(size uint64) string {
+	return strconv.FormatUint(size, 10) + "b"
+}
+
+// create creates a blank volume of size bytes
+func (l *lvm) create(ctx context.Context, name string, size uint64) error {
+	_, err := l.exec.Exec(ctx, "lvcreate", "-y", "-T", l.lv(l.pool), "-V", inBytes(size), "-n", name)
+	return err
+}
+
+// snapshot takes a read only snapshot of origin, it is not activated
+func (l *lvm) snapshot(ctx context.Context, origin, name string) error {
+	_, err := l.exec.Exec(ctx, "lvcreate", "-y", "-s", "-pr", "-n", name, l.lv(origin))
+	return err
+}
+
+// clone creates a writable and active volume from origin
+func (l *lvm) clone(ctx context.Context, origin, name string) error {
+	_, err := l.exec.Exec(ctx, "lvcreate", "-y", "-s", "-kn", "-prw", "-n", name, l.lv(origin))
+	return err
+}
+
+func (l *lvm) remove(ctx context.Context, name string) error {
+	_, err := l.exec.Exec(ctx, "lvremove", "-y", l.lv(name))
+	return err
+}
+
+func (l *lvm) rename(ctx context.Context, name, newName string) error {
+	_, err := l.exec.Exec(ctx, "lvrename", l.vg, name, newName)
+	return err
+}
+
+// resize grows the volume to size bytes
+func (l *lvm) resize(ctx context.Context, name string, size uint64) error {
+	_, err := l.exec.Exec(ctx, "lvextend", "-L", inBytes(size), l.lv(name))
+	return err
+}
+
+// exists tells whether the volume is there
+func (l *lvm) exists(ctx context.Context, name string) (bool, error) {
+	_, err := l.exec.Exec(ctx, "lvs", "--noheadings", "-o", "lv_name", l.lv(name))
+	if err != nil && strings.Contains(err.Error(), "Failed to find logical volume") {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// size returns the size of the volume in bytes
+func (l *lvm) size(ctx context.Context, name string) (uint64, error) {
+	output, err := l.exec.Exec(ctx, "lvs", "--noheadings", "--units", "b", "--nosuffix", "-o", "lv_size", l.lv(name))
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseUint(strings.TrimSpace(output), 10, 64)
+}
+
+// usage returns the size of the thin pool in bytes and the share of it taken
+func (l *lvm) usage(ctx context.Context) (size uint64, used float64, err error) {
+	output, err := l.exec.Exec(ctx, "lvs", "--noheadings", "--units", "b", "--nosuffix",
+		"-o", "lv_size,data_percent", l.lv(l.pool))
+	if err != nil {
+		return 0, 0, err
+	}
+	fields := strings.Fields(output)
+	if len(fields) != 2 {
+		return 0, 0, fmt.Errorf("unexpected lvs output: %q", output)
+	}
+	if size, err = strconv.ParseUint(fields[0], 10, 64); err != nil {
+		return 0, 0, err
+	}
+	percent, err := strconv.ParseFloat(fields[1], 64)
+	if err != nil {
+		return 0, 0, err
+	}
+	return size, percent / 100, nil
+}
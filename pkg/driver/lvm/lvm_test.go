@@ -0,0 +1,189 @@
+package lvm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"immortality-demo/config"
+	"immortality-demo/pkg/data"
+	"immortality-demo/pkg/driver"
+	"immortality-demo/pkg/logger"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// fakeExecutor records the commands and answers them by prefix
+type fakeExecutor struct {
+	commands []string
+	replies  map[string]string
+	failures map[string]error
+}
+
+func (f *fakeExecutor) Exec(ctx context.Context, name string, args ...string) (string, error) {
+	command := strings.Join(append([]string{name}, args...), " ")
+	f.commands = append(f.commands, command)
+	for prefix, err := range f.failures {
+		if strings.HasPrefix(command, prefix) {
+			return "", err
+		}
+	}
+	for prefix, reply := range f.replies {
+		if strings.HasPrefix(command, prefix) {
+			return reply, nil
+		}
+	}
+	return "", nil
+}
+
+func newDriver(t *testing.T, exec *fakeExecutor) *LvmVolumeDriver {
+	logger.Log = logger.NewStdoutLogger(os.Stdout, "")
+	v, err := NewLvmVolumeDriver(config.LvmConfig{
+		VolumeGroup:     "vg",
+		ThinPool:        "pool",
+		Category:        data.DiskCategoryHDD,
+		Target:          "lio",
+		TargetIqnPrefix: "iqn.2020-01.test",
+		Portal:          "10.0.0.1:3260",
+	}, exec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+// missing makes lvs fail on the volumes whose name starts with prefix
+func missing(prefix string) map[string]error {
+	return map[string]error{
+		"lvs --noheadings -o lv_name vg/" + prefix: errors.New(`lvs: exit status 5: Failed to find logical volume "vg/` + prefix + `"`),
+	}
+}
+
+func TestCreateDiskFromSnapshot(t *testing.T) {
+	exec := &fakeExecutor{
+		replies:  map[string]string{"lvs --noheadings --units b --nosuffix -o lv_size vg/disk-1": "  1073741824\n"},
+		failures: missing("disk-1"),
+	}
+	v := newDriver(t, exec)
+
+	_, err := v.CreateDisk(context.Background(), data.CreateDiskRequest{DiskId: "disk-1", SnapshotId: "snap-1", Size: 2 << 30})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{
+		"lvs --noheadings -o lv_name vg/disk-1",
+		"lvcreate -y -s -kn -prw -n disk-1 vg/snap-1",
+		"lvs --noheadings --units b --nosuffix -o lv_size vg/disk-1",
+		"lvextend -L 2147483648b vg/disk-1",
+	}
+	if !reflect.DeepEqual(exec.commands, expected) {
+		t.Fatalf("expected %q, got %q", expected, exec.commands)
+	}
+}
+
+func TestCreateDiskAndResizeDiskAgain(t *testing.T) {
+	exec := &fakeExecutor{replies: map[string]string{"lvs --noheadings --units b --nosuffix -o lv_size vg/disk-1": "  2147483648\n"}}
+	v := newDriver(t, exec)
+
+	// the volume was created by an attempt which failed afterwards
+	if _, err := v.CreateDisk(context.Background(), data.CreateDiskRequest{DiskId: "disk-1", Size: 2 << 30}); err != nil {
+		t.Fatal(err)
+	}
+	if err := v.ResizeDisk(context.Background(), data.ResizeDiskRequest{DiskId: "disk-1", NewSize: 2 << 30}); err != nil {
+		t.Fatal(err)
+	}
+	for _, command := range exec.commands {
+		if strings.HasPrefix(command, "lvcreate") || strings.HasPrefix(command, "lvextend") {
+			t.Fatalf("the disk is there with its size already, got %q", exec.commands)
+		}
+	}
+}
+
+func TestResetDisk(t *testing.T) {
+	exec := &fakeExecutor{
+		replies:  map[string]string{"lvs --noheadings --units b --nosuffix -o lv_size vg/disk-1_new": "  2147483648\n"},
+		failures: missing("disk-1_"),
+	}
+	v := newDriver(t, exec)
+
+	err := v.ResetDisk(context.Background(), data.ResetDiskRequest{DiskId: "disk-1", SnapshotId: "snap-1", DiskSize: 2 << 30})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{
+		"lvs --noheadings -o lv_name vg/disk-1",
+		"lvs --noheadings -o lv_name vg/disk-1_old",
+		"lvs --noheadings -o lv_name vg/disk-1_new",
+		"lvs --noheadings -o lv_name vg/disk-1_old",
+		"lvs --noheadings -o lv_name vg/disk-1_new",
+		"lvcreate -y -s -kn -prw -n disk-1_new vg/snap-1",
+		"lvs --noheadings --units b --nosuffix -o lv_size vg/disk-1_new",
+		"lvrename vg disk-1 disk-1_old",
+		"lvrename vg disk-1_new disk-1",
+		"lvremove -y vg/disk-1_old",
+	}
+	if !reflect.DeepEqual(exec.commands, expected) {
+		t.Fatalf("expected %q, got %q", expected, exec.commands)
+	}
+
+	// a failed clone leaves the disk alone
+	exec = &fakeExecutor{failures: missing("disk-1_")}
+	exec.failures["lvcreate"] = errors.New("lvcreate: exit status 5: Insufficient free space")
+	v = newDriver(t, exec)
+	if err = v.ResetDisk(context.Background(), data.ResetDiskRequest{DiskId: "disk-1", SnapshotId: "snap-1", DiskSize: 2 << 30}); err == nil {
+		t.Fatal("expected the clone error")
+	}
+	for _, command := range exec.commands {
+		if strings.HasPrefix(command, "lvrename") || command == "lvremove -y vg/disk-1" {
+			t.Fatalf("the disk should not be touched, got %q", exec.commands)
+		}
+	}
+}
+
+func TestDeleteMissingDisk(t *testing.T) {
+	exec := &fakeExecutor{failures: map[string]error{
+		"lvs": errors.New(`lvs: exit status 5: Failed to find logical volume "vg/disk-1"`),
+	}}
+	v := newDriver(t, exec)
+
+	err := v.DeleteDisk(context.Background(), data.DeleteDiskRequest{DiskId: "disk-1"})
+	if err != driver.ErrVolumeDoesNotExist {
+		t.Fatalf("expected ErrVolumeDoesNotExist, got %v", err)
+	}
+}
+
+func TestExport(t *testing.T) {
+	exec := &fakeExecutor{failures: map[string]error{
+		"targetcli /backstores/block create": errors.New("This _Backstore already exists in configFS"),
+	}}
+	v := newDriver(t, exec)
+
+	resp, err := v.Export(context.Background(), data.ExportDiskRequest{DiskId: "disk-1", Iqn: "iqn.cvk"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Target != "iscsi://10.0.0.1:3260/iqn.2020-01.test:disk-1/0" {
+		t.Fatalf("unexpected target %s", resp.Target)
+	}
+	if last := exec.commands[len(exec.commands)-1]; last != "targetcli saveconfig" {
+		t.Fatalf("expected the config to be saved, got %s", last)
+	}
+}
+
+func TestGetSystemCapacity(t *testing.T) {
+	exec := &fakeExecutor{replies: map[string]string{"lvs": "  10737418240 25.00\n"}}
+	v := newDriver(t, exec)
+
+	result, err := v.GetSystemCapacity(context.Background(), data.GetSystemCapacityRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var capacity data.SystemCapacity
+	if err = json.Unmarshal([]byte(result), &capacity); err != nil {
+		t.Fatal(err)
+	}
+	if capacity.NLCapacity.TotalMiB != 10240 || capacity.NLCapacity.FreeMiB != 7680 {
+		t.Fatalf("unexpected capacity %+v", capacity.NLCapacity)
+	}
+}
@@ -0,0 +1,307 @@
+package lvm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"immortality-demo/config"
+	"immortality-demo/pkg/data"
+	"immortality-demo/pkg/driver"
+	"immortality-demo/pkg/logger"
+)
+
+func init() {
+	driver.Register(data.LVM, func() (driver.VolumeDriver, error) {
+		v, err := CreateLvmVolumeDriver()
+		if err != nil {
+			return nil, err
+		}
+		return v, nil
+	}, driver.CapExport|driver.CapSnapshot|driver.CapClone|driver.CapCapacity)
+}
+
+// LvmVolumeDriver keeps the disks in a local LVM thin pool, for sites without
+// 3PAR or ceph
+type LvmVolumeDriver struct {
+	lvm      *lvm
+	category string
+	// target is nil if the volumes are not exported
+	target    Target
+	iqnPrefix string
+	portal    string
+}
+
+func CreateLvmVolumeDriver() (v *LvmVolumeDriver, err error) {
+	return NewLvmVolumeDriver(config.Config.Lvm, cmdExecutor{})
+}
+
+func NewLvmVolumeDriver(conf config.LvmConfig, exec Executor) (v *LvmVolumeDriver, err error) {
+	if conf.VolumeGroup == "" || conf.ThinPool == "" {
+		return nil, fmt.Errorf("lvm volume group and thin pool must be configured")
+	}
+	target, err := newTarget(conf.Target, exec)
+	if err != nil {
+		return nil, err
+	}
+	return &LvmVolumeDriver{
+		lvm:       &lvm{vg: conf.VolumeGroup, pool: conf.ThinPool, exec: exec},
+		category:  conf.Category,
+		target:    target,
+		iqnPrefix: conf.TargetIqnPrefix,
+		portal:    conf.Portal,
+	}, nil
+}
+
+func (v *LvmVolumeDriver) CreateDisk(ctx context.Context, req data.CreateDiskRequest) (resp data.CreateDiskResponse, err error) {
+	err = v.createDisk(ctx, req.RequestId, req.DiskId, req.SnapshotId, req.ImageId, req.Size)
+	return resp, err
+}
+
+// createDisk creates the disk from the snapshot, the image or blank, and grows
+// it to size if its origin is smaller. A disk left by an attempt which failed
+// halfway is completed rather than created again.
+func (v *LvmVolumeDriver) createDisk(ctx context.Context, requestId, diskId, snapshotId, imageId string, size uint64) (err error) {
+	origin := snapshotId
+	if origin == "" {
+		origin = imageId
+	}
+	exists, err := v.lvm.exists(ctx, diskId)
+	if err != nil {
+		logger.Log.Error1(requestId, "lvm check", diskId, "error:", err)
+		return
+	}
+	switch {
+	case exists:
+		logger.Log.Info1(requestId, "lvm volume", diskId, "exists already")
+	case origin == "":
+		if err = v.lvm.create(ctx, diskId, size); err != nil {
+			logger.Log.Error1(requestId, "lvm create disk", diskId, "error:", err)
+		}
+		return
+	default:
+		if err = v.lvm.clone(ctx, origin, diskId); err != nil {
+			logger.Log.Error1(requestId, "lvm clone", origin, "to", diskId, "error:", err)
+			return
+		}
+	}
+
+	return v.grow(ctx, requestId, diskId, size)
+}
+
+// grow extends the disk to size, a disk already that large is left alone
+func (v *LvmVolumeDriver) grow(ctx context.Context, requestId, diskId string, size uint64) (err error) {
+	current, err := v.lvm.size(ctx, diskId)
+	if err != nil {
+		logger.Log.Error1(requestId, "lvm size of", diskId, "error:", err)
+		return
+	}
+	if size <= current {
+		return nil
+	}
+	if err = v.lvm.resize(ctx, diskId, size); err != nil {
+		logger.Log.Error1(requestId, "lvm resize", diskId, "error:", err)
+	}
+	return
+}
+
+// replace builds the new content of the disk in a temporary volume and swaps
+// it in, the disk is left as it was if building fails. An attempt interrupted
+// during the swap is completed by the next one.
+func (v *LvmVolumeDriver) replace(ctx context.Context, requestId, diskId string, build func(tmp string) error) (err error) {
+	tmp, old := diskId+"_new", diskId+"_old"
+	hasDisk, err := v.lvm.exists(ctx, diskId)
+	if err != nil {
+		logger.Log.Error1(requestId, "lvm check", diskId, "error:", err)
+		return
+	}
+	hasOld, err := v.lvm.exists(ctx, old)
+	if err != nil {
+		logger.Log.Error1(requestId, "lvm check", old, "error:", err)
+		return
+	}
+
+	// without the disk but with its old copy, the disk was moved aside and
+	// tmp is ready to take its place
+	if hasDisk || !hasOld {
+		// whatever a failed attempt left is not trusted
+		for _, name := range []string{tmp, old} {
+			if err = v.remove(ctx, requestId, name); err != nil && err != driver.ErrVolumeDoesNotExist {
+				return
+			}
+		}
+		if err = build(tmp); err != nil {
+			return
+		}
+		if hasDisk {
+			if err = v.lvm.rename(ctx, diskId, old); err != nil {
+				logger.Log.Error1(requestId, "lvm rename", diskId, "to", old, "error:", err)
+				return
+			}
+		}
+	}
+
+	if err = v.lvm.rename(ctx, tmp, diskId); err != nil {
+		logger.Log.Error1(requestId, "lvm rename", tmp, "to", diskId, "error:", err)
+		return
+	}
+	if hasDisk || hasOld {
+		// the disk is replaced already, the old copy only takes space
+		if err = v.lvm.remove(ctx, old); err != nil {
+			logger.Log.Error1(requestId, "lvm remove", old, "error:", err)
+		}
+	}
+	return nil
+}
+
+// remove deletes the volume, driver.ErrVolumeDoesNotExist if it is gone already
+func (v *LvmVolumeDriver) remove(ctx context.Context, requestId, name string) (err error) {
+	exists, err := v.lvm.exists(ctx, name)
+	if err != nil {
+		logger.Log.Error1(requestId, "lvm check", name, "error:", err)
+		return
+	}
+	if !exists {
+		return driver.ErrVolumeDoesNotExist
+	}
+	if err = v.lvm.remove(ctx, name); err != nil {
+		logger.Log.Error1(requestId, "lvm remove", name, "error:", err)
+	}
+	return
+}
+
+func (v *LvmVolumeDriver) DeleteDisk(ctx context.Context, req data.DeleteDiskRequest) (err error) {
+	if v.target != nil {
+		if err = v.target.Remove(ctx, v.targetIqn(req.DiskId), req.DiskId); err != nil {
+			logger.Log.Error1(req.RequestId, "remove target of", req.DiskId, "error:", err)
+			return
+		}
+	}
+	return v.remove(ctx, req.RequestId, req.DiskId)
+}
+
+func (v *LvmVolumeDriver) CreateImage(ctx context.Context, req data.CreateImageRequest) (err error) {
+	if err = v.lvm.snapshot(ctx, req.SourceDiskId, req.ImageId); err != nil {
+		logger.Log.Error1(req.RequestId, "lvm snapshot", req.SourceDiskId, "to image", req.ImageId, "error:", err)
+	}
+	return
+}
+
+func (v *LvmVolumeDriver) DeleteImage(ctx context.Context, req data.DeleteImageRequest) (err error) {
+	return v.remove(ctx, req.RequestId, req.ImageId)
+}
+
+func (v *LvmVolumeDriver) CreateSnapshot(ctx context.Context, req data.CreateSnapshotRequest) (err error) {
+	if err = v.lvm.snapshot(ctx, req.DiskId, req.SnapshotId); err != nil {
+		logger.Log.Error1(req.RequestId, "lvm snapshot", req.DiskId, "to", req.SnapshotId, "error:", err)
+	}
+	return
+}
+
+func (v *LvmVolumeDriver) DeleteSnapshot(ctx context.Context, req data.DeleteSnapshotRequest) (err error) {
+	err = v.remove(ctx, req.RequestId, req.SnapshotId)
+	if err == driver.ErrVolumeDoesNotExist {
+		return nil
+	}
+	return
+}
+
+func (v *LvmVolumeDriver) ReInitDisk(ctx context.Context, req data.ReInitDiskRequest) (resp data.CreateDiskResponse, err error) {
+	err = v.replace(ctx, req.RequestId, req.DiskId, func(tmp string) error {
+		return v.createDisk(ctx, req.RequestId, tmp, req.SnapshotId, req.ImageId, req.Size)
+	})
+	return resp, err
+}
+
+// ResetDisk rolls the disk back by replacing it with a clone of the snapshot,
+// the snapshot itself is kept
+func (v *LvmVolumeDriver) ResetDisk(ctx context.Context, req data.ResetDiskRequest) (err error) {
+	return v.replace(ctx, req.RequestId, req.DiskId, func(tmp string) error {
+		return v.createDisk(ctx, req.RequestId, tmp, req.SnapshotId, "", req.DiskSize)
+	})
+}
+
+// ResizeDisk grows the disk, a disk grown by an earlier attempt is left alone
+func (v *LvmVolumeDriver) ResizeDisk(ctx context.Context, req data.ResizeDiskRequest) (err error) {
+	return v.grow(ctx, req.RequestId, req.DiskId, uint64(req.NewSize))
+}
+
+func (v *LvmVolumeDriver) NeedExport(req data.ExportDiskRequest) (isNeed bool) {
+	return v.target != nil
+}
+
+func (v *LvmVolumeDriver) targetIqn(diskId string) string {
+	return v.iqnPrefix + ":" + diskId
+}
+
+// Export returns the iSCSI url of the disk, iscsi://portal/target/lun
+func (v *LvmVolumeDriver) Export(ctx context.Context, req data.ExportDiskRequest) (resp data.ExportDiskResponse, err error) {
+	iqn := v.targetIqn(req.DiskId)
+	err = v.target.Export(ctx, iqn, req.DiskId, v.lvm.device(req.DiskId), req.Iqn)
+	if err != nil {
+		logger.Log.Error1(req.RequestId, "export", req.DiskId, "to", req.Iqn, "error:", err)
+		return
+	}
+	resp.Lun = 0
+	resp.Target = fmt.Sprintf("iscsi://%s/%s/%d", v.portal, iqn, resp.Lun)
+	return resp, nil
+}
+
+func (v *LvmVolumeDriver) CancelExport(ctx context.Context, req data.ExportDiskRequest) (err error) {
+	err = v.target.Unexport(ctx, v.targetIqn(req.DiskId), req.Iqn)
+	if err != nil {
+		logger.Log.Error1(req.RequestId, "cancel export", req.DiskId, "to", req.Iqn, "error:", err)
+	}
+	return
+}
+
+// GetSystemCapacity reports the thin pool as a device of the configured category
+func (v *LvmVolumeDriver) GetSystemCapacity(ctx context.Context, req data.GetSystemCapacityRequest) (result string, err error) {
+	size, used, err := v.lvm.usage(ctx)
+	if err != nil {
+		logger.Log.Error("lvm usage error:", err)
+		return result, err
+	}
+
+	var capacity data.SystemCapacity
+	device := &capacity.NLCapacity
+	if v.category == data.DiskCategorySSD {
+		device = &capacity.SSDCapacity
+	}
+	for _, d := range []*data.DeviceCapacity{&capacity.AllCapacity, device} {
+		d.TotalMiB = float64(size) / (1 << 20)
+		d.FreeMiB = d.TotalMiB * (1 - used)
+	}
+
+	jsonBytes, err := json.Marshal(capacity)
+	if err != nil {
+		return result, err
+	}
+	return string(jsonBytes), nil
+}
+
+func (v *LvmVolumeDriver) GetSystemUtilization(ctx context.Context, req data.GetSystemUtilizationRequest) (ssd, hdd float64, err error) {
+	_, used, err := v.lvm.usage(ctx)
+	if err != nil {
+		logger.Log.Error1(req.RequestID, "lvm usage error:", err)
+		return ssd, hdd, err
+	}
+	if v.category == data.DiskCategorySSD {
+		return used, hdd, nil
+	}
+	return ssd, used, nil
+}
+
+func (v *LvmVolumeDriver) AddDiskQoS(ctx context.Context, req data.DiskQoSRequest) (err error) {
+	logger.Log.Warnf(req.RequestID, "LvmVolumeDriver does not support QoS")
+	return nil
+}
+
+func (v *LvmVolumeDriver) RemoveDiskQoS(ctx context.Context, req data.DiskQoSRequest) (err error) {
+	logger.Log.Warnf(req.RequestID, "LvmVolumeDriver does not support QoS")
+	return nil
+}
+
+func (v *LvmVolumeDriver) UpdateDiskQoS(ctx context.Context, req data.DiskQoSRequest) (err error) {
+	logger.Log.Warnf(req.RequestID, "LvmVolumeDriver does not support QoS")
+	return nil
+}
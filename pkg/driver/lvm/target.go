@@ -0,0 +1,85 @@
+package lvm
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// Target exports the volumes over iSCSI, each volume gets its own target with
+// the volume as LUN 0
+type Target interface {
+	// Export makes device reachable under target for the initiator
+	Export(ctx context.Context, target, name, device, initiator string) error
+	// Unexport takes the access of the initiator away
+	Unexport(ctx context.Context, target, initiator string) error
+	// Remove deletes the target and the backing of the volume
+	Remove(ctx context.Context, target, name string) error
+}
+
+func newTarget(kind string, exec Executor) (Target, error) {
+	switch kind {
+	case "":
+		return nil, nil
+	case "lio":
+		return &lio{exec: exec}, nil
+	}
+	return nil, fmt.Errorf("unknown iSCSI target backend %q", kind)
+}
+
+// lio drives the kernel target through targetcli
+type lio struct {
+	exec Executor
+}
+
+func (t *lio) run(ctx context.Context, args ...string) error {
+	_, err := t.exec.Exec(ctx, "targetcli", args...)
+	return err
+}
+
+// ignore drops the errors targetcli reports for objects which are already in
+// the wanted state, so the calls can be repeated
+func ignore(err error, messages ...string) error {
+	if err == nil {
+		return nil
+	}
+	for _, message := range messages {
+		if strings.Contains(err.Error(), message) {
+			return nil
+		}
+	}
+	return err
+}
+
+func (t *lio) Export(ctx context.Context, target, name, device, initiator string) error {
+	steps := [][]string{
+		{"/backstores/block", "create", "name=" + name, "dev=" + device},
+		{"/iscsi", "create", target},
+		{"/iscsi/" + target + "/tpg1/luns", "create", "/backstores/block/" + name, "lun=0"},
+		{"/iscsi/" + target + "/tpg1/acls", "create", initiator},
+	}
+	for _, step := range steps {
+		if err := ignore(t.run(ctx, step...), "already exists"); err != nil {
+			return err
+		}
+	}
+	return t.run(ctx, "saveconfig")
+}
+
+func (t *lio) Unexport(ctx context.Context, target, initiator string) error {
+	err := t.run(ctx, "/iscsi/"+target+"/tpg1/acls", "delete", initiator)
+	if err = ignore(err, "No such", "does not exist"); err != nil {
+		return err
+	}
+	return t.run(ctx, "saveconfig")
+}
+
+func (t *lio) Remove(ctx context.Context, target, name string) error {
+	if err := ignore(t.run(ctx, "/iscsi", "delete", target), "No such", "does not exist"); err != nil {
+		return err
+	}
+	if err := ignore(t.run(ctx, "/backstores/block", "delete", name), "No such", "does not exist"); err != nil {
+		return err
+	}
+	return t.run(ctx, "saveconfig")
+}
@@ -0,0 +1,181 @@
+package driver
+
+import (
+	"context"
+	"errors"
+	"immortality-demo/config"
+	"immortality-demo/pkg/data"
+	"immortality-demo/pkg/db_model"
+	"immortality-demo/pkg/logger"
+	"sort"
+	"sync"
+)
+
+// Capability tells which optional operations a backend implements
+type Capability uint
+
+const (
+	CapExport Capability = 1 << iota
+	CapQoS
+	CapSnapshot
+	// CapClone creates disks from snapshots and images
+	CapClone
+	CapCapacity
+	// CapCvkLun backends do not pick the LUN of an export, it is allocated
+	// among the exports of the CVK before Export is called
+	CapCvkLun
+)
+
+func (c Capability) Has(other Capability) bool {
+	return c&other == other
+}
+
+// Factory creates the driver of a backend, it is called once on first use
+type Factory func() (VolumeDriver, error)
+
+type backend struct {
+	factory Factory
+	caps    Capability
+}
+
+// Closer is implemented by the drivers that hold connections or sessions
+type Closer interface {
+	Close(ctx context.Context) error
+}
+
+// ClusterLister is implemented by the drivers whose clusters come from the
+// config rather than from 3par_info, so that the collector can record them
+type ClusterLister interface {
+	// Clusters returns the id of the cluster serving each disk category
+	Clusters() map[string]string
+}
+
+// ArrayAdder is implemented by the drivers whose arrays are registered through
+// the api, so that a new array is used without a restart
+type ArrayAdder interface {
+	AddArray(ctx context.Context, par *db_model.ThreePar) error
+}
+
+// ErrVolumeDoesNotExist is returned by the drivers when the volume is already gone
+var ErrVolumeDoesNotExist = errors.New("the volume does not exist")
+
+var (
+	lock          sync.RWMutex
+	backends      = make(map[string]backend)
+	volumeDrivers = make(map[string]VolumeDriver)
+)
+
+// Register makes a backend available under the storage type name, backends
+// call it from their init. It panics if the name is taken.
+func Register(name string, factory Factory, caps Capability) {
+	lock.Lock()
+	defer lock.Unlock()
+
+	if factory == nil {
+		panic("driver: Register factory is nil for " + name)
+	}
+	if _, ok := backends[name]; ok {
+		panic("driver: Register called twice for " + name)
+	}
+	backends[name] = backend{factory: factory, caps: caps}
+}
+
+// Backends returns the names of the registered backends
+func Backends() []string {
+	lock.RLock()
+	defer lock.RUnlock()
+
+	names := make([]string, 0, len(backends))
+	for name := range backends {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Supports tells whether the backend is registered with all of caps
+func Supports(name string, caps Capability) bool {
+	lock.RLock()
+	defer lock.RUnlock()
+
+	b, ok := backends[name]
+	return ok && b.caps.Has(caps)
+}
+
+// enabled tells whether the config turns the backend on, all registered
+// backends are on if the config lists none or is not loaded, as in tests
+func enabled(name string) bool {
+	if config.Config == nil || len(config.Config.StorageBackends) == 0 {
+		return true
+	}
+	for _, n := range config.Config.StorageBackends {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+// GetDriver returns the driver of the storage type, creating it on first use
+func GetDriver(storageType string) (d VolumeDriver, err error) {
+	lock.RLock()
+	d, ok := volumeDrivers[storageType]
+	lock.RUnlock()
+	if ok {
+		return d, nil
+	}
+
+	lock.Lock()
+	defer lock.Unlock()
+
+	// another worker may have created it meanwhile
+	if d, ok = volumeDrivers[storageType]; ok {
+		return d, nil
+	}
+	b, ok := backends[storageType]
+	if !ok || !enabled(storageType) {
+		logger.Log.Error("Wrong StorageType :", storageType)
+		return nil, data.ErrInvalidDiskType
+	}
+	d, err = b.factory()
+	if err != nil {
+		logger.Log.Error("create", storageType, "volume Driver error:", err)
+		return nil, err
+	}
+
+	volumeDrivers[storageType] = d
+	return d, nil
+}
+
+// AddArray hands a newly registered array to the driver of the storage type.
+// Nothing is done if the driver is not created yet, it reads the array from
+// the database when it is.
+func AddArray(ctx context.Context, storageType string, par *db_model.ThreePar) error {
+	lock.RLock()
+	d, ok := volumeDrivers[storageType]
+	lock.RUnlock()
+	if !ok {
+		return nil
+	}
+	a, ok := d.(ArrayAdder)
+	if !ok {
+		return data.ErrOpNotSupport
+	}
+	return a.AddArray(ctx, par)
+}
+
+// Close closes the drivers created so far, on shutdown. A later GetDriver
+// creates them again.
+func Close(ctx context.Context) {
+	lock.Lock()
+	defer lock.Unlock()
+
+	for name, d := range volumeDrivers {
+		if c, ok := d.(Closer); ok {
+			if err := c.Close(ctx); err != nil {
+				logger.Log.Error("close", name, "volume Driver error:", err)
+			}
+		}
+	}
+	volumeDrivers = make(map[string]VolumeDriver)
+}
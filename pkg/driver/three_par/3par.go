@@ -1,15 +1,17 @@
 package three_par
 
 import (
-	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"immortality-demo/pkg/driver"
 	"immortality-demo/pkg/logger"
 	"io"
 	"io/ioutil"
 	"math/rand"
 	"net/http"
+	"net/url"
 	"strconv"
 	"strings"
 	"time"
@@ -64,7 +66,7 @@ var (
 )
 
 var (
-	ErrVolumeDoesNotExist = errors.New("the volume does not exist")
+	ErrVolumeDoesNotExist = driver.ErrVolumeDoesNotExist
 )
 
 type AuthenticateBody struct {
@@ -89,6 +91,7 @@ func (h HttpResponseBody) String() string {
 type ErrCode int
 
 const (
+	NonExistentHost    ErrCode = 17
 	NonExistentVolume  ErrCode = 23
 	NonExistentQoSRule ErrCode = 100
 	HaveExistentVolume ErrCode = 104
@@ -114,6 +117,11 @@ type VolumeInfo struct {
 	Wwn          string  `json:"wwn"`
 }
 
+type VolumeSnapInfos struct {
+	Total   int              `json:"total"`
+	Members []VolumeSnapInfo `json:"members"`
+}
+
 type VolumeSnapInfo struct {
 	Id       float64 `json:"id"`
 	Name     string  `json:"name"`
@@ -123,6 +131,11 @@ type VolumeSnapInfo struct {
 	Wwn      string  `json:"wwn"`
 }
 
+type HostInfos struct {
+	Total   int        `json:"total"`
+	Members []HostInfo `json:"members"`
+}
+
 type HostInfo struct {
 	Id         float64     `json:"id"`
 	Name       string      `json:"name"`
@@ -144,6 +157,11 @@ type PortPos struct {
 	Port int `json:"port"`
 }
 
+type VlunInfos struct {
+	Total   int       `json:"total"`
+	Members []VlunIfo `json:"members"`
+}
+
 type VlunIfo struct {
 	Lun        float64 `json:"lun"`
 	VolumeName string  `json:"volumeName"`
@@ -257,58 +275,8 @@ type AtTimeVolumeSpaceResponse struct {
 	//Links       []interface{}           `json:"links"`
 }
 
-func (T *ThreeParDriver) InitSessionKey() (err error) {
-	request := AuthenticateBody{
-		User:     T.User,
-		Password: T.Password}
-	jsonBytes, err := json.Marshal(request)
-	if err != nil {
-		logger.Log.Error("Failed to marshal json:", err)
-		return
-	}
-	requestUrl := fmt.Sprintf("%s/credentials", T.ServerPath)
-	req, err := http.NewRequest("POST", requestUrl, bytes.NewReader(jsonBytes))
-	if err != nil {
-		logger.Log.Error("NewRequest error:", err)
-		return
-	}
-	req.Header.Set("Content-Type", "application/json")
-	logger.Log.Info("InitSessionKey Request to three_par:", requestUrl, string(jsonBytes))
-	resp, err := T.HttpClient.Do(req)
-	if err != nil {
-		logger.Log.Error("Failed to connect to three_par:", err)
-		return
-	}
-
-	defer resp.Body.Close()
-
-	if resp.StatusCode == 201 {
-		var response AuthenticateResponse
-		err = readJsonBody(resp.Body, &response)
-		if err != nil {
-			logger.Log.Error("ReadJsonBody error:", err)
-			return
-		}
-		if response.Key != "" {
-			T.SessionKey = response.Key
-		}
-	} else {
-		var response HttpResponseBody
-		err = readJsonBody(resp.Body, &response)
-		if err != nil {
-			logger.Log.Error("ReadJsonBody error:", err)
-			return
-		}
-
-		logger.Log.Info("Failed to authenticate,three_par response:", response.String())
-		err = errors.New("Failed to authenticate,three_par response:" + response.String())
-		return err
-	}
-	return
-}
-
 //basic methods
-func (T *ThreeParDriver) CreateVolume(requestId, volumeName, cpgName string, sizeMiB int64, optional map[string]interface{}) (err error) {
+func (T *ThreeParDriver) CreateVolume(ctx context.Context, requestId, volumeName, cpgName string, sizeMiB int64, optional map[string]interface{}) (err error) {
 	request := map[string]interface{}{"name": volumeName, "cpg": cpgName, "sizeMiB": sizeMiB}
 	if optional != nil {
 		for k, v := range optional {
@@ -321,15 +289,8 @@ func (T *ThreeParDriver) CreateVolume(requestId, volumeName, cpgName string, siz
 		return
 	}
 	requestUrl := fmt.Sprintf("%s/volumes", T.ServerPath)
-	req, err := http.NewRequest("POST", requestUrl, bytes.NewReader(jsonBytes))
-	if err != nil {
-		logger.Log.Error1(requestId, "NewRequest error:", err)
-		return
-	}
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set(sessionCookieName, T.SessionKey)
 	logger.Log.Info1(requestId, "CreateVolume Request to three_par:", requestUrl)
-	resp, err := T.HttpClient.Do(req)
+	resp, err := T.do(ctx, requestId, "POST", requestUrl, jsonBytes)
 	if err != nil {
 		logger.Log.Error1(requestId, "Failed to request three_par:", err)
 		return
@@ -338,54 +299,27 @@ func (T *ThreeParDriver) CreateVolume(requestId, volumeName, cpgName string, siz
 	defer resp.Body.Close()
 
 	if resp.StatusCode != 201 {
-		result, err := checkHttpResponseOfSessionKey(resp)
-		if result {
-			err = T.InitSessionKey()
-			if err != nil {
-				logger.Log.Error1(requestId, "Failed to init three_par:", err)
-				return err
-			}
-			err = T.CreateVolume(requestId, volumeName, cpgName, sizeMiB, optional)
-			if err != nil {
-				logger.Log.Error1(requestId, "Failed to createVolume:", err)
-				return err
-			}
-			return nil
-		} else {
-			var response HttpResponseBody
-			err = readJsonBody(resp.Body, &response)
-			if err != nil {
-				logger.Log.Error1(requestId, "ReadJsonBody error:", err)
-				return err
-			}
-
-			if response.Code == HPE3ParSystemIsBusy {
-				sleepTime := rand.Intn(5-1) + 1
-				logger.Log.Debugf(requestId, "Waiting %d seconds to CreateVolume again!", sleepTime)
-				time.Sleep(time.Duration(sleepTime) * time.Second)
-				err = T.CreateVolume(requestId, volumeName, cpgName, sizeMiB, optional)
-				if err != nil {
-					logger.Log.Error1(requestId, "Failed to createVolume:", err)
-					return err
-				}
-				return nil
-			}
-
-			if response.Code == 22 && response.Desc == "volume exists" {
-				logger.Log.Warnf(requestId, "Volume [%s] has exists", volumeName)
-				return nil
-			}
-
-			logger.Log.Error1(requestId, "Failed to createVolume,three_par response:", response.String())
-			err = errors.New("Failed to createVolume,three_par response:" + response.String())
+		var response HttpResponseBody
+		err = readJsonBody(resp.Body, &response)
+		if err != nil {
+			logger.Log.Error1(requestId, "ReadJsonBody error:", err)
 			return err
 		}
+
+		if response.Code == 22 && response.Desc == "volume exists" {
+			logger.Log.Warnf(requestId, "Volume [%s] has exists", volumeName)
+			return nil
+		}
+
+		logger.Log.Error1(requestId, "Failed to createVolume,three_par response:", response.String())
+		err = errors.New("Failed to createVolume,three_par response:" + response.String())
+		return err
 	}
 	return
 }
 
-func (T *ThreeParDriver) createVolumeFromImage(requestId, srcName, destName string, sizeMiB int64, cpgName string) (err error) {
-	image, err := T.GetVolumeByName(requestId, srcName)
+func (T *ThreeParDriver) createVolumeFromImage(ctx context.Context, requestId, srcName, destName string, sizeMiB int64, cpgName string) (err error) {
+	image, err := T.GetVolumeByName(ctx, requestId, srcName)
 	if err != nil && err != ErrVolumeDoesNotExist {
 		logger.Log.Error1(requestId, "Failed to GetVolumeByName:", err)
 		return
@@ -395,14 +329,14 @@ func (T *ThreeParDriver) createVolumeFromImage(requestId, srcName, destName stri
 		return
 	}
 
-	err = T.CreateVolume(requestId, destName, cpgName, int64(image.SizeMiB), map[string]interface{}{"snapCPG": cpgName, "tpvv": true})
+	err = T.CreateVolume(ctx, requestId, destName, cpgName, int64(image.SizeMiB), map[string]interface{}{"snapCPG": cpgName, "tpvv": true})
 	if err != nil {
 		logger.Log.Error1(requestId, "Failed to CreateVolume:", err)
 		return
 	}
 
 	optional := map[string]interface{}{"online": false, "priority": 1}
-	err = T.CloneVolume(requestId, srcName, destName, cpgName, optional)
+	err = T.CloneVolume(ctx, requestId, srcName, destName, cpgName, optional)
 	if err != nil {
 		logger.Log.Error1(requestId, "Failed to CloneVolume:", err)
 		return
@@ -410,7 +344,7 @@ func (T *ThreeParDriver) createVolumeFromImage(requestId, srcName, destName stri
 	//resize to newSize
 	amount := sizeMiB - int64(image.SizeMiB)
 	if amount > 0 {
-		err = T.GrowVolume(requestId, destName, amount)
+		err = T.GrowVolume(ctx, requestId, destName, amount)
 		if err != nil {
 			logger.Log.Error1(requestId, "Failed to GrowVolume:", err)
 			return err
@@ -419,8 +353,8 @@ func (T *ThreeParDriver) createVolumeFromImage(requestId, srcName, destName stri
 	return err
 }
 
-func (T *ThreeParDriver) createVolumeFromSnapshot(requestId, srcName, destName, cpgName string) (err error) {
-	snapshot, err := T.GetVolumeByName(requestId, srcName)
+func (T *ThreeParDriver) createVolumeFromSnapshot(ctx context.Context, requestId, srcName, destName, cpgName string) (err error) {
+	snapshot, err := T.GetVolumeByName(ctx, requestId, srcName)
 	if err != nil && err != ErrVolumeDoesNotExist {
 		logger.Log.Error1(requestId, "Failed to GetVolumeByName:", err)
 		return
@@ -430,14 +364,14 @@ func (T *ThreeParDriver) createVolumeFromSnapshot(requestId, srcName, destName,
 		return
 	}
 
-	err = T.CreateVolume(requestId, destName, cpgName, int64(snapshot.SizeMiB), map[string]interface{}{"snapCPG": cpgName, "tpvv": true})
+	err = T.CreateVolume(ctx, requestId, destName, cpgName, int64(snapshot.SizeMiB), map[string]interface{}{"snapCPG": cpgName, "tpvv": true})
 	if err != nil {
 		logger.Log.Error1(requestId, "Failed to CreateVolume:", err)
 		return
 	}
 
 	optional := map[string]interface{}{"online": false, "priority": 1}
-	err = T.CloneVolume(requestId, srcName, destName, cpgName, optional)
+	err = T.CloneVolume(ctx, requestId, srcName, destName, cpgName, optional)
 	if err != nil {
 		logger.Log.Error1(requestId, "Failed to CloneVolume:", err)
 		return
@@ -445,62 +379,10 @@ func (T *ThreeParDriver) createVolumeFromSnapshot(requestId, srcName, destName,
 	return
 }
 
-func (T *ThreeParDriver) UnAuthenticate() (err error) {
-	requestUrl := fmt.Sprintf("%s/credentials/%s", T.ServerPath, T.SessionKey)
-	req, err := http.NewRequest("DELETE", requestUrl, nil)
-	if err != nil {
-		logger.Log.Error("NewRequest error:", err)
-		return
-	}
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set(sessionCookieName, T.SessionKey)
-	logger.Log.Info("UnAuthenticate Request to three_par:", requestUrl)
-	resp, err := T.HttpClient.Do(req)
-	if err != nil {
-		logger.Log.Error("Failed to request three_par:", err)
-		return
-	}
-	if resp.StatusCode != 200 {
-		result, err := checkHttpResponseOfSessionKey(resp)
-		if result {
-			err = T.InitSessionKey()
-			if err != nil {
-				logger.Log.Error("Failed to init three_par:", err)
-				return err
-			}
-			err = T.UnAuthenticate()
-			if err != nil {
-				logger.Log.Error("Failed to UnAuthenticate:", err)
-				return err
-			}
-		} else {
-			var response HttpResponseBody
-			err = readJsonBody(resp.Body, &response)
-			if err != nil {
-				logger.Log.Error("ReadJsonBody error:", err)
-				return err
-			}
-
-			logger.Log.Info("Failed to unAuthenticate,three_par response:", response.String())
-			err = errors.New("Failed to unAuthenticate,three_par response:" + response.String())
-			return err
-		}
-	} else {
-		T.SessionKey = ""
-	}
-	return
-}
-
-func (T *ThreeParDriver) GetVolumeByName(requestId, volumeName string) (volume VolumeInfo, err error) {
+func (T *ThreeParDriver) GetVolumeByName(ctx context.Context, requestId, volumeName string) (volume VolumeInfo, err error) {
 	requestUrl := fmt.Sprintf("%s/volumes/%s", T.ServerPath, volumeName)
-	req, err := http.NewRequest("GET", requestUrl, nil)
-	if err != nil {
-		logger.Log.Error1(requestId, "NewRequest error:", err)
-		return
-	}
-	req.Header.Set(sessionCookieName, T.SessionKey)
 	logger.Log.Info1(requestId, "GetVolumeByName Request to three_par:", requestUrl)
-	resp, err := T.HttpClient.Do(req)
+	resp, err := T.do(ctx, requestId, "GET", requestUrl, nil)
 	if err != nil {
 		logger.Log.Error1(requestId, "Failed to request three_par:", err)
 		return
@@ -510,79 +392,43 @@ func (T *ThreeParDriver) GetVolumeByName(requestId, volumeName string) (volume V
 
 	switch resp.StatusCode {
 	case http.StatusOK:
-		if resp.Body != nil {
-			err = readJsonBody(resp.Body, &volume)
-			if err != nil {
-				logger.Log.Error1(requestId, "ReadJsonBody error:", err)
-				return
-			}
-			jsonBytes2, err := json.Marshal(volume)
-			if err != nil {
-				logger.Log.Error1(requestId, "Failed to marshal json:", err)
-				return volume, err
-			}
-			logger.Log.Info1(requestId, "three_par response:", string(jsonBytes2))
+		err = readJsonBody(resp.Body, &volume)
+		if err != nil {
+			logger.Log.Error1(requestId, "ReadJsonBody error:", err)
+			return
+		}
+		jsonBytes2, err := json.Marshal(volume)
+		if err != nil {
+			logger.Log.Error1(requestId, "Failed to marshal json:", err)
+			return volume, err
 		}
+		logger.Log.Info1(requestId, "three_par response:", string(jsonBytes2))
 		return volume, nil
 	case http.StatusNotFound:
 		logger.Log.Error1(requestId, "GetVolumeByName response: The volume does not exist.")
 		return volume, ErrVolumeDoesNotExist
 	default:
-		result, err := checkHttpResponseOfSessionKey(resp)
-		if result {
-			err = T.InitSessionKey()
-			if err != nil {
-				logger.Log.Error1(requestId, "Failed to init three_par:", err)
-				return volume, err
-			}
-			volume, err = T.GetVolumeByName(requestId, volumeName)
-			if err != nil {
-				logger.Log.Error1(requestId, "Failed to GetVolumeByName:", err)
-				return volume, err
-			}
-			return volume, nil
-		} else {
-			var response HttpResponseBody
-			err = readJsonBody(resp.Body, &response)
-			if err != nil {
-				logger.Log.Error1(requestId, "ReadJsonBody error:", err)
-				return volume, err
-			}
-			if response.Code == HPE3ParSystemIsBusy {
-				sleepTime := rand.Intn(5-1) + 1
-				logger.Log.Debugf(requestId, "Waiting %d seconds to GetVolumeByName again!", sleepTime)
-				time.Sleep(time.Duration(sleepTime) * time.Second)
-				volume, err = T.GetVolumeByName(requestId, volumeName)
-				if err != nil {
-					logger.Log.Error1(requestId, "Failed to GetVolumeByName:", err)
-					return volume, err
-				}
-				return volume, nil
-			} else {
-				logger.Log.Info1(requestId, "Failed to get volume,three_par response:", response.String())
-				err = errors.New("Failed to get volume,three_par response:" + response.String())
-				return volume, err
-			}
+		var response HttpResponseBody
+		err = readJsonBody(resp.Body, &response)
+		if err != nil {
+			logger.Log.Error1(requestId, "ReadJsonBody error:", err)
+			return volume, err
 		}
+		logger.Log.Info1(requestId, "Failed to get volume,three_par response:", response.String())
+		err = errors.New("Failed to get volume,three_par response:" + response.String())
+		return volume, err
 	}
 }
 
-func (T *ThreeParDriver) ModifyVolume(requestId, name string, parameters map[string]interface{}) (err error) {
+func (T *ThreeParDriver) ModifyVolume(ctx context.Context, requestId, name string, parameters map[string]interface{}) (err error) {
 	jsonBytes, err := json.Marshal(parameters)
 	if err != nil {
 		logger.Log.Error1(requestId, "Failed to marshal json:", err)
 		return
 	}
 	requestUrl := fmt.Sprintf("%s/volumes/%s", T.ServerPath, name)
-	req, err := http.NewRequest("PUT", requestUrl, bytes.NewReader(jsonBytes))
-	if err != nil {
-		logger.Log.Error1(requestId, "NewRequest error:", err)
-		return
-	}
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set(sessionCookieName, T.SessionKey)
 	logger.Log.Info1(requestId, "ModifyVolume Request to three_par:", requestUrl)
-	resp, err := T.HttpClient.Do(req)
+	resp, err := T.do(ctx, requestId, "PUT", requestUrl, jsonBytes)
 	if err != nil {
 		logger.Log.Error1(requestId, "Failed to request three_par:", err)
 		return
@@ -591,35 +437,21 @@ func (T *ThreeParDriver) ModifyVolume(requestId, name string, parameters map[str
 	defer resp.Body.Close()
 
 	if resp.StatusCode != 200 {
-		result, err := checkHttpResponseOfSessionKey(resp)
-		if result {
-			err = T.InitSessionKey()
-			if err != nil {
-				logger.Log.Error1(requestId, "Failed to init three_par:", err)
-				return err
-			}
-			err = T.ModifyVolume(requestId, name, parameters)
-			if err != nil {
-				logger.Log.Error1(requestId, "Failed to ModifyVolume:", err)
-				return err
-			}
-		} else {
-			var response HttpResponseBody
-			err = readJsonBody(resp.Body, &response)
-			if err != nil {
-				logger.Log.Error1(requestId, "ReadJsonBody error:", err)
-				return err
-			}
-
-			logger.Log.Info1(requestId, "Failed to modify volume,three_par response:", response.String())
-			err = errors.New("Failed to modify volume,three_par response:" + response.String())
+		var response HttpResponseBody
+		err = readJsonBody(resp.Body, &response)
+		if err != nil {
+			logger.Log.Error1(requestId, "ReadJsonBody error:", err)
 			return err
 		}
+
+		logger.Log.Info1(requestId, "Failed to modify volume,three_par response:", response.String())
+		err = errors.New("Failed to modify volume,three_par response:" + response.String())
+		return err
 	}
 	return
 }
 
-func (T *ThreeParDriver) GrowVolume(requestId, name string, sizeMiB int64) (err error) {
+func (T *ThreeParDriver) GrowVolume(ctx context.Context, requestId, name string, sizeMiB int64) (err error) {
 	request := map[string]interface{}{"action": GrowVolume, "sizeMiB": sizeMiB}
 	jsonBytes, err := json.Marshal(request)
 	if err != nil {
@@ -627,15 +459,8 @@ func (T *ThreeParDriver) GrowVolume(requestId, name string, sizeMiB int64) (err
 		return
 	}
 	requestUrl := fmt.Sprintf("%s/volumes/%s", T.ServerPath, name)
-	req, err := http.NewRequest("PUT", requestUrl, bytes.NewReader(jsonBytes))
-	if err != nil {
-		logger.Log.Error1(requestId, "NewRequest error:", err)
-		return
-	}
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set(sessionCookieName, T.SessionKey)
 	logger.Log.Info1(requestId, "GrowVolume Request to three_par:", requestUrl, string(jsonBytes))
-	resp, err := T.HttpClient.Do(req)
+	resp, err := T.do(ctx, requestId, "PUT", requestUrl, jsonBytes)
 	if err != nil {
 		logger.Log.Error1(requestId, "Failed to request three_par:", err)
 		return
@@ -644,35 +469,21 @@ func (T *ThreeParDriver) GrowVolume(requestId, name string, sizeMiB int64) (err
 	defer resp.Body.Close()
 
 	if resp.StatusCode != 200 {
-		result, err := checkHttpResponseOfSessionKey(resp)
-		if result {
-			err = T.InitSessionKey()
-			if err != nil {
-				logger.Log.Error1(requestId, "Failed to init three_par:", err)
-				return err
-			}
-			err = T.GrowVolume(requestId, name, sizeMiB)
-			if err != nil {
-				logger.Log.Error1(requestId, "Failed to CloneVolume:", err)
-				return err
-			}
-		} else {
-			var response HttpResponseBody
-			err = readJsonBody(resp.Body, &response)
-			if err != nil {
-				logger.Log.Error1(requestId, "ReadJsonBody error:", err)
-				return err
-			}
-
-			logger.Log.Info1(requestId, "Failed to grow volume,three_par response:", response.String())
-			err = errors.New("Failed to grow volume,three_par response:" + response.String())
+		var response HttpResponseBody
+		err = readJsonBody(resp.Body, &response)
+		if err != nil {
+			logger.Log.Error1(requestId, "ReadJsonBody error:", err)
 			return err
 		}
+
+		logger.Log.Info1(requestId, "Failed to grow volume,three_par response:", response.String())
+		err = errors.New("Failed to grow volume,three_par response:" + response.String())
+		return err
 	}
 	return
 }
 
-func (T *ThreeParDriver) CloneVolume(requestId, srcName, destName, destCpg string, optional map[string]interface{}) (err error) {
+func (T *ThreeParDriver) CloneVolume(ctx context.Context, requestId, srcName, destName, destCpg string, optional map[string]interface{}) (err error) {
 	parameters := map[string]interface{}{"destVolume": destName, "destCPG": destCpg}
 	if optional != nil {
 		for k, v := range optional {
@@ -697,15 +508,8 @@ func (T *ThreeParDriver) CloneVolume(requestId, srcName, destName, destCpg strin
 		return
 	}
 	requestUrl := fmt.Sprintf("%s/volumes/%s", T.ServerPath, srcName)
-	req, err := http.NewRequest("POST", requestUrl, bytes.NewReader(jsonBytes))
-	if err != nil {
-		logger.Log.Error1(requestId, "NewRequest error:", err)
-		return
-	}
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set(sessionCookieName, T.SessionKey)
 	logger.Log.Info1(requestId, "CloneVolume Request to three_par:", requestUrl)
-	resp, err := T.HttpClient.Do(req)
+	resp, err := T.do(ctx, requestId, "POST", requestUrl, jsonBytes)
 	if err != nil {
 		logger.Log.Error1(requestId, "Failed to request three_par:", err)
 		return
@@ -714,31 +518,16 @@ func (T *ThreeParDriver) CloneVolume(requestId, srcName, destName, destCpg strin
 	defer resp.Body.Close()
 
 	if resp.StatusCode != 201 {
-		result, err := checkHttpResponseOfSessionKey(resp)
-		if result {
-			err = T.InitSessionKey()
-			if err != nil {
-				logger.Log.Error1(requestId, "Failed to init three_par:", err)
-				return err
-			}
-			err = T.CloneVolume(requestId, srcName, destName, destCpg, optional)
-			if err != nil {
-				logger.Log.Error1(requestId, "Failed to CloneVolume:", err)
-				return err
-			}
-			return nil
-		} else {
-			var response HttpResponseBody
-			err = readJsonBody(resp.Body, &response)
-			if err != nil {
-				logger.Log.Error1(requestId, "ReadJsonBody error:", err)
-				return err
-			}
-
-			logger.Log.Info1(requestId, "Failed to clone volume,three_par response:", response.String())
-			err = errors.New("Failed to clone volume,three_par response:" + response.String())
+		var response HttpResponseBody
+		err = readJsonBody(resp.Body, &response)
+		if err != nil {
+			logger.Log.Error1(requestId, "ReadJsonBody error:", err)
 			return err
 		}
+
+		logger.Log.Info1(requestId, "Failed to clone volume,three_par response:", response.String())
+		err = errors.New("Failed to clone volume,three_par response:" + response.String())
+		return err
 	}
 	var taskId TaskId
 	if resp.Body != nil {
@@ -765,9 +554,11 @@ func (T *ThreeParDriver) CloneVolume(requestId, srcName, destName, destCpg strin
 
 			logger.Log.Debugf(requestId, "Waiting %d seconds to getTask", sleepTime)
 
-			time.Sleep(time.Duration(sleepTime) * time.Second)
+			if err = sleepContext(ctx, time.Duration(sleepTime)*time.Second); err != nil {
+				return err
+			}
 
-			task, err := T.getTask(requestId, taskId.TaskId)
+			task, err := T.getTask(ctx, requestId, taskId.TaskId)
 			if err != nil {
 				logger.Log.Error1(requestId, "Failed to getTask,", err)
 				return err
@@ -795,17 +586,17 @@ func (T *ThreeParDriver) CloneVolume(requestId, srcName, destName, destCpg strin
 	return nil
 }
 
-func (T *ThreeParDriver) DeleteVolume(requestId, name string) (err error) {
+func (T *ThreeParDriver) DeleteVolume(ctx context.Context, requestId, name string) (err error) {
 	//check for snapshots
-	volume, err := T.GetVolumeByName(requestId, name)
+	volume, err := T.GetVolumeByName(ctx, requestId, name)
 	if err != nil {
 		logger.Log.Error1(requestId, "GetVolumeByName error:", err)
 		return
 	}
-	snapshots, err := T.GetSnapshotsOfVolume(requestId, volume.SnapCPG, name)
+	snapshots, err := T.GetSnapshotsOfVolume(ctx, requestId, volume.SnapCPG, name)
 	if snapshots != nil {
 		for _, volume := range snapshots {
-			err = T.RemoveSnapshot(requestId, volume.Name)
+			err = T.RemoveSnapshot(ctx, requestId, volume.Name)
 			if err != nil {
 				logger.Log.Error1(requestId, "RemoveSnapshot error:", err)
 				return
@@ -815,14 +606,8 @@ func (T *ThreeParDriver) DeleteVolume(requestId, name string) (err error) {
 
 	//delete volume
 	requestUrl := fmt.Sprintf("%s/volumes/%s", T.ServerPath, name)
-	req, err := http.NewRequest("DELETE", requestUrl, nil)
-	if err != nil {
-		logger.Log.Error1(requestId, "NewRequest error:", err)
-		return
-	}
-	req.Header.Set(sessionCookieName, T.SessionKey)
 	logger.Log.Info1(requestId, "DeleteVolume Request to three_par:", requestUrl)
-	resp, err := T.HttpClient.Do(req)
+	resp, err := T.do(ctx, requestId, "DELETE", requestUrl, nil)
 	if err != nil {
 		logger.Log.Error1(requestId, "Failed to request three_par:", err)
 		return
@@ -831,36 +616,22 @@ func (T *ThreeParDriver) DeleteVolume(requestId, name string) (err error) {
 	defer resp.Body.Close()
 
 	if resp.StatusCode != 200 {
-		result, err := checkHttpResponseOfSessionKey(resp)
-		if result {
-			err = T.InitSessionKey()
-			if err != nil {
-				logger.Log.Error1(requestId, "Failed to init three_par:", err)
-				return err
-			}
-			err = T.DeleteVolume(requestId, name)
-			if err != nil {
-				logger.Log.Error1(requestId, "Failed to DeleteVolume:", err)
-				return err
-			}
-		} else {
-			var response HttpResponseBody
-			err = readJsonBody(resp.Body, &response)
-			if err != nil {
-				logger.Log.Error1(requestId, "ReadJsonBody error:", err)
-				return err
-			}
-
-			logger.Log.Info1(requestId, "Failed to DeleteVolume,three_par response:", response.String())
-			err = errors.New("Failed to DeleteVolume,three_par response:" + response.String())
+		var response HttpResponseBody
+		err = readJsonBody(resp.Body, &response)
+		if err != nil {
+			logger.Log.Error1(requestId, "ReadJsonBody error:", err)
 			return err
 		}
+
+		logger.Log.Info1(requestId, "Failed to DeleteVolume,three_par response:", response.String())
+		err = errors.New("Failed to DeleteVolume,three_par response:" + response.String())
+		return err
 	}
 	return
 }
 
 //snapshot methods
-func (T *ThreeParDriver) CreateVolumeSnapshot(requestId, name, copyOfName string, optional map[string]interface{}) (err error) {
+func (T *ThreeParDriver) CreateVolumeSnapshot(ctx context.Context, requestId, name, copyOfName string, optional map[string]interface{}) (err error) {
 	parameters := map[string]interface{}{"name": name}
 	if optional != nil {
 		for k, v := range optional {
@@ -874,15 +645,8 @@ func (T *ThreeParDriver) CreateVolumeSnapshot(requestId, name, copyOfName string
 		return
 	}
 	requestUrl := fmt.Sprintf("%s/volumes/%s", T.ServerPath, copyOfName)
-	req, err := http.NewRequest("POST", requestUrl, bytes.NewReader(jsonBytes))
-	if err != nil {
-		logger.Log.Error1(requestId, "NewRequest error:", err)
-		return
-	}
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set(sessionCookieName, T.SessionKey)
 	logger.Log.Info1(requestId, "CreateVolumeSnapshot Request to three_par:", requestUrl)
-	resp, err := T.HttpClient.Do(req)
+	resp, err := T.do(ctx, requestId, "POST", requestUrl, jsonBytes)
 	if err != nil {
 		logger.Log.Error1(requestId, "Failed to request three_par:", err)
 		return
@@ -891,35 +655,21 @@ func (T *ThreeParDriver) CreateVolumeSnapshot(requestId, name, copyOfName string
 	defer resp.Body.Close()
 
 	if resp.StatusCode != 201 {
-		result, err := checkHttpResponseOfSessionKey(resp)
-		if result {
-			err = T.InitSessionKey()
-			if err != nil {
-				logger.Log.Error1(requestId, "Failed to init three_par:", err)
-				return err
-			}
-			err = T.CreateVolumeSnapshot(requestId, name, copyOfName, optional)
-			if err != nil {
-				logger.Log.Error1(requestId, "Failed to CreateSnapshot:", err)
-				return err
-			}
-		} else {
-			var response HttpResponseBody
-			err = readJsonBody(resp.Body, &response)
-			if err != nil {
-				logger.Log.Error1(requestId, "ReadJsonBody error:", err)
-				return err
-			}
-
-			logger.Log.Info1(requestId, "Failed to create snapshot,three_par response:", response.String())
-			err = errors.New("Failed to create snapshot,three_par response:" + response.String())
+		var response HttpResponseBody
+		err = readJsonBody(resp.Body, &response)
+		if err != nil {
+			logger.Log.Error1(requestId, "ReadJsonBody error:", err)
 			return err
 		}
+
+		logger.Log.Info1(requestId, "Failed to create snapshot,three_par response:", response.String())
+		err = errors.New("Failed to create snapshot,three_par response:" + response.String())
+		return err
 	}
 	return
 }
 
-func (T *ThreeParDriver) PromoteVirtualCopy(requestId, snapshot string, optional map[string]interface{}) (err error) {
+func (T *ThreeParDriver) PromoteVirtualCopy(ctx context.Context, requestId, snapshot string, optional map[string]interface{}) (err error) {
 	request := map[string]interface{}{"action": PromoteVirtualCopy}
 	if optional != nil {
 		for k, v := range optional {
@@ -932,16 +682,8 @@ func (T *ThreeParDriver) PromoteVirtualCopy(requestId, snapshot string, optional
 		return
 	}
 	requestUrl := fmt.Sprintf("%s/volumes/%s", T.ServerPath, snapshot)
-	req, err := http.NewRequest("PUT", requestUrl, bytes.NewReader(jsonBytes))
-	if err != nil {
-		logger.Log.Error1(requestId, "NewRequest error:", err)
-		return
-	}
-
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set(sessionCookieName, T.SessionKey)
 	logger.Log.Info1(requestId, "PromoteVirtualCopy Request to three_par:", requestUrl)
-	resp, err := T.HttpClient.Do(req)
+	resp, err := T.do(ctx, requestId, "PUT", requestUrl, jsonBytes)
 	if err != nil {
 		logger.Log.Error1(requestId, "Failed to request three_par:", err)
 		return
@@ -950,30 +692,16 @@ func (T *ThreeParDriver) PromoteVirtualCopy(requestId, snapshot string, optional
 	defer resp.Body.Close()
 
 	if resp.StatusCode != 200 {
-		result, err := checkHttpResponseOfSessionKey(resp)
-		if result {
-			err = T.InitSessionKey()
-			if err != nil {
-				logger.Log.Error1(requestId, "Failed to init three_par:", err)
-				return err
-			}
-			err = T.PromoteVirtualCopy(requestId, snapshot, optional)
-			if err != nil {
-				logger.Log.Error1(requestId, "Failed to PromoteVirtualCopy:", err)
-				return err
-			}
-		} else {
-			var response HttpResponseBody
-			err = readJsonBody(resp.Body, &response)
-			if err != nil {
-				logger.Log.Error1(requestId, "ReadJsonBody error:", err)
-				return err
-			}
-
-			logger.Log.Info1(requestId, "Failed to promoteVirtualCopy,three_par response:", response.String())
-			err = errors.New("Failed to promoteVirtualCopy,three_par response:" + response.String())
+		var response HttpResponseBody
+		err = readJsonBody(resp.Body, &response)
+		if err != nil {
+			logger.Log.Error1(requestId, "ReadJsonBody error:", err)
 			return err
 		}
+
+		logger.Log.Info1(requestId, "Failed to promoteVirtualCopy,three_par response:", response.String())
+		err = errors.New("Failed to promoteVirtualCopy,three_par response:" + response.String())
+		return err
 	}
 	var taskId TaskId
 	if resp.Body != nil {
@@ -991,8 +719,10 @@ func (T *ThreeParDriver) PromoteVirtualCopy(requestId, snapshot string, optional
 	}
 	if taskId.TaskId != 0 {
 		for {
-			time.Sleep(1 * time.Second)
-			task, err := T.getTask(requestId, taskId.TaskId)
+			if err = sleepContext(ctx, time.Second); err != nil {
+				return err
+			}
+			task, err := T.getTask(ctx, requestId, taskId.TaskId)
 			if err != nil {
 				logger.Log.Error1(requestId, "Failed to getTask,", err)
 				return err
@@ -1008,16 +738,10 @@ func (T *ThreeParDriver) PromoteVirtualCopy(requestId, snapshot string, optional
 	return
 }
 
-func (T *ThreeParDriver) RemoveSnapshot(requestId, snapshotName string) (err error) {
+func (T *ThreeParDriver) RemoveSnapshot(ctx context.Context, requestId, snapshotName string) (err error) {
 	requestUrl := fmt.Sprintf("%s/volumes/%s", T.ServerPath, snapshotName)
-	req, err := http.NewRequest("DELETE", requestUrl, nil)
-	if err != nil {
-		logger.Log.Error1(requestId, "NewRequest error:", err)
-		return
-	}
-	req.Header.Set(sessionCookieName, T.SessionKey)
 	logger.Log.Info1(requestId, "RemoveSnapshot Request to three_par:", requestUrl)
-	resp, err := T.HttpClient.Do(req)
+	resp, err := T.do(ctx, requestId, "DELETE", requestUrl, nil)
 	if err != nil {
 		logger.Log.Error1(requestId, "Failed to request three_par:", err)
 		return
@@ -1025,47 +749,26 @@ func (T *ThreeParDriver) RemoveSnapshot(requestId, snapshotName string) (err err
 	defer resp.Body.Close()
 
 	if resp.StatusCode != 200 {
-		result, err := checkHttpResponseOfSessionKey(resp)
-		if result {
-			err = T.InitSessionKey()
-			if err != nil {
-				logger.Log.Error1(requestId, "Failed to init three_par:", err)
-				return err
-			}
-			err = T.RemoveSnapshot(requestId, snapshotName)
-			if err != nil {
-				logger.Log.Error1(requestId, "Failed to RemoveSnapshot:", err)
-				return err
-			}
-		} else {
-			var response HttpResponseBody
-			err = readJsonBody(resp.Body, &response)
-			if err != nil {
-				logger.Log.Error1(requestId, "ReadJsonBody error:", err)
-				return err
-			}
-
-			logger.Log.Info1(requestId, "Failed to remove snapshot,three_par response:", response.String())
-			err = errors.New("Failed to remove snapshot,three_par response:" + response.String())
+		var response HttpResponseBody
+		err = readJsonBody(resp.Body, &response)
+		if err != nil {
+			logger.Log.Error1(requestId, "ReadJsonBody error:", err)
 			return err
 		}
+
+		logger.Log.Info1(requestId, "Failed to remove snapshot,three_par response:", response.String())
+		err = errors.New("Failed to remove snapshot,three_par response:" + response.String())
+		return err
 	}
 	return
 }
 
-func (T *ThreeParDriver) GetSnapshotsOfVolume(requestId, snapCPG, volName string) (snaps []VolumeSnapInfo, err error) {
-	requestUrl := fmt.Sprintf("%s/volumes", T.ServerPath)
-	req, err := http.NewRequest("GET", requestUrl, nil)
-	if err != nil {
-		logger.Log.Error1(requestId, "NewRequest error:", err)
-		return
-	}
-	q := req.URL.Query()
+func (T *ThreeParDriver) GetSnapshotsOfVolume(ctx context.Context, requestId, snapCPG, volName string) (snaps []VolumeSnapInfo, err error) {
+	q := url.Values{}
 	q.Add("query", "\"copyOf\tEQ\t"+volName+"\"")
-	req.URL.RawQuery = q.Encode()
-	req.Header.Set(sessionCookieName, T.SessionKey)
+	requestUrl := fmt.Sprintf("%s/volumes?%s", T.ServerPath, q.Encode())
 	logger.Log.Info1(requestId, "GetSnapshotsOfVolume Request to three_par:", requestUrl)
-	resp, err := T.HttpClient.Do(req)
+	resp, err := T.do(ctx, requestId, "GET", requestUrl, nil)
 	if err != nil {
 		logger.Log.Error1(requestId, "Failed to request three_par:", err)
 		return
@@ -1073,71 +776,33 @@ func (T *ThreeParDriver) GetSnapshotsOfVolume(requestId, snapCPG, volName string
 	defer resp.Body.Close()
 
 	if resp.StatusCode != 200 {
-		result, err := checkHttpResponseOfSessionKey(resp)
-		if result {
-			err = T.InitSessionKey()
-			if err != nil {
-				logger.Log.Error1(requestId, "Failed to init three_par:", err)
-				return nil, err
-			}
-			snaps, err = T.GetSnapshotsOfVolume(requestId, snapCPG, volName)
-			if err != nil {
-				logger.Log.Error1(requestId, "Failed to GetSnapshotsOfVolume:", err)
-				return nil, err
-			}
-			return snaps, nil
-		} else {
-			var response HttpResponseBody
-			err = readJsonBody(resp.Body, &response)
-			if err != nil {
-				logger.Log.Error1(requestId, "ReadJsonBody error:", err)
-				return nil, err
-			}
-
-			logger.Log.Info1(requestId, "Failed to get snapshots of volume,three_par response:", response.String())
-			err = errors.New("Failed to get snapshots of volume,three_par response:" + response.String())
-			return nil, err
-		}
-	}
-	if resp.Body != nil {
-		var response map[string]interface{}
+		var response HttpResponseBody
 		err = readJsonBody(resp.Body, &response)
 		if err != nil {
 			logger.Log.Error1(requestId, "ReadJsonBody error:", err)
-			return
+			return nil, err
 		}
-		if _, ok := response["members"]; ok {
-			list, ok := response["members"].([]interface{})
-			if ok {
-				for _, v := range list {
-					var snap VolumeSnapInfo
-					jsonBytesTemp, err := json.Marshal(v)
-					if err != nil {
-						logger.Log.Error1(requestId, "Failed to marshal json:", err)
-						return nil, err
-					}
-					err = json.Unmarshal(jsonBytesTemp, &snap)
-					if err != nil {
-						return nil, err
-					}
-					if snap.CopyType == virtualCopy {
-						snaps = append(snaps, snap)
-					}
-				}
-			}
+
+		logger.Log.Info1(requestId, "Failed to get snapshots of volume,three_par response:", response.String())
+		err = errors.New("Failed to get snapshots of volume,three_par response:" + response.String())
+		return nil, err
+	}
+	var response VolumeSnapInfos
+	err = readJsonBody(resp.Body, &response)
+	if err != nil {
+		logger.Log.Error1(requestId, "ReadJsonBody error:", err)
+		return
+	}
+	for _, snap := range response.Members {
+		if snap.CopyType == virtualCopy {
+			snaps = append(snaps, snap)
 		}
-		//jsonBytes2, err := json.Marshal(response)
-		//if err != nil {
-		//	logger.Log.Error1(requestId,"Failed to marshal json:", err)
-		//	return nil, err
-		//}
-		//logger.Log.Info1(requestId,"three_par response:", string(jsonBytes2))
 	}
 	return snaps, nil
 }
 
 //HOST methods
-func (T *ThreeParDriver) CreateHost(requestId, hostName string, iscsiNames, FCWwns []string, optional map[string]interface{}) (err error) {
+func (T *ThreeParDriver) CreateHost(ctx context.Context, requestId, hostName string, iscsiNames, FCWwns []string, optional map[string]interface{}) (err error) {
 	request := map[string]interface{}{"name": hostName}
 	if iscsiNames != nil {
 		request["iSCSINames"] = iscsiNames
@@ -1156,15 +821,8 @@ func (T *ThreeParDriver) CreateHost(requestId, hostName string, iscsiNames, FCWw
 		return
 	}
 	requestUrl := fmt.Sprintf("%s/hosts", T.ServerPath)
-	req, err := http.NewRequest("POST", requestUrl, bytes.NewReader(jsonBytes))
-	if err != nil {
-		logger.Log.Error1(requestId, "NewRequest error:", err)
-		return
-	}
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set(sessionCookieName, T.SessionKey)
 	logger.Log.Info1(requestId, "CreateHost Request to three_par:", requestUrl)
-	resp, err := T.HttpClient.Do(req)
+	resp, err := T.do(ctx, requestId, "POST", requestUrl, jsonBytes)
 	if err != nil {
 		logger.Log.Error1(requestId, "Failed to request three_par:", err)
 		return
@@ -1173,50 +831,29 @@ func (T *ThreeParDriver) CreateHost(requestId, hostName string, iscsiNames, FCWw
 	defer resp.Body.Close()
 
 	if resp.StatusCode != 201 {
-		result, err := checkHttpResponseOfSessionKey(resp)
-		if result {
-			err = T.InitSessionKey()
-			if err != nil {
-				logger.Log.Error1(requestId, "Failed to init three_par:", err)
-				return err
-			}
-			err = T.CreateHost(requestId, hostName, iscsiNames, FCWwns, optional)
-			if err != nil {
-				logger.Log.Error1(requestId, "Failed to CreateHost:", err)
-				return err
-			}
-		} else {
-			var response HttpResponseBody
-			err = readJsonBody(resp.Body, &response)
-			if err != nil {
-				logger.Log.Error1(requestId, "ReadJsonBody error:", err)
-				return err
-			}
-
-			logger.Log.Info1(requestId, "Failed to create host,three_par response:", response.String())
-			err = errors.New("Failed to create host,three_par response:" + response.String())
+		var response HttpResponseBody
+		err = readJsonBody(resp.Body, &response)
+		if err != nil {
+			logger.Log.Error1(requestId, "ReadJsonBody error:", err)
 			return err
 		}
+
+		logger.Log.Info1(requestId, "Failed to create host,three_par response:", response.String())
+		err = errors.New("Failed to create host,three_par response:" + response.String())
+		return err
 	}
 	return
 }
 
-func (T *ThreeParDriver) ModifyHost(requestId, hostName string, parameters map[string]interface{}) (err error) {
+func (T *ThreeParDriver) ModifyHost(ctx context.Context, requestId, hostName string, parameters map[string]interface{}) (err error) {
 	jsonBytes, err := json.Marshal(parameters)
 	if err != nil {
 		logger.Log.Error1(requestId, "Failed to marshal json:", err)
 		return
 	}
 	requestUrl := fmt.Sprintf("%s/hosts/%s", T.ServerPath, hostName)
-	req, err := http.NewRequest("PUT", requestUrl, bytes.NewReader(jsonBytes))
-	if err != nil {
-		logger.Log.Error1(requestId, "NewRequest error:", err)
-		return
-	}
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set(sessionCookieName, T.SessionKey)
 	logger.Log.Info1(requestId, "ModifyHost Request to three_par:", requestUrl)
-	resp, err := T.HttpClient.Do(req)
+	resp, err := T.do(ctx, requestId, "PUT", requestUrl, jsonBytes)
 	if err != nil {
 		logger.Log.Error1(requestId, "Failed to request three_par:", err)
 		return
@@ -1225,37 +862,23 @@ func (T *ThreeParDriver) ModifyHost(requestId, hostName string, parameters map[s
 	defer resp.Body.Close()
 
 	if resp.StatusCode != 200 {
-		result, err := checkHttpResponseOfSessionKey(resp)
-		if result {
-			err = T.InitSessionKey()
-			if err != nil {
-				logger.Log.Error1(requestId, "Failed to init three_par:", err)
-				return err
-			}
-			err = T.ModifyHost(requestId, hostName, parameters)
-			if err != nil {
-				logger.Log.Error1(requestId, "Failed to ModifyHost:", err)
-				return err
-			}
-		} else {
-			var response HttpResponseBody
-			err = readJsonBody(resp.Body, &response)
-			if err != nil {
-				logger.Log.Error1(requestId, "ReadJsonBody error:", err)
-				return err
-			}
-
-			logger.Log.Info1(requestId, "Failed to modify host,three_par response:", response.String())
-			err = errors.New("Failed to modify host,three_par response:" + response.String())
+		var response HttpResponseBody
+		err = readJsonBody(resp.Body, &response)
+		if err != nil {
+			logger.Log.Error1(requestId, "ReadJsonBody error:", err)
 			return err
 		}
+
+		logger.Log.Info1(requestId, "Failed to modify host,three_par response:", response.String())
+		err = errors.New("Failed to modify host,three_par response:" + response.String())
+		return err
 	}
 	return
 }
 
-func (T *ThreeParDriver) DeleteHost(requestId, hostName string) (err error) {
+func (T *ThreeParDriver) DeleteHost(ctx context.Context, requestId, hostName string) (err error) {
 	//check vluns
-	host, err := T.GetHostByName(requestId, hostName)
+	host, err := T.GetHostByName(ctx, requestId, hostName)
 	if err != nil {
 		logger.Log.Error1(requestId, "GetHostByName error:", err)
 		return
@@ -1264,10 +887,10 @@ func (T *ThreeParDriver) DeleteHost(requestId, hostName string) (err error) {
 		logger.Log.Error1(requestId, "the host is not exist")
 		return
 	}
-	vluns, err := T.GetVlunsByHostname(requestId, hostName)
+	vluns, err := T.GetVlunsByHostname(ctx, requestId, hostName)
 	if vluns != nil {
 		for _, host := range vluns {
-			err = T.DeleteVLUN(requestId, host.VolumeName, host.Hostname, host.Lun)
+			err = T.DeleteVLUN(ctx, requestId, host.VolumeName, host.Hostname, host.Lun)
 			if err != nil {
 				logger.Log.Error1(requestId, "DeleteVLUN error:", err)
 				return
@@ -1276,14 +899,8 @@ func (T *ThreeParDriver) DeleteHost(requestId, hostName string) (err error) {
 	}
 	//delete host
 	requestUrl := fmt.Sprintf("%s/hosts/%s", T.ServerPath, hostName)
-	req, err := http.NewRequest("DELETE", requestUrl, nil)
-	if err != nil {
-		logger.Log.Error1(requestId, "NewRequest error:", err)
-		return
-	}
-	req.Header.Set(sessionCookieName, T.SessionKey)
 	logger.Log.Info1(requestId, "DeleteHost Request to three_par:", requestUrl)
-	resp, err := T.HttpClient.Do(req)
+	resp, err := T.do(ctx, requestId, "DELETE", requestUrl, nil)
 	if err != nil {
 		logger.Log.Error1(requestId, "Failed to request three_par:", err)
 		return
@@ -1292,44 +909,25 @@ func (T *ThreeParDriver) DeleteHost(requestId, hostName string) (err error) {
 	defer resp.Body.Close()
 
 	if resp.StatusCode != 200 {
-		result, err := checkHttpResponseOfSessionKey(resp)
-		if result {
-			err = T.InitSessionKey()
-			if err != nil {
-				logger.Log.Error1(requestId, "Failed to init three_par:", err)
-				return err
-			}
-			err = T.DeleteHost(requestId, hostName)
-			if err != nil {
-				logger.Log.Error1(requestId, "Failed to DeleteHost:", err)
-				return err
-			}
-		} else {
-			var response HttpResponseBody
-			err = readJsonBody(resp.Body, &response)
-			if err != nil {
-				logger.Log.Error1(requestId, "ReadJsonBody error:", err)
-				return err
-			}
-
-			logger.Log.Info1(requestId, "Failed to remove host,three_par response:", response.String())
-			err = errors.New("Failed to remove host,three_par response:" + response.String())
+		var response HttpResponseBody
+		err = readJsonBody(resp.Body, &response)
+		if err != nil {
+			logger.Log.Error1(requestId, "ReadJsonBody error:", err)
 			return err
 		}
+
+		logger.Log.Info1(requestId, "Failed to remove host,three_par response:", response.String())
+		err = errors.New("Failed to remove host,three_par response:" + response.String())
+		return err
 	}
 	return
 }
 
-func (T *ThreeParDriver) GetHostByName(requestId, hostName string) (host HostInfo, err error) {
+// GetHostByName returns an empty host if it does not exist
+func (T *ThreeParDriver) GetHostByName(ctx context.Context, requestId, hostName string) (host HostInfo, err error) {
 	requestUrl := fmt.Sprintf("%s/hosts/%s", T.ServerPath, hostName)
-	req, err := http.NewRequest("GET", requestUrl, nil)
-	if err != nil {
-		logger.Log.Error1(requestId, "NewRequest error:", err)
-		return
-	}
-	req.Header.Set(sessionCookieName, T.SessionKey)
 	logger.Log.Info1(requestId, "GetHostByName Request to three_par:", requestUrl)
-	resp, err := T.HttpClient.Do(req)
+	resp, err := T.do(ctx, requestId, "GET", requestUrl, nil)
 	if err != nil {
 		logger.Log.Error1(requestId, "Failed to request three_par:", err)
 		return
@@ -1337,76 +935,40 @@ func (T *ThreeParDriver) GetHostByName(requestId, hostName string) (host HostInf
 	defer resp.Body.Close()
 
 	if resp.StatusCode != 200 {
-		result, err := checkHttpResponseOfSessionKey(resp)
-		if result {
-			err = T.InitSessionKey()
-			if err != nil {
-				logger.Log.Error1(requestId, "Failed to init three_par:", err)
-				return host, err
-			}
-			host, err = T.GetHostByName(requestId, hostName)
-			if err != nil {
-				logger.Log.Error1(requestId, "Failed to GetHostByName:", err)
-				return host, err
-			}
-			return host, nil
-		} else {
-			result2, err := checkHostExist(resp)
-			if !result2 {
-				return host, nil
-			}
-			var response HttpResponseBody
-			err = readJsonBody(resp.Body, &response)
-			if err != nil {
-				logger.Log.Error1(requestId, "ReadJsonBody error:", err)
-				return host, err
-			}
-			if response.Code == HPE3ParSystemIsBusy {
-				sleepTime := rand.Intn(5-1) + 1
-				logger.Log.Debugf(requestId, "Waiting %d seconds to GetHostByName again!", sleepTime)
-				time.Sleep(time.Duration(sleepTime) * time.Second)
-				host, err = T.GetHostByName(requestId, hostName)
-				if err != nil {
-					logger.Log.Error1(requestId, "Failed to GetHostByName:", err)
-					return host, err
-				}
-				return host, nil
-			} else {
-				logger.Log.Info1(requestId, "Failed to get host,three_par response:", response.String())
-				err = errors.New("Failed to get host,three_par response:" + response.String())
-				return host, err
-			}
-		}
-	}
-	if resp.Body != nil {
-		err = readJsonBody(resp.Body, &host)
+		var response HttpResponseBody
+		err = readJsonBody(resp.Body, &response)
 		if err != nil {
 			logger.Log.Error1(requestId, "ReadJsonBody error:", err)
-			return
-		}
-		jsonBytes2, err := json.Marshal(host)
-		if err != nil {
-			logger.Log.Error1(requestId, "Failed to marshal json:", err)
 			return host, err
 		}
-		logger.Log.Info1(requestId, "three_par response:", string(jsonBytes2))
+		if resp.StatusCode == http.StatusNotFound && response.Code == NonExistentHost {
+			return host, nil
+		}
+		logger.Log.Info1(requestId, "Failed to get host,three_par response:", response.String())
+		err = errors.New("Failed to get host,three_par response:" + response.String())
+		return host, err
 	}
-	return host, nil
-}
-
-func (T *ThreeParDriver) GetHostByIqn(requestId, iqn string) (host HostInfo, err error) {
-	requestUrl := fmt.Sprintf("%s/hosts", T.ServerPath)
-	req, err := http.NewRequest("GET", requestUrl, nil)
+	err = readJsonBody(resp.Body, &host)
 	if err != nil {
-		logger.Log.Error1(requestId, "NewRequest error:", err)
+		logger.Log.Error1(requestId, "ReadJsonBody error:", err)
 		return
 	}
-	q := req.URL.Query()
+	jsonBytes2, err := json.Marshal(host)
+	if err != nil {
+		logger.Log.Error1(requestId, "Failed to marshal json:", err)
+		return host, err
+	}
+	logger.Log.Info1(requestId, "three_par response:", string(jsonBytes2))
+	return host, nil
+}
+
+// GetHostByIqn returns an empty host if no host has the iqn
+func (T *ThreeParDriver) GetHostByIqn(ctx context.Context, requestId, iqn string) (host HostInfo, err error) {
+	q := url.Values{}
 	q.Add("query", "\"iSCSIPaths[name\tEQ\t"+iqn+"]\"")
-	req.URL.RawQuery = q.Encode()
-	req.Header.Set(sessionCookieName, T.SessionKey)
+	requestUrl := fmt.Sprintf("%s/hosts?%s", T.ServerPath, q.Encode())
 	logger.Log.Info1(requestId, "GetHostByIqn Request to three_par:", requestUrl)
-	resp, err := T.HttpClient.Do(req)
+	resp, err := T.do(ctx, requestId, "GET", requestUrl, nil)
 	if err != nil {
 		logger.Log.Error1(requestId, "Failed to request three_par:", err)
 		return
@@ -1415,72 +977,40 @@ func (T *ThreeParDriver) GetHostByIqn(requestId, iqn string) (host HostInfo, err
 	defer resp.Body.Close()
 
 	if resp.StatusCode != 200 {
-		result, err := checkHttpResponseOfSessionKey(resp)
-		if result {
-			err = T.InitSessionKey()
-			if err != nil {
-				logger.Log.Error1(requestId, "Failed to init three_par:", err)
-				return host, err
-			}
-			host, err = T.GetHostByIqn(requestId, iqn)
-			if err != nil {
-				logger.Log.Error1(requestId, "Failed to GetHostByIqn:", err)
-				return host, err
-			}
-			return host, nil
-		} else {
-			result2, err := checkHttpResponseOfHostExist(resp)
-			if result2 {
-				return host, nil
-			}
-			var response HttpResponseBody
-			err = readJsonBody(resp.Body, &response)
-			if err != nil {
-				logger.Log.Error1(requestId, "ReadJsonBody error:", err)
-				return host, err
-			}
-
-			logger.Log.Info1(requestId, "Failed to get GetHostByIqn of host,three_par response:", response.String())
-			err = errors.New("Failed to get GetHostByIqn of host,three_par response:" + response.String())
-			return host, err
-		}
-	}
-	if resp.Body != nil {
-		var response map[string]interface{}
+		var response HttpResponseBody
 		err = readJsonBody(resp.Body, &response)
 		if err != nil {
 			logger.Log.Error1(requestId, "ReadJsonBody error:", err)
-			return
-		}
-		if _, ok := response["members"]; ok {
-			list, ok := response["members"].([]interface{})
-			if ok {
-				if len(list) > 0 {
-					value := list[0]
-					jsonBytesTemp, err := json.Marshal(value)
-					if err != nil {
-						logger.Log.Error1(requestId, "Failed to marshal json:", err)
-						return host, err
-					}
-					err = json.Unmarshal(jsonBytesTemp, &host)
-					if err != nil {
-						return host, err
-					}
-				}
-			}
-		}
-		jsonBytes2, err := json.Marshal(response)
-		if err != nil {
-			logger.Log.Error1(requestId, "Failed to marshal json:", err)
 			return host, err
 		}
-		logger.Log.Info1(requestId, "three_par response:", string(jsonBytes2))
+		if resp.StatusCode == http.StatusNotFound && response.Code == NonExistentHost {
+			return host, nil
+		}
+
+		logger.Log.Info1(requestId, "Failed to get GetHostByIqn of host,three_par response:", response.String())
+		err = errors.New("Failed to get GetHostByIqn of host,three_par response:" + response.String())
+		return host, err
+	}
+	var response HostInfos
+	err = readJsonBody(resp.Body, &response)
+	if err != nil {
+		logger.Log.Error1(requestId, "ReadJsonBody error:", err)
+		return
+	}
+	if len(response.Members) > 0 {
+		host = response.Members[0]
+	}
+	jsonBytes2, err := json.Marshal(response)
+	if err != nil {
+		logger.Log.Error1(requestId, "Failed to marshal json:", err)
+		return host, err
 	}
+	logger.Log.Info1(requestId, "three_par response:", string(jsonBytes2))
 	return host, nil
 }
 
 //VLUN methods
-func (T *ThreeParDriver) CreateVLUN(requestId, volumeName, hostName string, lun int8, auto bool, optional map[string]interface{}) (lunId int, err error) {
+func (T *ThreeParDriver) CreateVLUN(ctx context.Context, requestId, volumeName, hostName string, lun int8, auto bool, optional map[string]interface{}) (lunId int, err error) {
 	request := map[string]interface{}{"volumeName": volumeName, "hostname": hostName, "lun": lun}
 	if optional != nil {
 		for k, v := range optional {
@@ -1498,15 +1028,8 @@ func (T *ThreeParDriver) CreateVLUN(requestId, volumeName, hostName string, lun
 		return
 	}
 	requestUrl := fmt.Sprintf("%s/vluns", T.ServerPath)
-	req, err := http.NewRequest("POST", requestUrl, bytes.NewReader(jsonBytes))
-	if err != nil {
-		logger.Log.Error1(requestId, "NewRequest error:", err)
-		return
-	}
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set(sessionCookieName, T.SessionKey)
 	logger.Log.Info1(requestId, "CreateVLUN Request to three_par:", requestUrl)
-	resp, err := T.HttpClient.Do(req)
+	resp, err := T.do(ctx, requestId, "POST", requestUrl, jsonBytes)
 	if err != nil {
 		logger.Log.Error1(requestId, "Failed to request three_par:", err)
 		return
@@ -1514,65 +1037,33 @@ func (T *ThreeParDriver) CreateVLUN(requestId, volumeName, hostName string, lun
 
 	defer resp.Body.Close()
 
-	if resp.StatusCode == 201 {
-		location := resp.Header["Location"][0]
-		lunStr := strings.Replace(location, "/api/v1/vluns/", "", -1)
-		lunSplice := strings.Split(lunStr, ",")
-		lunId, err = strconv.Atoi(lunSplice[1])
-		return lunId, nil
-	}
 	if resp.StatusCode != 201 {
-		result, err := checkHttpResponseOfSessionKey(resp)
-		if result {
-			err = T.InitSessionKey()
-			if err != nil {
-				logger.Log.Error1(requestId, "Failed to init three_par:", err)
-				return lunId, err
-			}
-			lunId, err = T.CreateVLUN(requestId, volumeName, hostName, lun, auto, optional)
-			if err != nil {
-				logger.Log.Error1(requestId, "Failed to CreateVLUN:", err)
-				return lunId, err
-			}
-			return lunId, nil
-		} else {
-			var response HttpResponseBody
-			err = readJsonBody(resp.Body, &response)
-			if err != nil {
-				logger.Log.Error1(requestId, "ReadJsonBody error:", err)
-				return lunId, err
-			}
-
-			if response.Code == HPE3ParSystemIsBusy {
-				sleepTime := rand.Intn(5-1) + 1
-				logger.Log.Debugf(requestId, "Waiting %d seconds to CreateVLUN again!", sleepTime)
-				time.Sleep(time.Duration(sleepTime) * time.Second)
-				lunId, err = T.CreateVLUN(requestId, volumeName, hostName, lun, auto, optional)
-				if err != nil {
-					logger.Log.Error1(requestId, "Failed to CreateVLUN:", err)
-					return lunId, err
-				}
-				return lunId, nil
-			}
-
-			logger.Log.Info1(requestId, "Failed to create vlun,three_par response:", response.String())
-			err = errors.New("Failed to create vlun,three_par response:" + response.String())
+		var response HttpResponseBody
+		err = readJsonBody(resp.Body, &response)
+		if err != nil {
+			logger.Log.Error1(requestId, "ReadJsonBody error:", err)
 			return lunId, err
 		}
+
+		logger.Log.Info1(requestId, "Failed to create vlun,three_par response:", response.String())
+		err = errors.New("Failed to create vlun,three_par response:" + response.String())
+		return lunId, err
 	}
-	return
+
+	// the location of the vlun is /api/v1/vluns/volume,lun,host
+	location := resp.Header.Get("Location")
+	lunStr := strings.Replace(location, "/api/v1/vluns/", "", -1)
+	lunSplice := strings.Split(lunStr, ",")
+	if len(lunSplice) < 2 {
+		return lunId, errors.New("Failed to create vlun,unexpected location:" + location)
+	}
+	return strconv.Atoi(lunSplice[1])
 }
 
-func (T *ThreeParDriver) DeleteVLUN(requestId, volumeName, hostName string, lunId float64) (err error) {
+func (T *ThreeParDriver) DeleteVLUN(ctx context.Context, requestId, volumeName, hostName string, lunId float64) (err error) {
 	requestUrl := fmt.Sprintf("%s/vluns/%s,%d,%s", T.ServerPath, volumeName, int64(lunId), hostName)
-	req, err := http.NewRequest("DELETE", requestUrl, nil)
-	if err != nil {
-		logger.Log.Error1(requestId, "NewRequest error:", err)
-		return
-	}
-	req.Header.Set(sessionCookieName, T.SessionKey)
 	logger.Log.Info1(requestId, "DeleteVLUN Request to three_par:", requestUrl)
-	resp, err := T.HttpClient.Do(req)
+	resp, err := T.do(ctx, requestId, "DELETE", requestUrl, nil)
 	if err != nil {
 		logger.Log.Error1(requestId, "Failed to request three_par:", err)
 		return
@@ -1581,63 +1072,30 @@ func (T *ThreeParDriver) DeleteVLUN(requestId, volumeName, hostName string, lunI
 	defer resp.Body.Close()
 
 	if resp.StatusCode != 200 {
-		result, err := checkHttpResponseOfSessionKey(resp)
-		if result {
-			err = T.InitSessionKey()
-			if err != nil {
-				logger.Log.Error1(requestId, "Failed to init three_par:", err)
-				return err
-			}
-			err = T.DeleteVLUN(requestId, volumeName, hostName, lunId)
-			if err != nil {
-				logger.Log.Error1(requestId, "Failed to DeleteVLUN:", err)
-				return err
-			}
-		} else {
-			var response HttpResponseBody
-			err = readJsonBody(resp.Body, &response)
-			if err != nil {
-				logger.Log.Error1(requestId, "ReadJsonBody error:", err)
-				return err
-			}
-			if response.Code == 19 && response.Desc == "VLUN does not exist" {
-				logger.Log.Warn("VLUN does not exist: ", response.String())
-				return err
-			}
-
-			if response.Code == HPE3ParSystemIsBusy {
-				sleepTime := rand.Intn(5-1) + 1
-				logger.Log.Debugf(requestId, "Waiting %d seconds to DeleteVLUN again!", sleepTime)
-				time.Sleep(time.Duration(sleepTime) * time.Second)
-				err = T.DeleteVLUN(requestId, volumeName, hostName, lunId)
-				if err != nil {
-					logger.Log.Error1(requestId, "Failed to DeleteVLUN:", err)
-					return err
-				}
-				return err
-			}
-
-			logger.Log.Info1(requestId, "Failed to remove VLUN,three_par response:", response.String())
-			err = errors.New("Failed to remove VLUN,three_par response:" + response.String())
+		var response HttpResponseBody
+		err = readJsonBody(resp.Body, &response)
+		if err != nil {
+			logger.Log.Error1(requestId, "ReadJsonBody error:", err)
 			return err
 		}
+		if response.Code == 19 && response.Desc == "VLUN does not exist" {
+			logger.Log.Warn("VLUN does not exist: ", response.String())
+			return nil
+		}
+
+		logger.Log.Info1(requestId, "Failed to remove VLUN,three_par response:", response.String())
+		err = errors.New("Failed to remove VLUN,three_par response:" + response.String())
+		return err
 	}
 	return
 }
 
-func (T *ThreeParDriver) GetVlunsByHostname(requestId, hostName string) (vluns []VlunIfo, err error) {
-	requestUrl := fmt.Sprintf("%s/vluns", T.ServerPath)
-	req, err := http.NewRequest("GET", requestUrl, nil)
-	if err != nil {
-		logger.Log.Error1(requestId, "NewRequest error:", err)
-		return
-	}
-	q := req.URL.Query()
+func (T *ThreeParDriver) GetVlunsByHostname(ctx context.Context, requestId, hostName string) (vluns []VlunIfo, err error) {
+	q := url.Values{}
 	q.Add("query", "\"hostname\tEQ\t"+hostName+"\"")
-	req.URL.RawQuery = q.Encode()
-	req.Header.Set(sessionCookieName, T.SessionKey)
+	requestUrl := fmt.Sprintf("%s/vluns?%s", T.ServerPath, q.Encode())
 	logger.Log.Info1(requestId, "GetVlunsByHostname Request to three_par:", requestUrl)
-	resp, err := T.HttpClient.Do(req)
+	resp, err := T.do(ctx, requestId, "GET", requestUrl, nil)
 	if err != nil {
 		logger.Log.Error1(requestId, "Failed to request three_par:", err)
 		return
@@ -1645,69 +1103,28 @@ func (T *ThreeParDriver) GetVlunsByHostname(requestId, hostName string) (vluns [
 	defer resp.Body.Close()
 
 	if resp.StatusCode != 200 {
-		result, err := checkHttpResponseOfSessionKey(resp)
-		if result {
-			err = T.InitSessionKey()
-			if err != nil {
-				logger.Log.Error1(requestId, "Failed to init three_par:", err)
-				return nil, err
-			}
-			vluns, err = T.GetVlunsByHostname(requestId, hostName)
-			if err != nil {
-				logger.Log.Error1(requestId, "Failed to GetVlunsByHostname:", err)
-				return nil, err
-			}
-			return vluns, nil
-		} else {
-			var response HttpResponseBody
-			err = readJsonBody(resp.Body, &response)
-			if err != nil {
-				logger.Log.Error1(requestId, "ReadJsonBody error:", err)
-				return nil, err
-			}
-
-			logger.Log.Info1(requestId, "Failed to get vluns of host,three_par response:", response.String())
-			err = errors.New("Failed to get vluns of host,three_par response:" + response.String())
-			return nil, err
-		}
-	}
-	if resp.Body != nil {
-		var response map[string]interface{}
+		var response HttpResponseBody
 		err = readJsonBody(resp.Body, &response)
 		if err != nil {
 			logger.Log.Error1(requestId, "ReadJsonBody error:", err)
-			return
-		}
-		if _, ok := response["members"]; ok {
-			list, ok := response["members"].([]interface{})
-			if ok {
-				for _, v := range list {
-					var vlun VlunIfo
-					jsonBytesTemp, err := json.Marshal(v)
-					if err != nil {
-						logger.Log.Error1(requestId, "Failed to marshal json:", err)
-						return nil, err
-					}
-					err = json.Unmarshal(jsonBytesTemp, &vlun)
-					if err != nil {
-						return nil, err
-					}
-					vluns = append(vluns, vlun)
-				}
-			}
+			return nil, err
 		}
-		//jsonBytes2, err := json.Marshal(response)
-		//if err != nil {
-		//	logger.Log.Error1(requestId,"Failed to marshal json:", err)
-		//	return nil, err
-		//}
-		//logger.Log.Info1(requestId,"three_par response:", string(jsonBytes2))
-	}
-	return vluns, nil
+
+		logger.Log.Info1(requestId, "Failed to get vluns of host,three_par response:", response.String())
+		err = errors.New("Failed to get vluns of host,three_par response:" + response.String())
+		return nil, err
+	}
+	var response VlunInfos
+	err = readJsonBody(resp.Body, &response)
+	if err != nil {
+		logger.Log.Error1(requestId, "ReadJsonBody error:", err)
+		return
+	}
+	return response.Members, nil
 }
 
-//func (T *ThreeParDriver) GetVlun(requestId, hostName, volumeName string) (vlun VlunIfo, err error) {
-//	vluns, err := T.GetVlunsByHostname(requestId, hostName)
+//func (T *ThreeParDriver) GetVlun(ctx context.Context, requestId, hostName, volumeName string) (vlun VlunIfo, err error) {
+//	vluns, err := T.GetVlunsByHostname(ctx, requestId, hostName)
 //	if err != nil {
 //		logger.Log.Error1(requestId, "Failed to GetVlunsByHostname,", err)
 //		return vlun, err
@@ -1724,7 +1141,7 @@ func (T *ThreeParDriver) GetVlunsByHostname(requestId, hostName string) (vluns [
 //}
 
 //volumeSet methods
-func (T *ThreeParDriver) CreateVolumeSet(requestId, name, domain, comment string, setMembers []string) (err error) {
+func (T *ThreeParDriver) CreateVolumeSet(ctx context.Context, requestId, name, domain, comment string, setMembers []string) (err error) {
 	request := map[string]interface{}{"name": name}
 	if domain != "" {
 		request["domain"] = domain
@@ -1741,15 +1158,8 @@ func (T *ThreeParDriver) CreateVolumeSet(requestId, name, domain, comment string
 		return
 	}
 	requestUrl := fmt.Sprintf("%s/volumesets", T.ServerPath)
-	req, err := http.NewRequest("POST", requestUrl, bytes.NewReader(jsonBytes))
-	if err != nil {
-		logger.Log.Error1(requestId, "NewRequest error:", err)
-		return
-	}
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set(sessionCookieName, T.SessionKey)
 	logger.Log.Info1(requestId, "CreateVolumeSet Request to three_par:", requestUrl)
-	resp, err := T.HttpClient.Do(req)
+	resp, err := T.do(ctx, requestId, "POST", requestUrl, jsonBytes)
 	if err != nil {
 		logger.Log.Error1(requestId, "Failed to request three_par:", err)
 		return
@@ -1758,48 +1168,28 @@ func (T *ThreeParDriver) CreateVolumeSet(requestId, name, domain, comment string
 	defer resp.Body.Close()
 
 	if resp.StatusCode != 201 {
-		result, err := checkHttpResponseOfSessionKey(resp)
-		if result {
-			err = T.InitSessionKey()
-			if err != nil {
-				logger.Log.Error1(requestId, "Failed to init three_par:", err)
-				return err
-			}
-			err = T.CreateVolumeSet(requestId, name, domain, comment, setMembers)
-			if err != nil {
-				logger.Log.Error1(requestId, "Failed to CreateVolumeSet:", err)
-				return err
-			}
-		} else {
-			var response HttpResponseBody
-			err = readJsonBody(resp.Body, &response)
-			if err != nil {
-				logger.Log.Error1(requestId, "ReadJsonBody error:", err)
-				return err
-			}
-			if response.Code == 101 && response.Desc == "Set exists" {
-				logger.Log.Warnf(requestId, "Volume set %s has exits", name)
-				return nil
-			}
-
-			logger.Log.Info1(requestId, "Failed to create volumeSet,three_par response:", response.String())
-			err = errors.New("Failed to create volumeSet,three_par response:" + response.String())
+		var response HttpResponseBody
+		err = readJsonBody(resp.Body, &response)
+		if err != nil {
+			logger.Log.Error1(requestId, "ReadJsonBody error:", err)
 			return err
 		}
+		if response.Code == 101 && response.Desc == "Set exists" {
+			logger.Log.Warnf(requestId, "Volume set %s has exits", name)
+			return nil
+		}
+
+		logger.Log.Info1(requestId, "Failed to create volumeSet,three_par response:", response.String())
+		err = errors.New("Failed to create volumeSet,three_par response:" + response.String())
+		return err
 	}
 	return
 }
 
-func (T *ThreeParDriver) DeleteVolumeSet(requestId, name string) (err error) {
+func (T *ThreeParDriver) DeleteVolumeSet(ctx context.Context, requestId, name string) (err error) {
 	requestUrl := fmt.Sprintf("%s/volumesets/%s", T.ServerPath, name)
-	req, err := http.NewRequest("DELETE", requestUrl, nil)
-	if err != nil {
-		logger.Log.Error1(requestId, "NewRequest error:", err)
-		return
-	}
-	req.Header.Set(sessionCookieName, T.SessionKey)
 	logger.Log.Info1(requestId, "DeleteVolumeSet Request to three_par:", requestUrl)
-	resp, err := T.HttpClient.Do(req)
+	resp, err := T.do(ctx, requestId, "DELETE", requestUrl, nil)
 	if err != nil {
 		logger.Log.Error1(requestId, "Failed to request three_par:", err)
 		return
@@ -1811,20 +1201,6 @@ func (T *ThreeParDriver) DeleteVolumeSet(requestId, name string) (err error) {
 		return
 	case http.StatusNotFound:
 		return ErrSetDoesNotExist
-	case http.StatusForbidden:
-		result, err := checkHttpResponseOfSessionKey(resp)
-		if result {
-			err = T.InitSessionKey()
-			if err != nil {
-				logger.Log.Error1(requestId, "Failed to init three_par:", err)
-				return err
-			}
-			err = T.DeleteVolumeSet(requestId, name)
-			if err != nil {
-				logger.Log.Error1(requestId, "Failed to DeleteVolumeSet:", err)
-				return err
-			}
-		}
 	}
 
 	var response HttpResponseBody
@@ -1839,91 +1215,47 @@ func (T *ThreeParDriver) DeleteVolumeSet(requestId, name string) (err error) {
 	return err
 }
 
-func (T *ThreeParDriver) ModifyVolumeSet(requestId, name string, parameters map[string]interface{}) (err error) {
+func (T *ThreeParDriver) ModifyVolumeSet(ctx context.Context, requestId, name string, parameters map[string]interface{}) (err error) {
 	jsonBytes, err := json.Marshal(parameters)
 	if err != nil {
 		logger.Log.Error1(requestId, "Failed to marshal json:", err)
 		return
 	}
 	requestUrl := fmt.Sprintf("%s/volumesets/%s", T.ServerPath, name)
-	req, err := http.NewRequest("PUT", requestUrl, bytes.NewReader(jsonBytes))
-	if err != nil {
-		logger.Log.Error1(requestId, "NewRequest error:", err)
-		return
-	}
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set(sessionCookieName, T.SessionKey)
 	logger.Log.Info1(requestId, "ModifyVolumeSet Request to three_par:", requestUrl)
-	resp, err := T.HttpClient.Do(req)
+	resp, err := T.do(ctx, requestId, "PUT", requestUrl, jsonBytes)
 	if err != nil {
 		logger.Log.Error1(requestId, "Failed to request three_par:", err)
 		return
 	}
 	defer resp.Body.Close()
 
-	switch resp.StatusCode {
-	case http.StatusOK:
+	if resp.StatusCode == http.StatusOK {
 		return
-	case http.StatusForbidden:
-		result, err := checkHttpResponseOfSessionKey(resp)
-		if result {
-			err = T.InitSessionKey()
-			if err != nil {
-				logger.Log.Error1(requestId, "Failed to init three_par:", err)
-				return err
-			}
-			err = T.ModifyVolumeSet(requestId, name, parameters)
-			if err != nil {
-				logger.Log.Error1(requestId, "Failed to ModifyVolumeSet:", err)
-				return err
-			}
-			return err
-		}
-	default:
-		var response HttpResponseBody
-
-		err = readJsonBody(resp.Body, &response)
-		if err != nil {
-			logger.Log.Error1(requestId, "ReadJsonBody error:", err)
-			return err
-		}
-
-		if response.Code == HPE3ParSystemIsBusy {
-			sleepTime := rand.Intn(5-1) + 1
-			logger.Log.Debugf(requestId, "Waiting %d seconds to ModifyVolumeSet again!", sleepTime)
-			time.Sleep(time.Duration(sleepTime) * time.Second)
-			err = T.ModifyVolumeSet(requestId, name, parameters)
-			if err != nil {
-				logger.Log.Error1(requestId, "Failed to ModifyVolumeSet:", err)
-				return err
-			}
-			return err
-		}
+	}
 
-		switch response.Code {
-		case NonExistentVolume:
-			return ErrVolumeNotInSet
-		case HaveExistentVolume:
-			return ErrVolumeHasInSet
-		default:
-			logger.Log.Error1(requestId, "Failed to modify volumeset, three_par response:", response.String())
-			return errors.New("Failed to modify volumeset,three_par response:" + response.String())
-		}
+	var response HttpResponseBody
+	err = readJsonBody(resp.Body, &response)
+	if err != nil {
+		logger.Log.Error1(requestId, "ReadJsonBody error:", err)
+		return err
 	}
 
-	return
+	switch response.Code {
+	case NonExistentVolume:
+		return ErrVolumeNotInSet
+	case HaveExistentVolume:
+		return ErrVolumeHasInSet
+	default:
+		logger.Log.Error1(requestId, "Failed to modify volumeset, three_par response:", response.String())
+		return errors.New("Failed to modify volumeset,three_par response:" + response.String())
+	}
 }
 
-func (T *ThreeParDriver) GetVolumeSet(requestId, name string) (volumeSet VolumeSetInfo, err error) {
+func (T *ThreeParDriver) GetVolumeSet(ctx context.Context, requestId, name string) (volumeSet VolumeSetInfo, err error) {
 	requestUrl := fmt.Sprintf("%s/volumesets/%s", T.ServerPath, name)
-	req, err := http.NewRequest("GET", requestUrl, nil)
-	if err != nil {
-		logger.Log.Error1(requestId, "NewRequest error:", err)
-		return
-	}
-	req.Header.Set(sessionCookieName, T.SessionKey)
 	logger.Log.Info1(requestId, "GetVolumeSet Request to three_par:", requestUrl)
-	resp, err := T.HttpClient.Do(req)
+	resp, err := T.do(ctx, requestId, "GET", requestUrl, nil)
 	if err != nil {
 		logger.Log.Error1(requestId, "Failed to request three_par:", err)
 		return
@@ -1931,50 +1263,33 @@ func (T *ThreeParDriver) GetVolumeSet(requestId, name string) (volumeSet VolumeS
 	defer resp.Body.Close()
 
 	if resp.StatusCode != 200 {
-		result, err := checkHttpResponseOfSessionKey(resp)
-		if result {
-			err = T.InitSessionKey()
-			if err != nil {
-				logger.Log.Error1(requestId, "Failed to init three_par:", err)
-				return volumeSet, err
-			}
-			volumeSet, err = T.GetVolumeSet(requestId, name)
-			if err != nil {
-				logger.Log.Error1(requestId, "Failed to GetVolumeSet:", err)
-				return volumeSet, err
-			}
-			return volumeSet, nil
-		} else {
-			var response HttpResponseBody
-			err = readJsonBody(resp.Body, &response)
-			if err != nil {
-				logger.Log.Error1(requestId, "ReadJsonBody error:", err)
-				return volumeSet, err
-			}
-
-			logger.Log.Info1(requestId, "Failed to get volumeset,three_par response:", response.String())
-			err = errors.New("Failed to get volumeset,three_par response:" + response.String())
-			return volumeSet, err
-		}
-	}
-	if resp.Body != nil {
-		err = readJsonBody(resp.Body, &volumeSet)
+		var response HttpResponseBody
+		err = readJsonBody(resp.Body, &response)
 		if err != nil {
 			logger.Log.Error1(requestId, "ReadJsonBody error:", err)
-			return
-		}
-		jsonBytes2, err := json.Marshal(volumeSet)
-		if err != nil {
-			logger.Log.Error1(requestId, "Failed to marshal json:", err)
 			return volumeSet, err
 		}
-		logger.Log.Info1(requestId, "three_par response:", string(jsonBytes2))
+
+		logger.Log.Info1(requestId, "Failed to get volumeset,three_par response:", response.String())
+		err = errors.New("Failed to get volumeset,three_par response:" + response.String())
+		return volumeSet, err
+	}
+	err = readJsonBody(resp.Body, &volumeSet)
+	if err != nil {
+		logger.Log.Error1(requestId, "ReadJsonBody error:", err)
+		return
 	}
+	jsonBytes2, err := json.Marshal(volumeSet)
+	if err != nil {
+		logger.Log.Error1(requestId, "Failed to marshal json:", err)
+		return volumeSet, err
+	}
+	logger.Log.Info1(requestId, "three_par response:", string(jsonBytes2))
 	return volumeSet, nil
 }
 
 //QoS Priority Optimization methods
-func (T *ThreeParDriver) CreateQoSRules(requestId, targetName string, targetType int8, qosRules map[string]interface{}) (err error) {
+func (T *ThreeParDriver) CreateQoSRules(ctx context.Context, requestId, targetName string, targetType int8, qosRules map[string]interface{}) (err error) {
 	request := map[string]interface{}{"name": targetName, "type": targetType}
 	if qosRules != nil {
 		for k, v := range qosRules {
@@ -1987,15 +1302,8 @@ func (T *ThreeParDriver) CreateQoSRules(requestId, targetName string, targetType
 		return
 	}
 	requestUrl := fmt.Sprintf("%s/qos", T.ServerPath)
-	req, err := http.NewRequest("POST", requestUrl, bytes.NewReader(jsonBytes))
-	if err != nil {
-		logger.Log.Error1(requestId, "NewRequest error:", err)
-		return
-	}
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set(sessionCookieName, T.SessionKey)
 	logger.Log.Info1(requestId, "CreateQoSRules Request to three_par:", requestUrl)
-	resp, err := T.HttpClient.Do(req)
+	resp, err := T.do(ctx, requestId, "POST", requestUrl, jsonBytes)
 	if err != nil {
 		logger.Log.Error1(requestId, "Failed to request three_par:", err)
 		return
@@ -2003,144 +1311,73 @@ func (T *ThreeParDriver) CreateQoSRules(requestId, targetName string, targetType
 	defer resp.Body.Close()
 
 	if resp.StatusCode != 201 {
-		result, err := checkHttpResponseOfSessionKey(resp)
-		if result {
-			err = T.InitSessionKey()
-			if err != nil {
-				logger.Log.Error1(requestId, "Failed to init three_par:", err)
-				return err
-			}
-			err = T.CreateQoSRules(requestId, targetName, targetType, qosRules)
-			if err != nil {
-				logger.Log.Error1(requestId, "Failed to CreateQoSRules:", err)
-				return err
-			}
-		} else {
-			var response HttpResponseBody
-			err = readJsonBody(resp.Body, &response)
-			if err != nil {
-				logger.Log.Error1(requestId, "ReadJsonBody error:", err)
-				return err
-			}
-
-			logger.Log.Info1(requestId, "Failed to create QoSRules,three_par response:", response.String())
+		var response HttpResponseBody
+		err = readJsonBody(resp.Body, &response)
+		if err != nil {
+			logger.Log.Error1(requestId, "ReadJsonBody error:", err)
+			return err
+		}
 
-			if response.Code == ExistentQoSRule {
-				return ErrQoSRuleExistent
-			}
+		logger.Log.Info1(requestId, "Failed to create QoSRules,three_par response:", response.String())
 
-			err = errors.New("Failed to create QoSRules,three_par response:" + response.String())
-			return err
+		if response.Code == ExistentQoSRule {
+			return ErrQoSRuleExistent
 		}
+
+		err = errors.New("Failed to create QoSRules,three_par response:" + response.String())
+		return err
 	}
 	return
 }
 
-func (T *ThreeParDriver) ModifyQoSRules(requestId, targetName string, targetType string, qosRules map[string]interface{}) (err error) {
+func (T *ThreeParDriver) ModifyQoSRules(ctx context.Context, requestId, targetName string, targetType string, qosRules map[string]interface{}) (err error) {
 	jsonBytes, err := json.Marshal(qosRules)
 	if err != nil {
 		logger.Log.Error1(requestId, "Failed to marshal json:", err)
 		return
 	}
 	requestUrl := fmt.Sprintf("%s/qos/%s:%s", T.ServerPath, targetType, targetName)
-	req, err := http.NewRequest("PUT", requestUrl, bytes.NewReader(jsonBytes))
-	if err != nil {
-		logger.Log.Error1(requestId, "NewRequest error:", err)
-		return
-	}
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set(sessionCookieName, T.SessionKey)
 	logger.Log.Info1(requestId, "ModifyQoSRules Request to three_par:", requestUrl)
-	resp, err := T.HttpClient.Do(req)
+	resp, err := T.do(ctx, requestId, "PUT", requestUrl, jsonBytes)
 	if err != nil {
 		logger.Log.Error1(requestId, "Failed to request three_par:", err)
 		return
 	}
 	defer resp.Body.Close()
 
-	switch resp.StatusCode {
-	case http.StatusOK:
+	if resp.StatusCode == http.StatusOK {
 		return
-	case http.StatusForbidden:
-		result, err := checkHttpResponseOfSessionKey(resp)
-		if result {
-			err = T.InitSessionKey()
-			if err != nil {
-				logger.Log.Error1(requestId, "Failed to init three_par:", err)
-				return err
-			}
-			err = T.ModifyQoSRules(requestId, targetName, targetType, qosRules)
-			if err != nil {
-				logger.Log.Error1(requestId, "Failed to ModifyQoSRules:", err)
-				return err
-			}
-			return err
-		}
-	default:
-		var response HttpResponseBody
-
-		err = readJsonBody(resp.Body, &response)
-		if err != nil {
-			logger.Log.Error1(requestId, "ReadJsonBody error:", err)
-			return err
-		}
+	}
 
-		switch response.Code {
-		case HPE3ParSystemIsBusy:
-			sleepTime := rand.Intn(5-1) + 1
-			logger.Log.Debugf(requestId, "Waiting %d seconds to ModifyQoSRules again!", sleepTime)
-			time.Sleep(time.Duration(sleepTime) * time.Second)
-			err = T.ModifyQoSRules(requestId, targetName, targetType, qosRules)
-			if err != nil {
-				logger.Log.Error1(requestId, "Failed to ModifyQoSRules:", err)
-				return err
-			}
-			return err
-		case NonExistentQoSRule:
-			logger.Log.Warnf(requestId, "QoS rule does not exist, response: %s", response.String())
-			return ErrQosRuleDoesNotExist
-		default:
-			logger.Log.Error1(requestId, "Failed to modify QoS rule, three_par response:", response.String())
-			return errors.New("Failed to modify QoS rule,three_par response:" + response.String())
-		}
+	var response HttpResponseBody
+	err = readJsonBody(resp.Body, &response)
+	if err != nil {
+		logger.Log.Error1(requestId, "ReadJsonBody error:", err)
+		return err
 	}
 
-	return
+	switch response.Code {
+	case NonExistentQoSRule:
+		logger.Log.Warnf(requestId, "QoS rule does not exist, response: %s", response.String())
+		return ErrQosRuleDoesNotExist
+	default:
+		logger.Log.Error1(requestId, "Failed to modify QoS rule, three_par response:", response.String())
+		return errors.New("Failed to modify QoS rule,three_par response:" + response.String())
+	}
 }
 
-func (T *ThreeParDriver) DeleteQoSRules(requestId, targetName string, targetType string) (err error) {
+func (T *ThreeParDriver) DeleteQoSRules(ctx context.Context, requestId, targetName string, targetType string) (err error) {
 	requestUrl := fmt.Sprintf("%s/qos/%s:%s", T.ServerPath, targetType, targetName)
-	req, err := http.NewRequest("DELETE", requestUrl, nil)
-	if err != nil {
-		logger.Log.Error1(requestId, "NewRequest error:", err)
-		return
-	}
-	req.Header.Set(sessionCookieName, T.SessionKey)
 	logger.Log.Info1(requestId, "DeleteQoSRules Request to three_par:", requestUrl)
-	resp, err := T.HttpClient.Do(req)
+	resp, err := T.do(ctx, requestId, "DELETE", requestUrl, nil)
 	if err != nil {
 		logger.Log.Error1(requestId, "Failed to request three_par:", err)
 		return
 	}
 	defer resp.Body.Close()
 
-	switch resp.StatusCode {
-	case http.StatusOK:
+	if resp.StatusCode == http.StatusOK {
 		return
-	case http.StatusForbidden:
-		result, err := checkHttpResponseOfSessionKey(resp)
-		if result {
-			err = T.InitSessionKey()
-			if err != nil {
-				logger.Log.Error1(requestId, "Failed to init three_par:", err)
-				return err
-			}
-			err = T.DeleteQoSRules(requestId, targetName, targetType)
-			if err != nil {
-				logger.Log.Error1(requestId, "Failed to DeleteQoSRules:", err)
-				return err
-			}
-		}
 	}
 
 	var response HttpResponseBody
@@ -2155,16 +1392,10 @@ func (T *ThreeParDriver) DeleteQoSRules(requestId, targetName string, targetType
 	return err
 }
 
-func (T *ThreeParDriver) GetQoSRule(requestId, targetName string, targetType string) (result string, err error) {
+func (T *ThreeParDriver) GetQoSRule(ctx context.Context, requestId, targetName string, targetType string) (result string, err error) {
 	requestUrl := fmt.Sprintf("%s/qos/%s:%s", T.ServerPath, targetType, targetName)
-	req, err := http.NewRequest("GET", requestUrl, nil)
-	if err != nil {
-		logger.Log.Error1(requestId, "NewRequest error:", err)
-		return
-	}
-	req.Header.Set(sessionCookieName, T.SessionKey)
 	logger.Log.Info1(requestId, "GetQoSRule Request to three_par:", requestUrl)
-	resp, err := T.HttpClient.Do(req)
+	resp, err := T.do(ctx, requestId, "GET", requestUrl, nil)
 	if err != nil {
 		logger.Log.Error1(requestId, "Failed to request three_par:", err)
 		return
@@ -2174,30 +1405,13 @@ func (T *ThreeParDriver) GetQoSRule(requestId, targetName string, targetType str
 
 	switch resp.StatusCode {
 	case http.StatusOK:
-		if resp.Body != nil {
-			jsonBytes, err := ioutil.ReadAll(resp.Body)
-			if err != nil {
-				return result, err
-			}
-			result = string(jsonBytes)
-			logger.Log.Info1(requestId, "three_par response:", result)
+		jsonBytes, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			return result, err
 		}
+		result = string(jsonBytes)
+		logger.Log.Info1(requestId, "three_par response:", result)
 		return result, nil
-	case http.StatusForbidden:
-		result2, err := checkHttpResponseOfSessionKey(resp)
-		if result2 {
-			err = T.InitSessionKey()
-			if err != nil {
-				logger.Log.Error1(requestId, "Failed to init three_par:", err)
-				return result, err
-			}
-			result, err = T.GetQoSRule(requestId, targetName, targetType)
-			if err != nil {
-				logger.Log.Error1(requestId, "Failed to GetQoSRule:", err)
-				return result, err
-			}
-			return result, nil
-		}
 	case http.StatusNotFound:
 		return result, ErrQosRuleDoesNotExist
 	}
@@ -2209,34 +1423,16 @@ func (T *ThreeParDriver) GetQoSRule(requestId, targetName string, targetType str
 		return result, err
 	}
 
-	if response.Code == HPE3ParSystemIsBusy {
-		sleepTime := rand.Intn(5-1) + 1
-		logger.Log.Debugf(requestId, "Waiting %d seconds to GetQoSRule again!", sleepTime)
-		time.Sleep(time.Duration(sleepTime) * time.Second)
-		result, err = T.GetQoSRule(requestId, targetName, targetType)
-		if err != nil {
-			logger.Log.Error1(requestId, "Failed to DeleteVLUN:", err)
-			return result, err
-		}
-		return result, err
-	}
-
 	logger.Log.Info1(requestId, "Failed to get Qos rule response:", response.String())
 	err = errors.New("Failed to get Qos rule response:" + response.String())
 	return result, err
 }
 
 //system information methods
-func (T *ThreeParDriver) GetSystemCapacity() (result string, err error) {
+func (T *ThreeParDriver) GetSystemCapacity(ctx context.Context) (result string, err error) {
 	requestUrl := fmt.Sprintf("%s/capacity", T.ServerPath)
-	req, err := http.NewRequest("GET", requestUrl, nil)
-	if err != nil {
-		logger.Log.Error("NewRequest error:", err)
-		return
-	}
-	req.Header.Set(sessionCookieName, T.SessionKey)
 	logger.Log.Info("GetSystemCapacity Request to three_par:", requestUrl)
-	resp, err := T.HttpClient.Do(req)
+	resp, err := T.do(ctx, "", "GET", requestUrl, nil)
 	if err != nil {
 		logger.Log.Error("Failed to request three_par:", err)
 		return
@@ -2245,54 +1441,30 @@ func (T *ThreeParDriver) GetSystemCapacity() (result string, err error) {
 	defer resp.Body.Close()
 
 	if resp.StatusCode != 200 {
-		result2, err := checkHttpResponseOfSessionKey(resp)
-		if result2 {
-			err = T.InitSessionKey()
-			if err != nil {
-				logger.Log.Error("Failed to init three_par:", err)
-				return result, err
-			}
-			result, err = T.GetSystemCapacity()
-			if err != nil {
-				logger.Log.Error("Failed to GetSystemCapacity:", err)
-				return result, err
-			}
-			return result, nil
-		} else {
-			var response HttpResponseBody
-			err = readJsonBody(resp.Body, &response)
-			if err != nil {
-				logger.Log.Error("ReadJsonBody error:", err)
-				return result, err
-			}
-
-			logger.Log.Info("Failed to get system capacity,three_par response:", response.String())
-			err = errors.New("Failed to get system capacity,three_par response:" + response.String())
-			return result, err
-		}
-	}
-	if resp.Body != nil {
-		jsonBytes, err := ioutil.ReadAll(resp.Body)
+		var response HttpResponseBody
+		err = readJsonBody(resp.Body, &response)
 		if err != nil {
+			logger.Log.Error("ReadJsonBody error:", err)
 			return result, err
 		}
-		result = string(jsonBytes)
-		//logger.Log.Info1(requestId,"three_par response:", result)
+
+		logger.Log.Info("Failed to get system capacity,three_par response:", response.String())
+		err = errors.New("Failed to get system capacity,three_par response:" + response.String())
+		return result, err
+	}
+	jsonBytes, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return result, err
 	}
+	result = string(jsonBytes)
 	return result, nil
 }
 
 // GetSystemUtilization 计算虚拟卷利用率
-func (T *ThreeParDriver) GetSystemUtilization() (ssd, hdd float64, err error) {
+func (T *ThreeParDriver) GetSystemUtilization(ctx context.Context) (ssd, hdd float64, err error) {
 	requestUrl := fmt.Sprintf("%s/systemreporter/attime/volumespacedata/hires;groupby:userCPG", T.ServerPath)
-	req, err := http.NewRequest("GET", requestUrl, nil)
-	if err != nil {
-		logger.Log.Error("NewRequest error:", err)
-		return
-	}
-	req.Header.Set(sessionCookieName, T.SessionKey)
 	logger.Log.Info("GetSystemUtilization Request to three_par:", requestUrl)
-	resp, err := T.HttpClient.Do(req)
+	resp, err := T.do(ctx, "", "GET", requestUrl, nil)
 	if err != nil {
 		logger.Log.Error("Failed to request three_par:", err)
 		return
@@ -2301,31 +1473,16 @@ func (T *ThreeParDriver) GetSystemUtilization() (ssd, hdd float64, err error) {
 	defer resp.Body.Close()
 
 	if resp.StatusCode != 200 {
-		result2, err := checkHttpResponseOfSessionKey(resp)
-		if result2 {
-			err = T.InitSessionKey()
-			if err != nil {
-				logger.Log.Error("Failed to init three_par:", err)
-				return ssd, hdd, err
-			}
-			ssd, hdd, err = T.GetSystemUtilization()
-			if err != nil {
-				logger.Log.Error("Failed to GetSystemUtilization:", err)
-				return ssd, hdd, err
-			}
-			return ssd, hdd, nil
-		} else {
-			var response HttpResponseBody
-			err = readJsonBody(resp.Body, &response)
-			if err != nil {
-				logger.Log.Error("ReadJsonBody error:", err)
-				return ssd, hdd, err
-			}
-
-			logger.Log.Info("Failed to get volumes, three_par response:", response.String())
-			err = errors.New("Failed to get volumes, three_par response:" + response.String())
+		var response HttpResponseBody
+		err = readJsonBody(resp.Body, &response)
+		if err != nil {
+			logger.Log.Error("ReadJsonBody error:", err)
 			return ssd, hdd, err
 		}
+
+		logger.Log.Info("Failed to get volumes, three_par response:", response.String())
+		err = errors.New("Failed to get volumes, three_par response:" + response.String())
+		return ssd, hdd, err
 	}
 
 	var volumeSpaceResponse AtTimeVolumeSpaceResponse
@@ -2359,16 +1516,10 @@ func (T *ThreeParDriver) GetSystemUtilization() (ssd, hdd float64, err error) {
 	return ssd, hdd, nil
 }
 
-func (T *ThreeParDriver) getTask(requestId string, taskId float64) (task TaskInfo, err error) {
+func (T *ThreeParDriver) getTask(ctx context.Context, requestId string, taskId float64) (task TaskInfo, err error) {
 	requestUrl := fmt.Sprintf("%s/tasks/%d", T.ServerPath, int64(taskId))
-	req, err := http.NewRequest("GET", requestUrl, nil)
-	if err != nil {
-		logger.Log.Error1(requestId, "NewRequest error:", err)
-		return
-	}
-	req.Header.Set(sessionCookieName, T.SessionKey)
 	logger.Log.Info1(requestId, "getTask Request to three_par:", requestUrl)
-	resp, err := T.HttpClient.Do(req)
+	resp, err := T.do(ctx, requestId, "GET", requestUrl, nil)
 	if err != nil {
 		logger.Log.Error1(requestId, "Failed to request three_par:", err)
 		return
@@ -2377,103 +1528,33 @@ func (T *ThreeParDriver) getTask(requestId string, taskId float64) (task TaskInf
 	defer resp.Body.Close()
 
 	if resp.StatusCode != 200 {
-		result2, err := checkHttpResponseOfSessionKey(resp)
-		if result2 {
-			err = T.InitSessionKey()
-			if err != nil {
-				logger.Log.Error1(requestId, "Failed to init three_par:", err)
-				return task, err
-			}
-			task, err = T.getTask(requestId, taskId)
-			if err != nil {
-				logger.Log.Error1(requestId, "Failed to getTask:", err)
-				return task, err
-			}
-			return task, err
-		} else {
-			var response HttpResponseBody
-			err = readJsonBody(resp.Body, &response)
-			if err != nil {
-				logger.Log.Error1(requestId, "ReadJsonBody error:", err)
-				return task, err
-			}
-			if response.Code == HPE3ParSystemIsBusy {
-				logger.Log.Info1(requestId, "getTask,three_par response:", response.String())
-				task := TaskInfo{
-					Id:         0,
-					Type:       0,
-					Name:       "",
-					Status:     2,
-					StartTime:  "",
-					FinishTime: "",
-					User:       "",
-				}
-				return task, nil
-			}
-			logger.Log.Info1(requestId, "Failed to getTask,three_par response:", response.String())
-			err = errors.New("Failed to getTask,three_par response:" + response.String())
-			return task, err
-		}
-	}
-	if resp.Body != nil {
-		err = readJsonBody(resp.Body, &task)
-		if err != nil {
-			logger.Log.Error1(requestId, "ReadJsonBody error:", err)
-			return task, err
-		}
-		jsonBytes2, err := json.Marshal(task)
-		if err != nil {
-			logger.Log.Error1(requestId, "Failed to marshal json:", err)
-			return task, err
-		}
-		logger.Log.Info1(requestId, "three_par response:", string(jsonBytes2))
-	}
-	return task, nil
-}
-
-func checkHttpResponseOfSessionKey(resp *http.Response) (result bool, err error) {
-	result = false
-	if resp.StatusCode == 403 {
 		var response HttpResponseBody
 		err = readJsonBody(resp.Body, &response)
 		if err != nil {
-			return
+			logger.Log.Error1(requestId, "ReadJsonBody error:", err)
+			return task, err
 		}
-		if response.Code == 6 {
-			result = true
+		// still busy after the retries, the caller polls again
+		if response.Code == HPE3ParSystemIsBusy {
+			logger.Log.Info1(requestId, "getTask,three_par response:", response.String())
+			return TaskInfo{Status: TASKSTATUS_ACTIVE}, nil
 		}
+		logger.Log.Info1(requestId, "Failed to getTask,three_par response:", response.String())
+		err = errors.New("Failed to getTask,three_par response:" + response.String())
+		return task, err
 	}
-	return result, nil
-}
-
-func checkHostExist(resp *http.Response) (result bool, err error) {
-	result = true
-	if resp.StatusCode == 404 {
-		var response HttpResponseBody
-		err = readJsonBody(resp.Body, &response)
-		if err != nil {
-			return
-		}
-		if response.Code == 17 {
-			result = false
-		}
+	err = readJsonBody(resp.Body, &task)
+	if err != nil {
+		logger.Log.Error1(requestId, "ReadJsonBody error:", err)
+		return task, err
 	}
-	return result, nil
-}
-
-func checkHttpResponseOfHostExist(resp *http.Response) (result bool, err error) {
-	result = false
-	if resp.StatusCode == 404 {
-		var response HttpResponseBody
-		err = readJsonBody(resp.Body, &response)
-		if err != nil {
-			return
-		}
-		if response.Code == 17 {
-			result = true
-		}
+	jsonBytes2, err := json.Marshal(task)
+	if err != nil {
+		logger.Log.Error1(requestId, "Failed to marshal json:", err)
+		return task, err
 	}
-	return result, nil
+	logger.Log.Info1(requestId, "three_par response:", string(jsonBytes2))
+	return task, nil
 }
 
 func readJsonBody(body io.ReadCloser, out interface{}) (err error) {
@@ -2492,3 +1573,15 @@ func readJsonBody(body io.ReadCloser, out interface{}) (err error) {
 	}
 	return nil
 }
+
+// sleepContext waits for d unless ctx is done first, in which case the error of ctx is returned
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
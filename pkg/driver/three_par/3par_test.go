@@ -1,86 +1,95 @@
 package three_par_test
 
 import (
-	"immortality/service/driver/three_par"
-	"immortality/service/logger"
-	"net"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"immortality-demo/pkg/data"
+	"immortality-demo/pkg/db_model"
+	"immortality-demo/pkg/driver/three_par"
+	"immortality-demo/pkg/driver/three_par/wsapitest"
+	"immortality-demo/pkg/logger"
+	"io/ioutil"
+	"log"
 	"net/http"
 	"os"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 )
 
 const RequestId = "requestId"
 
-func getThreePar() (threePar *three_par.ThreeParDriver) {
+var ctx = context.Background()
 
+func getThreePar(t *testing.T) (threePar *three_par.ThreeParDriver) {
+	threePar, _ = getSimulatedThreePar(t)
+	return threePar
+}
+
+// getSimulatedThreePar returns a driver talking to a fresh simulated array
+func getSimulatedThreePar(t *testing.T) (threePar *three_par.ThreeParDriver, server *wsapitest.Server) {
 	stdoutLogger := logger.NewStdoutLogger(os.Stdout, "")
 	logger.Log = stdoutLogger
 
-	httpClient := &http.Client{
-		Timeout: 30 * time.Second,
-		Transport: &http.Transport{
-			MaxIdleConnsPerHost: 1024,
-			DialContext: (&net.Dialer{
-				Timeout: 3 * time.Second,
-			}).DialContext,
-			TLSHandshakeTimeout: 10 * time.Second,
-		},
-	}
+	server = wsapitest.NewServer("3paradm", "3pardata")
+	t.Cleanup(server.Close)
 
 	threePar = &three_par.ThreeParDriver{
-		HttpClient: httpClient,
-		ServerPath: "http://10.254.7.77:8008/api/v1",
-		SessionKey: "",
+		HttpClient: &http.Client{Timeout: 30 * time.Second},
+		ServerPath: server.ServerPath(),
 		User:       "3paradm",
 		Password:   "3pardata",
 	}
 
-	return threePar
+	return threePar, server
 }
 
 func TestThreePar_Volume(t *testing.T) {
-	T := getThreePar()
-	err := T.InitSessionKey()
+	T := getThreePar(t)
+	err := T.InitSessionKey(ctx)
 	if err != nil {
 		t.Error("Failed to initialize three_par client,", err)
 		return
 	}
 
 	var volumeName string = "Test-volumeName-112"
-	err = T.CreateVolume(RequestId, volumeName, "ssd", 1024, map[string]interface{}{"snapCPG": "ssd"})
+	err = T.CreateVolume(ctx, RequestId, volumeName, "ssd", 1024, map[string]interface{}{"snapCPG": "ssd"})
 	if err != nil {
 		t.Error("Failed to create volume!", err)
 		return
 	}
-	volume, err := T.GetVolumeByName(RequestId, volumeName)
+	volume, err := T.GetVolumeByName(ctx, RequestId, volumeName)
 	if err != nil {
 		t.Error("Failed to get volume!", err)
 	}
 	t.Log(volume)
 	//var volumeName string = "Test-volumeName-1572258432"
-	err = T.ModifyVolume(RequestId, volumeName, map[string]interface{}{"comment": "test case"})
+	err = T.ModifyVolume(ctx, RequestId, volumeName, map[string]interface{}{"comment": "test case"})
 	if err != nil {
 		t.Error("Failed to modify volume!", err)
 		return
 	}
 	//var volumeName string = "Test-volumeName-1572258432"
-	err = T.GrowVolume(RequestId, volumeName, 1024)
+	err = T.GrowVolume(ctx, RequestId, volumeName, 1024)
 	if err != nil {
 		t.Error("Failed to grow volume!", err)
 		return
 	}
-	err = T.CloneVolume(RequestId, volumeName, "testCopyVolume3", "hdd", map[string]interface{}{"online": true})
+	err = T.CloneVolume(ctx, RequestId, volumeName, "testCopyVolume3", "hdd", map[string]interface{}{"online": true})
 	if err != nil {
 		t.Error("Failed to modify volume!", err)
 		return
 	}
-	err = T.DeleteVolume(RequestId, volumeName)
+	err = T.DeleteVolume(ctx, RequestId, volumeName)
 	if err != nil {
 		t.Errorf("Failed to delete volume!")
 		return
 	}
-	err = T.DeleteVolume(RequestId, "testCopyVolume3")
+	err = T.DeleteVolume(ctx, RequestId, "testCopyVolume3")
 	if err != nil {
 		t.Errorf("Failed to delete volume!")
 		return
@@ -88,42 +97,42 @@ func TestThreePar_Volume(t *testing.T) {
 }
 
 func TestThreePar_Snapshot(t *testing.T) {
-	T := getThreePar()
-	err := T.InitSessionKey()
+	T := getThreePar(t)
+	err := T.InitSessionKey(ctx)
 	if err != nil {
 		t.Error("Failed to initialize three_par client,", err)
 		return
 	}
 
 	var volumeName string = "Test-volumeName-113"
-	err = T.CreateVolume(RequestId, volumeName, "ssd", 1024, map[string]interface{}{"snapCPG": "ssd"})
+	err = T.CreateVolume(ctx, RequestId, volumeName, "ssd", 1024, map[string]interface{}{"snapCPG": "ssd"})
 	if err != nil {
 		t.Error("Failed to create volume!", err)
 		return
 	}
 	var snapshotName string = "Test-snapshotName-001"
-	err = T.CreateVolumeSnapshot(RequestId, snapshotName, volumeName, nil)
+	err = T.CreateVolumeSnapshot(ctx, RequestId, snapshotName, volumeName, nil)
 	if err != nil {
 		t.Error("Failed to create snapshot of volume!", err)
 		return
 	}
-	volumeSnaps, err := T.GetSnapshotsOfVolume(RequestId, "ssd", volumeName)
+	volumeSnaps, err := T.GetSnapshotsOfVolume(ctx, RequestId, "ssd", volumeName)
 	if err != nil {
 		t.Error("Failed to get snapshots of volume!", err)
 	}
 	t.Log(volumeSnaps)
 	//var snapshotName string = "Test-snapshotName-1572260546"
-	err = T.PromoteVirtualCopy(RequestId, snapshotName, nil)
+	err = T.PromoteVirtualCopy(ctx, RequestId, snapshotName, nil)
 	if err != nil {
 		t.Error("Failed to create snapshot of volume!", err)
 		return
 	}
-	err = T.RemoveSnapshot(RequestId, snapshotName)
+	err = T.RemoveSnapshot(ctx, RequestId, snapshotName)
 	if err != nil {
 		t.Error("Failed to delete snapshot of volume!", err)
 		return
 	}
-	err = T.DeleteVolume(RequestId, volumeName)
+	err = T.DeleteVolume(ctx, RequestId, volumeName)
 	if err != nil {
 		t.Errorf("Failed to delete volume!")
 		return
@@ -131,39 +140,39 @@ func TestThreePar_Snapshot(t *testing.T) {
 }
 
 func TestThreePar_Host(t *testing.T) {
-	T := getThreePar()
-	err := T.InitSessionKey()
+	T := getThreePar(t)
+	err := T.InitSessionKey(ctx)
 	if err != nil {
 		t.Error("Failed to initialize three_par client,", err)
 		return
 	}
 
-	err = T.CreateHost(RequestId, "test-case-host-1", []string{"iqn.2019-06.com.example.com:desktop"}, nil, nil)
+	err = T.CreateHost(ctx, RequestId, "test-case-host-1", []string{"iqn.2019-06.com.example.com:desktop"}, nil, nil)
 	if err != nil {
 		t.Error("Failed to CreateHost,", err)
 		return
 	}
 
-	err = T.ModifyHost(RequestId, "test-case-host-1", map[string]interface{}{"pathOperation": 1, "iSCSINames": []string{"iqn.2019-07.com.example.com:desktop"}})
+	err = T.ModifyHost(ctx, RequestId, "test-case-host-1", map[string]interface{}{"pathOperation": 1, "iSCSINames": []string{"iqn.2019-07.com.example.com:desktop"}})
 	if err != nil {
 		t.Error("Failed to ModifyHost,", err)
 		return
 	}
 
-	host, err := T.GetHostByName(RequestId, "test-case-host-1")
+	host, err := T.GetHostByName(ctx, RequestId, "test-case-host-1")
 	if err != nil {
 		t.Error("Failed to GetHostByName,", err)
 		return
 	}
 	t.Log(host)
-	host2, err := T.GetHostByIqn(RequestId, "iqn.2018-05.com.example.com:desktop")
+	host2, err := T.GetHostByIqn(ctx, RequestId, "iqn.2018-05.com.example.com:desktop")
 	if err != nil {
 		t.Error("Failed to GetHostByIqn,", err)
 		return
 	}
 	t.Log(host2)
 
-	err = T.DeleteHost(RequestId, "test-case-host-1")
+	err = T.DeleteHost(ctx, RequestId, "test-case-host-1")
 	if err != nil {
 		t.Error("Failed to DeleteHost,", err)
 		return
@@ -172,54 +181,54 @@ func TestThreePar_Host(t *testing.T) {
 }
 
 func TestThreePar_VLUN(t *testing.T) {
-	T := getThreePar()
-	err := T.InitSessionKey()
+	T := getThreePar(t)
+	err := T.InitSessionKey(ctx)
 	if err != nil {
 		t.Error("Failed to initialize three_par client,", err)
 		return
 	}
 
 	var volumeName string = "storage_uca_volume_test1"
-	err = T.CreateVolume(RequestId, volumeName, "ssd", 1024, map[string]interface{}{"snapCPG": "ssd"})
+	err = T.CreateVolume(ctx, RequestId, volumeName, "ssd", 1024, map[string]interface{}{"snapCPG": "ssd"})
 	if err != nil {
 		t.Error("Failed to create volume!", err)
 		return
 	}
 
 	var hostName string = "storage_uca_host_test1"
-	err = T.CreateHost(RequestId, hostName, []string{"iqn.1901-08.org.debian:01:e15349fa9211"}, nil, nil)
+	err = T.CreateHost(ctx, RequestId, hostName, []string{"iqn.1901-08.org.debian:01:e15349fa9211"}, nil, nil)
 	if err != nil {
 		t.Error("Failed to CreateHost,", err)
 		return
 	}
 
-	lunId, err := T.CreateVLUN(RequestId, volumeName, hostName, 1, true, nil)
+	lunId, err := T.CreateVLUN(ctx, RequestId, volumeName, hostName, 1, true, nil)
 	if err != nil {
 		t.Error("Failed to CreateHost,", err)
 		return
 	}
 	t.Log(lunId)
 
-	vluns, err := T.GetVlunsByHostname(RequestId, hostName)
+	vluns, err := T.GetVlunsByHostname(ctx, RequestId, hostName)
 	if err != nil {
 		t.Error("Failed to ModifyHost,", err)
 		return
 	}
 	t.Log(vluns)
 
-	err = T.DeleteVLUN(RequestId, volumeName, hostName, 0)
+	err = T.DeleteVLUN(ctx, RequestId, volumeName, hostName, 0)
 	if err != nil {
 		t.Error("Failed to DeleteHost,", err)
 		return
 	}
 
-	err = T.DeleteHost(RequestId, hostName)
+	err = T.DeleteHost(ctx, RequestId, hostName)
 	if err != nil {
 		t.Error("Failed to DeleteHost,", err)
 		return
 	}
 
-	err = T.DeleteVolume(RequestId, volumeName)
+	err = T.DeleteVolume(ctx, RequestId, volumeName)
 	if err != nil {
 		t.Errorf("Failed to delete volume!")
 		return
@@ -228,30 +237,30 @@ func TestThreePar_VLUN(t *testing.T) {
 }
 
 func TestThreePar_VolumeSet(t *testing.T) {
-	T := getThreePar()
-	err := T.InitSessionKey()
+	T := getThreePar(t)
+	err := T.InitSessionKey(ctx)
 	if err != nil {
 		t.Error("Failed to initialize three_par client,", err)
 		return
 	}
 
-	err = T.CreateVolumeSet(RequestId, "TestCase-volumeSet-1", "", "", nil)
+	err = T.CreateVolumeSet(ctx, RequestId, "TestCase-volumeSet-1", "", "", nil)
 	if err != nil {
 		t.Error("Failed to CreateVolumeSet,", err)
 		return
 	}
-	volumeSet, err := T.GetVolumeSet(RequestId, "TestCase-volumeSet-1")
+	volumeSet, err := T.GetVolumeSet(ctx, RequestId, "TestCase-volumeSet-1")
 	if err != nil {
 		t.Error("Failed to GetVolumeSet,", err)
 		return
 	}
 	t.Log(volumeSet)
-	//err = T.ModifyVolumeSet("TestCase-volumeSet-1", map[string]interface{}{"action": 1, "setmembers": []string{"testCopyVolume3"}})
+	//err = T.ModifyVolumeSet(ctx, "TestCase-volumeSet-1", map[string]interface{}{"action": 1, "setmembers": []string{"testCopyVolume3"}})
 	//if err != nil {
 	//	t.Error("Failed to ModifyVolumeSet,", err)
 	//	return
 	//}
-	err = T.DeleteVolumeSet(RequestId, "TestCase-volumeSet-1")
+	err = T.DeleteVolumeSet(ctx, RequestId, "TestCase-volumeSet-1")
 	if err != nil {
 		t.Error("Failed to DeleteVolumeSet,", err)
 		return
@@ -259,48 +268,48 @@ func TestThreePar_VolumeSet(t *testing.T) {
 }
 
 func TestThreePar_QoSRules(t *testing.T) {
-	T := getThreePar()
-	err := T.InitSessionKey()
+	T := getThreePar(t)
+	err := T.InitSessionKey(ctx)
 	if err != nil {
 		t.Error("Failed to initialize three_par client,", err)
 		return
 	}
 
-	err = T.CreateVolumeSet(RequestId, "TestCase-volumeSet-1", "", "", nil)
+	err = T.CreateVolumeSet(ctx, RequestId, "TestCase-volumeSet-1", "", "", nil)
 	if err != nil {
 		t.Error("Failed to CreateVolumeSet,", err)
 		return
 	}
 
-	err = T.CreateQoSRules(RequestId, "TestCase-volumeSet-1", 1, map[string]interface{}{"bwMinGoalKB": 1024, "bwMaxLimitKB": 1024})
+	err = T.CreateQoSRules(ctx, RequestId, "TestCase-volumeSet-1", 1, map[string]interface{}{"bwMinGoalKB": 1024, "bwMaxLimitKB": 1024})
 	if err != nil {
 		t.Error("Failed to CreateQoSRules,", err)
 		return
 	}
-	result, err := T.GetQoSRule(RequestId, "TestCase-volumeSet-1", "vvset")
+	result, err := T.GetQoSRule(ctx, RequestId, "TestCase-volumeSet-1", "vvset")
 	if err != nil {
 		t.Error("Failed to GetQoSRule,", err)
 		return
 	}
 	t.Log(result)
-	err = T.ModifyQoSRules(RequestId, "TestCase-volumeSet-1", "vvset", map[string]interface{}{"bwMinGoalKB": 2048, "bwMaxLimitKB": 2048})
+	err = T.ModifyQoSRules(ctx, RequestId, "TestCase-volumeSet-1", "vvset", map[string]interface{}{"bwMinGoalKB": 2048, "bwMaxLimitKB": 2048})
 	if err != nil {
 		t.Error("Failed to CreateQoSRules,", err)
 		return
 	}
-	result2, err := T.GetQoSRule(RequestId, "TestCase-volumeSet-1", "vvset")
+	result2, err := T.GetQoSRule(ctx, RequestId, "TestCase-volumeSet-1", "vvset")
 	if err != nil {
 		t.Error("Failed to GetQoSRule,", err)
 		return
 	}
 	t.Log(result2)
-	err = T.DeleteQoSRules(RequestId, "TestCase-volumeSet-1", "vvset")
+	err = T.DeleteQoSRules(ctx, RequestId, "TestCase-volumeSet-1", "vvset")
 	if err != nil {
 		t.Error("Failed to DeleteQoSRules,", err)
 		return
 	}
 
-	err = T.DeleteVolumeSet(RequestId, "TestCase-volumeSet-1")
+	err = T.DeleteVolumeSet(ctx, RequestId, "TestCase-volumeSet-1")
 	if err != nil {
 		t.Error("Failed to DeleteVolumeSet,", err)
 		return
@@ -308,14 +317,14 @@ func TestThreePar_QoSRules(t *testing.T) {
 }
 
 func TestThreePar_GetSystemCapacity(t *testing.T) {
-	T := getThreePar()
-	err := T.InitSessionKey()
+	T := getThreePar(t)
+	err := T.InitSessionKey(ctx)
 	if err != nil {
 		t.Error("Failed to initialize three_par client,", err)
 		return
 	}
 
-	result, err := T.GetSystemCapacity()
+	result, err := T.GetSystemCapacity(ctx)
 	if err != nil {
 		t.Error("Failed to GetSystemCapacity,", err)
 		return
@@ -324,20 +333,20 @@ func TestThreePar_GetSystemCapacity(t *testing.T) {
 }
 
 func TestThreeParDriver_CreateVolume(t *testing.T) {
-	T := getThreePar()
-	err := T.InitSessionKey()
+	T := getThreePar(t)
+	err := T.InitSessionKey(ctx)
 	if err != nil {
 		t.Error("Failed to initialize three_par client,", err)
 		return
 	}
 
 	var volumeName string = "Test-volumeName-119"
-	err = T.CreateVolume(RequestId, volumeName, "hybrid-ssd", 1024, map[string]interface{}{"snapCPG": "hybrid-ssd"})
+	err = T.CreateVolume(ctx, RequestId, volumeName, "hybrid-ssd", 1024, map[string]interface{}{"snapCPG": "hybrid-ssd"})
 	if err != nil {
 		t.Error("Failed to create volume!", err)
 		return
 	}
-	volume, err := T.GetVolumeByName(RequestId, volumeName)
+	volume, err := T.GetVolumeByName(ctx, RequestId, volumeName)
 	if err != nil {
 		t.Error("Failed to get volume!", err)
 	}
@@ -346,14 +355,14 @@ func TestThreeParDriver_CreateVolume(t *testing.T) {
 }
 
 //func TestThreeParDriver_GetVlun(t *testing.T) {
-//	T := getThreePar()
-//	err := T.InitSessionKey()
+//	T := getThreePar(t)
+//	err := T.InitSessionKey(ctx)
 //	if err != nil {
 //		t.Error("Failed to initialize three_par client,", err)
 //		return
 //	}
 //
-//	vlun, err := T.GetVlun(RequestId, "cvkDXN", "dxn_2")
+//	vlun, err := T.GetVlun(ctx, RequestId, "cvkDXN", "dxn_2")
 //	if err != nil {
 //		t.Error("Failed to GetVlun!", err)
 //		return
@@ -361,3 +370,336 @@ func TestThreeParDriver_CreateVolume(t *testing.T) {
 //	t.Log(vlun)
 //
 //}
+
+func TestThreePar_SessionExpired(t *testing.T) {
+	T, server := getSimulatedThreePar(t)
+	err := T.InitSessionKey(ctx)
+	if err != nil {
+		t.Fatal("Failed to initialize three_par client,", err)
+	}
+	key := T.SessionKey()
+
+	server.ExpireSessions()
+	err = T.CreateVolume(ctx, RequestId, "Test-volumeName-120", "ssd", 1024, nil)
+	if err != nil {
+		t.Fatal("Failed to create volume after the session expired,", err)
+	}
+	if T.SessionKey() == key {
+		t.Fatal("expected a new session key")
+	}
+	if n := server.Requests("POST", "credentials"); n != 2 {
+		t.Fatalf("expected 2 logins, got %d", n)
+	}
+
+	err = T.UnAuthenticate(ctx)
+	if err != nil || T.SessionKey() != "" {
+		t.Fatalf("Failed to UnAuthenticate, key %q, err %v", T.SessionKey(), err)
+	}
+	if _, err = T.GetVolumeByName(ctx, RequestId, "Test-volumeName-120"); err != nil {
+		t.Fatal("Failed to get volume after UnAuthenticate,", err)
+	}
+}
+
+func TestThreePar_SessionSingleFlight(t *testing.T) {
+	T, server := getSimulatedThreePar(t)
+	err := T.CreateVolume(ctx, RequestId, "Test-volumeName-127", "ssd", 1024, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server.ExpireSessions()
+	var wg sync.WaitGroup
+	errs := make(chan error, 10)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := T.GetVolumeByName(ctx, RequestId, "Test-volumeName-127")
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := server.Requests("POST", "credentials"); n != 2 {
+		t.Fatalf("expected the workers to share one login, got %d logins", n)
+	}
+}
+
+func TestThreePar_SessionRefresh(t *testing.T) {
+	T, server := getSimulatedThreePar(t)
+	// every session is about to expire, so each request renews it first
+	T.SessionIdleTimeout = time.Minute
+
+	for i := 0; i < 3; i++ {
+		if _, err := T.GetSystemCapacity(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := server.Requests("GET", "capacity"); n != 3 {
+		t.Fatalf("expected no request to be rejected, got %d requests", n)
+	}
+	if n := server.Requests("POST", "credentials"); n != 3 {
+		t.Fatalf("expected 3 logins, got %d", n)
+	}
+	if n := server.Requests("DELETE", "credentials"); n != 2 {
+		t.Fatalf("expected the 2 stale sessions to be logged out, got %d", n)
+	}
+}
+
+func TestThreePar_SystemBusy(t *testing.T) {
+	defer three_par.SetBusyWait(time.Millisecond)()
+	T, server := getSimulatedThreePar(t)
+
+	server.Busy("POST", "volumes", 1)
+	err := T.CreateVolume(ctx, RequestId, "Test-volumeName-121", "ssd", 1024, nil)
+	if err != nil {
+		t.Fatal("Failed to create volume on a busy array,", err)
+	}
+	if n := server.Requests("POST", "volumes"); n != 2 {
+		t.Fatalf("expected the request to be retried once, got %d requests", n)
+	}
+
+	server.Busy("POST", "hosts", three_par.MaxBusyRetries+1)
+	err = T.CreateHost(ctx, RequestId, "Test-host-121", nil, nil, nil)
+	if err == nil {
+		t.Fatal("expected CreateHost to give up on a busy array")
+	}
+	if n := server.Requests("POST", "hosts"); n != three_par.MaxBusyRetries+1 {
+		t.Fatalf("expected %d requests, got %d", three_par.MaxBusyRetries+1, n)
+	}
+}
+
+func TestThreePar_Errors(t *testing.T) {
+	T, _ := getSimulatedThreePar(t)
+	T.User = "nobody"
+	if err := T.InitSessionKey(ctx); err == nil {
+		t.Fatal("expected wrong credentials to fail")
+	}
+	T.User = "3paradm"
+
+	// an existing volume is not an error, a missing one is
+	if err := T.CreateVolume(ctx, RequestId, "Test-volumeName-122", "ssd", 1024, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := T.CreateVolume(ctx, RequestId, "Test-volumeName-122", "ssd", 1024, nil); err != nil {
+		t.Fatal("expected an existing volume to be ignored,", err)
+	}
+	if _, err := T.GetVolumeByName(ctx, RequestId, "missing"); err != three_par.ErrVolumeDoesNotExist {
+		t.Fatalf("expected ErrVolumeDoesNotExist, got %v", err)
+	}
+
+	if err := T.DeleteVLUN(ctx, RequestId, "Test-volumeName-122", "missing", 1); err != nil {
+		t.Fatal("expected a missing VLUN to be ignored,", err)
+	}
+	if host, err := T.GetHostByName(ctx, RequestId, "missing"); err != nil || host.Name != "" {
+		t.Fatalf("expected no host, got %+v, %v", host, err)
+	}
+
+	if err := T.DeleteVolumeSet(ctx, RequestId, "missing"); err != three_par.ErrSetDoesNotExist {
+		t.Fatalf("expected ErrSetDoesNotExist, got %v", err)
+	}
+	if err := T.CreateVolumeSet(ctx, RequestId, "Test-volumeSet-122", "", "", []string{"Test-volumeName-122"}); err != nil {
+		t.Fatal(err)
+	}
+	err := T.ModifyVolumeSet(ctx, RequestId, "Test-volumeSet-122", map[string]interface{}{"action": 1, "setmembers": []string{"Test-volumeName-122"}})
+	if err != three_par.ErrVolumeHasInSet {
+		t.Fatalf("expected ErrVolumeHasInSet, got %v", err)
+	}
+	err = T.ModifyVolumeSet(ctx, RequestId, "Test-volumeSet-122", map[string]interface{}{"action": 2, "setmembers": []string{"Test-volumeName-122"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = T.ModifyVolumeSet(ctx, RequestId, "Test-volumeSet-122", map[string]interface{}{"action": 2, "setmembers": []string{"Test-volumeName-122"}})
+	if err != three_par.ErrVolumeNotInSet {
+		t.Fatalf("expected ErrVolumeNotInSet, got %v", err)
+	}
+
+	if err := T.CreateQoSRules(ctx, RequestId, "Test-volumeSet-122", three_par.QoS_TargetType_VVSET, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := T.CreateQoSRules(ctx, RequestId, "Test-volumeSet-122", three_par.QoS_TargetType_VVSET, nil); err != three_par.ErrQoSRuleExistent {
+		t.Fatalf("expected ErrQoSRuleExistent, got %v", err)
+	}
+	if _, err := T.GetQoSRule(ctx, RequestId, "missing", "vvset"); err != three_par.ErrQosRuleDoesNotExist {
+		t.Fatalf("expected ErrQosRuleDoesNotExist, got %v", err)
+	}
+	if err := T.ModifyQoSRules(ctx, RequestId, "missing", "vvset", nil); err != three_par.ErrQosRuleDoesNotExist {
+		t.Fatalf("expected ErrQosRuleDoesNotExist, got %v", err)
+	}
+}
+
+func TestThreePar_CloneAndExport(t *testing.T) {
+	T, server := getSimulatedThreePar(t)
+	err := T.InitSessionKey(ctx)
+	if err != nil {
+		t.Fatal("Failed to initialize three_par client,", err)
+	}
+
+	if err = T.CreateVolume(ctx, RequestId, "Test-image-123", "image", 1024, map[string]interface{}{"snapCPG": "image"}); err != nil {
+		t.Fatal(err)
+	}
+	if err = T.CreateVolume(ctx, RequestId, "Test-volumeName-123", "ssd", 1024, map[string]interface{}{"snapCPG": "ssd"}); err != nil {
+		t.Fatal(err)
+	}
+	// an offline copy into the new volume, then grown to 2GiB
+	err = T.CloneVolume(ctx, RequestId, "Test-image-123", "Test-volumeName-123", "ssd", map[string]interface{}{"online": false})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = T.GrowVolume(ctx, RequestId, "Test-volumeName-123", 1024); err != nil {
+		t.Fatal(err)
+	}
+	if volume, _ := server.Volume("Test-volumeName-123"); volume.SizeMiB != 2048 {
+		t.Fatalf("expected 2048MiB, got %v", volume.SizeMiB)
+	}
+
+	server.SetTaskStatus(three_par.TASKSTATUS_FAILED)
+	err = T.CloneVolume(ctx, RequestId, "Test-image-123", "Test-volumeName-124", "ssd", map[string]interface{}{"online": true})
+	if err == nil {
+		t.Fatal("expected a failed clone task to fail the clone")
+	}
+	server.SetTaskStatus(three_par.TASKSTATUS_DONE)
+
+	if err = T.CreateHost(ctx, RequestId, "Test-host-123", []string{"iqn.test:123"}, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	first, err := T.CreateVLUN(ctx, RequestId, "Test-volumeName-123", "Test-host-123", 0, true, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := T.CreateVLUN(ctx, RequestId, "Test-image-123", "Test-host-123", 0, true, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first != 0 || second != 1 {
+		t.Fatalf("expected LUNs 0 and 1, got %d and %d", first, second)
+	}
+	host, err := T.GetHostByIqn(ctx, RequestId, "iqn.test:123")
+	if err != nil || host.Name != "Test-host-123" {
+		t.Fatalf("expected Test-host-123, got %+v, %v", host, err)
+	}
+
+	// the host goes with its VLUNs
+	if err = T.DeleteHost(ctx, RequestId, "Test-host-123"); err != nil {
+		t.Fatal(err)
+	}
+	if vluns, _ := T.GetVlunsByHostname(ctx, RequestId, "Test-host-123"); len(vluns) != 0 {
+		t.Fatalf("expected no VLUNs, got %+v", vluns)
+	}
+}
+
+func TestThreePar_GetSystemUtilization(t *testing.T) {
+	T, server := getSimulatedThreePar(t)
+	err := T.InitSessionKey(ctx)
+	if err != nil {
+		t.Fatal("Failed to initialize three_par client,", err)
+	}
+
+	if err = T.CreateVolume(ctx, RequestId, "Test-volumeName-125", "ssd", 1024, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err = T.CreateVolume(ctx, RequestId, "Test-volumeName-126", "hybrid-hdd", 1024, nil); err != nil {
+		t.Fatal(err)
+	}
+	server.SetUsedMiB("Test-volumeName-125", 256)
+	server.SetUsedMiB("Test-volumeName-126", 512)
+
+	ssd, hdd, err := T.GetSystemUtilization(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ssd != 0.25 || hdd != 0.5 {
+		t.Fatalf("expected 0.25 and 0.5, got %v and %v", ssd, hdd)
+	}
+}
+
+func TestThreePar_TLS(t *testing.T) {
+	logger.Log = logger.NewStdoutLogger(os.Stdout, "")
+	server := wsapitest.NewTLSServer("3paradm", "3pardata")
+	t.Cleanup(server.Close)
+	// the handshakes refused on purpose are not worth a log line
+	server.Config.ErrorLog = log.New(ioutil.Discard, "", 0)
+
+	caBundle := string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}))
+	sum := sha256.Sum256(server.Certificate().Raw)
+	wrongSum := sha256.Sum256([]byte("another certificate"))
+
+	cases := []struct {
+		name    string
+		par     db_model.ThreePar
+		certErr bool
+	}{
+		{name: "CA bundle", par: db_model.ThreePar{Https: 1, CaBundle: caBundle}},
+		{name: "pinned", par: db_model.ThreePar{Https: 1, CertSha256: hex.EncodeToString(sum[:])}},
+		{name: "pinned with colons", par: db_model.ThreePar{Https: 1, CertSha256: strings.ToUpper(strings.ReplaceAll(fmt.Sprintf("% x", sum[:]), " ", ":"))}},
+		{name: "pinned and CA bundle", par: db_model.ThreePar{Https: 1, CaBundle: caBundle, CertSha256: hex.EncodeToString(sum[:])}},
+		{name: "insecure", par: db_model.ThreePar{Https: 1, Insecure: 1}},
+		{name: "system roots", par: db_model.ThreePar{Https: 1}, certErr: true},
+		{name: "wrong pin", par: db_model.ThreePar{Https: 1, CertSha256: hex.EncodeToString(wrongSum[:])}, certErr: true},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			par := c.par
+			par.ThreeParId = "3par-tls"
+			par.Ipv4AddrManagement = server.Listener.Addr().String()
+			par.Username = "3paradm"
+			par.Password = "3pardata"
+			T, err := three_par.NewThreeParDriver(&par)
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = T.InitSessionKey(ctx)
+			if c.certErr {
+				if !three_par.IsCertificateError(err) {
+					t.Fatalf("expected a certificate error, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err = T.GetSystemCapacity(ctx); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+
+	invalid := []db_model.ThreePar{
+		{Https: 1, CaBundle: "not a certificate"},
+		{Https: 1, CertSha256: "abcd"},
+		{Https: 1, Insecure: 1, CertSha256: hex.EncodeToString(sum[:])},
+		{Insecure: 1},
+	}
+	for _, par := range invalid {
+		if _, err := three_par.NewThreeParDriver(&par); err == nil {
+			t.Errorf("expected %+v to be refused", par)
+		}
+	}
+}
+
+func TestThreeParVolumeDriver_AddArray(t *testing.T) {
+	_, server := getSimulatedThreePar(t)
+	v := &three_par.ThreeParVolumeDriver{ThreePars: map[string]*three_par.ThreeParDriver{}}
+
+	req := data.GetSystemCapacityRequest{ScheduleInfo: "par-new"}
+	if _, err := v.GetSystemCapacity(ctx, req); err == nil {
+		t.Fatal("expected an error for an unknown array")
+	}
+	par := &db_model.ThreePar{
+		ThreeParId:         "par-new",
+		Ipv4AddrManagement: server.Listener.Addr().String(),
+		Username:           "3paradm",
+		Password:           "3pardata",
+	}
+	if err := v.AddArray(ctx, par); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := v.GetSystemCapacity(ctx, req); err != nil {
+		t.Fatal("the added array is not used:", err)
+	}
+}
@@ -1,48 +1,55 @@
 package three_par
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"fmt"
-	"net"
 	"net/http"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/gofrs/uuid"
 
 	. "immortality-demo/pkg/data"
 	"immortality-demo/pkg/db_model"
+	"immortality-demo/pkg/driver"
 	"immortality-demo/pkg/logger"
 	"immortality-demo/pkg/util"
 )
 
+func init() {
+	driver.Register(HPE3PARA, func() (driver.VolumeDriver, error) {
+		v, err := Create3paraVolumeDriver()
+		if err != nil {
+			return nil, err
+		}
+		return v, nil
+	}, driver.CapExport|driver.CapQoS|driver.CapSnapshot|driver.CapClone|driver.CapCapacity)
+}
+
 type ThreeParDriver struct {
 	HttpClient *http.Client
 	ServerPath string
-	SessionKey string
 	User       string
 	Password   string
+	// SessionIdleTimeout is how long the array keeps an idle session,
+	// DefaultSessionIdleTimeout if zero
+	SessionIdleTimeout time.Duration
+
+	session session
 }
 
 type ThreeParVolumeDriver struct {
+	// mu guards ThreePars, arrays are added while the jobs run
+	mu        sync.RWMutex
 	ThreePars map[string]*ThreeParDriver
 }
 
 func Create3paraVolumeDriver() (v *ThreeParVolumeDriver, err error) {
 	threePars := map[string]*ThreeParDriver{}
 
-	httpClient := &http.Client{
-		Timeout: 120 * time.Second,
-		Transport: &http.Transport{
-			MaxIdleConnsPerHost: 1024,
-			DialContext: (&net.Dialer{
-				Timeout: 3 * time.Second,
-			}).DialContext,
-			TLSHandshakeTimeout: 10 * time.Second,
-		},
-	}
-
 	//Get 3par information
 	pars, err := db_model.ThreePars(Db)
 	if err != nil {
@@ -50,23 +57,20 @@ func Create3paraVolumeDriver() (v *ThreeParVolumeDriver, err error) {
 		return nil, err
 	}
 
-	if pars != nil {
-		for _, par := range pars {
-			threeParId := par.ThreeParId
-			threePars[threeParId] = &ThreeParDriver{
-				HttpClient: httpClient,
-				ServerPath: "http://" + par.Ipv4AddrManagement + "/api/v1",
-				SessionKey: "",
-				User:       par.Username,
-				Password:   par.Password,
-			}
+	// a broken row must not take the other arrays down, it is left out until
+	// it is fixed and the service restarts
+	for _, par := range pars {
+		T, err := NewThreeParDriver(par)
+		if err != nil {
+			logger.Log.Error("Failed to configure three_par", par.ThreeParId, ":", err)
+			continue
 		}
+		threePars[par.ThreeParId] = T
 	}
-	for _, threePar := range threePars {
-		err = threePar.InitSessionKey()
-		if err != nil {
-			logger.Log.Error("Failed to initSessionKey:", err)
-			return nil, err
+	for id, threePar := range threePars {
+		// the session is opened again on first use
+		if err := threePar.InitSessionKey(context.Background()); err != nil {
+			logger.Log.Error("Failed to initSessionKey of three_par", id, ":", err)
 		}
 	}
 
@@ -77,8 +81,45 @@ func Create3paraVolumeDriver() (v *ThreeParVolumeDriver, err error) {
 	return threeParVolumeDriver, nil
 }
 
-func (p *ThreeParVolumeDriver) CreateDisk(req CreateDiskRequest) (resp CreateDiskResponse, err error) {
-	T := p.ThreePars[req.ScheduleInfo]
+// AddArray starts using an array registered while the service runs
+func (p *ThreeParVolumeDriver) AddArray(ctx context.Context, par *db_model.ThreePar) error {
+	T, err := NewThreeParDriver(par)
+	if err != nil {
+		return err
+	}
+	if err = T.InitSessionKey(ctx); err != nil {
+		logger.Log.Error("Failed to initSessionKey of three_par", par.ThreeParId, ":", err)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.ThreePars[par.ThreeParId] = T
+	return nil
+}
+
+// array returns the driver of the array, nil if it is unknown
+func (p *ThreeParVolumeDriver) array(id string) *ThreeParDriver {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.ThreePars[id]
+}
+
+// Close logs out of the arrays
+func (p *ThreeParVolumeDriver) Close(ctx context.Context) (err error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	for id, T := range p.ThreePars {
+		if e := T.UnAuthenticate(ctx); e != nil {
+			logger.Log.Error("Failed to UnAuthenticate 3par", id, ":", e)
+			err = e
+		}
+	}
+	return
+}
+
+func (p *ThreeParVolumeDriver) CreateDisk(ctx context.Context, req CreateDiskRequest) (resp CreateDiskResponse, err error) {
+	T := p.array(req.ScheduleInfo)
 	if T == nil {
 		err = errors.New("3par information is empty,clusterId:" + req.ScheduleInfo)
 		logger.Log.Error1(req.RequestId, err)
@@ -96,17 +137,17 @@ func (p *ThreeParVolumeDriver) CreateDisk(req CreateDiskRequest) (resp CreateDis
 	}
 
 	if req.ImageId != "" {
-		err = T.createVolumeFromImage(req.RequestId, req.ImageId, req.DiskId, int64(req.Size), cpgName)
+		err = T.createVolumeFromImage(ctx, req.RequestId, req.ImageId, req.DiskId, int64(req.Size), cpgName)
 	} else if req.SnapshotId != "" {
-		err = T.createVolumeFromSnapshot(req.RequestId, req.SnapshotId, req.DiskId, cpgName)
+		err = T.createVolumeFromSnapshot(ctx, req.RequestId, req.SnapshotId, req.DiskId, cpgName)
 	} else {
 		optional := map[string]interface{}{"snapCPG": cpgName, "tpvv": true}
-		err = T.CreateVolume(req.RequestId, req.DiskId, cpgName, int64(req.Size), optional)
+		err = T.CreateVolume(ctx, req.RequestId, req.DiskId, cpgName, int64(req.Size), optional)
 	}
 
 	//defer func() {
 	//	if err != nil {
-	//		e := T.DeleteVolume(req.RequestId, req.DiskId)
+	//		e := T.DeleteVolume(ctx, req.RequestId, req.DiskId)
 	//		if e != nil {
 	//			logger.Log.Error1(req.RequestId, "Failed to rollback created disk:", req.ScheduleInfo, req.DiskId)
 	//		}
@@ -119,7 +160,7 @@ func (p *ThreeParVolumeDriver) CreateDisk(req CreateDiskRequest) (resp CreateDis
 	}
 
 	//get wwn of disk
-	disk, err := T.GetVolumeByName(req.RequestId, req.DiskId)
+	disk, err := T.GetVolumeByName(ctx, req.RequestId, req.DiskId)
 	if err != nil {
 		logger.Log.Error1(req.RequestId, "Failed to GetVolumeByName:", req.DiskId, err)
 		return
@@ -130,15 +171,15 @@ func (p *ThreeParVolumeDriver) CreateDisk(req CreateDiskRequest) (resp CreateDis
 	return resp, nil
 }
 
-func (p *ThreeParVolumeDriver) DeleteDisk(req DeleteDiskRequest) (err error) {
-	T := p.ThreePars[req.ScheduleInfo]
+func (p *ThreeParVolumeDriver) DeleteDisk(ctx context.Context, req DeleteDiskRequest) (err error) {
+	T := p.array(req.ScheduleInfo)
 	if T == nil {
 		err = errors.New("3par information is empty,clusterId:" + req.ScheduleInfo)
 		logger.Log.Error1(req.RequestId, err)
 		return err
 	}
 
-	err = T.DeleteVolume(req.RequestId, req.DiskId)
+	err = T.DeleteVolume(ctx, req.RequestId, req.DiskId)
 	if err != nil {
 		logger.Log.Error1(req.RequestId, "Failed to deleteDisk:", req.DiskId, err)
 		return err
@@ -146,8 +187,8 @@ func (p *ThreeParVolumeDriver) DeleteDisk(req DeleteDiskRequest) (err error) {
 	return nil
 }
 
-func (p *ThreeParVolumeDriver) CreateImage(req CreateImageRequest) (err error) {
-	T := p.ThreePars[req.ScheduleInfo]
+func (p *ThreeParVolumeDriver) CreateImage(ctx context.Context, req CreateImageRequest) (err error) {
+	T := p.array(req.ScheduleInfo)
 	if T == nil {
 		err = errors.New("3par information is empty,clusterId:" + req.ScheduleInfo)
 		logger.Log.Error1(req.RequestId, err)
@@ -155,7 +196,7 @@ func (p *ThreeParVolumeDriver) CreateImage(req CreateImageRequest) (err error) {
 	}
 
 	var destCpg string
-	disk, err := T.GetVolumeByName(req.RequestId, req.SourceDiskId)
+	disk, err := T.GetVolumeByName(ctx, req.RequestId, req.SourceDiskId)
 	if err != nil {
 		logger.Log.Error1(req.RequestId, "Failed to GetVolumeByName,", req.SourceDiskId, err)
 		return
@@ -164,14 +205,14 @@ func (p *ThreeParVolumeDriver) CreateImage(req CreateImageRequest) (err error) {
 		destCpg = disk.UserCPG
 	}
 
-	err = T.CreateVolume(req.RequestId, req.ImageId, destCpg, int64(disk.SizeMiB), map[string]interface{}{"snapCPG": destCpg, "tpvv": true})
+	err = T.CreateVolume(ctx, req.RequestId, req.ImageId, destCpg, int64(disk.SizeMiB), map[string]interface{}{"snapCPG": destCpg, "tpvv": true})
 	if err != nil {
 		logger.Log.Error1(req.RequestId, "Failed to CreateVolume:", err)
 		return
 	}
 
 	optional := map[string]interface{}{"online": false, "priority": 1}
-	err = T.CloneVolume(req.RequestId, disk.Name, req.ImageId, destCpg, optional)
+	err = T.CloneVolume(ctx, req.RequestId, disk.Name, req.ImageId, destCpg, optional)
 	if err != nil {
 		logger.Log.Error1(req.RequestId, "Failed to createImage,", err)
 		return err
@@ -180,15 +221,15 @@ func (p *ThreeParVolumeDriver) CreateImage(req CreateImageRequest) (err error) {
 	return nil
 }
 
-func (p *ThreeParVolumeDriver) DeleteImage(req DeleteImageRequest) (err error) {
-	T := p.ThreePars[req.ScheduleInfo]
+func (p *ThreeParVolumeDriver) DeleteImage(ctx context.Context, req DeleteImageRequest) (err error) {
+	T := p.array(req.ScheduleInfo)
 	if T == nil {
 		err = errors.New("3par information is empty,clusterId:" + req.ScheduleInfo)
 		logger.Log.Error1(req.RequestId, err)
 		return err
 	}
 
-	err = T.DeleteVolume(req.RequestId, req.ImageId)
+	err = T.DeleteVolume(ctx, req.RequestId, req.ImageId)
 	if err != nil {
 		logger.Log.Error1(req.RequestId, "Failed to deleteImage,ImageId:", req.ImageId, err)
 		return err
@@ -196,8 +237,8 @@ func (p *ThreeParVolumeDriver) DeleteImage(req DeleteImageRequest) (err error) {
 	return nil
 }
 
-func (p *ThreeParVolumeDriver) CreateSnapshot(req CreateSnapshotRequest) (err error) {
-	T := p.ThreePars[req.ScheduleInfo]
+func (p *ThreeParVolumeDriver) CreateSnapshot(ctx context.Context, req CreateSnapshotRequest) (err error) {
+	T := p.array(req.ScheduleInfo)
 	if T == nil {
 		err = errors.New("3par information is empty,clusterId:" + req.ScheduleInfo)
 		logger.Log.Error1(req.RequestId, err)
@@ -205,7 +246,7 @@ func (p *ThreeParVolumeDriver) CreateSnapshot(req CreateSnapshotRequest) (err er
 	}
 
 	optional := map[string]interface{}{}
-	err = T.CreateVolumeSnapshot(req.RequestId, req.SnapshotId, req.DiskId, optional)
+	err = T.CreateVolumeSnapshot(ctx, req.RequestId, req.SnapshotId, req.DiskId, optional)
 	if err != nil {
 		logger.Log.Error1(req.RequestId, "Failed to createSnapshot:", req.DiskId, err)
 		return err
@@ -214,15 +255,15 @@ func (p *ThreeParVolumeDriver) CreateSnapshot(req CreateSnapshotRequest) (err er
 	return nil
 }
 
-func (p *ThreeParVolumeDriver) DeleteSnapshot(req DeleteSnapshotRequest) (err error) {
-	T := p.ThreePars[req.ScheduleInfo]
+func (p *ThreeParVolumeDriver) DeleteSnapshot(ctx context.Context, req DeleteSnapshotRequest) (err error) {
+	T := p.array(req.ScheduleInfo)
 	if T == nil {
 		err = errors.New("3par information is empty,clusterId:" + req.ScheduleInfo)
 		logger.Log.Error1(req.RequestId, err)
 		return err
 	}
 
-	err = T.DeleteVolume(req.RequestId, req.SnapshotId)
+	err = T.DeleteVolume(ctx, req.RequestId, req.SnapshotId)
 	if err != nil {
 		logger.Log.Error1(req.RequestId, "Failed to deleteSnapshot:", req.SnapshotId, err)
 		return err
@@ -230,8 +271,8 @@ func (p *ThreeParVolumeDriver) DeleteSnapshot(req DeleteSnapshotRequest) (err er
 	return nil
 }
 
-func (p *ThreeParVolumeDriver) ReInitDisk(req ReInitDiskRequest) (resp CreateDiskResponse, err error) {
-	T := p.ThreePars[req.ScheduleInfo]
+func (p *ThreeParVolumeDriver) ReInitDisk(ctx context.Context, req ReInitDiskRequest) (resp CreateDiskResponse, err error) {
+	T := p.array(req.ScheduleInfo)
 	if T == nil {
 		err = errors.New("3par information is empty,clusterId:" + req.ScheduleInfo)
 		logger.Log.Error1(req.RequestId, err)
@@ -239,7 +280,7 @@ func (p *ThreeParVolumeDriver) ReInitDisk(req ReInitDiskRequest) (resp CreateDis
 	}
 
 	//delete original disk
-	err = T.DeleteVolume(req.RequestId, req.DiskId)
+	err = T.DeleteVolume(ctx, req.RequestId, req.DiskId)
 	if err != nil {
 		logger.Log.Error1(req.RequestId, "Failed to deleteVolume:", req.DiskId, err)
 		return
@@ -256,12 +297,12 @@ func (p *ThreeParVolumeDriver) ReInitDisk(req ReInitDiskRequest) (resp CreateDis
 		cpgName = HYBRID_SSD_CPG
 	}
 	if req.ImageId != "" {
-		err = T.createVolumeFromImage(req.RequestId, req.ImageId, req.DiskId, int64(req.Size), cpgName)
+		err = T.createVolumeFromImage(ctx, req.RequestId, req.ImageId, req.DiskId, int64(req.Size), cpgName)
 	} else if req.SnapshotId != "" {
-		err = T.createVolumeFromSnapshot(req.RequestId, req.SnapshotId, req.DiskId, cpgName)
+		err = T.createVolumeFromSnapshot(ctx, req.RequestId, req.SnapshotId, req.DiskId, cpgName)
 	} else {
 		optional := map[string]interface{}{"snapCPG": cpgName, "tpvv": true}
-		err = T.CreateVolume(req.RequestId, req.DiskId, cpgName, int64(req.Size), optional)
+		err = T.CreateVolume(ctx, req.RequestId, req.DiskId, cpgName, int64(req.Size), optional)
 	}
 	if err != nil {
 		logger.Log.Error1(req.RequestId, "Failed to create new disk:", req, err)
@@ -269,7 +310,7 @@ func (p *ThreeParVolumeDriver) ReInitDisk(req ReInitDiskRequest) (resp CreateDis
 	}
 
 	//get wwn of disk
-	disk, err := T.GetVolumeByName(req.RequestId, req.DiskId)
+	disk, err := T.GetVolumeByName(ctx, req.RequestId, req.DiskId)
 	if err != nil {
 		logger.Log.Error1(req.RequestId, "Failed to GetVolumeByName:", req.DiskId, err)
 		return
@@ -280,8 +321,8 @@ func (p *ThreeParVolumeDriver) ReInitDisk(req ReInitDiskRequest) (resp CreateDis
 	return
 }
 
-func (p *ThreeParVolumeDriver) ResetDisk(req ResetDiskRequest) (err error) {
-	T := p.ThreePars[req.ScheduleInfo]
+func (p *ThreeParVolumeDriver) ResetDisk(ctx context.Context, req ResetDiskRequest) (err error) {
+	T := p.array(req.ScheduleInfo)
 	if T == nil {
 		err = errors.New("3par information is empty,clusterId:" + req.ScheduleInfo)
 		logger.Log.Error1(req.RequestId, err)
@@ -289,7 +330,7 @@ func (p *ThreeParVolumeDriver) ResetDisk(req ResetDiskRequest) (err error) {
 	}
 
 	optional := map[string]interface{}{}
-	err = T.PromoteVirtualCopy(req.RequestId, req.SnapshotId, optional)
+	err = T.PromoteVirtualCopy(ctx, req.RequestId, req.SnapshotId, optional)
 	if err != nil {
 		logger.Log.Error1(req.RequestId, "Failed to resetDisk:", req.SnapshotId, err)
 		return err
@@ -298,9 +339,9 @@ func (p *ThreeParVolumeDriver) ResetDisk(req ResetDiskRequest) (err error) {
 	return nil
 }
 
-func (p *ThreeParVolumeDriver) ResizeDisk(req ResizeDiskRequest) (err error) {
+func (p *ThreeParVolumeDriver) ResizeDisk(ctx context.Context, req ResizeDiskRequest) (err error) {
 
-	T := p.ThreePars[req.ScheduleInfo]
+	T := p.array(req.ScheduleInfo)
 	if T == nil {
 		err = errors.New("3par information is empty,clusterId:" + req.ScheduleInfo)
 		logger.Log.Error1(req.RequestId, err)
@@ -309,7 +350,7 @@ func (p *ThreeParVolumeDriver) ResizeDisk(req ResizeDiskRequest) (err error) {
 
 	amount := (req.NewSize - req.OldSize) >> 20
 	if amount > 0 {
-		err = T.GrowVolume(req.RequestId, req.DiskId, amount)
+		err = T.GrowVolume(ctx, req.RequestId, req.DiskId, amount)
 		if err != nil {
 			logger.Log.Error1(req.RequestId, "Failed to GrowVolume:", req.DiskId, err)
 			return err
@@ -319,8 +360,8 @@ func (p *ThreeParVolumeDriver) ResizeDisk(req ResizeDiskRequest) (err error) {
 	return nil
 }
 
-func (p *ThreeParVolumeDriver) Export(req ExportDiskRequest) (resp ExportDiskResponse, err error) {
-	T := p.ThreePars[req.ScheduleInfo]
+func (p *ThreeParVolumeDriver) Export(ctx context.Context, req ExportDiskRequest) (resp ExportDiskResponse, err error) {
+	T := p.array(req.ScheduleInfo)
 	if T == nil {
 		err = errors.New("3par information is empty,clusterId:" + req.ScheduleInfo)
 		logger.Log.Error1(req.RequestId, err)
@@ -332,14 +373,14 @@ func (p *ThreeParVolumeDriver) Export(req ExportDiskRequest) (resp ExportDiskRes
 	hostName = req.CVKName
 	hostName = strings.ReplaceAll(hostName, "(", "")
 	hostName = strings.ReplaceAll(hostName, ")", "")
-	host, err := T.GetHostByName(req.RequestId, hostName)
+	host, err := T.GetHostByName(ctx, req.RequestId, hostName)
 	if err != nil {
 		logger.Log.Error1(req.RequestId, "Failed to GetHostByName:", hostName, err)
 		return resp, err
 	}
 	if host.Name == "" {
 		//create host
-		err = T.CreateHost(req.RequestId, hostName, []string{req.Iqn}, nil, nil)
+		err = T.CreateHost(ctx, req.RequestId, hostName, []string{req.Iqn}, nil, nil)
 		if err != nil {
 			logger.Log.Error1(req.RequestId, "Failed to CreateHost:", req, err)
 			return resp, err
@@ -357,7 +398,7 @@ func (p *ThreeParVolumeDriver) Export(req ExportDiskRequest) (resp ExportDiskRes
 			//modify the iqn of host
 			parameters := map[string]interface{}{"pathOperation": HOST_EDIT_OPERATION_ADD,
 				"iSCSINames": []string{req.Iqn}}
-			err = T.ModifyHost(req.RequestId, hostName, parameters)
+			err = T.ModifyHost(ctx, req.RequestId, hostName, parameters)
 			if err != nil {
 				logger.Log.Error1(req.RequestId, "Failed to ModifyHost:", req, err)
 				return resp, err
@@ -366,13 +407,13 @@ func (p *ThreeParVolumeDriver) Export(req ExportDiskRequest) (resp ExportDiskRes
 	}
 
 	//create VLUN
-	lunId, err := T.CreateVLUN(req.RequestId, req.DiskId, req.CVKName, 0, true, nil)
+	lunId, err := T.CreateVLUN(ctx, req.RequestId, req.DiskId, req.CVKName, 0, true, nil)
 	if err != nil {
 		logger.Log.Error1(req.RequestId, "Failed to CreateVLUN:", req, err)
 		return resp, err
 	}
 	//get disk
-	disk, err := T.GetVolumeByName(req.RequestId, req.DiskId)
+	disk, err := T.GetVolumeByName(ctx, req.RequestId, req.DiskId)
 	if err != nil {
 		logger.Log.Error1(req.RequestId, "Failed to GetVolumeByName:", err)
 		return resp, err
@@ -383,8 +424,8 @@ func (p *ThreeParVolumeDriver) Export(req ExportDiskRequest) (resp ExportDiskRes
 	return resp, nil
 }
 
-func (p *ThreeParVolumeDriver) CancelExport(req ExportDiskRequest) (err error) {
-	T := p.ThreePars[req.ScheduleInfo]
+func (p *ThreeParVolumeDriver) CancelExport(ctx context.Context, req ExportDiskRequest) (err error) {
+	T := p.array(req.ScheduleInfo)
 	if T == nil {
 		err = errors.New("3par information is empty,clusterId:" + req.ScheduleInfo)
 		logger.Log.Error1(req.RequestId, err)
@@ -394,7 +435,7 @@ func (p *ThreeParVolumeDriver) CancelExport(req ExportDiskRequest) (err error) {
 	var hostName = req.CVKName
 	//hostName = strings.ReplaceAll(hostName, "(", "")
 	//hostName = strings.ReplaceAll(hostName, ")", "")
-	//host, err := T.GetHostByName(req.RequestId, hostName)
+	//host, err := T.GetHostByName(ctx, req.RequestId, hostName)
 	//if err != nil {
 	//	logger.Log.Error1(req.RequestId, "Failed to GetHostByName:", hostName, err)
 	//	return err
@@ -406,7 +447,7 @@ func (p *ThreeParVolumeDriver) CancelExport(req ExportDiskRequest) (err error) {
 	//}
 
 	//delete VLUN
-	err = T.DeleteVLUN(req.RequestId, req.DiskId, hostName, float64(req.Lun))
+	err = T.DeleteVLUN(ctx, req.RequestId, req.DiskId, hostName, float64(req.Lun))
 	if err != nil {
 		logger.Log.Error1(req.RequestId, "Failed to DeleteVLUN:", req, err)
 		return err
@@ -415,15 +456,15 @@ func (p *ThreeParVolumeDriver) CancelExport(req ExportDiskRequest) (err error) {
 	return nil
 }
 
-func (p *ThreeParVolumeDriver) GetSystemCapacity(req GetSystemCapacityRequest) (result string, err error) {
-	T := p.ThreePars[req.ScheduleInfo]
+func (p *ThreeParVolumeDriver) GetSystemCapacity(ctx context.Context, req GetSystemCapacityRequest) (result string, err error) {
+	T := p.array(req.ScheduleInfo)
 	if T == nil {
 		err = errors.New("3par information is empty,clusterId:" + req.ScheduleInfo)
 		logger.Log.Error(err)
 		return result, err
 	}
 
-	result, err = T.GetSystemCapacity()
+	result, err = T.GetSystemCapacity(ctx)
 	if err != nil {
 		logger.Log.Error("Failed to GetSystemCapacity:", err)
 		return result, err
@@ -432,17 +473,17 @@ func (p *ThreeParVolumeDriver) GetSystemCapacity(req GetSystemCapacityRequest) (
 	return result, nil
 }
 
-func (p *ThreeParVolumeDriver) GetSystemUtilization(req GetSystemUtilizationRequest) (ssd, hdd float64, err error) {
-	T := p.ThreePars[req.ScheduleInfo]
+func (p *ThreeParVolumeDriver) GetSystemUtilization(ctx context.Context, req GetSystemUtilizationRequest) (ssd, hdd float64, err error) {
+	T := p.array(req.ScheduleInfo)
 	if T == nil {
 		err = errors.New("3par information is empty,clusterId:" + req.ScheduleInfo)
 		logger.Log.Errorf(req.RequestID, "%s", err)
 		return ssd, hdd, err
 	}
 
-	ssd, hdd, err = T.GetSystemUtilization()
+	ssd, hdd, err = T.GetSystemUtilization(ctx)
 	if err != nil {
-		logger.Log.Errorf(req.RequestID, "failed to GetSystemUtilization:", err)
+		logger.Log.Errorf(req.RequestID, "failed to GetSystemUtilization, err: %+v", err)
 		return ssd, hdd, err
 	}
 
@@ -454,8 +495,8 @@ func (p *ThreeParVolumeDriver) NeedExport(req ExportDiskRequest) (isNeed bool) {
 }
 
 // AddDiskQoS 设定磁盘限速规则
-func (p *ThreeParVolumeDriver) AddDiskQoS(req DiskQoSRequest) (err error) {
-	T := p.ThreePars[req.ScheduleInfo]
+func (p *ThreeParVolumeDriver) AddDiskQoS(ctx context.Context, req DiskQoSRequest) (err error) {
+	T := p.array(req.ScheduleInfo)
 	if T == nil {
 		err = errors.New("3par information is empty,clusterId:" + req.ScheduleInfo)
 		logger.Log.Error1(req.RequestID, err)
@@ -466,7 +507,7 @@ func (p *ThreeParVolumeDriver) AddDiskQoS(req DiskQoSRequest) (err error) {
 
 	switch req.DiskCategory {
 	case DiskCategorySSD:
-		err = T.CreateVolumeSet(req.RequestID, req.DiskID, "", "", []string{req.DiskID})
+		err = T.CreateVolumeSet(ctx, req.RequestID, req.DiskID, "", "", []string{req.DiskID})
 		if err != nil {
 			logger.Log.Errorf(req.RequestID, "failed to CreateVolumeSet, req: %+v, err: %+v", req, err)
 			return
@@ -486,7 +527,7 @@ func (p *ThreeParVolumeDriver) AddDiskQoS(req DiskQoSRequest) (err error) {
 			"ioMinGoal":    Hpe3parIoMinGoal,
 			"ioMaxLimit":   ioMaxLimit,
 		}
-		err = T.CreateQoSRules(req.RequestID, req.DiskID, QoS_TargetType_VVSET, qosRules)
+		err = T.CreateQoSRules(ctx, req.RequestID, req.DiskID, QoS_TargetType_VVSET, qosRules)
 		if err != nil && err != ErrQoSRuleExistent {
 			logger.Log.Error1(req.RequestID, "failed to CreateQoSRules:", req.DiskID, qosRules, err)
 			return fmt.Errorf("failed to create QoS level, err: %w", err)
@@ -517,7 +558,7 @@ func (p *ThreeParVolumeDriver) AddDiskQoS(req DiskQoSRequest) (err error) {
 			"action":     1, // Adds a member to the VV set
 			"setmembers": []string{req.DiskID},
 		}
-		err = T.ModifyVolumeSet(req.RequestID, group, params)
+		err = T.ModifyVolumeSet(ctx, req.RequestID, group, params)
 		if err == ErrVolumeHasInSet {
 			if err = tx.Commit(); err != nil {
 				logger.Log.Errorf(req.RequestID, "failed to commit tx, req: %+v, err: %+v", req, err)
@@ -567,8 +608,8 @@ func (p *ThreeParVolumeDriver) AddDiskQoS(req DiskQoSRequest) (err error) {
 }
 
 // RemoveDiskQoS 移除磁盘限速规则
-func (p *ThreeParVolumeDriver) RemoveDiskQoS(req DiskQoSRequest) (err error) {
-	T := p.ThreePars[req.ScheduleInfo]
+func (p *ThreeParVolumeDriver) RemoveDiskQoS(ctx context.Context, req DiskQoSRequest) (err error) {
+	T := p.array(req.ScheduleInfo)
 	if T == nil {
 		err = errors.New("3par information is empty,clusterId:" + req.ScheduleInfo)
 		logger.Log.Error1(req.RequestID, err)
@@ -578,7 +619,7 @@ func (p *ThreeParVolumeDriver) RemoveDiskQoS(req DiskQoSRequest) (err error) {
 	switch req.DiskCategory {
 	case DiskCategorySSD:
 		// 3par will automatically delete QoS
-		err = T.DeleteVolumeSet(req.RequestID, req.DiskID)
+		err = T.DeleteVolumeSet(ctx, req.RequestID, req.DiskID)
 		if err != nil && err != ErrSetDoesNotExist {
 			logger.Log.Errorf(req.RequestID, "failed to remove vvset, err: %+v", err)
 			return
@@ -615,7 +656,7 @@ func (p *ThreeParVolumeDriver) RemoveDiskQoS(req DiskQoSRequest) (err error) {
 			"action":     2, // Removes a member from the VV set
 			"setmembers": []string{req.DiskID},
 		}
-		if err = T.ModifyVolumeSet(req.RequestID, group, params); err != nil && err != ErrVolumeNotInSet {
+		if err = T.ModifyVolumeSet(ctx, req.RequestID, group, params); err != nil && err != ErrVolumeNotInSet {
 			logger.Log.Errorf(req.RequestID, "failed to ModifyVolumeSet, vvset: %s, params: %+v, err: %+v", group, params, err)
 			return
 		}
@@ -633,8 +674,8 @@ func (p *ThreeParVolumeDriver) RemoveDiskQoS(req DiskQoSRequest) (err error) {
 }
 
 // UpdateDiskQoS 更新磁盘限速规则
-func (p *ThreeParVolumeDriver) UpdateDiskQoS(req DiskQoSRequest) (err error) {
-	T := p.ThreePars[req.ScheduleInfo]
+func (p *ThreeParVolumeDriver) UpdateDiskQoS(ctx context.Context, req DiskQoSRequest) (err error) {
+	T := p.array(req.ScheduleInfo)
 	if T == nil {
 		err = errors.New("3par information is empty,clusterId:" + req.ScheduleInfo)
 		logger.Log.Error1(req.RequestID, err)
@@ -659,7 +700,7 @@ func (p *ThreeParVolumeDriver) UpdateDiskQoS(req DiskQoSRequest) (err error) {
 			"ioMinGoal":    Hpe3parIoMinGoal,
 			"ioMaxLimit":   ioMaxLimit,
 		}
-		err = T.ModifyQoSRules(req.RequestID, req.DiskID, QoSTargetType[QoS_TargetType_VVSET], qosRules)
+		err = T.ModifyQoSRules(ctx, req.RequestID, req.DiskID, QoSTargetType[QoS_TargetType_VVSET], qosRules)
 		if err != nil && err != ErrQosRuleDoesNotExist {
 			logger.Log.Error1(req.RequestID, "failed to ModifyQoSRules:", req.DiskID, qosRules, err)
 			return fmt.Errorf("failed to modify QoS rule, err: %w", err)
@@ -680,10 +721,10 @@ func (p *ThreeParVolumeDriver) UpdateDiskQoS(req DiskQoSRequest) (err error) {
 		if current != shouldBe {
 			logger.Log.Infof(req.RequestID, "updates needed, current: %s, should be: %s", current, shouldBe)
 
-			if err = p.RemoveDiskQoS(req); err != nil {
+			if err = p.RemoveDiskQoS(ctx, req); err != nil {
 				return fmt.Errorf("failed to remove QoS, req: %+v, err: %w", req, err)
 			}
-			if err = p.AddDiskQoS(req); err != nil {
+			if err = p.AddDiskQoS(ctx, req); err != nil {
 				return fmt.Errorf("failed to add QoS, req: %+v, err: %w", req, err)
 			}
 		}
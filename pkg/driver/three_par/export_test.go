@@ -0,0 +1,13 @@
+package three_par
+
+import "time"
+
+const MaxBusyRetries = maxBusyRetries
+
+// SetBusyWait makes the retries on a busy array wait d, restore puts the
+// random wait back
+func SetBusyWait(d time.Duration) (restore func()) {
+	wait := busyWait
+	busyWait = func() time.Duration { return d }
+	return func() { busyWait = wait }
+}
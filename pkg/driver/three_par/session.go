@@ -0,0 +1,277 @@
+package three_par
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"immortality-demo/pkg/logger"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultSessionIdleTimeout is how long the array keeps an unused session
+	DefaultSessionIdleTimeout = 15 * time.Minute
+	// sessions are renewed this long before the array would drop them
+	sessionRefreshMargin = time.Minute
+	// maxBusyRetries bounds the retries of a request the array is too busy for
+	maxBusyRetries = 5
+)
+
+// InvalidSessionKey is the code of a 403 answer to an unknown or expired key
+const InvalidSessionKey ErrCode = 6
+
+// busyWait is how long to wait before retrying a request the array was busy for
+var busyWait = func() time.Duration {
+	return time.Duration(rand.Intn(5-1)+1) * time.Second
+}
+
+// session is the WSAPI session of an array, shared by all the workers. Logins
+// are single-flight: the workers that find the same key rejected wait for one
+// login and then use its key.
+type session struct {
+	// lock guards key and lastUsed
+	lock     sync.Mutex
+	key      string
+	lastUsed time.Time
+
+	// login is held while logging in
+	login sync.Mutex
+}
+
+// SessionKey returns the current session key, empty if not logged in
+func (T *ThreeParDriver) SessionKey() string {
+	T.session.lock.Lock()
+	defer T.session.lock.Unlock()
+	return T.session.key
+}
+
+func (T *ThreeParDriver) idleTimeout() time.Duration {
+	if T.SessionIdleTimeout > 0 {
+		return T.SessionIdleTimeout
+	}
+	return DefaultSessionIdleTimeout
+}
+
+// sessionKey returns the key to send, logging in first if there is none or
+// the array is about to drop it for being idle
+func (T *ThreeParDriver) sessionKey(ctx context.Context) (key string, err error) {
+	s := &T.session
+	s.lock.Lock()
+	key = s.key
+	if key != "" && time.Since(s.lastUsed) < T.idleTimeout()-sessionRefreshMargin {
+		s.lastUsed = time.Now()
+		s.lock.Unlock()
+		return key, nil
+	}
+	s.lock.Unlock()
+	return T.renewSession(ctx, key)
+}
+
+// renewSession replaces the stale key with a new one. If another worker has
+// replaced it meanwhile its key is used, the stale key is logged out.
+func (T *ThreeParDriver) renewSession(ctx context.Context, stale string) (key string, err error) {
+	s := &T.session
+	s.login.Lock()
+	defer s.login.Unlock()
+
+	s.lock.Lock()
+	key = s.key
+	s.lock.Unlock()
+	if key != "" && key != stale {
+		return key, nil
+	}
+
+	key, err = T.authenticate(ctx)
+	if err != nil {
+		return "", err
+	}
+	s.lock.Lock()
+	s.key = key
+	s.lastUsed = time.Now()
+	s.lock.Unlock()
+
+	// the array limits the sessions of a user, do not leave the old one behind
+	if stale != "" {
+		if err := T.deleteSession(ctx, stale); err != nil {
+			logger.Log.Warnf("", "Failed to log out the stale session of %s: %v", T.ServerPath, err)
+		}
+	}
+	return key, nil
+}
+
+// InitSessionKey logs in to the array, replacing the current session
+func (T *ThreeParDriver) InitSessionKey(ctx context.Context) (err error) {
+	_, err = T.renewSession(ctx, T.SessionKey())
+	return
+}
+
+// UnAuthenticate logs out of the array, the next request logs in again
+func (T *ThreeParDriver) UnAuthenticate(ctx context.Context) (err error) {
+	s := &T.session
+	s.login.Lock()
+	defer s.login.Unlock()
+
+	s.lock.Lock()
+	key := s.key
+	s.key = ""
+	s.lock.Unlock()
+	if key == "" {
+		return nil
+	}
+	return T.deleteSession(ctx, key)
+}
+
+func (T *ThreeParDriver) authenticate(ctx context.Context) (key string, err error) {
+	request := AuthenticateBody{
+		User:     T.User,
+		Password: T.Password}
+	jsonBytes, err := json.Marshal(request)
+	if err != nil {
+		logger.Log.Error("Failed to marshal json:", err)
+		return
+	}
+	requestUrl := fmt.Sprintf("%s/credentials", T.ServerPath)
+	req, err := http.NewRequestWithContext(ctx, "POST", requestUrl, bytes.NewReader(jsonBytes))
+	if err != nil {
+		logger.Log.Error("NewRequest error:", err)
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	logger.Log.Info("InitSessionKey Request to three_par:", requestUrl)
+	resp, err := T.HttpClient.Do(req)
+	if err != nil {
+		logger.Log.Error("Failed to connect to three_par:", err)
+		return
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 201 {
+		var response HttpResponseBody
+		err = readJsonBody(resp.Body, &response)
+		if err != nil {
+			logger.Log.Error("ReadJsonBody error:", err)
+			return
+		}
+
+		logger.Log.Info("Failed to authenticate,three_par response:", response.String())
+		err = errors.New("Failed to authenticate,three_par response:" + response.String())
+		return "", err
+	}
+
+	var response AuthenticateResponse
+	err = readJsonBody(resp.Body, &response)
+	if err != nil {
+		logger.Log.Error("ReadJsonBody error:", err)
+		return
+	}
+	if response.Key == "" {
+		return "", errors.New("three_par returned an empty session key")
+	}
+	return response.Key, nil
+}
+
+// deleteSession logs the key out, a key the array no longer knows is gone already
+func (T *ThreeParDriver) deleteSession(ctx context.Context, key string) (err error) {
+	requestUrl := fmt.Sprintf("%s/credentials/%s", T.ServerPath, key)
+	req, err := http.NewRequestWithContext(ctx, "DELETE", requestUrl, nil)
+	if err != nil {
+		logger.Log.Error("NewRequest error:", err)
+		return
+	}
+	req.Header.Set(sessionCookieName, key)
+	logger.Log.Info("UnAuthenticate Request to three_par:", T.ServerPath)
+	resp, err := T.HttpClient.Do(req)
+	if err != nil {
+		logger.Log.Error("Failed to request three_par:", err)
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == 200 {
+		return nil
+	}
+	var response HttpResponseBody
+	err = readJsonBody(resp.Body, &response)
+	if err != nil {
+		logger.Log.Error("ReadJsonBody error:", err)
+		return err
+	}
+	if resp.StatusCode == http.StatusForbidden && response.Code == InvalidSessionKey {
+		return nil
+	}
+
+	logger.Log.Info("Failed to unAuthenticate,three_par response:", response.String())
+	return errors.New("Failed to unAuthenticate,three_par response:" + response.String())
+}
+
+// do sends the request with the session key. It logs in again once if the
+// array rejects the key, and retries up to maxBusyRetries times while the
+// array is busy. The body of an error response is buffered, it is the last
+// answer of the array.
+func (T *ThreeParDriver) do(ctx context.Context, requestId, method, requestUrl string, body []byte) (resp *http.Response, err error) {
+	renewed := false
+	busy := 0
+	for {
+		key, err := T.sessionKey(ctx)
+		if err != nil {
+			logger.Log.Error1(requestId, "Failed to init three_par:", err)
+			return nil, err
+		}
+		var req *http.Request
+		if body != nil {
+			req, err = http.NewRequestWithContext(ctx, method, requestUrl, bytes.NewReader(body))
+		} else {
+			req, err = http.NewRequestWithContext(ctx, method, requestUrl, nil)
+		}
+		if err != nil {
+			logger.Log.Error1(requestId, "NewRequest error:", err)
+			return nil, err
+		}
+		if body != nil {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		req.Header.Set(sessionCookieName, key)
+		resp, err = T.HttpClient.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode < 300 {
+			return resp, nil
+		}
+
+		jsonBytes, err := ioutil.ReadAll(resp.Body)
+		_ = resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		resp.Body = ioutil.NopCloser(bytes.NewReader(jsonBytes))
+		var response HttpResponseBody
+		_ = json.Unmarshal(jsonBytes, &response)
+
+		switch {
+		case resp.StatusCode == http.StatusForbidden && response.Code == InvalidSessionKey && !renewed:
+			renewed = true
+			logger.Log.Info1(requestId, "three_par session expired, logging in again")
+			if _, err = T.renewSession(ctx, key); err != nil {
+				logger.Log.Error1(requestId, "Failed to init three_par:", err)
+				return nil, err
+			}
+		case response.Code == HPE3ParSystemIsBusy && busy < maxBusyRetries:
+			busy++
+			wait := busyWait()
+			logger.Log.Debugf(requestId, "three_par is busy, waiting %v to send %s %s again", wait, method, requestUrl)
+			if err = sleepContext(ctx, wait); err != nil {
+				return nil, err
+			}
+		default:
+			return resp, nil
+		}
+	}
+}
@@ -0,0 +1,146 @@
+package three_par
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"immortality-demo/pkg/db_model"
+	"immortality-demo/pkg/logger"
+)
+
+// ErrCertificatePinMismatch is returned by the handshake with an array whose
+// certificate does not match the pinned fingerprint
+var ErrCertificatePinMismatch = errors.New("three_par certificate does not match the pinned fingerprint")
+
+// NewThreeParDriver returns the driver of the array stored in the row. Its
+// WSAPI is reached over https when the row asks for it, the certificate is
+// checked against the CA bundle or the pinned fingerprint of the row, or
+// against the system roots if neither is set.
+func NewThreeParDriver(par *db_model.ThreePar) (*ThreeParDriver, error) {
+	tlsConfig, err := wsapiTLSConfig(par)
+	if err != nil {
+		return nil, err
+	}
+
+	scheme := "https://"
+	if tlsConfig == nil {
+		scheme = "http://"
+		logger.Log.Warnf("", "WSAPI of three_par %s is plain http, its credentials are sent in clear", par.ThreeParId)
+	}
+	return &ThreeParDriver{
+		HttpClient: &http.Client{
+			Timeout: 120 * time.Second,
+			Transport: &http.Transport{
+				MaxIdleConnsPerHost: 1024,
+				DialContext: (&net.Dialer{
+					Timeout: 3 * time.Second,
+				}).DialContext,
+				TLSHandshakeTimeout: 10 * time.Second,
+				TLSClientConfig:     tlsConfig,
+			},
+		},
+		ServerPath: scheme + par.Ipv4AddrManagement + "/api/v1",
+		User:       par.Username,
+		Password:   par.Password,
+	}, nil
+}
+
+// wsapiTLSConfig returns the TLS settings of the row, nil for plain http
+func wsapiTLSConfig(par *db_model.ThreePar) (*tls.Config, error) {
+	if par.Https != 1 {
+		if par.Insecure == 1 || par.CaBundle != "" || par.CertSha256 != "" {
+			return nil, fmt.Errorf("three_par %s has certificate settings but does not use https", par.ThreeParId)
+		}
+		return nil, nil
+	}
+
+	if par.Insecure == 1 {
+		if par.CaBundle != "" || par.CertSha256 != "" {
+			return nil, fmt.Errorf("three_par %s is insecure and has certificate settings", par.ThreeParId)
+		}
+		logger.Log.Warnf("", "Certificate verification of three_par %s is disabled", par.ThreeParId)
+		return &tls.Config{InsecureSkipVerify: true}, nil
+	}
+
+	var roots *x509.CertPool
+	if par.CaBundle != "" {
+		roots = x509.NewCertPool()
+		if !roots.AppendCertsFromPEM([]byte(par.CaBundle)) {
+			return nil, fmt.Errorf("CA bundle of three_par %s holds no PEM certificate", par.ThreeParId)
+		}
+	}
+	if par.CertSha256 == "" {
+		return &tls.Config{RootCAs: roots}, nil
+	}
+
+	pin, err := ParseFingerprint(par.CertSha256)
+	if err != nil {
+		return nil, fmt.Errorf("fingerprint of three_par %s: %v", par.ThreeParId, err)
+	}
+	// the pin stands in for the host name check, arrays usually present a
+	// self-signed certificate which does not name their management address
+	return &tls.Config{
+		InsecureSkipVerify: true,
+		VerifyPeerCertificate: func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+			if len(rawCerts) == 0 {
+				return ErrCertificatePinMismatch
+			}
+			sum := sha256.Sum256(rawCerts[0])
+			if !bytes.Equal(sum[:], pin) {
+				return ErrCertificatePinMismatch
+			}
+			if roots == nil {
+				return nil
+			}
+			// with a CA bundle as well, the chain must lead to it
+			leaf, err := x509.ParseCertificate(rawCerts[0])
+			if err != nil {
+				return err
+			}
+			intermediates := x509.NewCertPool()
+			for _, raw := range rawCerts[1:] {
+				cert, err := x509.ParseCertificate(raw)
+				if err != nil {
+					return err
+				}
+				intermediates.AddCert(cert)
+			}
+			_, err = leaf.Verify(x509.VerifyOptions{Roots: roots, Intermediates: intermediates})
+			return err
+		},
+	}, nil
+}
+
+// ParseFingerprint decodes a SHA-256 fingerprint written in hex, with or
+// without colons between the bytes
+func ParseFingerprint(fingerprint string) ([]byte, error) {
+	pin, err := hex.DecodeString(strings.ReplaceAll(fingerprint, ":", ""))
+	if err != nil {
+		return nil, err
+	}
+	if len(pin) != sha256.Size {
+		return nil, fmt.Errorf("a SHA-256 fingerprint has %d bytes, not %d", sha256.Size, len(pin))
+	}
+	return pin, nil
+}
+
+// IsCertificateError tells whether err comes from the array certificate
+// failing verification
+func IsCertificateError(err error) bool {
+	var unknownAuthority x509.UnknownAuthorityError
+	var invalid x509.CertificateInvalidError
+	var hostname x509.HostnameError
+	return errors.Is(err, ErrCertificatePinMismatch) ||
+		errors.As(err, &unknownAuthority) ||
+		errors.As(err, &invalid) ||
+		errors.As(err, &hostname)
+}
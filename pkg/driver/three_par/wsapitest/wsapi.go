@@ -0,0 +1,1046 @@
+// Package wsapitest is a stateful stand-in for the 3PAR WSAPI, so that
+// ThreeParDriver can be tested without an array. It keeps volumes, snapshots,
+// hosts, vluns, volume sets and QoS rules in memory and answers with the codes
+// the array uses, tests can expire the sessions or make the array busy.
+package wsapitest
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"immortality-demo/pkg/data"
+	"immortality-demo/pkg/driver/three_par"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const apiPrefix = "/api/v1/"
+
+// error codes of the WSAPI besides the ones the driver already names
+const (
+	invalidInput       three_par.ErrCode = 1
+	invalidCredentials three_par.ErrCode = 5
+	invalidSessionKey  three_par.ErrCode = 6
+	nonExistentTask    three_par.ErrCode = 15
+	existentHost       three_par.ErrCode = 16
+	nonExistentHost    three_par.ErrCode = 17
+	nonExistentVLUN    three_par.ErrCode = 19
+	existentVolume     three_par.ErrCode = 22
+	volumeHasChild     three_par.ErrCode = 32
+	inUse              three_par.ErrCode = 34
+	existentSet        three_par.ErrCode = 101
+	nonExistentSet     three_par.ErrCode = 102
+)
+
+// copy types of a volume
+const (
+	baseVolume   float64 = 1
+	physicalCopy float64 = 2
+	virtualCopy  float64 = 3
+)
+
+// set actions of PUT /volumesets
+const (
+	memberAdd    = 1
+	memberRemove = 2
+)
+
+// Volume is a volume or a snapshot as the array reports it
+type Volume struct {
+	Id           float64 `json:"id"`
+	Name         string  `json:"name"`
+	SizeMiB      float64 `json:"sizeMiB"`
+	TotalUsedMiB float64 `json:"totalUsedMiB"`
+	UserCPG      string  `json:"userCPG"`
+	SnapCPG      string  `json:"snapCPG"`
+	Wwn          string  `json:"wwn"`
+	CopyOf       string  `json:"copyOf,omitempty"`
+	CopyType     float64 `json:"copyType"`
+	Comment      string  `json:"comment,omitempty"`
+}
+
+type qosRule struct {
+	Name string `json:"name"`
+	Type int8   `json:"type"`
+	three_par.QoSRules
+	Enabled bool `json:"enabled"`
+}
+
+// Server is the simulated array, its zero value is not usable, see NewServer
+type Server struct {
+	*httptest.Server
+
+	// SSDTotalMiB and NLTotalMiB size the tiers reported by /capacity
+	SSDTotalMiB float64
+	NLTotalMiB  float64
+
+	user     string
+	password string
+
+	lock       sync.Mutex
+	nextId     float64
+	sessions   map[string]bool
+	volumes    map[string]*Volume
+	hosts      map[string]*three_par.HostInfo
+	vluns      []three_par.VlunIfo
+	volumeSets map[string]*three_par.VolumeSetInfo
+	qos        map[string]*qosRule
+	tasks      map[int64]three_par.TaskInfo
+	taskStatus float64
+	busy       map[string]int
+	requests   map[string]int
+}
+
+// NewServer starts a simulated array that accepts the user and password
+func NewServer(user, password string) *Server {
+	s := newServer(user, password)
+	s.Server = httptest.NewServer(s)
+	return s
+}
+
+// NewTLSServer starts an array serving the WSAPI over https, with the
+// self-signed certificate of httptest valid for 127.0.0.1
+func NewTLSServer(user, password string) *Server {
+	s := newServer(user, password)
+	s.Server = httptest.NewTLSServer(s)
+	return s
+}
+
+func newServer(user, password string) *Server {
+	return &Server{
+		SSDTotalMiB: 1 << 20,
+		NLTotalMiB:  4 << 20,
+		user:        user,
+		password:    password,
+		sessions:    make(map[string]bool),
+		volumes:     make(map[string]*Volume),
+		hosts:       make(map[string]*three_par.HostInfo),
+		volumeSets:  make(map[string]*three_par.VolumeSetInfo),
+		qos:         make(map[string]*qosRule),
+		tasks:       make(map[int64]three_par.TaskInfo),
+		taskStatus:  three_par.TASKSTATUS_DONE,
+		busy:        make(map[string]int),
+		requests:    make(map[string]int),
+	}
+}
+
+// ServerPath is the value for ThreeParDriver.ServerPath
+func (s *Server) ServerPath() string {
+	return s.URL + strings.TrimSuffix(apiPrefix, "/")
+}
+
+// ExpireSessions drops every session key, as the array does after a restart
+// or an idle timeout
+func (s *Server) ExpireSessions() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.sessions = make(map[string]bool)
+}
+
+// Busy answers the next times requests of method on the resource, such as
+// "POST" and "vluns", with HPE3ParSystemIsBusy
+func (s *Server) Busy(method, resource string, times int) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.busy[method+" "+resource] = times
+}
+
+// Requests counts the requests of method on the resource, the busy and the
+// unauthorized ones included
+func (s *Server) Requests(method, resource string) int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.requests[method+" "+resource]
+}
+
+// SetTaskStatus sets the status the tasks started from now on report, tasks
+// are done at once by default. The driver polls active tasks until they end.
+func (s *Server) SetTaskStatus(status float64) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.taskStatus = status
+}
+
+// Volume returns the volume or snapshot named name
+func (s *Server) Volume(name string) (v Volume, ok bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if p, ok := s.volumes[name]; ok {
+		return *p, true
+	}
+	return v, false
+}
+
+// SetUsedMiB sets the space the volume takes in its CPG
+func (s *Server) SetUsedMiB(name string, usedMiB float64) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if v, ok := s.volumes[name]; ok {
+		v.TotalUsedMiB = usedMiB
+	}
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !strings.HasPrefix(r.URL.Path, apiPrefix) {
+		writeError(w, http.StatusNotFound, invalidInput, "unknown resource")
+		return
+	}
+	resource, name := splitPath(strings.TrimPrefix(r.URL.Path, apiPrefix))
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	key := r.Method + " " + resource
+	s.requests[key]++
+	if s.busy[key] > 0 {
+		s.busy[key]--
+		writeError(w, http.StatusServiceUnavailable, three_par.HPE3ParSystemIsBusy, "system is busy")
+		return
+	}
+	if resource == "credentials" {
+		s.credentials(w, r, name)
+		return
+	}
+	if !s.sessions[r.Header.Get("X-Hp3Par-Wsapi-Sessionkey")] {
+		writeError(w, http.StatusForbidden, invalidSessionKey, "invalid session key")
+		return
+	}
+
+	switch resource {
+	case "volumes":
+		s.handleVolumes(w, r, name)
+	case "hosts":
+		s.handleHosts(w, r, name)
+	case "vluns":
+		s.handleVluns(w, r, name)
+	case "volumesets":
+		s.handleVolumeSets(w, r, name)
+	case "qos":
+		s.handleQoS(w, r, name)
+	case "capacity":
+		s.capacity(w, r)
+	case "systemreporter":
+		s.volumeSpace(w, r)
+	case "tasks":
+		s.task(w, r, name)
+	default:
+		writeError(w, http.StatusNotFound, invalidInput, "unknown resource")
+	}
+}
+
+func (s *Server) credentials(w http.ResponseWriter, r *http.Request, key string) {
+	switch r.Method {
+	case http.MethodPost:
+		var body three_par.AuthenticateBody
+		if !readBody(w, r, &body) {
+			return
+		}
+		if body.User != s.user || body.Password != s.password {
+			writeError(w, http.StatusForbidden, invalidCredentials, "invalid username or password")
+			return
+		}
+		key := newKey()
+		s.sessions[key] = true
+		writeJson(w, http.StatusCreated, three_par.AuthenticateResponse{Key: key})
+	case http.MethodDelete:
+		if !s.sessions[key] {
+			writeError(w, http.StatusForbidden, invalidSessionKey, "invalid session key")
+			return
+		}
+		delete(s.sessions, key)
+		w.WriteHeader(http.StatusOK)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *Server) handleVolumes(w http.ResponseWriter, r *http.Request, name string) {
+	switch {
+	case r.Method == http.MethodGet && name == "":
+		field, value := parseQuery(r)
+		var members []Volume
+		for _, v := range s.sortedVolumes() {
+			if field == "" || field == "copyOf" && v.CopyOf == value {
+				members = append(members, *v)
+			}
+		}
+		writeJson(w, http.StatusOK, map[string]interface{}{"total": len(members), "members": members})
+	case r.Method == http.MethodPost && name == "":
+		s.createVolume(w, r)
+	case r.Method == http.MethodGet:
+		if v, ok := s.volumes[name]; ok {
+			writeJson(w, http.StatusOK, v)
+			return
+		}
+		writeError(w, http.StatusNotFound, three_par.NonExistentVolume, "volume does not exist")
+	case r.Method == http.MethodPost:
+		s.copyVolume(w, r, name)
+	case r.Method == http.MethodPut:
+		s.modifyVolume(w, r, name)
+	case r.Method == http.MethodDelete:
+		s.deleteVolume(w, name)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *Server) createVolume(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Name    string  `json:"name"`
+		Cpg     string  `json:"cpg"`
+		SizeMiB float64 `json:"sizeMiB"`
+		SnapCPG string  `json:"snapCPG"`
+		Comment string  `json:"comment"`
+	}
+	if !readBody(w, r, &body) {
+		return
+	}
+	if body.Name == "" || body.Cpg == "" || body.SizeMiB <= 0 {
+		writeError(w, http.StatusBadRequest, invalidInput, "name, cpg and sizeMiB are required")
+		return
+	}
+	if _, ok := s.volumes[body.Name]; ok {
+		writeError(w, http.StatusConflict, existentVolume, "volume exists")
+		return
+	}
+	s.addVolume(&Volume{
+		Name:     body.Name,
+		SizeMiB:  body.SizeMiB,
+		UserCPG:  body.Cpg,
+		SnapCPG:  body.SnapCPG,
+		CopyType: baseVolume,
+		Comment:  body.Comment,
+	})
+	w.WriteHeader(http.StatusCreated)
+}
+
+// copyVolume creates a snapshot or a physical copy of the volume
+func (s *Server) copyVolume(w http.ResponseWriter, r *http.Request, name string) {
+	var body struct {
+		Action     string `json:"action"`
+		Parameters struct {
+			Name       string `json:"name"`
+			DestVolume string `json:"destVolume"`
+			DestCPG    string `json:"destCPG"`
+			Online     bool   `json:"online"`
+		} `json:"parameters"`
+	}
+	if !readBody(w, r, &body) {
+		return
+	}
+	src, ok := s.volumes[name]
+	if !ok {
+		writeError(w, http.StatusNotFound, three_par.NonExistentVolume, "volume does not exist")
+		return
+	}
+	params := body.Parameters
+
+	switch body.Action {
+	case "createSnapshot":
+		if params.Name == "" {
+			writeError(w, http.StatusBadRequest, invalidInput, "name is required")
+			return
+		}
+		if _, ok := s.volumes[params.Name]; ok {
+			writeError(w, http.StatusConflict, existentVolume, "volume exists")
+			return
+		}
+		s.addVolume(&Volume{
+			Name:     params.Name,
+			SizeMiB:  src.SizeMiB,
+			UserCPG:  src.SnapCPG,
+			SnapCPG:  src.SnapCPG,
+			CopyOf:   src.Name,
+			CopyType: virtualCopy,
+		})
+		w.WriteHeader(http.StatusCreated)
+	case "createPhysicalCopy":
+		dest, exists := s.volumes[params.DestVolume]
+		if params.Online {
+			if params.DestCPG == "" {
+				writeError(w, http.StatusBadRequest, invalidInput, "destCPG is required for an online copy")
+				return
+			}
+			if exists {
+				writeError(w, http.StatusConflict, existentVolume, "volume exists")
+				return
+			}
+			s.addVolume(&Volume{
+				Name:     params.DestVolume,
+				SizeMiB:  src.SizeMiB,
+				UserCPG:  params.DestCPG,
+				SnapCPG:  params.DestCPG,
+				CopyType: physicalCopy,
+			})
+		} else {
+			if !exists {
+				writeError(w, http.StatusNotFound, three_par.NonExistentVolume, "volume does not exist")
+				return
+			}
+			if dest.SizeMiB < src.SizeMiB {
+				writeError(w, http.StatusBadRequest, invalidInput, "destination volume is smaller than the source")
+				return
+			}
+		}
+		writeJson(w, http.StatusCreated, three_par.TaskId{TaskId: s.addTask("copy_vv", name)})
+	default:
+		writeError(w, http.StatusBadRequest, invalidInput, "unknown action")
+	}
+}
+
+func (s *Server) modifyVolume(w http.ResponseWriter, r *http.Request, name string) {
+	var body struct {
+		Action  float64 `json:"action"`
+		SizeMiB float64 `json:"sizeMiB"`
+		NewName string  `json:"newName"`
+		Comment *string `json:"comment"`
+	}
+	if !readBody(w, r, &body) {
+		return
+	}
+	v, ok := s.volumes[name]
+	if !ok {
+		writeError(w, http.StatusNotFound, three_par.NonExistentVolume, "volume does not exist")
+		return
+	}
+
+	switch body.Action {
+	case three_par.GrowVolume:
+		if v.CopyType == virtualCopy || body.SizeMiB <= 0 {
+			writeError(w, http.StatusBadRequest, invalidInput, "invalid volume or size to grow")
+			return
+		}
+		v.SizeMiB += body.SizeMiB
+		w.WriteHeader(http.StatusOK)
+	case three_par.PromoteVirtualCopy:
+		if v.CopyType != virtualCopy {
+			writeError(w, http.StatusBadRequest, invalidInput, "volume is not a virtual copy")
+			return
+		}
+		writeJson(w, http.StatusOK, three_par.TaskId{TaskId: s.addTask("promote_sv", name)})
+	case 0:
+		if body.NewName != "" && body.NewName != name {
+			if _, ok := s.volumes[body.NewName]; ok {
+				writeError(w, http.StatusConflict, existentVolume, "volume exists")
+				return
+			}
+			delete(s.volumes, name)
+			v.Name = body.NewName
+			s.volumes[v.Name] = v
+		}
+		if body.Comment != nil {
+			v.Comment = *body.Comment
+		}
+		w.WriteHeader(http.StatusOK)
+	default:
+		writeError(w, http.StatusBadRequest, invalidInput, "unknown action")
+	}
+}
+
+func (s *Server) deleteVolume(w http.ResponseWriter, name string) {
+	if _, ok := s.volumes[name]; !ok {
+		writeError(w, http.StatusNotFound, three_par.NonExistentVolume, "volume does not exist")
+		return
+	}
+	for _, v := range s.volumes {
+		if v.CopyOf == name {
+			writeError(w, http.StatusConflict, volumeHasChild, "volume has a child")
+			return
+		}
+	}
+	for _, l := range s.vluns {
+		if l.VolumeName == name {
+			writeError(w, http.StatusConflict, inUse, "volume is exported")
+			return
+		}
+	}
+	// the array drops a removed volume from its sets
+	for _, set := range s.volumeSets {
+		set.Setmembers = remove(set.Setmembers, name)
+	}
+	delete(s.volumes, name)
+	w.WriteHeader(http.StatusOK)
+}
+
+func (s *Server) handleHosts(w http.ResponseWriter, r *http.Request, name string) {
+	switch {
+	case r.Method == http.MethodGet && name == "":
+		field, value := parseQuery(r)
+		var members []three_par.HostInfo
+		for _, h := range s.sortedHosts() {
+			if field == "" || field == "iSCSIPaths[name" && hasPath(h, value) {
+				members = append(members, *h)
+			}
+		}
+		writeJson(w, http.StatusOK, map[string]interface{}{"total": len(members), "members": members})
+	case r.Method == http.MethodPost && name == "":
+		s.createHost(w, r)
+	case r.Method == http.MethodGet:
+		if h, ok := s.hosts[name]; ok {
+			writeJson(w, http.StatusOK, h)
+			return
+		}
+		writeError(w, http.StatusNotFound, nonExistentHost, "host does not exist")
+	case r.Method == http.MethodPut:
+		s.modifyHost(w, r, name)
+	case r.Method == http.MethodDelete:
+		if _, ok := s.hosts[name]; !ok {
+			writeError(w, http.StatusNotFound, nonExistentHost, "host does not exist")
+			return
+		}
+		for _, l := range s.vluns {
+			if l.Hostname == name {
+				writeError(w, http.StatusConflict, inUse, "host has exported VLUNs")
+				return
+			}
+		}
+		delete(s.hosts, name)
+		w.WriteHeader(http.StatusOK)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *Server) createHost(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Name       string   `json:"name"`
+		ISCSINames []string `json:"iSCSINames"`
+		FCWWNs     []string `json:"FCWWNs"`
+		Persona    float64  `json:"persona"`
+	}
+	if !readBody(w, r, &body) {
+		return
+	}
+	if body.Name == "" {
+		writeError(w, http.StatusBadRequest, invalidInput, "name is required")
+		return
+	}
+	if _, ok := s.hosts[body.Name]; ok {
+		writeError(w, http.StatusConflict, existentHost, "host exists")
+		return
+	}
+	for _, iqn := range body.ISCSINames {
+		if owner := s.hostOfPath(iqn); owner != "" {
+			writeError(w, http.StatusConflict, inUse, "iSCSI name is used by host "+owner)
+			return
+		}
+	}
+	host := &three_par.HostInfo{Id: s.newId(), Name: body.Name, Persona: body.Persona, ISCSIPaths: []three_par.IscsiPath{}}
+	for _, iqn := range body.ISCSINames {
+		host.ISCSIPaths = append(host.ISCSIPaths, three_par.IscsiPath{Name: iqn})
+	}
+	var fcPaths []map[string]string
+	for _, wwn := range body.FCWWNs {
+		fcPaths = append(fcPaths, map[string]string{"wwn": wwn})
+	}
+	host.FCPaths = fcPaths
+	s.hosts[host.Name] = host
+	w.WriteHeader(http.StatusCreated)
+}
+
+func (s *Server) modifyHost(w http.ResponseWriter, r *http.Request, name string) {
+	var body struct {
+		NewName       string   `json:"newName"`
+		PathOperation int      `json:"pathOperation"`
+		ISCSINames    []string `json:"iSCSINames"`
+		Persona       float64  `json:"persona"`
+	}
+	if !readBody(w, r, &body) {
+		return
+	}
+	host, ok := s.hosts[name]
+	if !ok {
+		writeError(w, http.StatusNotFound, nonExistentHost, "host does not exist")
+		return
+	}
+
+	switch body.PathOperation {
+	case three_par.HOST_EDIT_OPERATION_ADD:
+		for _, iqn := range body.ISCSINames {
+			if owner := s.hostOfPath(iqn); owner != "" && owner != name {
+				writeError(w, http.StatusConflict, inUse, "iSCSI name is used by host "+owner)
+				return
+			}
+		}
+		for _, iqn := range body.ISCSINames {
+			if !hasPath(host, iqn) {
+				host.ISCSIPaths = append(host.ISCSIPaths, three_par.IscsiPath{Name: iqn})
+			}
+		}
+	case three_par.HOST_EDIT_OPERATION_REMOVE:
+		paths := host.ISCSIPaths[:0]
+		for _, p := range host.ISCSIPaths {
+			if !contains(body.ISCSINames, p.Name) {
+				paths = append(paths, p)
+			}
+		}
+		host.ISCSIPaths = paths
+	}
+	if body.Persona != 0 {
+		host.Persona = body.Persona
+	}
+	if body.NewName != "" && body.NewName != name {
+		if _, ok := s.hosts[body.NewName]; ok {
+			writeError(w, http.StatusConflict, existentHost, "host exists")
+			return
+		}
+		delete(s.hosts, name)
+		host.Name = body.NewName
+		s.hosts[host.Name] = host
+		for i := range s.vluns {
+			if s.vluns[i].Hostname == name {
+				s.vluns[i].Hostname = host.Name
+			}
+		}
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+func (s *Server) handleVluns(w http.ResponseWriter, r *http.Request, name string) {
+	switch {
+	case r.Method == http.MethodGet && name == "":
+		field, value := parseQuery(r)
+		var members []three_par.VlunIfo
+		for _, l := range s.vluns {
+			if field == "" || field == "hostname" && l.Hostname == value {
+				members = append(members, l)
+			}
+		}
+		writeJson(w, http.StatusOK, map[string]interface{}{"total": len(members), "members": members})
+	case r.Method == http.MethodPost && name == "":
+		s.createVlun(w, r)
+	case r.Method == http.MethodDelete:
+		// the vlun is named volume,lun,host
+		parts := strings.Split(name, ",")
+		if len(parts) == 3 {
+			for i, l := range s.vluns {
+				if l.VolumeName == parts[0] && strconv.FormatFloat(l.Lun, 'f', 0, 64) == parts[1] && l.Hostname == parts[2] {
+					s.vluns = append(s.vluns[:i], s.vluns[i+1:]...)
+					w.WriteHeader(http.StatusOK)
+					return
+				}
+			}
+		}
+		writeError(w, http.StatusNotFound, nonExistentVLUN, "VLUN does not exist")
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *Server) createVlun(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		VolumeName string  `json:"volumeName"`
+		Hostname   string  `json:"hostname"`
+		Lun        float64 `json:"lun"`
+		AutoLun    bool    `json:"autoLun"`
+	}
+	if !readBody(w, r, &body) {
+		return
+	}
+	volume, ok := s.volumes[body.VolumeName]
+	if !ok {
+		writeError(w, http.StatusNotFound, three_par.NonExistentVolume, "volume does not exist")
+		return
+	}
+	if _, ok := s.hosts[body.Hostname]; !ok {
+		writeError(w, http.StatusNotFound, nonExistentHost, "host does not exist")
+		return
+	}
+	used := make(map[float64]bool)
+	for _, l := range s.vluns {
+		if l.Hostname == body.Hostname {
+			used[l.Lun] = true
+		}
+	}
+	lun := body.Lun
+	if body.AutoLun {
+		// the array takes the lowest free LUN from lun on
+		for used[lun] {
+			lun++
+		}
+	} else if used[lun] {
+		writeError(w, http.StatusConflict, inUse, "LUN is in use")
+		return
+	}
+
+	s.vluns = append(s.vluns, three_par.VlunIfo{
+		Lun:        lun,
+		VolumeName: volume.Name,
+		Hostname:   body.Hostname,
+		VolumeWWN:  volume.Wwn,
+	})
+	w.Header().Set("Location", fmt.Sprintf("%svluns/%s,%d,%s", apiPrefix, volume.Name, int64(lun), body.Hostname))
+	w.WriteHeader(http.StatusCreated)
+}
+
+func (s *Server) handleVolumeSets(w http.ResponseWriter, r *http.Request, name string) {
+	switch {
+	case r.Method == http.MethodPost && name == "":
+		var body struct {
+			Name       string   `json:"name"`
+			Comment    string   `json:"comment"`
+			Setmembers []string `json:"setmembers"`
+		}
+		if !readBody(w, r, &body) {
+			return
+		}
+		if _, ok := s.volumeSets[body.Name]; ok {
+			writeError(w, http.StatusConflict, existentSet, "Set exists")
+			return
+		}
+		if !s.volumesExist(w, body.Setmembers) {
+			return
+		}
+		s.volumeSets[body.Name] = &three_par.VolumeSetInfo{
+			Id:         s.newId(),
+			Name:       body.Name,
+			Comment:    body.Comment,
+			Setmembers: append([]string{}, body.Setmembers...),
+		}
+		w.WriteHeader(http.StatusCreated)
+	case r.Method == http.MethodGet:
+		set, ok := s.volumeSets[name]
+		if !ok {
+			writeError(w, http.StatusNotFound, nonExistentSet, "set does not exist")
+			return
+		}
+		_, set.QosEnabled = s.qos[qosKey(three_par.QoS_TargetType_VVSET, name)]
+		writeJson(w, http.StatusOK, set)
+	case r.Method == http.MethodPut:
+		s.modifyVolumeSet(w, r, name)
+	case r.Method == http.MethodDelete:
+		if _, ok := s.volumeSets[name]; !ok {
+			writeError(w, http.StatusNotFound, nonExistentSet, "set does not exist")
+			return
+		}
+		delete(s.volumeSets, name)
+		delete(s.qos, qosKey(three_par.QoS_TargetType_VVSET, name))
+		w.WriteHeader(http.StatusOK)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *Server) modifyVolumeSet(w http.ResponseWriter, r *http.Request, name string) {
+	var body struct {
+		Action     int      `json:"action"`
+		NewName    string   `json:"newName"`
+		Comment    *string  `json:"comment"`
+		Setmembers []string `json:"setmembers"`
+	}
+	if !readBody(w, r, &body) {
+		return
+	}
+	set, ok := s.volumeSets[name]
+	if !ok {
+		writeError(w, http.StatusNotFound, nonExistentSet, "set does not exist")
+		return
+	}
+
+	switch body.Action {
+	case memberAdd:
+		if !s.volumesExist(w, body.Setmembers) {
+			return
+		}
+		for _, m := range body.Setmembers {
+			if contains(set.Setmembers, m) {
+				writeError(w, http.StatusConflict, three_par.HaveExistentVolume, "object is already part of the set")
+				return
+			}
+		}
+		set.Setmembers = append(set.Setmembers, body.Setmembers...)
+	case memberRemove:
+		for _, m := range body.Setmembers {
+			if !contains(set.Setmembers, m) {
+				writeError(w, http.StatusNotFound, three_par.NonExistentVolume, "volume is not part of the set")
+				return
+			}
+		}
+		for _, m := range body.Setmembers {
+			set.Setmembers = remove(set.Setmembers, m)
+		}
+	}
+	if body.Comment != nil {
+		set.Comment = *body.Comment
+	}
+	if body.NewName != "" && body.NewName != name {
+		if _, ok := s.volumeSets[body.NewName]; ok {
+			writeError(w, http.StatusConflict, existentSet, "Set exists")
+			return
+		}
+		delete(s.volumeSets, name)
+		set.Name = body.NewName
+		s.volumeSets[set.Name] = set
+		if rule, ok := s.qos[qosKey(three_par.QoS_TargetType_VVSET, name)]; ok {
+			delete(s.qos, qosKey(three_par.QoS_TargetType_VVSET, name))
+			rule.Name = set.Name
+			s.qos[qosKey(three_par.QoS_TargetType_VVSET, set.Name)] = rule
+		}
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+func (s *Server) handleQoS(w http.ResponseWriter, r *http.Request, name string) {
+	if r.Method == http.MethodPost && name == "" {
+		var rule qosRule
+		if !readBody(w, r, &rule) {
+			return
+		}
+		if _, ok := three_par.QoSTargetType[rule.Type]; !ok {
+			writeError(w, http.StatusBadRequest, invalidInput, "invalid target type")
+			return
+		}
+		if _, ok := s.volumeSets[rule.Name]; !ok && rule.Type == three_par.QoS_TargetType_VVSET {
+			writeError(w, http.StatusNotFound, nonExistentSet, "set does not exist")
+			return
+		}
+		key := qosKey(rule.Type, rule.Name)
+		if _, ok := s.qos[key]; ok {
+			writeError(w, http.StatusConflict, three_par.ExistentQoSRule, "QoS rule exists")
+			return
+		}
+		rule.Enabled = true
+		s.qos[key] = &rule
+		w.WriteHeader(http.StatusCreated)
+		return
+	}
+
+	// the rule is named type:target
+	rule, ok := s.qos[name]
+	if !ok {
+		writeError(w, http.StatusNotFound, three_par.NonExistentQoSRule, "QoS rule does not exist")
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		writeJson(w, http.StatusOK, rule)
+	case http.MethodPut:
+		if !readBody(w, r, &rule.QoSRules) {
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	case http.MethodDelete:
+		delete(s.qos, name)
+		w.WriteHeader(http.StatusOK)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// capacity reports the SSD tier for the ssd, hybrid-ssd and image CPGs and the
+// NL tier for the others
+func (s *Server) capacity(w http.ResponseWriter, r *http.Request) {
+	var capacity data.SystemCapacity
+	capacity.SSDCapacity.TotalMiB = s.SSDTotalMiB
+	capacity.SSDCapacity.FreeMiB = s.SSDTotalMiB
+	capacity.NLCapacity.TotalMiB = s.NLTotalMiB
+	capacity.NLCapacity.FreeMiB = s.NLTotalMiB
+	for _, v := range s.volumes {
+		if isSSD(v.UserCPG) {
+			capacity.SSDCapacity.FreeMiB -= v.TotalUsedMiB
+		} else {
+			capacity.NLCapacity.FreeMiB -= v.TotalUsedMiB
+		}
+	}
+	capacity.AllCapacity.TotalMiB = capacity.SSDCapacity.TotalMiB + capacity.NLCapacity.TotalMiB
+	capacity.AllCapacity.FreeMiB = capacity.SSDCapacity.FreeMiB + capacity.NLCapacity.FreeMiB
+	writeJson(w, http.StatusOK, capacity)
+}
+
+// volumeSpace answers the hires volume space report grouped by userCPG, the
+// only one the driver asks for
+func (s *Server) volumeSpace(w http.ResponseWriter, r *http.Request) {
+	if !strings.HasSuffix(r.URL.Path, "/attime/volumespacedata/hires;groupby:userCPG") {
+		writeError(w, http.StatusNotFound, invalidInput, "unknown report")
+		return
+	}
+	byCPG := make(map[string]*three_par.AtTimeVolumeSpaceData)
+	var cpgs []string
+	for _, v := range s.volumes {
+		m, ok := byCPG[v.UserCPG]
+		if !ok {
+			m = &three_par.AtTimeVolumeSpaceData{UserCPG: v.UserCPG}
+			byCPG[v.UserCPG] = m
+			cpgs = append(cpgs, v.UserCPG)
+		}
+		m.TotalSpace.UsedMiB += v.TotalUsedMiB
+		m.TotalSpace.VirtualSizeMiB += v.SizeMiB
+	}
+	sort.Strings(cpgs)
+
+	now := time.Now()
+	response := three_par.AtTimeVolumeSpaceResponse{
+		SampleTime:    now.Format(time.RFC3339),
+		SampleTimeSec: int32(now.Unix()),
+		Total:         int32(len(cpgs)),
+		Members:       []three_par.AtTimeVolumeSpaceData{},
+	}
+	for _, cpg := range cpgs {
+		response.Members = append(response.Members, *byCPG[cpg])
+	}
+	writeJson(w, http.StatusOK, response)
+}
+
+func (s *Server) task(w http.ResponseWriter, r *http.Request, name string) {
+	id, _ := strconv.ParseInt(name, 10, 64)
+	task, ok := s.tasks[id]
+	if r.Method != http.MethodGet || !ok {
+		writeError(w, http.StatusNotFound, nonExistentTask, "task does not exist")
+		return
+	}
+	writeJson(w, http.StatusOK, task)
+}
+
+func (s *Server) newId() float64 {
+	s.nextId++
+	return s.nextId
+}
+
+func (s *Server) addVolume(v *Volume) {
+	v.Id = s.newId()
+	v.Wwn = fmt.Sprintf("60002AC%025X", int64(v.Id))
+	s.volumes[v.Name] = v
+}
+
+func (s *Server) addTask(taskType, name string) float64 {
+	id := s.newId()
+	now := time.Now().Format("2006-01-02 15:04:05 MST")
+	task := three_par.TaskInfo{
+		Id:        id,
+		Type:      1,
+		Name:      taskType + "." + name,
+		Status:    s.taskStatus,
+		StartTime: now,
+		User:      s.user,
+	}
+	if task.Status != three_par.TASKSTATUS_ACTIVE {
+		task.FinishTime = now
+	}
+	s.tasks[int64(id)] = task
+	return id
+}
+
+// volumesExist writes the error for the first of names that is not a volume
+func (s *Server) volumesExist(w http.ResponseWriter, names []string) bool {
+	for _, n := range names {
+		if _, ok := s.volumes[n]; !ok {
+			writeError(w, http.StatusNotFound, three_par.NonExistentVolume, "volume does not exist")
+			return false
+		}
+	}
+	return true
+}
+
+func (s *Server) hostOfPath(iqn string) string {
+	for _, h := range s.hosts {
+		if hasPath(h, iqn) {
+			return h.Name
+		}
+	}
+	return ""
+}
+
+func (s *Server) sortedVolumes() []*Volume {
+	volumes := make([]*Volume, 0, len(s.volumes))
+	for _, v := range s.volumes {
+		volumes = append(volumes, v)
+	}
+	sort.Slice(volumes, func(i, j int) bool { return volumes[i].Id < volumes[j].Id })
+	return volumes
+}
+
+func (s *Server) sortedHosts() []*three_par.HostInfo {
+	hosts := make([]*three_par.HostInfo, 0, len(s.hosts))
+	for _, h := range s.hosts {
+		hosts = append(hosts, h)
+	}
+	sort.Slice(hosts, func(i, j int) bool { return hosts[i].Id < hosts[j].Id })
+	return hosts
+}
+
+func qosKey(targetType int8, name string) string {
+	return three_par.QoSTargetType[targetType] + ":" + name
+}
+
+func isSSD(cpg string) bool {
+	return cpg == three_par.SSD_CPG || cpg == three_par.HYBRID_SSD_CPG || cpg == three_par.IMAGE_CPG
+}
+
+func hasPath(h *three_par.HostInfo, iqn string) bool {
+	for _, p := range h.ISCSIPaths {
+		if p.Name == iqn {
+			return true
+		}
+	}
+	return false
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+func remove(list []string, s string) []string {
+	result := list[:0]
+	for _, v := range list {
+		if v != s {
+			result = append(result, v)
+		}
+	}
+	return result
+}
+
+// splitPath splits "volumes/name" into the resource and the name, the name
+// of the system reporter is the rest of the path
+func splitPath(path string) (resource, name string) {
+	parts := strings.SplitN(path, "/", 2)
+	if len(parts) == 2 {
+		return parts[0], parts[1]
+	}
+	return parts[0], ""
+}
+
+// parseQuery parses the single condition queries the driver sends, such as
+// "copyOf EQ name" or "iSCSIPaths[name EQ iqn]" with tabs between the words
+func parseQuery(r *http.Request) (field, value string) {
+	query := strings.Trim(r.URL.Query().Get("query"), "\"")
+	parts := strings.Split(strings.TrimSuffix(query, "]"), "\t")
+	if len(parts) != 3 || parts[1] != "EQ" {
+		return "", ""
+	}
+	return parts[0], parts[2]
+}
+
+func readBody(w http.ResponseWriter, r *http.Request, out interface{}) bool {
+	if err := json.NewDecoder(r.Body).Decode(out); err != nil {
+		writeError(w, http.StatusBadRequest, invalidInput, "malformed json: "+err.Error())
+		return false
+	}
+	return true
+}
+
+func writeJson(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+func writeError(w http.ResponseWriter, status int, code three_par.ErrCode, desc string) {
+	writeJson(w, status, three_par.HttpResponseBody{Code: code, Desc: desc})
+}
+
+func newKey() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,82 @@
+// Package envelope is the message contract of the job queue, producers encode
+// jobs with it and the worker pool decodes them.
+package envelope
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Version is the schema version written by Encode
+const Version = 1
+
+var (
+	ErrUnsupportedVersion = errors.New("unsupported envelope version")
+	ErrMissingAction      = errors.New("envelope has no action")
+)
+
+// Envelope wraps the payload of a job with what the worker needs to route and
+// track it. Payload is the marshalled request of the action, e.g. a
+// data.CreateDiskRequest for CreateDisk.
+type Envelope struct {
+	Version    int              `json:"version"`
+	Action     string           `json:"action"`
+	RequestId  string           `json:"request_id"`
+	EnqueuedAt time.Time        `json:"enqueued_at"`
+	Attempts   int              `json:"attempts"`
+	Fence      int64            `json:"fence,omitempty"`  // lock token kept for a keyed job until its retry runs
+	Fences     map[string]int64 `json:"fences,omitempty"` // tokens of the other keys of the job, kept like Fence
+	Payload    json.RawMessage  `json:"payload"`
+}
+
+// New builds an envelope of the current version for the payload
+func New(action, requestId string, payload interface{}) (*Envelope, error) {
+	if action == "" {
+		return nil, ErrMissingAction
+	}
+	b, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	return &Envelope{
+		Version:    Version,
+		Action:     action,
+		RequestId:  requestId,
+		EnqueuedAt: time.Now(),
+		Payload:    b,
+	}, nil
+}
+
+// Encode returns the queue entry of the envelope
+func Encode(e *Envelope) ([]byte, error) {
+	if e.Action == "" {
+		return nil, ErrMissingAction
+	}
+	if e.Version == 0 {
+		e.Version = Version
+	}
+	return json.Marshal(e)
+}
+
+// Decode parses a queue entry, entries of another version are rejected with
+// an error wrapping ErrUnsupportedVersion.
+func Decode(b []byte) (*Envelope, error) {
+	var e Envelope
+	if err := json.Unmarshal(b, &e); err != nil {
+		return nil, err
+	}
+	if e.Version != Version {
+		return nil, fmt.Errorf("%w: got %d, want %d", ErrUnsupportedVersion, e.Version, Version)
+	}
+	if e.Action == "" {
+		return nil, ErrMissingAction
+	}
+	return &e, nil
+}
+
+// Unmarshal parses the payload into v
+func (e *Envelope) Unmarshal(v interface{}) error {
+	return json.Unmarshal(e.Payload, v)
+}
@@ -0,0 +1,51 @@
+package envelope
+
+import (
+	"errors"
+	"testing"
+)
+
+type createDiskRequest struct {
+	DiskId string `json:"disk_id"`
+	Size   uint64 `json:"size"`
+}
+
+func TestEncodeDecode(t *testing.T) {
+	e, err := New("CreateDisk", "req-1", createDiskRequest{DiskId: "disk-1", Size: 1 << 30})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := Encode(e)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := Decode(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Action != "CreateDisk" || got.RequestId != "req-1" || got.Version != Version {
+		t.Fatalf("decoded %+v", got)
+	}
+	var req createDiskRequest
+	if err = got.Unmarshal(&req); err != nil {
+		t.Fatal(err)
+	}
+	if req.DiskId != "disk-1" || req.Size != 1<<30 {
+		t.Fatalf("payload %+v", req)
+	}
+}
+
+func TestDecodeRejects(t *testing.T) {
+	cases := map[string]error{
+		`{"version":2,"action":"CreateDisk","payload":{}}`: ErrUnsupportedVersion,
+		// a bare request as pushed by old producers
+		`{"request_id":"req-1","disk_id":"disk-1"}`: ErrUnsupportedVersion,
+		`{"version":1,"payload":{}}`:                ErrMissingAction,
+	}
+	for in, want := range cases {
+		if _, err := Decode([]byte(in)); !errors.Is(err, want) {
+			t.Errorf("Decode(%s) = %v, want %v", in, err, want)
+		}
+	}
+}
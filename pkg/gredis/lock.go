@@ -0,0 +1,251 @@
+package gredis
+
+import (
+	"github.com/gomodule/redigo/redis"
+	"strings"
+	"time"
+)
+
+// Keyed locks serialize the jobs of one disk, snapshot or image across nodes.
+// The lock value is a fencing token taken from an ever growing counter, so a
+// holder whose lock expired can not renew or release the lock of the next one.
+// Jobs arriving while the key is locked are parked in a per-key list, and the
+// holder runs them one by one before it lets the lock go.
+//
+// Fencing is advisory only: the tokens guard the lock operations, they are not
+// passed to the drivers or checked on the database writes of a job. The worker
+// pool renews the locks of a running job every lockTTL/3, so a lock only
+// expires under a job if its node stalls or loses redis for a whole lockTTL.
+// The stale attempt then keeps running until it ends or hits JobTimeout, and
+// may overlap with the next holder.
+
+// acquireScript
+// KEYS: lock, fence, parked, processing
+// ARGV: ttl in ms, item, fence token the item was running under (0 if none)
+// returns {token, item to run} or {0} if the item was parked
+var acquireScript = redis.NewScript(4, `
+local cur = redis.call('GET', KEYS[1])
+if cur then
+	if ARGV[3] ~= '0' and cur == ARGV[3] then
+		redis.call('PEXPIRE', KEYS[1], ARGV[1])
+		return {tonumber(cur), ARGV[2]}
+	end
+	redis.call('RPUSH', KEYS[3], ARGV[2])
+	redis.call('LREM', KEYS[4], 1, ARGV[2])
+	return {0}
+end
+local token = redis.call('INCR', KEYS[2])
+redis.call('SET', KEYS[1], token, 'PX', ARGV[1])
+if redis.call('LLEN', KEYS[3]) > 0 then
+	redis.call('RPUSH', KEYS[3], ARGV[2])
+	redis.call('LREM', KEYS[4], 1, ARGV[2])
+	local head = redis.call('LPOP', KEYS[3])
+	redis.call('LPUSH', KEYS[4], head)
+	return {token, head}
+end
+return {token, ARGV[2]}
+`)
+
+// nextScript hands the lock over to the next parked item or deletes it
+// KEYS: lock, fence, parked, processing
+// ARGV: token, ttl in ms
+// returns {token, item to run} or {0}
+var nextScript = redis.NewScript(4, `
+if redis.call('GET', KEYS[1]) ~= ARGV[1] then
+	return {0}
+end
+local head = redis.call('LPOP', KEYS[3])
+if not head then
+	redis.call('DEL', KEYS[1])
+	return {0}
+end
+redis.call('LPUSH', KEYS[4], head)
+local token = redis.call('INCR', KEYS[2])
+redis.call('SET', KEYS[1], token, 'PX', ARGV[2])
+return {token, head}
+`)
+
+// wakeScript takes an expired key with parked items
+// KEYS: lock, fence, parked, processing
+// ARGV: ttl in ms
+// returns {token, item to run} or {0}
+var wakeScript = redis.NewScript(4, `
+if redis.call('EXISTS', KEYS[1]) == 1 then
+	return {0}
+end
+local head = redis.call('LPOP', KEYS[3])
+if not head then
+	return {0}
+end
+redis.call('LPUSH', KEYS[4], head)
+local token = redis.call('INCR', KEYS[2])
+redis.call('SET', KEYS[1], token, 'PX', ARGV[1])
+return {token, head}
+`)
+
+// tryLocksScript locks every key at once or none of them, it never parks. It
+// takes the other keys of a job which is already running under the lock of
+// its first key.
+// KEYS: lock, fence and parked of each key
+// ARGV: ttl in ms, then for each key the token the job held it under (0 if none)
+// returns the token of each key, or {} if a key is locked by another job or
+// has parked items
+var tryLocksScript = redis.NewScript(-1, `
+local n = #KEYS / 3
+for i = 1, n do
+	local cur = redis.call('GET', KEYS[3*i-2])
+	if cur then
+		if ARGV[i+1] == '0' or cur ~= ARGV[i+1] then
+			return {}
+		end
+	elseif redis.call('LLEN', KEYS[3*i]) > 0 then
+		return {}
+	end
+end
+local tokens = {}
+for i = 1, n do
+	local cur = redis.call('GET', KEYS[3*i-2])
+	if cur then
+		redis.call('PEXPIRE', KEYS[3*i-2], ARGV[1])
+		tokens[i] = tonumber(cur)
+	else
+		local token = redis.call('INCR', KEYS[3*i-1])
+		redis.call('SET', KEYS[3*i-2], token, 'PX', ARGV[1])
+		tokens[i] = token
+	end
+end
+return tokens
+`)
+
+var holdScript = redis.NewScript(1, `
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('PEXPIRE', KEYS[1], ARGV[2])
+end
+return 0
+`)
+
+func lockKey(queue, key string) string {
+	return queue + ":lock:" + key
+}
+
+func fenceKey(queue, key string) string {
+	return queue + ":fence:" + key
+}
+
+func ParkedKey(queue, key string) string {
+	return queue + ":parked:" + key
+}
+
+func lockKeys(queue, key, node string) []interface{} {
+	return []interface{}{lockKey(queue, key), fenceKey(queue, key), ParkedKey(queue, key), ProcessingKey(queue, node)}
+}
+
+func milliseconds(d time.Duration) int64 {
+	return int64(d / time.Millisecond)
+}
+
+// tokenReply parses the {token, item} reply of the lock scripts
+func tokenReply(reply interface{}, err error) (int64, []byte, error) {
+	values, err := redis.Values(reply, err)
+	if err != nil {
+		return 0, nil, err
+	}
+	token, err := redis.Int64(values[0], nil)
+	if err != nil || token == 0 || len(values) < 2 {
+		return 0, nil, err
+	}
+	item, err := redis.Bytes(values[1], nil)
+	return token, item, err
+}
+
+// AcquireOrPark locks key for item, which must be in the processing list of
+// node. If the key is locked by another job the item is moved to the parked
+// list and a zero token is returned. fence is the token item ran under before
+// a retry, it takes the lock back if it is still held for the item. If older
+// items are parked the oldest one is returned to run instead of item.
+func AcquireOrPark(queue, key, node string, ttl time.Duration, item []byte, fence int64) (int64, []byte, error) {
+	conn := RedisConn.Get()
+	defer conn.Close()
+
+	args := append(lockKeys(queue, key, node), milliseconds(ttl), item, fence)
+	return tokenReply(acquireScript.Do(conn, args...))
+}
+
+// ReleaseOrNext releases the lock of token, or hands it over to the next
+// parked item which is then moved to the processing list of node and returned.
+func ReleaseOrNext(queue, key, node string, token int64, ttl time.Duration) (int64, []byte, error) {
+	conn := RedisConn.Get()
+	defer conn.Close()
+
+	args := append(lockKeys(queue, key, node), token, milliseconds(ttl))
+	return tokenReply(nextScript.Do(conn, args...))
+}
+
+// Wake locks a key whose holder died while items were parked, and returns the
+// oldest parked item to run.
+func Wake(queue, key, node string, ttl time.Duration) (int64, []byte, error) {
+	conn := RedisConn.Get()
+	defer conn.Close()
+
+	args := append(lockKeys(queue, key, node), milliseconds(ttl))
+	return tokenReply(wakeScript.Do(conn, args...))
+}
+
+// TryLocks locks all the keys for one job, or none of them if one is taken.
+// fences are the tokens the job held the keys under before a retry, in the
+// order of keys, nil if none. It returns nil tokens if the keys are not all
+// free. The locks are released with ReleaseOrNext like the others.
+func TryLocks(queue string, keys []string, ttl time.Duration, fences []int64) ([]int64, error) {
+	conn := RedisConn.Get()
+	defer conn.Close()
+
+	args := []interface{}{3 * len(keys)}
+	for _, key := range keys {
+		args = append(args, lockKey(queue, key), fenceKey(queue, key), ParkedKey(queue, key))
+	}
+	args = append(args, milliseconds(ttl))
+	for i := range keys {
+		var fence int64
+		if i < len(fences) {
+			fence = fences[i]
+		}
+		args = append(args, fence)
+	}
+	tokens, err := redis.Int64s(tryLocksScript.Do(conn, args...))
+	if err != nil || len(tokens) != len(keys) {
+		return nil, err
+	}
+	return tokens, nil
+}
+
+// HoldLock extends the lock of token to ttl, it returns false if the lock is lost
+func HoldLock(queue, key string, token int64, ttl time.Duration) (bool, error) {
+	conn := RedisConn.Get()
+	defer conn.Close()
+
+	return redis.Bool(holdScript.Do(conn, lockKey(queue, key), token, milliseconds(ttl)))
+}
+
+// ParkedKeys returns the keys which have parked items
+func ParkedKeys(queue string) ([]string, error) {
+	conn := RedisConn.Get()
+	defer conn.Close()
+
+	prefix := ParkedKey(queue, "")
+	var keys []string
+	cursor := 0
+	for {
+		values, err := redis.Values(conn.Do("SCAN", cursor, "MATCH", prefix+"*", "COUNT", 100))
+		if err != nil {
+			return nil, err
+		}
+		cursor, _ = redis.Int(values[0], nil)
+		names, _ := redis.Strings(values[1], nil)
+		for _, name := range names {
+			keys = append(keys, strings.TrimPrefix(name, prefix))
+		}
+		if cursor == 0 {
+			return keys, nil
+		}
+	}
+}
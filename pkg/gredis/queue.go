@@ -0,0 +1,225 @@
+package gredis
+
+import (
+	"github.com/gomodule/redigo/redis"
+	"time"
+)
+
+// A reliable queue: producers LPUSH into the queue, consumers atomically move
+// the tail into their own processing list with BRPOPLPUSH and remove it with
+// Ack once it has been handled. Entries left in the processing list of a node
+// whose heartbeat expired are moved back to the queue by Reap.
+
+// requeueScript moves every entry of KEYS[1] back to the consuming end of
+// KEYS[2], oldest entry last so it is consumed first.
+var requeueScript = redis.NewScript(2, `
+local n = 0
+while true do
+	local v = redis.call('LPOP', KEYS[1])
+	if not v then
+		break
+	end
+	redis.call('RPUSH', KEYS[2], v)
+	n = n + 1
+end
+return n
+`)
+
+func ProcessingKey(queue, node string) string {
+	return queue + ":processing:" + node
+}
+
+func HeartbeatKey(queue, node string) string {
+	return queue + ":heartbeat:" + node
+}
+
+func nodesKey(queue string) string {
+	return queue + ":nodes"
+}
+
+// Enqueue pushes an encoded entry into a reliable queue
+func Enqueue(queue string, value []byte) error {
+	conn := RedisConn.Get()
+	defer conn.Close()
+
+	_, err := conn.Do("LPUSH", queue, value)
+	return err
+}
+
+// Dequeue moves the oldest entry of the queue into the processing list of the
+// node, blocking up to timeout. It returns nil if the queue stays empty.
+func Dequeue(queue, node string, timeout time.Duration) ([]byte, error) {
+	conn := RedisConn.Get()
+	defer conn.Close()
+
+	seconds := int(timeout / time.Second)
+	if seconds < 1 {
+		seconds = 1
+	}
+	reply, err := redis.Bytes(conn.Do("BRPOPLPUSH", queue, ProcessingKey(queue, node), seconds))
+	if err == redis.ErrNil {
+		return nil, nil
+	}
+	return reply, err
+}
+
+// Ack removes an entry returned by Dequeue from the processing list of the node
+func Ack(queue, node string, item []byte) error {
+	conn := RedisConn.Get()
+	defer conn.Close()
+
+	_, err := conn.Do("LREM", ProcessingKey(queue, node), 1, item)
+	return err
+}
+
+// Heartbeat marks the node as alive for ttl
+func Heartbeat(queue, node string, ttl time.Duration) error {
+	conn := RedisConn.Get()
+	defer conn.Close()
+
+	_, err := conn.Do("SET", HeartbeatKey(queue, node), time.Now().Unix(), "PX", int64(ttl/time.Millisecond))
+	if err != nil {
+		return err
+	}
+	_, err = conn.Do("SADD", nodesKey(queue), node)
+	return err
+}
+
+// Requeue moves everything in the processing list of the node back to the queue
+func Requeue(queue, node string) (int, error) {
+	conn := RedisConn.Get()
+	defer conn.Close()
+
+	return redis.Int(requeueScript.Do(conn, ProcessingKey(queue, node), queue))
+}
+
+// Reap requeues the in-flight entries of every node whose heartbeat expired
+// and returns the number of entries moved back.
+func Reap(queue string) (int, error) {
+	conn := RedisConn.Get()
+	defer conn.Close()
+
+	nodes, err := redis.Strings(conn.Do("SMEMBERS", nodesKey(queue)))
+	if err != nil {
+		return 0, err
+	}
+
+	total := 0
+	for _, node := range nodes {
+		alive, err := redis.Bool(conn.Do("EXISTS", HeartbeatKey(queue, node)))
+		if err != nil {
+			return total, err
+		}
+		if alive {
+			continue
+		}
+		n, err := redis.Int(requeueScript.Do(conn, ProcessingKey(queue, node), queue))
+		if err != nil {
+			return total, err
+		}
+		total += n
+		if _, err = conn.Do("SREM", nodesKey(queue), node); err != nil {
+			return total, err
+		}
+	}
+	return total, nil
+}
+
+// moveDueScript moves the entries of the delayed set KEYS[1] whose score is not
+// after ARGV[1] to the consuming end of the queue KEYS[2].
+var moveDueScript = redis.NewScript(2, `
+local items = redis.call('ZRANGEBYSCORE', KEYS[1], '-inf', ARGV[1])
+for _, v in ipairs(items) do
+	redis.call('ZREM', KEYS[1], v)
+	redis.call('RPUSH', KEYS[2], v)
+end
+return #items
+`)
+
+// replayScript moves ARGV[1] out of the dead-letter list KEYS[1] and pushes
+// ARGV[2] in its place to the consuming end of the queue KEYS[2].
+var replayScript = redis.NewScript(2, `
+local n = redis.call('LREM', KEYS[1], 1, ARGV[1])
+if n > 0 then
+	redis.call('RPUSH', KEYS[2], ARGV[2])
+end
+return n
+`)
+
+// purgeScript deletes the dead-letter list KEYS[1] and returns the number of
+// entries it held.
+var purgeScript = redis.NewScript(1, `
+local n = redis.call('LLEN', KEYS[1])
+redis.call('DEL', KEYS[1])
+return n
+`)
+
+func DelayedKey(queue string) string {
+	return queue + ":delayed"
+}
+
+func DeadKey(queue string) string {
+	return queue + ":dead"
+}
+
+// EnqueueAt pushes an encoded entry into the delayed set of the queue, it
+// becomes available to Dequeue once MoveDue is called after at.
+func EnqueueAt(queue string, value []byte, at time.Time) error {
+	conn := RedisConn.Get()
+	defer conn.Close()
+
+	_, err := conn.Do("ZADD", DelayedKey(queue), at.UnixNano()/int64(time.Millisecond), value)
+	return err
+}
+
+// MoveDue moves the delayed entries which are due to the queue
+func MoveDue(queue string, now time.Time) (int, error) {
+	conn := RedisConn.Get()
+	defer conn.Close()
+
+	return redis.Int(moveDueScript.Do(conn, DelayedKey(queue), queue, now.UnixNano()/int64(time.Millisecond)))
+}
+
+// Bury pushes an encoded entry into the dead-letter list of the queue
+func Bury(queue string, value []byte) error {
+	conn := RedisConn.Get()
+	defer conn.Close()
+
+	_, err := conn.Do("LPUSH", DeadKey(queue), value)
+	return err
+}
+
+// DeadLetters returns the raw entries of the dead-letter list, newest first
+func DeadLetters(queue string, start, stop int) ([][]byte, error) {
+	conn := RedisConn.Get()
+	defer conn.Close()
+
+	return redis.ByteSlices(conn.Do("LRANGE", DeadKey(queue), start, stop))
+}
+
+// Replay moves the dead entry item back to the queue as value. It returns
+// false if item is no longer in the dead-letter list.
+func Replay(queue string, item []byte, value []byte) (bool, error) {
+	conn := RedisConn.Get()
+	defer conn.Close()
+
+	n, err := redis.Int(replayScript.Do(conn, DeadKey(queue), queue, item, value))
+	return n > 0, err
+}
+
+// RemoveDead removes the dead entry item
+func RemoveDead(queue string, item []byte) (bool, error) {
+	conn := RedisConn.Get()
+	defer conn.Close()
+
+	n, err := redis.Int(conn.Do("LREM", DeadKey(queue), 1, item))
+	return n > 0, err
+}
+
+// PurgeDead empties the dead-letter list and returns the number of entries dropped
+func PurgeDead(queue string) (int, error) {
+	conn := RedisConn.Get()
+	defer conn.Close()
+
+	return redis.Int(purgeScript.Do(conn, DeadKey(queue)))
+}
@@ -0,0 +1,162 @@
+package gredis
+
+import (
+	"immortality-demo/config"
+	"testing"
+	"time"
+)
+
+func setupRedis(t *testing.T) {
+	config.LoadConfig()
+	Setup()
+	conn := RedisConn.Get()
+	defer conn.Close()
+	if _, err := conn.Do("PING"); err != nil {
+		t.Skip("redis is not available:", err)
+	}
+}
+
+func TestReliableQueue(t *testing.T) {
+	setupRedis(t)
+
+	queue, node := "test-queue", "test-node"
+	defer LikeDeletes(queue)
+
+	for _, action := range []string{"CreateDisk", "DeleteDisk"} {
+		if err := Enqueue(queue, []byte(action)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	first, err := Dequeue(queue, node, time.Second)
+	if err != nil || first == nil {
+		t.Fatal("dequeue failed:", err)
+	}
+	if _, err = Dequeue(queue, node, time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if err = Ack(queue, node, first); err != nil {
+		t.Fatal(err)
+	}
+
+	// the second entry was never acked, the node is dead so it goes back to the queue
+	if err = Heartbeat(queue, node, time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(10 * time.Millisecond)
+	n, err := Reap(queue)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Fatalf("reaped %d entries, want 1", n)
+	}
+	if l, _ := Len(queue); l != 1 {
+		t.Fatalf("queue length %d, want 1", l)
+	}
+}
+
+func TestDelayedAndDeadLetters(t *testing.T) {
+	setupRedis(t)
+
+	queue := "test-queue"
+	defer LikeDeletes(queue)
+
+	now := time.Now()
+	if err := EnqueueAt(queue, []byte("Export"), now.Add(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if n, _ := MoveDue(queue, now); n != 0 {
+		t.Fatalf("moved %d entries before they are due", n)
+	}
+	if n, _ := MoveDue(queue, now.Add(2*time.Minute)); n != 1 {
+		t.Fatalf("moved %d entries, want 1", n)
+	}
+
+	if err := Bury(queue, []byte("DeleteDisk")); err != nil {
+		t.Fatal(err)
+	}
+	items, err := DeadLetters(queue, 0, -1)
+	if err != nil || len(items) != 1 {
+		t.Fatal("dead letters:", len(items), err)
+	}
+	ok, err := Replay(queue, items[0], []byte("DeleteDisk"))
+	if err != nil || !ok {
+		t.Fatal("replay failed:", err)
+	}
+	if l, _ := Len(queue); l != 2 {
+		t.Fatalf("queue length %d, want 2", l)
+	}
+}
+
+func TestKeyedLock(t *testing.T) {
+	setupRedis(t)
+
+	queue, node, key := "test-queue", "test-node", "disk:d-1"
+	defer LikeDeletes(queue)
+
+	conn := RedisConn.Get()
+	for _, item := range []string{"resize", "snapshot"} {
+		conn.Do("LPUSH", ProcessingKey(queue, node), item)
+	}
+	conn.Close()
+
+	token, item, err := AcquireOrPark(queue, key, node, time.Minute, []byte("resize"), 0)
+	if err != nil || token == 0 || string(item) != "resize" {
+		t.Fatal("acquire:", token, string(item), err)
+	}
+	parked, _, err := AcquireOrPark(queue, key, node, time.Minute, []byte("snapshot"), 0)
+	if err != nil || parked != 0 {
+		t.Fatal("second job should be parked:", parked, err)
+	}
+
+	next, item, err := ReleaseOrNext(queue, key, node, token, time.Minute)
+	if err != nil || next <= token || string(item) != "snapshot" {
+		t.Fatal("next:", next, string(item), err)
+	}
+	if ok, _ := HoldLock(queue, key, token, time.Minute); ok {
+		t.Fatal("stale token must not hold the lock")
+	}
+	if last, _, _ := ReleaseOrNext(queue, key, node, next, time.Minute); last != 0 {
+		t.Fatal("lock should be released")
+	}
+}
+
+func TestTryLocks(t *testing.T) {
+	setupRedis(t)
+
+	queue, node := "test-queue", "test-node"
+	defer LikeDeletes(queue)
+
+	conn := RedisConn.Get()
+	conn.Do("LPUSH", ProcessingKey(queue, node), "delete-snapshot")
+	conn.Close()
+
+	token, _, err := AcquireOrPark(queue, "snapshot:s-1", node, time.Minute, []byte("delete-snapshot"), 0)
+	if err != nil || token == 0 {
+		t.Fatal("acquire:", token, err)
+	}
+	keys := []string{"disk:d-1", "snapshot:s-1"}
+	if tokens, err := TryLocks(queue, keys, time.Minute, nil); err != nil || tokens != nil {
+		t.Fatal("snapshot:s-1 is taken, nothing should be locked:", tokens, err)
+	}
+	if tokens, err := TryLocks(queue, keys[:1], time.Minute, nil); err != nil || tokens == nil {
+		t.Fatal("disk:d-1 was left locked:", tokens, err)
+	} else if _, _, err = ReleaseOrNext(queue, keys[0], node, tokens[0], time.Minute); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, err = ReleaseOrNext(queue, "snapshot:s-1", node, token, time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	tokens, err := TryLocks(queue, keys, time.Minute, nil)
+	if err != nil || len(tokens) != 2 {
+		t.Fatal("try locks:", tokens, err)
+	}
+	if again, err := TryLocks(queue, keys, time.Minute, tokens); err != nil || again[0] != tokens[0] || again[1] != tokens[1] {
+		t.Fatal("the fences should take the locks back:", again, err)
+	}
+	if parked, _, _ := AcquireOrPark(queue, "snapshot:s-1", node, time.Minute, []byte("delete-snapshot"), 0); parked != 0 {
+		t.Fatal("a job of snapshot:s-1 should be parked while it is locked")
+	}
+}
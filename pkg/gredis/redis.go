@@ -113,6 +113,40 @@ func Set(key string, data interface{}, time int) error {
 	return nil
 }
 
+// SetNX sets the key for seconds unless it exists, it tells whether it was set
+func SetNX(key string, data interface{}, seconds int) (bool, error) {
+	conn := RedisConn.Get()
+	defer conn.Close()
+
+	value, err := json.Marshal(data)
+	if err != nil {
+		return false, err
+	}
+
+	reply, err := redis.String(conn.Do("SET", key, value, "EX", seconds, "NX"))
+	if err == redis.ErrNil {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return reply == "OK", nil
+}
+
+// Publish sends data to the subscribers of the channel
+func Publish(channel string, data interface{}) error {
+	conn := RedisConn.Get()
+	defer conn.Close()
+
+	value, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	_, err = conn.Do("PUBLISH", channel, value)
+	return err
+}
+
 // Exists check a key
 func Exists(key string) bool {
 	conn := RedisConn.Get()
@@ -0,0 +1,84 @@
+package schedule
+
+import (
+	"immortality-demo/pkg/data"
+	"immortality-demo/pkg/db_model"
+)
+
+// PodFilter keeps the arrays of the requested pod, any array fits if no pod is given
+type PodFilter struct{}
+
+func (PodFilter) Name() string { return "PodFilter" }
+
+func (PodFilter) Pass(param data.ScheduleParam, c *Candidate) bool {
+	return param.PodId == "" || c.Info.PodId == param.PodId
+}
+
+// StorageTypeFilter keeps the arrays or clusters of the requested storage type
+type StorageTypeFilter struct{}
+
+func (StorageTypeFilter) Name() string { return "StorageTypeFilter" }
+
+func (StorageTypeFilter) Pass(param data.ScheduleParam, c *Candidate) bool {
+	return storageTypeOr3Par(param.StorageType) == storageTypeOr3Par(c.Info.StorageType)
+}
+
+func storageTypeOr3Par(storageType string) string {
+	if storageType == "" {
+		return data.HPE3PARA
+	}
+	return storageType
+}
+
+// StaleFilter drops the arrays whose capacity figures could not be refreshed
+type StaleFilter struct{}
+
+func (StaleFilter) Name() string { return "StaleFilter" }
+
+func (StaleFilter) Pass(param data.ScheduleParam, c *Candidate) bool {
+	return !c.Info.Stale
+}
+
+// CategoryFilter keeps the arrays which provide the requested disk category,
+// and the clusters dedicated to it
+type CategoryFilter struct{}
+
+func (CategoryFilter) Name() string { return "CategoryFilter" }
+
+func (CategoryFilter) Pass(param data.ScheduleParam, c *Candidate) bool {
+	if c.Info.Category != "" && c.Info.Category != param.DiskCategory {
+		return false
+	}
+	_, total, _, ok := usageOf(c.Info, param.DiskCategory)
+	return ok && total > 0
+}
+
+// ReserveFilter drops the arrays whose usage of the category reached 100 - Reserve
+// percent, or would exceed it with the requested disk
+type ReserveFilter struct{}
+
+func (ReserveFilter) Name() string { return "ReserveFilter" }
+
+func (ReserveFilter) Pass(param data.ScheduleParam, c *Candidate) bool {
+	used, total, percent, ok := usageOf(c.Info, param.DiskCategory)
+	if !ok || percent >= float64(100-c.Info.Reserve) {
+		return false
+	}
+	free := total*float64(100-c.Info.Reserve)/100 - used
+	return free >= float64(param.Size)
+}
+
+// usageOf returns the used and total capacity of the category on the array
+// and its usage in percent. Hybrid disks grow into the HDD tier, so the HDD
+// capacity is what they consume.
+func usageOf(info *db_model.ThreeParInfo, category string) (used, total, percent float64, ok bool) {
+	switch category {
+	case data.DiskCategorySSD:
+		return info.SSDUsage, info.SSDTotal, info.SSDUsagePercent, true
+	case data.DiskCategoryHDD:
+		return info.HDDUsage, info.HDDTotal, info.HDDUsagePercent, true
+	case data.DiskCategoryHybridHDD:
+		return info.HDDUsage, info.HDDTotal, info.HybridUsagePercent, true
+	}
+	return 0, 0, 0, false
+}
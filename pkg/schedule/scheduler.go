@@ -0,0 +1,174 @@
+package schedule
+
+import (
+	"immortality-demo/pkg/data"
+	"immortality-demo/pkg/db_model"
+	"immortality-demo/pkg/logger"
+	"sync"
+)
+
+// Candidate is an array the disk may be placed on
+type Candidate struct {
+	Info *db_model.ThreeParInfo
+	// Load is the number of disks being created on the array
+	Load int
+}
+
+// Filter drops the arrays which can not take the disk
+type Filter interface {
+	Name() string
+	Pass(param data.ScheduleParam, c *Candidate) bool
+}
+
+// Weigher rates the arrays which passed the filters, a higher weight is better.
+// Weights are normalized across the candidates before the multiplier applies,
+// so weighers with different units can be combined.
+type Weigher interface {
+	Name() string
+	Weigh(param data.ScheduleParam, c *Candidate) float64
+}
+
+type weigher struct {
+	Weigher
+	multiplier float64
+}
+
+type Scheduler struct {
+	filters  []Filter
+	weighers []weigher
+}
+
+var (
+	scheduler *Scheduler
+	once      sync.Once
+)
+
+// GetScheduler returns the scheduler with the default filters and weighers
+func GetScheduler() *Scheduler {
+	once.Do(func() {
+		scheduler = NewScheduler().
+			AddFilter(StorageTypeFilter{}).
+			AddFilter(PodFilter{}).
+			AddFilter(StaleFilter{}).
+			AddFilter(CategoryFilter{}).
+			AddFilter(ReserveFilter{}).
+			AddWeigher(FreeCapacityWeigher{}, 1.0).
+			AddWeigher(LoadWeigher{}, 0.5)
+	})
+	return scheduler
+}
+
+func NewScheduler() *Scheduler {
+	return &Scheduler{}
+}
+
+// AddFilter appends a filter, filters run in the order they are added
+func (s *Scheduler) AddFilter(f Filter) *Scheduler {
+	s.filters = append(s.filters, f)
+	return s
+}
+
+// AddWeigher appends a weigher, a negative multiplier prefers lower weights
+func (s *Scheduler) AddWeigher(w Weigher, multiplier float64) *Scheduler {
+	s.weighers = append(s.weighers, weigher{Weigher: w, multiplier: multiplier})
+	return s
+}
+
+// Schedule picks the array for a new disk
+func (s *Scheduler) Schedule(param data.ScheduleParam) (result data.ScheduleResult, err error) {
+	candidates, err := loadCandidates()
+	if err != nil {
+		return result, err
+	}
+
+	best := s.Pick(param, candidates)
+	if best == nil {
+		logger.Log.Error("No array fits, param:", param, "arrays:", len(candidates))
+		return result, data.ErrNoStorageAvailable
+	}
+	return data.ScheduleResult{Id: best.Info.ThreeParId}, nil
+}
+
+// Pick filters the candidates and returns the one with the highest weight, nil if none passed
+func (s *Scheduler) Pick(param data.ScheduleParam, candidates []*Candidate) *Candidate {
+	var passed []*Candidate
+	for _, c := range candidates {
+		if s.pass(param, c) {
+			passed = append(passed, c)
+		}
+	}
+	if len(passed) == 0 {
+		return nil
+	}
+
+	total := make([]float64, len(passed))
+	raw := make([]float64, len(passed))
+	for _, w := range s.weighers {
+		for i, c := range passed {
+			raw[i] = w.Weigh(param, c)
+		}
+		for i, v := range normalize(raw) {
+			total[i] += w.multiplier * v
+		}
+	}
+
+	// ties go to the lower id so the result does not depend on the query order
+	best := 0
+	for i := 1; i < len(passed); i++ {
+		if total[i] > total[best] ||
+			total[i] == total[best] && passed[i].Info.ThreeParId < passed[best].Info.ThreeParId {
+			best = i
+		}
+	}
+	return passed[best]
+}
+
+func (s *Scheduler) pass(param data.ScheduleParam, c *Candidate) bool {
+	for _, f := range s.filters {
+		if !f.Pass(param, c) {
+			logger.Log.Debug("array", c.Info.ThreeParId, "rejected by", f.Name())
+			return false
+		}
+	}
+	return true
+}
+
+// normalize scales the weights to [0, 1]
+func normalize(weights []float64) []float64 {
+	out := make([]float64, len(weights))
+	min, max := weights[0], weights[0]
+	for _, w := range weights {
+		if w < min {
+			min = w
+		}
+		if w > max {
+			max = w
+		}
+	}
+	if max == min {
+		return out
+	}
+	for i, w := range weights {
+		out[i] = (w - min) / (max - min)
+	}
+	return out
+}
+
+func loadCandidates() ([]*Candidate, error) {
+	infos, err := db_model.ThreeParInfos(data.Db)
+	if err != nil {
+		logger.Log.Error("ThreeParInfos error:", err)
+		return nil, data.ErrServerInternalDB
+	}
+	loads, err := db_model.CountDisksByCluster(data.Db, data.DiskStatusCreating)
+	if err != nil {
+		logger.Log.Error("CountDisksByCluster error:", err)
+		return nil, data.ErrServerInternalDB
+	}
+
+	candidates := make([]*Candidate, 0, len(infos))
+	for _, info := range infos {
+		candidates = append(candidates, &Candidate{Info: info, Load: loads[info.ThreeParId]})
+	}
+	return candidates, nil
+}
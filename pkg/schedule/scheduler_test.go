@@ -0,0 +1,90 @@
+package schedule
+
+import (
+	"immortality-demo/pkg/data"
+	"immortality-demo/pkg/db_model"
+	"immortality-demo/pkg/logger"
+	"os"
+	"testing"
+)
+
+func candidate(id, pod string, ssdUsage, ssdTotal float64, reserve, load int) *Candidate {
+	return &Candidate{
+		Info: &db_model.ThreeParInfo{
+			ThreeParId:      id,
+			PodId:           pod,
+			SSDUsage:        ssdUsage,
+			SSDTotal:        ssdTotal,
+			SSDUsagePercent: ssdUsage / ssdTotal * 100,
+			Reserve:         reserve,
+		},
+		Load: load,
+	}
+}
+
+func TestPick(t *testing.T) {
+	logger.Log = logger.NewStdoutLogger(os.Stdout, "")
+
+	candidates := []*Candidate{
+		candidate("3par-a", "pod-1", 100, 1000, 10, 0),
+		candidate("3par-b", "pod-1", 950, 1000, 10, 0), // over the reserve
+		candidate("3par-c", "pod-2", 0, 1000, 10, 0),   // other pod
+		candidate("3par-d", "pod-1", 100, 1000, 10, 4),
+		candidate("3par-e", "pod-1", 0, 1000, 10, 0), // stale
+		candidate("ceph-f", "pod-1", 0, 1000, 10, 0), // other storage type
+	}
+	candidates[4].Info.Stale = true
+	candidates[5].Info.StorageType = data.CEPH
+	s := GetScheduler()
+
+	param := data.ScheduleParam{DiskCategory: data.DiskCategorySSD, PodId: "pod-1"}
+	if c := s.Pick(param, candidates); c == nil || c.Info.ThreeParId != "3par-a" {
+		t.Fatalf("expected 3par-a, got %+v", c)
+	}
+
+	param.StorageType = data.CEPH
+	if c := s.Pick(param, candidates); c == nil || c.Info.ThreeParId != "ceph-f" {
+		t.Fatalf("expected ceph-f, got %+v", c)
+	}
+
+	param = data.ScheduleParam{DiskCategory: data.DiskCategorySSD, PodId: "pod-3"}
+	if c := s.Pick(param, candidates); c != nil {
+		t.Fatalf("expected no array in pod-3, got %s", c.Info.ThreeParId)
+	}
+
+	param = data.ScheduleParam{DiskCategory: data.DiskCategoryHDD, PodId: "pod-1"}
+	if c := s.Pick(param, candidates); c != nil {
+		t.Fatalf("expected no array with hdd capacity, got %s", c.Info.ThreeParId)
+	}
+
+	// 3par-a has 800GiB free below its reserve, 3par-d too but it is busier
+	param = data.ScheduleParam{DiskCategory: data.DiskCategorySSD, PodId: "pod-1", Size: 800}
+	if c := s.Pick(param, candidates); c == nil || c.Info.ThreeParId != "3par-a" {
+		t.Fatalf("expected 3par-a for 800GiB, got %+v", c)
+	}
+	param.Size = 801
+	if c := s.Pick(param, candidates); c != nil {
+		t.Fatalf("expected no array with 801GiB below the reserve, got %s", c.Info.ThreeParId)
+	}
+
+	// ceph clusters only take the disks of their own category
+	hybrid := candidate("ceph-g", "pod-1", 0, 0, 10, 0)
+	hdd := candidate("ceph-h", "pod-1", 0, 0, 10, 1)
+	for _, c := range []*Candidate{hybrid, hdd} {
+		c.Info.StorageType = data.CEPH
+		c.Info.HDDTotal = 1000
+	}
+	hybrid.Info.Category = data.DiskCategoryHybridHDD
+	hdd.Info.Category = data.DiskCategoryHDD
+	param = data.ScheduleParam{DiskCategory: data.DiskCategoryHDD, StorageType: data.CEPH}
+	if c := s.Pick(param, append(candidates, hybrid, hdd)); c == nil || c.Info.ThreeParId != "ceph-h" {
+		t.Fatalf("expected ceph-h, got %+v", c)
+	}
+
+	// a negative multiplier turns the preference of a weigher around
+	s = NewScheduler().AddFilter(PodFilter{}).AddWeigher(LoadWeigher{}, -1)
+	param = data.ScheduleParam{DiskCategory: data.DiskCategorySSD, PodId: "pod-1"}
+	if c := s.Pick(param, candidates); c == nil || c.Info.ThreeParId != "3par-d" {
+		t.Fatalf("expected 3par-d, got %+v", c)
+	}
+}
@@ -0,0 +1,24 @@
+package schedule
+
+import (
+	"immortality-demo/pkg/data"
+)
+
+// FreeCapacityWeigher prefers the arrays with more free capacity of the category
+type FreeCapacityWeigher struct{}
+
+func (FreeCapacityWeigher) Name() string { return "FreeCapacityWeigher" }
+
+func (FreeCapacityWeigher) Weigh(param data.ScheduleParam, c *Candidate) float64 {
+	used, total, _, _ := usageOf(c.Info, param.DiskCategory)
+	return total - used
+}
+
+// LoadWeigher prefers the arrays with fewer disks being created
+type LoadWeigher struct{}
+
+func (LoadWeigher) Name() string { return "LoadWeigher" }
+
+func (LoadWeigher) Weigh(param data.ScheduleParam, c *Candidate) float64 {
+	return -float64(c.Load)
+}
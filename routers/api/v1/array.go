@@ -0,0 +1,55 @@
+package v1
+
+import (
+	"github.com/gin-gonic/gin"
+	"immortality-demo/pkg/app"
+	e "immortality-demo/pkg/error"
+	"immortality-demo/service/array_service"
+	"immortality-demo/service/model"
+	"net/http"
+)
+
+// @Summary List storage arrays
+// @Description list the 3PAR arrays with their capacity and the time of their last successful poll
+// @Tags Arrays
+// @Produce  json
+// @Param X-User-Id header string true "X-User-Id"
+// @Param RequestId header string true "RequestId"
+// @Success 200 {object} app.Response
+// @Router /v1/arrays [get]
+func DescribeArrays(c *gin.Context) {
+	appG := app.Gin{C: c}
+	header := app.GetHeaderInfo(c)
+
+	result, err := (&array_service.DescribeArraysHandler{}).Handle(header.RequestId)
+	if err != nil {
+		appG.ErrorResponse(err)
+		return
+	}
+	appG.Response(http.StatusOK, e.SUCCESS, "", result)
+}
+
+// @Summary Register a storage array
+// @Description register a 3PAR array. The array is logged in to first, which checks its credentials and, over https, the certificate of its WSAPI against CaBundle, CertSha256 or the system roots. Insecure skips the check and is logged. The volume driver takes the array up when the service restarts.
+// @Tags Arrays
+// @Accept  json
+// @Produce  json
+// @Param X-User-Id header string true "X-User-Id"
+// @Param RequestId header string true "RequestId"
+// @Param body body model.RegisterArrayParams true "register array body"
+// @Success 200 {object} app.Response
+// @Router /v1/arrays [post]
+func RegisterArray(c *gin.Context) {
+	var request model.RegisterArrayParams
+	appG := app.Gin{C: c}
+
+	app.LoadBody(c, &request)
+	header := app.GetHeaderInfo(c)
+
+	result, err := (&array_service.RegisterArrayHandler{}).Handle(request, header.RequestId)
+	if err != nil {
+		appG.ErrorResponse(err)
+		return
+	}
+	appG.Response(http.StatusOK, e.SUCCESS, "", result)
+}
@@ -10,15 +10,15 @@ import (
 )
 
 // @Summary Create a disk
-// @Description create server
-// @Tags Servers
+// @Description create disk
+// @Tags Disks
 // @Accept  json
 // @Produce  json
 // @Param X-User-Id header string true "X-User-Id"
 // @Param RequestId header string true "RequestId"
-// @Param body body model.CreateRequest true "create server body"
-// @Success 200 {object} common.Response
-// @Router /v1/disk [post]
+// @Param body body model.CreateDiskParams true "create disk body"
+// @Success 200 {object} app.Response
+// @Router /v1/disks [post]
 func CreateDisk(c *gin.Context) {
 	var request model.CreateDiskParams
 	appG := app.Gin{C: c}
@@ -26,9 +26,254 @@ func CreateDisk(c *gin.Context) {
 	app.LoadBody(c, &request)
 	header := app.GetHeaderInfo(c)
 
-	result, err := (disk_service.CreateDiskHandler{}).Handle(&request, header.UserId, header.RequestId)
+	result, err := (&disk_service.CreateDiskHandler{}).Handle(request, header.UserId, header.RequestId)
 	if err != nil {
-		appG.Response(http.StatusInternalServerError, e.SUCCESS, "", result)
+		appG.ErrorResponse(err)
+		return
 	}
-	appG.Response(http.StatusOK, e.CreateDiskError, "", result)
+	appG.Response(http.StatusOK, e.SUCCESS, "", result)
+}
+
+// @Summary Delete a disk
+// @Description delete disk, the disk must be Available or Error
+// @Tags Disks
+// @Produce  json
+// @Param X-User-Id header string true "X-User-Id"
+// @Param RequestId header string true "RequestId"
+// @Param id path string true "disk id"
+// @Success 200 {object} app.Response
+// @Router /v1/disks/{id} [delete]
+func DeleteDisk(c *gin.Context) {
+	appG := app.Gin{C: c}
+	header := app.GetHeaderInfo(c)
+
+	result, err := (&disk_service.DeleteDiskHandler{}).Handle(c.Param("id"), header.UserId, header.RequestId)
+	if err != nil {
+		appG.ErrorResponse(err)
+		return
+	}
+	appG.Response(http.StatusOK, e.SUCCESS, "", result)
+}
+
+// @Summary Resize a disk
+// @Description resize disk, NewSize is in GB and must be larger than the current size
+// @Tags Disks
+// @Accept  json
+// @Produce  json
+// @Param X-User-Id header string true "X-User-Id"
+// @Param RequestId header string true "RequestId"
+// @Param id path string true "disk id"
+// @Param body body model.ResizeDiskParams true "resize disk body"
+// @Success 200 {object} app.Response
+// @Router /v1/disks/{id}/resize [post]
+func ResizeDisk(c *gin.Context) {
+	var request model.ResizeDiskParams
+	appG := app.Gin{C: c}
+
+	app.LoadBody(c, &request)
+	header := app.GetHeaderInfo(c)
+
+	result, err := (&disk_service.ResizeDiskHandler{}).Handle(c.Param("id"), request, header.UserId, header.RequestId)
+	if err != nil {
+		appG.ErrorResponse(err)
+		return
+	}
+	appG.Response(http.StatusOK, e.SUCCESS, "", result)
+}
+
+// @Summary Reset a disk
+// @Description roll disk back to one of its snapshots
+// @Tags Disks
+// @Accept  json
+// @Produce  json
+// @Param X-User-Id header string true "X-User-Id"
+// @Param RequestId header string true "RequestId"
+// @Param id path string true "disk id"
+// @Param body body model.ResetDiskParams true "reset disk body"
+// @Success 200 {object} app.Response
+// @Router /v1/disks/{id}/reset [post]
+func ResetDisk(c *gin.Context) {
+	var request model.ResetDiskParams
+	appG := app.Gin{C: c}
+
+	app.LoadBody(c, &request)
+	header := app.GetHeaderInfo(c)
+
+	result, err := (&disk_service.ResetDiskHandler{}).Handle(c.Param("id"), request, header.UserId, header.RequestId)
+	if err != nil {
+		appG.ErrorResponse(err)
+		return
+	}
+	appG.Response(http.StatusOK, e.SUCCESS, "", result)
+}
+
+// @Summary Re-initialize a disk
+// @Description re-initialize disk from the image or snapshot it was created from
+// @Tags Disks
+// @Produce  json
+// @Param X-User-Id header string true "X-User-Id"
+// @Param RequestId header string true "RequestId"
+// @Param id path string true "disk id"
+// @Success 200 {object} app.Response
+// @Router /v1/disks/{id}/reinit [post]
+func ReInitDisk(c *gin.Context) {
+	appG := app.Gin{C: c}
+	header := app.GetHeaderInfo(c)
+
+	result, err := (&disk_service.ReInitDiskHandler{}).Handle(c.Param("id"), header.UserId, header.RequestId)
+	if err != nil {
+		appG.ErrorResponse(err)
+		return
+	}
+	appG.Response(http.StatusOK, e.SUCCESS, "", result)
+}
+
+// @Summary Create a snapshot
+// @Description create snapshot of disk
+// @Tags Disks
+// @Accept  json
+// @Produce  json
+// @Param X-User-Id header string true "X-User-Id"
+// @Param RequestId header string true "RequestId"
+// @Param id path string true "disk id"
+// @Param body body model.CreateSnapshotParams true "create snapshot body"
+// @Success 200 {object} app.Response
+// @Router /v1/disks/{id}/snapshots [post]
+func CreateSnapshot(c *gin.Context) {
+	var request model.CreateSnapshotParams
+	appG := app.Gin{C: c}
+
+	app.LoadBody(c, &request)
+	header := app.GetHeaderInfo(c)
+
+	result, err := (&disk_service.CreateSnapshotHandler{}).Handle(c.Param("id"), request, header.UserId, header.RequestId)
+	if err != nil {
+		appG.ErrorResponse(err)
+		return
+	}
+	appG.Response(http.StatusOK, e.SUCCESS, "", result)
+}
+
+// @Summary Set the expiry of a snapshot
+// @Description set, extend or clear the time the snapshot is deleted at, ExpireAt is a future time in RFC3339 or empty for none. Snapshots some disk was created from are kept past it.
+// @Tags Disks
+// @Accept  json
+// @Produce  json
+// @Param X-User-Id header string true "X-User-Id"
+// @Param RequestId header string true "RequestId"
+// @Param id path string true "snapshot id"
+// @Param body body model.SnapshotExpiryParams true "snapshot expiry body"
+// @Success 200 {object} app.Response
+// @Router /v1/snapshots/{id}/expiry [put]
+func SetSnapshotExpiry(c *gin.Context) {
+	var request model.SnapshotExpiryParams
+	appG := app.Gin{C: c}
+
+	app.LoadBody(c, &request)
+	header := app.GetHeaderInfo(c)
+
+	result, err := (&disk_service.SetSnapshotExpiryHandler{}).Handle(c.Param("id"), request, header.UserId, header.RequestId)
+	if err != nil {
+		appG.ErrorResponse(err)
+		return
+	}
+	appG.Response(http.StatusOK, e.SUCCESS, "", result)
+}
+
+// @Summary Export a disk
+// @Description export disk to a cvk
+// @Tags Disks
+// @Accept  json
+// @Produce  json
+// @Param X-User-Id header string true "X-User-Id"
+// @Param RequestId header string true "RequestId"
+// @Param id path string true "disk id"
+// @Param body body model.ExportDiskParams true "export disk body"
+// @Success 200 {object} app.Response
+// @Router /v1/disks/{id}/exports [post]
+func ExportDisk(c *gin.Context) {
+	var request model.ExportDiskParams
+	appG := app.Gin{C: c}
+
+	app.LoadBody(c, &request)
+	header := app.GetHeaderInfo(c)
+
+	result, err := (&disk_service.ExportDiskHandler{}).Handle(c.Param("id"), request, header.UserId, header.RequestId)
+	if err != nil {
+		appG.ErrorResponse(err)
+		return
+	}
+	appG.Response(http.StatusOK, e.SUCCESS, "", result)
+}
+
+// @Summary Cancel a disk export
+// @Description cancel export of disk on a cvk
+// @Tags Disks
+// @Produce  json
+// @Param X-User-Id header string true "X-User-Id"
+// @Param RequestId header string true "RequestId"
+// @Param id path string true "disk id"
+// @Param cvk path string true "cvk name"
+// @Success 200 {object} app.Response
+// @Router /v1/disks/{id}/exports/{cvk} [delete]
+func CancelExport(c *gin.Context) {
+	appG := app.Gin{C: c}
+	header := app.GetHeaderInfo(c)
+
+	result, err := (&disk_service.CancelExportHandler{}).Handle(c.Param("id"), c.Param("cvk"), header.UserId, header.RequestId)
+	if err != nil {
+		appG.ErrorResponse(err)
+		return
+	}
+	appG.Response(http.StatusOK, e.SUCCESS, "", result)
+}
+
+// @Summary List disks
+// @Description list disks of the user, paged by Marker
+// @Tags Disks
+// @Produce  json
+// @Param X-User-Id header string true "X-User-Id"
+// @Param RequestId header string true "RequestId"
+// @Param Status query string false "disk status"
+// @Param DiskCategory query string false "disk category"
+// @Param StorageType query string false "storage type"
+// @Param ClusterId query string false "cluster id"
+// @Param Marker query string false "NextMarker of the previous page"
+// @Param Limit query int false "page size, 20 by default"
+// @Success 200 {object} app.Response
+// @Router /v1/disks [get]
+func DescribeDisks(c *gin.Context) {
+	var request model.DescribeDisksParams
+	appG := app.Gin{C: c}
+
+	app.LoadBody(c, &request)
+	header := app.GetHeaderInfo(c)
+
+	result, err := (&disk_service.DescribeDisksHandler{}).Handle(request, header.UserId, header.RequestId)
+	if err != nil {
+		appG.ErrorResponse(err)
+		return
+	}
+	appG.Response(http.StatusOK, e.SUCCESS, "", result)
+}
+
+// @Summary Describe a disk
+// @Description describe disk with its attach and export info
+// @Tags Disks
+// @Produce  json
+// @Param X-User-Id header string true "X-User-Id"
+// @Param RequestId header string true "RequestId"
+// @Param id path string true "disk id"
+// @Success 200 {object} app.Response
+// @Router /v1/disks/{id} [get]
+func DescribeDisk(c *gin.Context) {
+	appG := app.Gin{C: c}
+	header := app.GetHeaderInfo(c)
+
+	result, err := (&disk_service.DescribeDiskHandler{}).Handle(c.Param("id"), header.UserId, header.RequestId)
+	if err != nil {
+		appG.ErrorResponse(err)
+		return
+	}
+	appG.Response(http.StatusOK, e.SUCCESS, "", result)
 }
@@ -0,0 +1,118 @@
+package v1
+
+import (
+	"github.com/gin-gonic/gin"
+	"immortality-demo/pkg/app"
+	e "immortality-demo/pkg/error"
+	"immortality-demo/service/job_service"
+	"immortality-demo/service/model"
+	"net/http"
+)
+
+// @Summary Describe a job
+// @Description describe the asynchronous job started by a request
+// @Tags Jobs
+// @Produce  json
+// @Param X-User-Id header string true "X-User-Id"
+// @Param RequestId header string true "RequestId"
+// @Param requestId path string true "RequestId of the request which started the job"
+// @Success 200 {object} app.Response
+// @Router /v1/jobs/{requestId} [get]
+func DescribeJob(c *gin.Context) {
+	appG := app.Gin{C: c}
+	header := app.GetHeaderInfo(c)
+
+	result, err := (&job_service.DescribeJobHandler{}).Handle(c.Param("requestId"), header.UserId, header.RequestId)
+	if err != nil {
+		appG.ErrorResponse(err)
+		return
+	}
+	appG.Response(http.StatusOK, e.SUCCESS, "", result)
+}
+
+// @Summary List jobs
+// @Description list the latest asynchronous jobs of the user
+// @Tags Jobs
+// @Produce  json
+// @Param X-User-Id header string true "X-User-Id"
+// @Param RequestId header string true "RequestId"
+// @Param state query string false "pending, running, failed, succeeded, dead or timeout"
+// @Param limit query int false "max number of jobs, 20 by default"
+// @Success 200 {object} app.Response
+// @Router /v1/jobs [get]
+func DescribeJobs(c *gin.Context) {
+	var request model.DescribeJobsParams
+	appG := app.Gin{C: c}
+
+	app.LoadBody(c, &request)
+	header := app.GetHeaderInfo(c)
+
+	result, err := (&job_service.DescribeJobsHandler{}).Handle(request, header.UserId, header.RequestId)
+	if err != nil {
+		appG.ErrorResponse(err)
+		return
+	}
+	appG.Response(http.StatusOK, e.SUCCESS, "", result)
+}
+
+// @Summary List dead jobs
+// @Description list the jobs which ran out of attempts
+// @Tags Jobs
+// @Produce  json
+// @Param X-User-Id header string true "X-User-Id"
+// @Param RequestId header string true "RequestId"
+// @Success 200 {object} app.Response
+// @Router /v1/deadletters [get]
+func DescribeDeadLetters(c *gin.Context) {
+	appG := app.Gin{C: c}
+	header := app.GetHeaderInfo(c)
+
+	result, err := (&job_service.DescribeDeadLettersHandler{}).Handle(header.RequestId)
+	if err != nil {
+		appG.ErrorResponse(err)
+		return
+	}
+	appG.Response(http.StatusOK, e.SUCCESS, "", result)
+}
+
+// @Summary Replay a dead job
+// @Description put a dead job back to the queue with its attempts reset
+// @Tags Jobs
+// @Produce  json
+// @Param X-User-Id header string true "X-User-Id"
+// @Param RequestId header string true "RequestId"
+// @Param requestId path string true "RequestId of the dead job"
+// @Success 200 {object} app.Response
+// @Router /v1/deadletters/{requestId}/replay [post]
+func ReplayDeadLetter(c *gin.Context) {
+	appG := app.Gin{C: c}
+	header := app.GetHeaderInfo(c)
+
+	result, err := (&job_service.ReplayDeadLetterHandler{}).Handle(c.Param("requestId"), header.RequestId)
+	if err != nil {
+		appG.ErrorResponse(err)
+		return
+	}
+	appG.Response(http.StatusOK, e.SUCCESS, "", result)
+}
+
+// @Summary Purge dead jobs
+// @Description drop one dead job, or all of them when no requestId is given
+// @Tags Jobs
+// @Produce  json
+// @Param X-User-Id header string true "X-User-Id"
+// @Param RequestId header string true "RequestId"
+// @Param requestId path string false "RequestId of the dead job"
+// @Success 200 {object} app.Response
+// @Router /v1/deadletters/{requestId} [delete]
+func PurgeDeadLetters(c *gin.Context) {
+	appG := app.Gin{C: c}
+	header := app.GetHeaderInfo(c)
+
+	result, err := (&job_service.PurgeDeadLettersHandler{}).Handle(c.Param("requestId"), header.RequestId)
+	if err != nil {
+		appG.ErrorResponse(err)
+		return
+	}
+	appG.Response(http.StatusOK, e.SUCCESS, "", result)
+}
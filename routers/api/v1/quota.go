@@ -0,0 +1,83 @@
+package v1
+
+import (
+	"github.com/gin-gonic/gin"
+	"immortality-demo/pkg/app"
+	e "immortality-demo/pkg/error"
+	"immortality-demo/service/model"
+	"immortality-demo/service/quota_service"
+	"net/http"
+)
+
+// @Summary List the quotas of a subject
+// @Description list the effective limit, default and usage of each quota of a Project, User, VmInstance or Disk
+// @Tags Quotas
+// @Produce  json
+// @Param RequestId header string true "RequestId"
+// @Param SubjectType query string true "Project, User, VmInstance or Disk"
+// @Param SubjectId query string true "subject id"
+// @Success 200 {object} app.Response
+// @Router /v1/quotas [get]
+func DescribeQuotas(c *gin.Context) {
+	var request model.DescribeQuotasParams
+	appG := app.Gin{C: c}
+
+	app.LoadBody(c, &request)
+	header := app.GetHeaderInfo(c)
+
+	result, err := (&quota_service.DescribeQuotasHandler{}).Handle(request, header.RequestId)
+	if err != nil {
+		appG.ErrorResponse(err)
+		return
+	}
+	appG.Response(http.StatusOK, e.SUCCESS, "", result)
+}
+
+// @Summary Set a quota of a subject
+// @Description override the default limit of ObjectType (Disk, Snapshot, Image or TotalSize in GB) for the subject
+// @Tags Quotas
+// @Accept  json
+// @Produce  json
+// @Param RequestId header string true "RequestId"
+// @Param body body model.SetQuotaParams true "set quota body"
+// @Success 200 {object} app.Response
+// @Router /v1/quotas [put]
+func SetQuota(c *gin.Context) {
+	var request model.SetQuotaParams
+	appG := app.Gin{C: c}
+
+	app.LoadBody(c, &request)
+	header := app.GetHeaderInfo(c)
+
+	result, err := (&quota_service.SetQuotaHandler{}).Handle(request, header.RequestId)
+	if err != nil {
+		appG.ErrorResponse(err)
+		return
+	}
+	appG.Response(http.StatusOK, e.SUCCESS, "", result)
+}
+
+// @Summary Reset a quota of a subject
+// @Description drop the override of ObjectType, the subject gets the default limit back
+// @Tags Quotas
+// @Produce  json
+// @Param RequestId header string true "RequestId"
+// @Param SubjectType query string true "Project, User, VmInstance or Disk"
+// @Param SubjectId query string true "subject id"
+// @Param ObjectType query string true "Disk, Snapshot, Image or TotalSize"
+// @Success 200 {object} app.Response
+// @Router /v1/quotas [delete]
+func ResetQuota(c *gin.Context) {
+	var request model.ResetQuotaParams
+	appG := app.Gin{C: c}
+
+	app.LoadBody(c, &request)
+	header := app.GetHeaderInfo(c)
+
+	result, err := (&quota_service.ResetQuotaHandler{}).Handle(request, header.RequestId)
+	if err != nil {
+		appG.ErrorResponse(err)
+		return
+	}
+	appG.Response(http.StatusOK, e.SUCCESS, "", result)
+}
@@ -0,0 +1,180 @@
+package v1
+
+import (
+	"github.com/gin-gonic/gin"
+	"immortality-demo/pkg/app"
+	e "immortality-demo/pkg/error"
+	"immortality-demo/service/model"
+	"immortality-demo/service/strategy_service"
+	"net/http"
+)
+
+// @Summary Create a snapshot strategy
+// @Description create a strategy taking automatic snapshots at the listed Hours (0-23) of the listed Weeks days (1 is Monday, 7 is Sunday), kept for Duration days (-1 for ever) and at most SnapshotQuota per disk
+// @Tags Strategies
+// @Accept  json
+// @Produce  json
+// @Param X-User-Id header string true "X-User-Id"
+// @Param RequestId header string true "RequestId"
+// @Param body body model.CreateStrategyParams true "create strategy body"
+// @Success 200 {object} app.Response
+// @Router /v1/strategies [post]
+func CreateStrategy(c *gin.Context) {
+	var request model.CreateStrategyParams
+	appG := app.Gin{C: c}
+
+	app.LoadBody(c, &request)
+	header := app.GetHeaderInfo(c)
+
+	result, err := (&strategy_service.CreateStrategyHandler{}).Handle(request, header.UserId, header.RequestId)
+	if err != nil {
+		appG.ErrorResponse(err)
+		return
+	}
+	appG.Response(http.StatusOK, e.SUCCESS, "", result)
+}
+
+// @Summary Update a snapshot strategy
+// @Description update the fields of the strategy which are set
+// @Tags Strategies
+// @Accept  json
+// @Produce  json
+// @Param X-User-Id header string true "X-User-Id"
+// @Param RequestId header string true "RequestId"
+// @Param id path string true "strategy id"
+// @Param body body model.UpdateStrategyParams true "update strategy body"
+// @Success 200 {object} app.Response
+// @Router /v1/strategies/{id} [put]
+func UpdateStrategy(c *gin.Context) {
+	var request model.UpdateStrategyParams
+	appG := app.Gin{C: c}
+
+	app.LoadBody(c, &request)
+	header := app.GetHeaderInfo(c)
+
+	result, err := (&strategy_service.UpdateStrategyHandler{}).Handle(c.Param("id"), request, header.UserId, header.RequestId)
+	if err != nil {
+		appG.ErrorResponse(err)
+		return
+	}
+	appG.Response(http.StatusOK, e.SUCCESS, "", result)
+}
+
+// @Summary Delete a snapshot strategy
+// @Description delete strategy, no disk may be bound to it
+// @Tags Strategies
+// @Produce  json
+// @Param X-User-Id header string true "X-User-Id"
+// @Param RequestId header string true "RequestId"
+// @Param id path string true "strategy id"
+// @Success 200 {object} app.Response
+// @Router /v1/strategies/{id} [delete]
+func DeleteStrategy(c *gin.Context) {
+	appG := app.Gin{C: c}
+	header := app.GetHeaderInfo(c)
+
+	result, err := (&strategy_service.DeleteStrategyHandler{}).Handle(c.Param("id"), header.UserId, header.RequestId)
+	if err != nil {
+		appG.ErrorResponse(err)
+		return
+	}
+	appG.Response(http.StatusOK, e.SUCCESS, "", result)
+}
+
+// @Summary List snapshot strategies
+// @Description list strategies of the user with the number of disks bound, or the one of StrategyId or DiskId
+// @Tags Strategies
+// @Produce  json
+// @Param X-User-Id header string true "X-User-Id"
+// @Param RequestId header string true "RequestId"
+// @Param StrategyId query string false "strategy id"
+// @Param DiskId query string false "id of a disk bound to the strategy"
+// @Success 200 {object} app.Response
+// @Router /v1/strategies [get]
+func DescribeStrategies(c *gin.Context) {
+	var request model.DescribeStrategiesParams
+	appG := app.Gin{C: c}
+
+	app.LoadBody(c, &request)
+	header := app.GetHeaderInfo(c)
+
+	result, err := (&strategy_service.DescribeStrategiesHandler{}).Handle(request, header.UserId, header.RequestId)
+	if err != nil {
+		appG.ErrorResponse(err)
+		return
+	}
+	appG.Response(http.StatusOK, e.SUCCESS, "", result)
+}
+
+// @Summary Describe a snapshot strategy
+// @Description describe strategy with the disks bound to it
+// @Tags Strategies
+// @Produce  json
+// @Param X-User-Id header string true "X-User-Id"
+// @Param RequestId header string true "RequestId"
+// @Param id path string true "strategy id"
+// @Success 200 {object} app.Response
+// @Router /v1/strategies/{id} [get]
+func DescribeStrategy(c *gin.Context) {
+	appG := app.Gin{C: c}
+	header := app.GetHeaderInfo(c)
+
+	result, err := (&strategy_service.DescribeStrategyHandler{}).Handle(c.Param("id"), header.UserId, header.RequestId)
+	if err != nil {
+		appG.ErrorResponse(err)
+		return
+	}
+	appG.Response(http.StatusOK, e.SUCCESS, "", result)
+}
+
+// @Summary Bind disks to a snapshot strategy
+// @Description bind disks to strategy, a disk is bound to one strategy at most
+// @Tags Strategies
+// @Accept  json
+// @Produce  json
+// @Param X-User-Id header string true "X-User-Id"
+// @Param RequestId header string true "RequestId"
+// @Param id path string true "strategy id"
+// @Param body body model.BindStrategyParams true "bind strategy body"
+// @Success 200 {object} app.Response
+// @Router /v1/strategies/{id}/bind [post]
+func BindStrategy(c *gin.Context) {
+	var request model.BindStrategyParams
+	appG := app.Gin{C: c}
+
+	app.LoadBody(c, &request)
+	header := app.GetHeaderInfo(c)
+
+	result, err := (&strategy_service.BindStrategyHandler{}).Handle(c.Param("id"), request, header.UserId, header.RequestId)
+	if err != nil {
+		appG.ErrorResponse(err)
+		return
+	}
+	appG.Response(http.StatusOK, e.SUCCESS, "", result)
+}
+
+// @Summary Unbind disks from a snapshot strategy
+// @Description unbind disks from strategy, their automatic snapshots are kept
+// @Tags Strategies
+// @Accept  json
+// @Produce  json
+// @Param X-User-Id header string true "X-User-Id"
+// @Param RequestId header string true "RequestId"
+// @Param id path string true "strategy id"
+// @Param body body model.BindStrategyParams true "unbind strategy body"
+// @Success 200 {object} app.Response
+// @Router /v1/strategies/{id}/unbind [post]
+func UnbindStrategy(c *gin.Context) {
+	var request model.BindStrategyParams
+	appG := app.Gin{C: c}
+
+	app.LoadBody(c, &request)
+	header := app.GetHeaderInfo(c)
+
+	result, err := (&strategy_service.UnbindStrategyHandler{}).Handle(c.Param("id"), request, header.UserId, header.RequestId)
+	if err != nil {
+		appG.ErrorResponse(err)
+		return
+	}
+	appG.Response(http.StatusOK, e.SUCCESS, "", result)
+}
@@ -48,10 +48,57 @@ func init() {
 
 	disk := router.Group("/v1")
 	{
-		disk.Any("/disks", v1.CreateDisk)
+		disk.GET("/disks", v1.DescribeDisks)
+		disk.POST("/disks", v1.CreateDisk)
+		disk.GET("/disks/:id", v1.DescribeDisk)
+		disk.DELETE("/disks/:id", v1.DeleteDisk)
+		disk.POST("/disks/:id/resize", v1.ResizeDisk)
+		disk.POST("/disks/:id/reset", v1.ResetDisk)
+		disk.POST("/disks/:id/reinit", v1.ReInitDisk)
+		disk.POST("/disks/:id/snapshots", v1.CreateSnapshot)
+		disk.POST("/disks/:id/exports", v1.ExportDisk)
+		disk.DELETE("/disks/:id/exports/:cvk", v1.CancelExport)
+		disk.PUT("/snapshots/:id/expiry", v1.SetSnapshotExpiry)
 	}
 
+	job := router.Group("/v1")
+	{
+		job.GET("/jobs", v1.DescribeJobs)
+		job.GET("/jobs/:requestId", v1.DescribeJob)
+	}
 
+	// the dead jobs of every user, for the operators only
+	deadletter := router.Group("/v1", app.OperatorOnly())
+	{
+		deadletter.GET("/deadletters", v1.DescribeDeadLetters)
+		deadletter.POST("/deadletters/:requestId/replay", v1.ReplayDeadLetter)
+		deadletter.DELETE("/deadletters/:requestId", v1.PurgeDeadLetters)
+		deadletter.DELETE("/deadletters", v1.PurgeDeadLetters)
+	}
+
+	array := router.Group("/v1")
+	{
+		array.GET("/arrays", v1.DescribeArrays)
+		array.POST("/arrays", app.OperatorOnly(), v1.RegisterArray)
+	}
+
+	strategy := router.Group("/v1")
+	{
+		strategy.GET("/strategies", v1.DescribeStrategies)
+		strategy.POST("/strategies", v1.CreateStrategy)
+		strategy.GET("/strategies/:id", v1.DescribeStrategy)
+		strategy.PUT("/strategies/:id", v1.UpdateStrategy)
+		strategy.DELETE("/strategies/:id", v1.DeleteStrategy)
+		strategy.POST("/strategies/:id/bind", v1.BindStrategy)
+		strategy.POST("/strategies/:id/unbind", v1.UnbindStrategy)
+	}
+
+	quota := router.Group("/v1")
+	{
+		quota.GET("/quotas", v1.DescribeQuotas)
+		quota.PUT("/quotas", app.OperatorOnly(), v1.SetQuota)
+		quota.DELETE("/quotas", app.OperatorOnly(), v1.ResetQuota)
+	}
 
 	router.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
 }
@@ -0,0 +1,44 @@
+package array_service
+
+import (
+	"immortality-demo/pkg/data"
+	"immortality-demo/pkg/db_model"
+	"immortality-demo/pkg/logger"
+	"immortality-demo/service/model"
+	"time"
+)
+
+type DescribeArraysHandler struct {
+}
+
+func (h *DescribeArraysHandler) Handle(requestId string) ([]model.ArrayInfo, error) {
+	infos, err := db_model.ThreeParInfos(data.Db)
+	if err != nil {
+		logger.Log.Error1(requestId, "ThreeParInfos error:", err)
+		return nil, data.ErrServerInternalDB
+	}
+
+	arrays := make([]model.ArrayInfo, 0, len(infos))
+	for _, info := range infos {
+		array := model.ArrayInfo{
+			ThreeParId:         info.ThreeParId,
+			PodId:              info.PodId,
+			StorageType:        info.StorageType,
+			Category:           info.Category,
+			HDDUsage:           info.HDDUsage,
+			HDDTotal:           info.HDDTotal,
+			HDDUsagePercent:    info.HDDUsagePercent,
+			SSDUsage:           info.SSDUsage,
+			SSDTotal:           info.SSDTotal,
+			SSDUsagePercent:    info.SSDUsagePercent,
+			HybridUsagePercent: info.HybridUsagePercent,
+			Reserve:            info.Reserve,
+			Stale:              info.Stale,
+		}
+		if info.PolledAt.Valid {
+			array.PolledAt = info.PolledAt.Time.Format(time.RFC3339)
+		}
+		arrays = append(arrays, array)
+	}
+	return arrays, nil
+}
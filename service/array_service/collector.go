@@ -0,0 +1,181 @@
+package array_service
+
+import (
+	"context"
+	"encoding/json"
+	"immortality-demo/config"
+	"immortality-demo/pkg/data"
+	"immortality-demo/pkg/db_model"
+	"immortality-demo/pkg/driver"
+	"immortality-demo/pkg/logger"
+	"sync"
+	"time"
+)
+
+// pollTimeout bounds the requests made to one array in a round
+const pollTimeout = 30 * time.Second
+
+// Collector refreshes the capacity figures of the arrays in 3par_info on a
+// timer. An array which can not be polled is marked stale, so the scheduler
+// stops placing disks on it until a poll succeeds again.
+type Collector struct {
+	period time.Duration
+	quit   chan struct{}
+	wg     sync.WaitGroup
+}
+
+func NewCollector(period time.Duration) *Collector {
+	return &Collector{
+		period: period,
+		quit:   make(chan struct{}),
+	}
+}
+
+// Start polls the arrays right away and then once every period
+func (c *Collector) Start() {
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+		ticker := time.NewTicker(c.period)
+		defer ticker.Stop()
+		for {
+			c.Collect()
+			select {
+			case <-c.quit:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Stop waits for the running round to finish
+func (c *Collector) Stop() {
+	close(c.quit)
+	c.wg.Wait()
+}
+
+// Collect polls every array once
+func (c *Collector) Collect() {
+	infos, err := db_model.ThreeParInfos(data.Db)
+	if err != nil {
+		logger.Log.Error("ThreeParInfos error:", err)
+		return
+	}
+	infos = registerClusters(infos)
+	for _, info := range infos {
+		// arrays of backends which can not report capacity keep what was set by hand
+		if !driver.Supports(storageTypeOf(info), driver.CapCapacity) {
+			continue
+		}
+		collect(info)
+	}
+}
+
+// collect polls the array and marks it stale if the poll fails
+func collect(info *db_model.ThreeParInfo) {
+	if err := poll(info); err != nil {
+		logger.Log.Error("Failed to poll 3par", info.ThreeParId, "err:", err)
+		if err = db_model.MarkThreeParInfoStale(data.Db, info.ThreeParId); err != nil {
+			logger.Log.Error("MarkThreeParInfoStale error:", err)
+		}
+	}
+}
+
+// registerClusters records the ceph clusters of the config which are not in
+// 3par_info yet, so that the scheduler can place disks on them once polled.
+// It returns infos with the new records.
+func registerClusters(infos []*db_model.ThreeParInfo) []*db_model.ThreeParInfo {
+	if len(config.Config.Ceph) == 0 {
+		return infos
+	}
+	d, err := driver.GetDriver(data.CEPH)
+	if err != nil {
+		return infos
+	}
+	lister, ok := d.(driver.ClusterLister)
+	if !ok {
+		return infos
+	}
+
+	known := make(map[string]bool, len(infos))
+	for _, info := range infos {
+		known[info.ThreeParId] = true
+	}
+	for category, id := range lister.Clusters() {
+		if known[id] {
+			continue
+		}
+		info := &db_model.ThreeParInfo{
+			ThreeParId:  id,
+			StorageType: data.CEPH,
+			Category:    category,
+			UpdateAt:    time.Now(),
+		}
+		if err = info.Insert(data.Db); err != nil {
+			logger.Log.Error("Insert 3par_info of ceph cluster", id, "error:", err)
+			continue
+		}
+		logger.Log.Info("Recorded ceph cluster", id, "of category", category)
+		infos = append(infos, info)
+	}
+	return infos
+}
+
+func poll(info *db_model.ThreeParInfo) error {
+	ctx, cancel := context.WithTimeout(context.Background(), pollTimeout)
+	defer cancel()
+
+	storageType := storageTypeOf(info)
+	result, err := driver.AbsDriver.GetSystemCapacity(ctx, data.GetSystemCapacityRequest{
+		StorageType:  storageType,
+		ScheduleInfo: info.ThreeParId,
+	})
+	if err != nil {
+		return err
+	}
+	var capacity data.SystemCapacity
+	if err = json.Unmarshal([]byte(result), &capacity); err != nil {
+		return err
+	}
+	_, hybrid, err := driver.AbsDriver.GetSystemUtilization(ctx, data.GetSystemUtilizationRequest{
+		StorageType:  storageType,
+		ScheduleInfo: info.ThreeParId,
+	})
+	if err != nil {
+		return err
+	}
+
+	hdd := capacity.FCCapacity
+	hdd.TotalMiB += capacity.NLCapacity.TotalMiB
+	hdd.FreeMiB += capacity.NLCapacity.FreeMiB
+	ssd := capacity.SSDCapacity
+
+	now := time.Now()
+	info.HDDUsage, info.HDDTotal, info.HDDUsagePercent = usage(hdd)
+	info.SSDUsage, info.SSDTotal, info.SSDUsagePercent = usage(ssd)
+	info.HybridUsagePercent = hybrid * 100
+	info.UpdateAt = now
+	info.Stale = false
+	info.PolledAt.Time, info.PolledAt.Valid = now, true
+	return info.Update(data.Db)
+}
+
+// storageTypeOf returns the storage type of the array, records made before
+// ceph clusters were tracked are 3PAR arrays
+func storageTypeOf(info *db_model.ThreeParInfo) string {
+	if info.StorageType == "" {
+		return data.HPE3PARA
+	}
+	return info.StorageType
+}
+
+// usage returns the used and total GiB of the device type and its usage in percent
+func usage(c data.DeviceCapacity) (used, total, percent float64) {
+	total = c.TotalMiB / 1024
+	used = (c.TotalMiB - c.FreeMiB) / 1024
+	if total > 0 {
+		percent = used / total * 100
+	}
+	return
+}
@@ -0,0 +1,102 @@
+package array_service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"immortality-demo/config"
+	"immortality-demo/pkg/data"
+	"immortality-demo/pkg/db_model"
+	"immortality-demo/pkg/driver/fake"
+	"immortality-demo/pkg/logger"
+	"net/url"
+	"os"
+	"testing"
+	"time"
+
+	_ "github.com/go-sql-driver/mysql"
+)
+
+func TestUsage(t *testing.T) {
+	used, total, percent := usage(data.DeviceCapacity{TotalMiB: 4096, FreeMiB: 1024})
+	if used != 3 || total != 4 || percent != 75 {
+		t.Errorf("expected 3 of 4GiB used, got %v of %v, %v%%", used, total, percent)
+	}
+	if _, _, percent = usage(data.DeviceCapacity{}); percent != 0 {
+		t.Errorf("an empty device is 0%% used, got %v", percent)
+	}
+	if storageType := storageTypeOf(&db_model.ThreeParInfo{}); storageType != data.HPE3PARA {
+		t.Errorf("records without a storage type are 3par arrays, got %q", storageType)
+	}
+}
+
+// setupDB connects the database of the config, the test is skipped if it is
+// not reachable
+func setupDB(t *testing.T) {
+	config.LoadConfig()
+	logger.Log = logger.NewStdoutLogger(os.Stdout, "")
+	u, err := url.Parse(config.Config.DBPath)
+	if err != nil {
+		t.Skip("bad db path:", err)
+	}
+	db, err := sql.Open("mysql", fmt.Sprintf("%s@tcp(%s)%s?%s", u.User.String(), u.Host, u.Path, u.RawQuery))
+	if err == nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+		err = db.PingContext(ctx)
+		cancel()
+	}
+	if err != nil {
+		t.Skip("database is not available:", err)
+	}
+	data.Db = db
+}
+
+func threeParInfo(t *testing.T, id string) *db_model.ThreeParInfo {
+	infos, err := db_model.ThreeParInfos(data.Db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, info := range infos {
+		if info.ThreeParId == id {
+			return info
+		}
+	}
+	t.Fatalf("no 3par_info of %s", id)
+	return nil
+}
+
+// TestCollect polls an array of the fake driver, a failed poll marks it stale
+// and keeps the figures and PolledAt of the last successful one
+func TestCollect(t *testing.T) {
+	setupDB(t)
+
+	id := fmt.Sprintf("fake-array-%d", time.Now().UnixNano())
+	info := &db_model.ThreeParInfo{ThreeParId: id, StorageType: fake.StorageType, UpdateAt: time.Now()}
+	if err := info.Insert(data.Db); err != nil {
+		t.Fatal(err)
+	}
+	defer data.Db.Exec(`DELETE FROM 3par_info WHERE three_par_id = ?`, id)
+
+	fake.Default.Reset()
+	collect(info)
+	polled := threeParInfo(t, id)
+	if polled.Stale || !polled.PolledAt.Valid || polled.SSDTotal != 1024 || polled.HDDTotal != 2048 {
+		t.Fatalf("expected a polled 1TiB array, got %+v", polled)
+	}
+
+	fake.Default.Inject("GetSystemCapacity", fake.Fault{Err: errors.New("array is not reachable"), Times: 1})
+	collect(polled)
+	stale := threeParInfo(t, id)
+	if !stale.Stale {
+		t.Error("a failed poll should mark the array stale")
+	}
+	if stale.SSDTotal != polled.SSDTotal || !stale.PolledAt.Time.Equal(polled.PolledAt.Time) {
+		t.Errorf("a failed poll should keep the last figures, got %+v", stale)
+	}
+
+	collect(stale)
+	if threeParInfo(t, id).Stale {
+		t.Error("a successful poll should clear the stale flag")
+	}
+}
@@ -0,0 +1,156 @@
+package array_service
+
+import (
+	"context"
+	"immortality-demo/pkg/data"
+	"immortality-demo/pkg/db_model"
+	"immortality-demo/pkg/driver"
+	"immortality-demo/pkg/driver/three_par"
+	"immortality-demo/pkg/logger"
+	"immortality-demo/service/model"
+	"time"
+)
+
+// registerTimeout bounds the login made to check the array
+const registerTimeout = 30 * time.Second
+
+type RegisterArrayHandler struct {
+}
+
+// Handle stores a 3PAR array after logging in to it, which checks its
+// address, its credentials and the certificate of its WSAPI
+func (h *RegisterArrayHandler) Handle(param model.RegisterArrayParams, requestId string) (result map[string]interface{}, err error) {
+	par, err := verifyRegisterArray(param)
+	if err != nil {
+		logger.Log.Error1(requestId, "RegisterArray param verify err:", err)
+		return nil, err
+	}
+
+	exist, err := db_model.ThreeParExistByThreeParId(data.Db, par.ThreeParId)
+	if err != nil {
+		logger.Log.Error1(requestId, "ThreeParExistByThreeParId error:", err)
+		return nil, data.ErrServerInternalDB
+	}
+	if exist {
+		return nil, data.ErrConflictThreeParId
+	}
+
+	T, err := three_par.NewThreeParDriver(par)
+	if err != nil {
+		logger.Log.Error1(requestId, "NewThreeParDriver error:", err)
+		return nil, data.ErrInvalidArrayTLS
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), registerTimeout)
+	defer cancel()
+	if err = T.InitSessionKey(ctx); err != nil {
+		logger.Log.Errorf(requestId, "Failed to log in to three_par %s: %v", par.ThreeParId, err)
+		if three_par.IsCertificateError(err) {
+			return nil, data.ErrArrayCertificate
+		}
+		return nil, data.ErrArrayUnreachable
+	}
+	if err = T.UnAuthenticate(ctx); err != nil {
+		logger.Log.Warnf(requestId, "Failed to log out of three_par %s: %v", par.ThreeParId, err)
+	}
+
+	if par.Insecure == 1 {
+		logger.Log.Warnf(requestId, "three_par %s is registered without certificate verification", par.ThreeParId)
+	}
+	if err = insertArray(par, requestId); err != nil {
+		return nil, err
+	}
+
+	// the running driver takes the array at once, the collector polls its
+	// capacity into the new 3par_info row so that the scheduler picks it
+	ctx, cancel = context.WithTimeout(context.Background(), registerTimeout)
+	defer cancel()
+	if err = driver.AddArray(ctx, data.HPE3PARA, par); err != nil {
+		logger.Log.Errorf(requestId, "Failed to add three_par %s to the driver, it is used after a restart: %v", par.ThreeParId, err)
+	}
+	return map[string]interface{}{"ThreeParId": par.ThreeParId}, nil
+}
+
+// insertArray stores the array with an empty 3par_info row
+func insertArray(par *db_model.ThreePar, requestId string) (err error) {
+	tx, err := data.Db.Begin()
+	if err != nil {
+		logger.Log.Error1(requestId, "Begin transaction error:", err)
+		return data.ErrServerInternalDB
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if err = par.Insert(tx); err != nil {
+		logger.Log.Error1(requestId, "Insert three_par error:", err)
+		return data.ErrServerInternalDB
+	}
+	info := &db_model.ThreeParInfo{
+		ThreeParId:  par.ThreeParId,
+		StorageType: data.HPE3PARA,
+		UpdateAt:    par.CreatedAt,
+	}
+	if err = info.Insert(tx); err != nil {
+		logger.Log.Error1(requestId, "Insert 3par_info error:", err)
+		return data.ErrServerInternalDB
+	}
+
+	if err = tx.Commit(); err != nil {
+		logger.Log.Error1(requestId, "Commit transaction error:", err)
+		return data.ErrServerInternalDB
+	}
+	return nil
+}
+
+func verifyRegisterArray(param model.RegisterArrayParams) (*db_model.ThreePar, error) {
+	switch {
+	case param.ThreeParId == "":
+		return nil, data.ErrLackOfRequiredFieldThreeParId
+	case param.Ipv4AddrManagement == "":
+		return nil, data.ErrLackOfRequiredFieldIpv4AddrManagement
+	case param.Ipv4AddrSSH == "":
+		return nil, data.ErrLackOfRequiredFieldIpv4AddrSSH
+	case param.Ipv4AddrController == "":
+		return nil, data.ErrLackOfRequiredFieldIpv4AddrController
+	case param.Username == "":
+		return nil, data.ErrLackOfRequiredFieldUsername
+	case param.Password == "":
+		return nil, data.ErrLackOfRequiredFieldPassword
+	case param.InCharge == "":
+		return nil, data.ErrLackOfRequiredFieldInCharge
+	case param.Contact == "":
+		return nil, data.ErrLackOfRequiredFieldContact
+	case param.LaunchTime == "":
+		return nil, data.ErrLackOfRequiredFieldLaunchTime
+	}
+	launchTime, err := time.ParseInLocation(data.DatetimeFormatString, param.LaunchTime, time.Local)
+	if err != nil {
+		return nil, data.ErrInvalidLaunchTime
+	}
+
+	now := time.Now()
+	par := &db_model.ThreePar{
+		ThreeParId:         param.ThreeParId,
+		Ipv4AddrManagement: param.Ipv4AddrManagement,
+		Ipv4AddrSSH:        param.Ipv4AddrSSH,
+		Ipv4AddrController: param.Ipv4AddrController,
+		Username:           param.Username,
+		Password:           param.Password,
+		LaunchTime:         launchTime,
+		InCharge:           param.InCharge,
+		Contact:            param.Contact,
+		CreatedAt:          now,
+		UpdatedAt:          now,
+		CaBundle:           param.CaBundle,
+		CertSha256:         param.CertSha256,
+	}
+	if param.Https {
+		par.Https = 1
+	}
+	if param.Insecure {
+		par.Insecure = 1
+	}
+	return par, nil
+}
@@ -2,12 +2,13 @@ package disk_service
 
 import (
 	"database/sql"
-	"encoding/json"
 	"immortality-demo/pkg/data"
 	"immortality-demo/pkg/db_model"
-	"immortality-demo/pkg/gredis"
 	"immortality-demo/pkg/logger"
+	"immortality-demo/pkg/schedule"
+	"immortality-demo/service/image_service"
 	"immortality-demo/service/model"
+	"immortality-demo/service/quota_service"
 	"time"
 )
 
@@ -15,13 +16,6 @@ type CreateDiskHandler struct {
 }
 
 func (h *CreateDiskHandler) Handle(param model.CreateDiskParams, userId, requestId string) (result map[string]interface{}, err error) {
-
-	//param, err = verifyCreateDisk(requestId, param)
-	if err != nil {
-		logger.Log.Error1(requestId, "CreateDisk param verify err:", err, "CreateDiskParams:", param)
-		return nil, err
-	}
-
 	var imageType string
 	var clusterId string
 	if param.SnapshotId != "" {
@@ -41,16 +35,19 @@ func (h *CreateDiskHandler) Handle(param model.CreateDiskParams, userId, request
 		param.Size = snapshot.Size >> 30
 		clusterId = snapshot.ClusterID
 	} else if param.ImageId != "" {
-		image, err := image_service.GetImageByImageID(param.ImageId)
+		image, err := image_service.GetImageByImageID(requestId, param.ImageId)
 		if err != nil {
 			logger.Log.Error1(requestId, "CreateDisk getImageByImageID error:", err)
 			return nil, err
 		}
+		if err = checkImage(requestId, image); err != nil {
+			return nil, err
+		}
 		if param.Size < image.Size {
 			return nil, data.ErrInvalidSize
 		}
 		imageType = data.IMAGE_TYPE_CUSTOM
-		if image.UserId == data.SpatialUserPublic {
+		if image.IsPublic() {
 			imageType = data.IMAGE_TYPE_PUBLIC
 		}
 		if imageType == data.IMAGE_TYPE_CUSTOM {
@@ -63,11 +60,13 @@ func (h *CreateDiskHandler) Handle(param model.CreateDiskParams, userId, request
 	}
 
 	storageType := param.StorageType
-	if storageType == data.HPE3PARA && param.SnapshotId == "" && imageType != data.IMAGE_TYPE_CUSTOM {
+	if (storageType == data.HPE3PARA || storageType == data.CEPH) && param.SnapshotId == "" && imageType != data.IMAGE_TYPE_CUSTOM {
 		scheduler := schedule.GetScheduler()
 		info := data.ScheduleParam{
 			DiskCategory: param.DiskCategory,
 			PodId:        param.PodId,
+			StorageType:  storageType,
+			Size:         param.Size,
 		}
 		var scheduleResult data.ScheduleResult
 		scheduleResult, err = scheduler.Schedule(info)
@@ -76,12 +75,6 @@ func (h *CreateDiskHandler) Handle(param model.CreateDiskParams, userId, request
 			return nil, err
 		}
 		clusterId = scheduleResult.Id
-	} else if storageType == data.CEPH {
-		//cephCluster, err := config.CephClusterByCategory(param.DiskCategory)
-		//if err != nil {
-		//	return nil, data.ErrZoneNotAvailable
-		//}
-		//clusterId = cephCluster.Id
 	}
 
 	disk := db_model.Disk{
@@ -104,11 +97,25 @@ func (h *CreateDiskHandler) Handle(param model.CreateDiskParams, userId, request
 		CreatedAt:    time.Now(),
 		UpdatedAt:    time.Now(),
 	}
-	err = disk.Save(data.Db)
+	tx, err := data.Db.Begin()
 	if err != nil {
+		logger.Log.Error1(requestId, "Begin transaction error:", err)
+		return nil, data.ErrServerInternalDB
+	}
+	if err = quota_service.CheckCreateDisk(tx, requestId, userId, 1, param.Size); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	err = disk.Save(tx)
+	if err != nil {
+		tx.Rollback()
 		logger.Log.Error1(requestId, "Error saving disk to DB:", err)
 		return nil, data.ErrServerInternalDB
 	}
+	if err = tx.Commit(); err != nil {
+		logger.Log.Error1(requestId, "Commit transaction error:", err)
+		return nil, data.ErrServerInternalDB
+	}
 
 	asyncReq := data.CreateDiskRequest{
 		RequestId:    requestId,
@@ -124,16 +131,14 @@ func (h *CreateDiskHandler) Handle(param model.CreateDiskParams, userId, request
 		ImageType:    imageType,
 	}
 
-	jsonBytes, err := json.Marshal(asyncReq)
+	err = publish(requestId, userId, data.ActionCreateDisk, asyncReq, nil)
 	if err != nil {
-		logger.Log.Error1(requestId, "Failed to marshal json:", err)
+		// the disk was never created, it must not count against the quotas
+		if dErr := db_model.MarkDiskDeleted(data.Db, param.DiskId); dErr != nil {
+			logger.Log.Error1(requestId, "Failed to delete the disk not enqueued:", dErr)
+		}
 		return nil, err
 	}
-	err = gredis.Push("queue", jsonBytes)
-	if err != nil {
-		logger.Log.Error1(requestId, "Failed to publish:", err)
-		return nil, data.ErrServerInternalMQ
-	}
 
 	result = map[string]interface{}{
 		"DiskId":    param.DiskId,
@@ -142,3 +147,16 @@ func (h *CreateDiskHandler) Handle(param model.CreateDiskParams, userId, request
 
 	return result, nil
 }
+
+// checkImage checks disks can be created from the image
+func checkImage(requestId string, image *image_service.Image) error {
+	if image.Status != data.ImageStatusAvailable {
+		logger.Log.Error1(requestId, "image is not available:", image.ImageId)
+		return data.ErrInvalidImageId
+	}
+	if !image.Known() {
+		logger.Log.Errorf(requestId, "image %s is of an unknown kind: %+v", image.ImageId, image)
+		return data.ErrInvalidImageId
+	}
+	return nil
+}
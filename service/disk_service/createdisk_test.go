@@ -0,0 +1,64 @@
+package disk_service_test
+
+import (
+	"immortality-demo/pkg/data"
+	"immortality-demo/pkg/logger"
+	"immortality-demo/service/disk_service"
+	"immortality-demo/service/image_service"
+	"immortality-demo/service/image_service/imagetest"
+	"immortality-demo/service/model"
+	"os"
+	"testing"
+)
+
+// TestCreateDiskHandler_Image runs the image checks of CreateDisk against a
+// simulated image service, they all fail before the database is needed
+func TestCreateDiskHandler_Image(t *testing.T) {
+	logger.Log = logger.NewStdoutLogger(os.Stdout, "")
+	server := imagetest.NewServer()
+	defer server.Close()
+	image_service.SetDefaultClient(server.ImageClient())
+	defer image_service.SetDefaultClient(nil)
+
+	image := image_service.Image{
+		ImageId:      "img-centos",
+		Size:         40,
+		UserId:       data.SpatialUserPublic,
+		Status:       data.ImageStatusAvailable,
+		Format:       data.ImageFormatQcow2,
+		Architecture: data.ImageArchitectureX8664,
+		OSType:       data.OSTypeLinux,
+	}
+	server.AddImage(image)
+	other := image
+	other.ImageId, other.UserId = "img-other", "user-2"
+	server.AddImage(other)
+	unknown := image
+	unknown.ImageId, unknown.Format = "img-vmdk", 0
+	server.AddImage(unknown)
+
+	create := func(imageId string, size int64) error {
+		_, err := (&disk_service.CreateDiskHandler{}).Handle(model.CreateDiskParams{ImageId: imageId, Size: size}, "user-1", "req-1")
+		return err
+	}
+	if err := create("img-centos", 20); err != data.ErrInvalidSize {
+		t.Errorf("a disk smaller than the image: %v", err)
+	}
+	if err := create("img-other", 40); err != data.ErrInvalidImageId {
+		t.Errorf("an image of another user: %v", err)
+	}
+	if err := create("img-vmdk", 40); err != data.ErrInvalidImageId {
+		t.Errorf("an image of an unknown format: %v", err)
+	}
+
+	// the image was described once already, its deletion must still be seen
+	server.DeleteImage("img-centos")
+	if err := create("img-centos", 20); err != data.ErrInvalidImageId {
+		t.Errorf("a deleted image: %v", err)
+	}
+	image.Status = data.ImageStatusCreating
+	server.AddImage(image)
+	if err := create("img-centos", 40); err != data.ErrInvalidImageId {
+		t.Errorf("an image which is not available: %v", err)
+	}
+}
@@ -0,0 +1,92 @@
+package disk_service
+
+import (
+	"database/sql"
+	"immortality-demo/pkg/data"
+	"immortality-demo/pkg/db_model"
+	"immortality-demo/pkg/logger"
+	"immortality-demo/service/model"
+	"immortality-demo/service/quota_service"
+	"time"
+)
+
+type CreateSnapshotHandler struct {
+}
+
+func (h *CreateSnapshotHandler) Handle(diskId string, param model.CreateSnapshotParams, userId, requestId string) (result map[string]interface{}, err error) {
+	if param.SnapshotId == "" {
+		return nil, data.ErrLackOfRequiredFieldSnapshotId
+	}
+	expireAt, err := parseExpireAt(param.ExpireAt)
+	if err != nil {
+		return nil, err
+	}
+
+	exist, err := db_model.SnapshotExistBySnapshotId(data.Db, param.SnapshotId)
+	if err != nil {
+		logger.Log.Error1(requestId, "SnapshotExistBySnapshotId error:", err)
+		return nil, data.ErrServerInternalDB
+	}
+	if exist {
+		return nil, data.ErrConflictSnapshotId
+	}
+
+	disk, err := transitDisk(requestId, diskId, userId, data.DiskStatusCreatingSnapshot, func(disk *db_model.Disk) error {
+		if !statusIn(disk, data.DiskStatusAvailable, data.DiskStatusInUse) {
+			return data.ErrDiskSnapshottingBadStatus
+		}
+		return nil
+	}, func(tx *sql.Tx, disk *db_model.Disk) error {
+		if err := quota_service.CheckCreateSnapshot(tx, requestId, disk.DiskID); err != nil {
+			return err
+		}
+
+		snapshot := db_model.Snapshot{
+			SnapshotID:   param.SnapshotId,
+			Status:       data.SnapshotStatusCreating,
+			DiskID:       disk.DiskID,
+			Name:         param.SnapshotName,
+			Region:       disk.Region,
+			Zone:         disk.Zone,
+			Category:     disk.Category,
+			Size:         disk.Size,
+			Description:  param.Description,
+			UserID:       userId,
+			ClusterID:    disk.ClusterID,
+			StorageType:  disk.StorageType,
+			SnapshotType: param.SnapshotType,
+			ExpireAt:     expireAt,
+			CreatedAt:    time.Now(),
+			UpdatedAt:    time.Now(),
+		}
+		if err := snapshot.Save(tx); err != nil {
+			logger.Log.Error1(requestId, "Error saving snapshot to DB:", err)
+			return data.ErrServerInternalDB
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	asyncReq := data.CreateSnapshotRequest{
+		RequestId:    requestId,
+		DiskCategory: data.CategoryMap2[disk.Category],
+		DiskId:       disk.DiskID,
+		SnapshotId:   param.SnapshotId,
+		StorageType:  disk.StorageType,
+		ScheduleInfo: disk.ClusterID,
+	}
+	if err = publish(requestId, userId, data.ActionCreateSnapshot, asyncReq, disk); err != nil {
+		db_model.MarkSnapshotError(data.Db, param.SnapshotId)
+		return nil, err
+	}
+	logger.Log.Infof(requestId, "CreateSnapshot %s of disk %s enqueued", param.SnapshotId, diskId)
+
+	result = map[string]interface{}{
+		"DiskId":     diskId,
+		"SnapshotId": param.SnapshotId,
+		"RequestId":  requestId,
+	}
+	return result, nil
+}
@@ -0,0 +1,44 @@
+package disk_service
+
+import (
+	"database/sql"
+	"immortality-demo/pkg/data"
+	"immortality-demo/pkg/db_model"
+	"immortality-demo/pkg/logger"
+)
+
+type DeleteDiskHandler struct {
+}
+
+func (h *DeleteDiskHandler) Handle(diskId, userId, requestId string) (result map[string]interface{}, err error) {
+	disk, err := transitDisk(requestId, diskId, userId, data.DiskStatusDeleting, func(disk *db_model.Disk) error {
+		if !statusIn(disk, data.DiskStatusAvailable, data.DiskStatusError) {
+			return data.ErrBadDiskDeletionStatus
+		}
+		return nil
+	}, func(tx *sql.Tx, disk *db_model.Disk) error {
+		// a deleted disk no longer takes automatic snapshots
+		if err := db_model.DeleteBindByDiskId(tx, disk.DiskID); err != nil {
+			logger.Log.Error1(requestId, "DeleteBindByDiskId error:", err)
+			return data.ErrServerInternalDB
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	asyncReq := data.DeleteDiskRequest{
+		RequestId:    requestId,
+		DiskId:       disk.DiskID,
+		DiskCategory: data.CategoryMap2[disk.Category],
+		StorageType:  disk.StorageType,
+		ScheduleInfo: disk.ClusterID,
+	}
+	if err = publish(requestId, userId, data.ActionDeleteDisk, asyncReq, disk); err != nil {
+		return nil, err
+	}
+	logger.Log.Infof(requestId, "DeleteDisk %s enqueued", diskId)
+
+	return diskResult(diskId, requestId), nil
+}
@@ -0,0 +1,103 @@
+package disk_service
+
+import (
+	"database/sql"
+	"errors"
+	"immortality-demo/pkg/data"
+	"immortality-demo/pkg/db_model"
+	"immortality-demo/pkg/logger"
+	"time"
+)
+
+type DeleteSnapshotHandler struct {
+}
+
+// Handle marks the snapshot Deleting and enqueues its removal, the row is
+// deleted once the driver has removed the snapshot. Snapshots still backing a
+// disk are kept.
+func (h *DeleteSnapshotHandler) Handle(snapshotId, userId, requestId string) (result map[string]interface{}, err error) {
+	return deleteSnapshot(snapshotId, userId, requestId, time.Time{})
+}
+
+// errNotExpired is returned to the Sweeper when the expiry of the snapshot was
+// moved or cleared after it was picked
+var errNotExpired = errors.New("the snapshot is not expired")
+
+// deleteSnapshot deletes the snapshot, if now is set only when it is expired
+// by then. The expiry is published once the driver has removed it.
+func deleteSnapshot(snapshotId, userId, requestId string, now time.Time) (result map[string]interface{}, err error) {
+	if snapshotId == "" {
+		return nil, data.ErrLackOfRequiredFieldSnapshotId
+	}
+
+	tx, err := data.Db.Begin()
+	if err != nil {
+		logger.Log.Error1(requestId, "Begin transaction error:", err)
+		return nil, data.ErrServerInternalDB
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	snapshot, err := db_model.SnapshotBySnapshotIDForUpdate(tx, snapshotId)
+	if err == sql.ErrNoRows {
+		return nil, data.ErrInvalidSnapshotId
+	}
+	if err != nil {
+		logger.Log.Error1(requestId, "SnapshotBySnapshotIDForUpdate error:", err)
+		return nil, data.ErrServerInternalDB
+	}
+	if snapshot.UserID != userId {
+		return nil, data.ErrSnapshotDoesNotBelongToUser
+	}
+	if !now.IsZero() && (!snapshot.ExpireAt.Valid || snapshot.ExpireAt.Time.After(now)) {
+		return nil, errNotExpired
+	}
+	if snapshot.Status != data.SnapshotStatusAvailable && snapshot.Status != data.SnapshotStatusError {
+		return nil, data.ErrSnapshotDeletingBadStatus
+	}
+	count, err := db_model.DiskCountFromSnapshot(tx, snapshotId)
+	if err != nil {
+		logger.Log.Error1(requestId, "DiskCountFromSnapshot error:", err)
+		return nil, data.ErrServerInternalDB
+	}
+	if count > 0 {
+		return nil, data.ErrDiskFromSnapshotExists
+	}
+	if err = db_model.MarkSnapshotDeleting(tx, snapshotId); err != nil {
+		logger.Log.Error1(requestId, "MarkSnapshotDeleting error:", err)
+		return nil, data.ErrServerInternalDB
+	}
+	if err = tx.Commit(); err != nil {
+		logger.Log.Error1(requestId, "Commit transaction error:", err)
+		return nil, data.ErrServerInternalDB
+	}
+
+	asyncReq := data.DeleteSnapshotRequest{
+		RequestId:    requestId,
+		DiskCategory: data.CategoryMap2[snapshot.Category],
+		DiskId:       snapshot.DiskID,
+		SnapshotId:   snapshotId,
+		StorageType:  snapshot.StorageType,
+		ScheduleInfo: snapshot.ClusterID,
+	}
+	if !now.IsZero() {
+		asyncReq.ExpireAt = snapshot.ExpireAt.Time.Format(time.RFC3339)
+		asyncReq.UserId = snapshot.UserID
+	}
+	if err = publish(requestId, userId, data.ActionDeleteSnapshot, asyncReq, nil); err != nil {
+		if mErr := db_model.MarkSnapshotStatus(data.Db, snapshot.Status, snapshotId); mErr != nil {
+			logger.Log.Error1(requestId, "Failed to restore snapshot status:", mErr)
+		}
+		return nil, err
+	}
+	logger.Log.Infof(requestId, "DeleteSnapshot %s of disk %s enqueued", snapshotId, snapshot.DiskID)
+
+	result = map[string]interface{}{
+		"SnapshotId": snapshotId,
+		"RequestId":  requestId,
+	}
+	return result, nil
+}
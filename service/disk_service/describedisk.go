@@ -0,0 +1,172 @@
+package disk_service
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"immortality-demo/pkg/data"
+	"immortality-demo/pkg/db_model"
+	"immortality-demo/pkg/logger"
+	"immortality-demo/service/model"
+	"strconv"
+	"time"
+)
+
+const (
+	defaultDescribeLimit = 20
+	maxDescribeLimit     = 100
+)
+
+type DescribeDisksHandler struct {
+}
+
+func (h *DescribeDisksHandler) Handle(param model.DescribeDisksParams, userId, requestId string) (result *model.DescribeDisksResult, err error) {
+	filter := db_model.DiskFilter{
+		UserID:      userId,
+		StorageType: param.StorageType,
+		ClusterID:   param.ClusterId,
+		Limit:       param.Limit,
+	}
+	if param.Status != "" {
+		status, ok := data.DiskStatusMap2[param.Status]
+		if !ok {
+			return nil, data.ErrFieldStatusWrongValue
+		}
+		filter.Status = status
+	}
+	if param.DiskCategory != "" {
+		category, ok := data.CategoryMap[param.DiskCategory]
+		if !ok {
+			return nil, data.ErrFieldDiskCategoryWrongValue
+		}
+		filter.Category = category
+	}
+	if param.StorageType != "" && param.StorageType != data.HPE3PARA && param.StorageType != data.CEPH && param.StorageType != data.LVM &&
+		param.StorageType != data.FAKE {
+		return nil, data.ErrFieldStorageTypeWrongValue
+	}
+	if filter.Limit == 0 {
+		filter.Limit = defaultDescribeLimit
+	}
+	if filter.Limit < 0 || filter.Limit > maxDescribeLimit {
+		return nil, data.ErrFieldPageSizeWrongValue
+	}
+	if param.Marker != "" {
+		filter.Marker, err = decodeMarker(param.Marker)
+		if err != nil {
+			return nil, data.ErrFieldFilterWrongValue
+		}
+	}
+
+	disks, err := db_model.DisksByFilter(data.Db, filter)
+	if err != nil {
+		logger.Log.Error1(requestId, "DisksByFilter error:", err)
+		return nil, data.ErrServerInternalDB
+	}
+	infos, err := diskInfos(requestId, disks)
+	if err != nil {
+		return nil, err
+	}
+
+	result = &model.DescribeDisksResult{
+		Disks:     infos,
+		RequestId: requestId,
+	}
+	if len(disks) == filter.Limit {
+		result.NextMarker = encodeMarker(disks[len(disks)-1].Id)
+	}
+	return result, nil
+}
+
+type DescribeDiskHandler struct {
+}
+
+func (h *DescribeDiskHandler) Handle(diskId, userId, requestId string) (result *model.DiskInfo, err error) {
+	disk, err := db_model.DiskByDiskID(data.Db, diskId)
+	if err == sql.ErrNoRows {
+		return nil, data.ErrInvalidDiskId
+	}
+	if err != nil {
+		logger.Log.Error1(requestId, "DiskByDiskID error:", err)
+		return nil, data.ErrServerInternalDB
+	}
+	if disk.UserID != userId {
+		return nil, data.ErrInvalidDiskId
+	}
+
+	infos, err := diskInfos(requestId, []*db_model.Disk{disk})
+	if err != nil {
+		return nil, err
+	}
+	return &infos[0], nil
+}
+
+// diskInfos converts disks to their api form together with attach and export info
+func diskInfos(requestId string, disks []*db_model.Disk) ([]model.DiskInfo, error) {
+	diskIds := make([]string, len(disks))
+	for i, disk := range disks {
+		diskIds[i] = disk.DiskID
+	}
+	attaches, err := db_model.AttachesByDiskIDs(data.Db, diskIds)
+	if err != nil {
+		logger.Log.Error1(requestId, "AttachesByDiskIDs error:", err)
+		return nil, data.ErrServerInternalDB
+	}
+	exports, err := db_model.ExportsByDiskIDs(data.Db, diskIds)
+	if err != nil {
+		logger.Log.Error1(requestId, "ExportsByDiskIDs error:", err)
+		return nil, data.ErrServerInternalDB
+	}
+
+	infos := make([]model.DiskInfo, 0, len(disks))
+	for _, disk := range disks {
+		info := model.DiskInfo{
+			DiskId:       disk.DiskID,
+			DiskName:     disk.Name,
+			Status:       data.DiskStatusMap[disk.Status],
+			DiskType:     data.DiskTypeMap[disk.DiskType],
+			DiskCategory: data.CategoryMap2[disk.Category],
+			Size:         disk.Size >> 30,
+			RegionId:     disk.Region,
+			ZoneId:       disk.Zone,
+			Description:  disk.Description,
+			SnapshotId:   disk.FromSnapshot,
+			ImageId:      disk.FromImage,
+			StorageType:  disk.StorageType,
+			ClusterId:    disk.ClusterID,
+			IsShare:      data.DiskIsShareMap[disk.IsShare],
+			Qos:          disk.Qos,
+			CreatedAt:    disk.CreatedAt.Format(time.RFC3339),
+			Exports:      []model.ExportInfo{},
+		}
+		if attach, ok := attaches[disk.DiskID]; ok {
+			info.Attach = &model.AttachInfo{
+				InstanceId:   attach.InstanceId,
+				CvkName:      attach.CvkName,
+				AttachStatus: attach.AttachStatus,
+			}
+		}
+		for _, export := range exports[disk.DiskID] {
+			info.Exports = append(info.Exports, model.ExportInfo{
+				CvkName: export.CvkName,
+				Iqn:     export.Iqn,
+				Lun:     export.CvkLun,
+				Target:  export.Target,
+				Status:  data.ExportStatusMap[export.Status],
+			})
+		}
+		infos = append(infos, info)
+	}
+	return infos, nil
+}
+
+func encodeMarker(id int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.Itoa(id)))
+}
+
+func decodeMarker(marker string) (int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(marker)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(string(b))
+}
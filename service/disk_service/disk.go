@@ -1 +1,157 @@
 package disk_service
+
+import (
+	"database/sql"
+	"immortality-demo/pkg/data"
+	"immortality-demo/pkg/db_model"
+	"immortality-demo/pkg/envelope"
+	"immortality-demo/pkg/gredis"
+	"immortality-demo/pkg/logger"
+	"immortality-demo/service/quota_service"
+	"immortality-demo/service/workerpool"
+	"time"
+)
+
+// statusChecker returns a business logic error if the disk can not be moved on
+type statusChecker func(disk *db_model.Disk) error
+
+// transitDisk locks the disk row, checks it belongs to the user and is in an
+// acceptable state, then marks it with the new status (0 keeps it). extra runs inside the
+// same transaction so callers can insert related rows atomically.
+func transitDisk(requestId, diskId, userId string, newStatus int8, check statusChecker,
+	extra func(tx *sql.Tx, disk *db_model.Disk) error) (disk *db_model.Disk, err error) {
+	return transit(requestId, diskId, userId, newStatus, check, extra, false)
+}
+
+// transitUserDisk is transitDisk for the callers checking the disk quotas of
+// the user in extra. The disks of the user are locked before the disk, in the
+// order CreateDisk takes them, so that two such transactions can not wait on
+// each other.
+func transitUserDisk(requestId, diskId, userId string, newStatus int8, check statusChecker,
+	extra func(tx *sql.Tx, disk *db_model.Disk) error) (disk *db_model.Disk, err error) {
+	return transit(requestId, diskId, userId, newStatus, check, extra, true)
+}
+
+func transit(requestId, diskId, userId string, newStatus int8, check statusChecker,
+	extra func(tx *sql.Tx, disk *db_model.Disk) error, lockUser bool) (disk *db_model.Disk, err error) {
+	if diskId == "" {
+		return nil, data.ErrLackOfRequiredFieldDiskId
+	}
+
+	tx, err := data.Db.Begin()
+	if err != nil {
+		logger.Log.Error1(requestId, "Begin transaction error:", err)
+		return nil, data.ErrServerInternalDB
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if lockUser {
+		if err = quota_service.LockDisks(tx, requestId, userId); err != nil {
+			return nil, err
+		}
+	}
+
+	disk, err = db_model.DiskByDiskIDForUpdate(tx, diskId)
+	if err == sql.ErrNoRows {
+		logger.Log.Error1(requestId, "cannot find disk:", diskId)
+		return nil, data.ErrInvalidDiskId
+	}
+	if err != nil {
+		logger.Log.Error1(requestId, "DiskByDiskIDForUpdate error:", err)
+		return nil, data.ErrServerInternalDB
+	}
+	if disk.UserID != userId {
+		logger.Log.Error1(requestId, "disk userId doesn't match, disk:", diskId)
+		return nil, data.ErrInvalidDiskId
+	}
+
+	if err = check(disk); err != nil {
+		logger.Log.Error1(requestId, "disk", diskId, "status check failed, status:", disk.Status, "err:", err)
+		return nil, err
+	}
+
+	if extra != nil {
+		if err = extra(tx, disk); err != nil {
+			return nil, err
+		}
+	}
+
+	if newStatus != 0 && newStatus != disk.Status {
+		disk.StatusOrig = disk.Status
+		disk.Status = newStatus
+		disk.UpdatedAt = time.Now()
+		if err = disk.Update(tx); err != nil {
+			logger.Log.Error1(requestId, "Update disk status error:", err)
+			return nil, data.ErrServerInternalDB
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		logger.Log.Error1(requestId, "Commit transaction error:", err)
+		return nil, data.ErrServerInternalDB
+	}
+	return disk, nil
+}
+
+// publish records a job and pushes it for the worker pool. If the queue is not
+// reachable and disk is given, its status is restored so the disk is not stuck.
+func publish(requestId, userId string, action string, detail interface{}, disk *db_model.Disk) error {
+	e, err := envelope.New(action, requestId, detail)
+	if err != nil {
+		logger.Log.Error1(requestId, "Failed to build envelope:", err)
+		return data.ErrServerInternal
+	}
+	value, err := envelope.Encode(e)
+	if err != nil {
+		logger.Log.Error1(requestId, "Failed to encode envelope:", err)
+		return data.ErrServerInternal
+	}
+	record := db_model.Job{
+		RequestID: requestId,
+		UserID:    userId,
+		Action:    action,
+		Payload:   string(e.Payload),
+		State:     data.JobStatePending,
+		CreatedAt: e.EnqueuedAt,
+		UpdatedAt: e.EnqueuedAt,
+	}
+	if err = record.Save(data.Db); err != nil {
+		logger.Log.Error1(requestId, "Error saving job to DB:", err)
+		return data.ErrServerInternalDB
+	}
+
+	err = gredis.Enqueue(workerpool.JobQueue, value)
+	if err != nil {
+		logger.Log.Error1(requestId, "Failed to publish", action, "err:", err)
+		if mErr := db_model.MarkJobFinished(data.Db, requestId, data.JobStateFailed, err.Error()); mErr != nil {
+			logger.Log.Error1(requestId, "Failed to mark job failed:", mErr)
+		}
+		if disk != nil {
+			if mErr := db_model.MarkDiskStatus(data.Db, disk.StatusOrig, disk.DiskID); mErr != nil {
+				logger.Log.Error1(requestId, "Failed to restore disk status:", mErr)
+			}
+		}
+		return data.ErrServerInternalMQ
+	}
+	return nil
+}
+
+func statusIn(disk *db_model.Disk, status ...int8) bool {
+	for _, s := range status {
+		if disk.Status == s {
+			return true
+		}
+	}
+	return false
+}
+
+func diskResult(diskId, requestId string) map[string]interface{} {
+	return map[string]interface{}{
+		"DiskId":    diskId,
+		"RequestId": requestId,
+	}
+}
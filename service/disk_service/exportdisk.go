@@ -0,0 +1,144 @@
+package disk_service
+
+import (
+	"database/sql"
+	"immortality-demo/pkg/data"
+	"immortality-demo/pkg/db_model"
+	"immortality-demo/pkg/logger"
+	"immortality-demo/service/model"
+	"time"
+)
+
+type ExportDiskHandler struct {
+}
+
+func (h *ExportDiskHandler) Handle(diskId string, param model.ExportDiskParams, userId, requestId string) (result map[string]interface{}, err error) {
+	if param.CVKName == "" {
+		return nil, data.ErrLackOfRequiredFieldCvkName
+	}
+	if param.Iqn == "" {
+		return nil, data.ErrLackOfRequiredFieldIqn
+	}
+
+	disk, err := transitDisk(requestId, diskId, userId, 0, func(disk *db_model.Disk) error {
+		if !statusIn(disk, data.DiskStatusAvailable, data.DiskStatusInUse) {
+			return data.ErrDiskExportingBadStatus
+		}
+		return nil
+	}, func(tx *sql.Tx, disk *db_model.Disk) error {
+		export, err := db_model.GetExport3(tx, disk.DiskID, param.CVKName)
+		if err != nil && err != sql.ErrNoRows {
+			logger.Log.Error1(requestId, "GetExport3 error:", err)
+			return data.ErrServerInternalDB
+		}
+		if export != nil {
+			// only a failed export may be retried
+			if export.Status != data.ExportStatusExportFail {
+				return data.ErrDiskExportBadStatus
+			}
+			export.Iqn = param.Iqn
+			export.Status = data.ExportStatusExporting
+			export.UpdateAt = time.Now()
+			if err := export.Save(tx); err != nil {
+				logger.Log.Error1(requestId, "Error update export to DB:", err)
+				return data.ErrServerInternalDB
+			}
+			return nil
+		}
+
+		export = &db_model.Export{
+			DiskId:   disk.DiskID,
+			CvkName:  param.CVKName,
+			Iqn:      param.Iqn,
+			Status:   data.ExportStatusExporting,
+			CreateAt: time.Now(),
+			UpdateAt: time.Now(),
+		}
+		if err := export.Save(tx); err != nil {
+			logger.Log.Error1(requestId, "Error saving export to DB:", err)
+			return data.ErrServerInternalDB
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	asyncReq := data.ExportDiskRequest{
+		RequestId:    requestId,
+		CVKName:      param.CVKName,
+		DiskId:       disk.DiskID,
+		Iqn:          param.Iqn,
+		StorageType:  disk.StorageType,
+		ScheduleInfo: disk.ClusterID,
+	}
+	if err = publish(requestId, userId, data.ActionExport, asyncReq, nil); err != nil {
+		// leave the export retriable, only a failed one may be exported again
+		if mErr := db_model.MarkExportStatus(data.Db, data.ExportStatusExportFail, disk.DiskID, param.CVKName); mErr != nil {
+			logger.Log.Error1(requestId, "Failed to mark export failed:", mErr)
+		}
+		return nil, err
+	}
+	logger.Log.Infof(requestId, "Export %s to %s enqueued", diskId, param.CVKName)
+
+	result = map[string]interface{}{
+		"DiskId":    diskId,
+		"CVKName":   param.CVKName,
+		"RequestId": requestId,
+	}
+	return result, nil
+}
+
+type CancelExportHandler struct {
+}
+
+func (h *CancelExportHandler) Handle(diskId, cvkName, userId, requestId string) (result map[string]interface{}, err error) {
+	if cvkName == "" {
+		return nil, data.ErrLackOfRequiredFieldCvkName
+	}
+
+	var iqn string
+	disk, err := transitDisk(requestId, diskId, userId, 0, func(disk *db_model.Disk) error {
+		if !statusIn(disk, data.DiskStatusAvailable, data.DiskStatusInUse) {
+			return data.ErrDiskCancelExportingBadStatus
+		}
+		return nil
+	}, func(tx *sql.Tx, disk *db_model.Disk) error {
+		export, err := db_model.GetExport3(tx, disk.DiskID, cvkName)
+		if err == sql.ErrNoRows {
+			return data.ErrDiskCancelExportBadStatus
+		}
+		if err != nil {
+			logger.Log.Error1(requestId, "GetExport3 error:", err)
+			return data.ErrServerInternalDB
+		}
+		if export.Status == data.ExportStatusExporting {
+			return data.ErrDiskCancelExportBadStatus
+		}
+		iqn = export.Iqn
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	asyncReq := data.ExportDiskRequest{
+		RequestId:    requestId,
+		CVKName:      cvkName,
+		DiskId:       disk.DiskID,
+		Iqn:          iqn,
+		StorageType:  disk.StorageType,
+		ScheduleInfo: disk.ClusterID,
+	}
+	if err = publish(requestId, userId, data.ActionCancelExport, asyncReq, nil); err != nil {
+		return nil, err
+	}
+	logger.Log.Infof(requestId, "CancelExport %s from %s enqueued", diskId, cvkName)
+
+	result = map[string]interface{}{
+		"DiskId":    diskId,
+		"CVKName":   cvkName,
+		"RequestId": requestId,
+	}
+	return result, nil
+}
@@ -0,0 +1,75 @@
+package disk_service
+
+import (
+	"immortality-demo/pkg/data"
+	"immortality-demo/pkg/db_model"
+	"immortality-demo/pkg/logger"
+	"immortality-demo/service/image_service"
+)
+
+type ReInitDiskHandler struct {
+}
+
+// Handle re-initializes a disk from the image or snapshot it was created from
+func (h *ReInitDiskHandler) Handle(diskId, userId, requestId string) (result map[string]interface{}, err error) {
+	disk, err := transitDisk(requestId, diskId, userId, data.DiskStatusResetting, func(disk *db_model.Disk) error {
+		if disk.Status != data.DiskStatusInUse {
+			return data.ErrDiskReInitBadStatus
+		}
+		if disk.FromImage == "" && disk.FromSnapshot == "" {
+			return data.ErrInitSourceNotFound
+		}
+		if disk.FromImage == "" {
+			exist, err := db_model.SnapshotExistBySnapshotId(data.Db, disk.FromSnapshot)
+			if err != nil {
+				return data.ErrServerInternalDB
+			}
+			if !exist {
+				return data.ErrInitSourceNotFound
+			}
+		}
+		return nil
+	}, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var imageType string
+	if disk.FromImage != "" {
+		image, err := image_service.GetImageByImageID(requestId, disk.FromImage)
+		if err == nil {
+			err = checkImage(requestId, image)
+		}
+		if err != nil {
+			logger.Log.Error1(requestId, "ReInitDisk image", disk.FromImage, "error:", err)
+			db_model.MarkDiskStatus(data.Db, disk.StatusOrig, disk.DiskID)
+			return nil, err
+		}
+		imageType = data.IMAGE_TYPE_CUSTOM
+		if image.IsPublic() {
+			imageType = data.IMAGE_TYPE_PUBLIC
+		}
+	}
+
+	asyncReq := data.ReInitDiskRequest{
+		RequestId:      requestId,
+		DiskCategory:   data.CategoryMap2[disk.Category],
+		DiskId:         disk.DiskID,
+		OriginalStatus: disk.StatusOrig,
+		Size:           uint64(disk.Size),
+		StorageType:    disk.StorageType,
+		ScheduleInfo:   disk.ClusterID,
+		ImageType:      imageType,
+	}
+	if disk.FromImage != "" {
+		asyncReq.ImageId = disk.FromImage
+	} else {
+		asyncReq.SnapshotId = disk.FromSnapshot
+	}
+	if err = publish(requestId, userId, data.ActionReInitDisk, asyncReq, disk); err != nil {
+		return nil, err
+	}
+	logger.Log.Infof(requestId, "ReInitDisk %s enqueued", diskId)
+
+	return diskResult(diskId, requestId), nil
+}
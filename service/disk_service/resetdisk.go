@@ -0,0 +1,67 @@
+package disk_service
+
+import (
+	"database/sql"
+	"immortality-demo/pkg/data"
+	"immortality-demo/pkg/db_model"
+	"immortality-demo/pkg/logger"
+	"immortality-demo/service/model"
+)
+
+type ResetDiskHandler struct {
+}
+
+func (h *ResetDiskHandler) Handle(diskId string, param model.ResetDiskParams, userId, requestId string) (result map[string]interface{}, err error) {
+	if param.SnapshotId == "" {
+		return nil, data.ErrLackOfRequiredFieldSnapshotId
+	}
+
+	snapshot, err := db_model.SnapshotBySnapshotID(data.Db, param.SnapshotId)
+	if err == sql.ErrNoRows {
+		logger.Log.Error1(requestId, "cannot find snapshot:", param.SnapshotId)
+		return nil, data.ErrInvalidSnapshotId
+	}
+	if err != nil {
+		logger.Log.Error1(requestId, "ResetDisk SnapshotBySnapshotID error:", err)
+		return nil, data.ErrServerInternalDB
+	}
+	if snapshot.UserID != userId || snapshot.Status != data.SnapshotStatusAvailable {
+		return nil, data.ErrInvalidSnapshotId
+	}
+	if snapshot.DiskID != diskId {
+		return nil, data.ErrSnapshotNotBelongToDisk
+	}
+
+	disk, err := transitDisk(requestId, diskId, userId, data.DiskStatusResetting, func(disk *db_model.Disk) error {
+		if disk.DiskType == data.DiskTypeData && disk.Status != data.DiskStatusAvailable {
+			return data.ErrDiskDataResettingBadStatus
+		}
+		if !statusIn(disk, data.DiskStatusAvailable, data.DiskStatusInUse) {
+			return data.ErrDiskResettingBadStatus
+		}
+		return nil
+	}, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	asyncReq := data.ResetDiskRequest{
+		RequestId:      requestId,
+		DiskCategory:   data.CategoryMap2[disk.Category],
+		DiskId:         disk.DiskID,
+		DiskType:       disk.DiskType,
+		SnapshotId:     snapshot.SnapshotID,
+		OriginalStatus: disk.StatusOrig,
+		UserId:         userId,
+		SnapSize:       uint64(snapshot.Size),
+		DiskSize:       uint64(disk.Size),
+		StorageType:    disk.StorageType,
+		ScheduleInfo:   disk.ClusterID,
+	}
+	if err = publish(requestId, userId, data.ActionResetDisk, asyncReq, disk); err != nil {
+		return nil, err
+	}
+	logger.Log.Infof(requestId, "ResetDisk %s from snapshot %s enqueued", diskId, param.SnapshotId)
+
+	return diskResult(diskId, requestId), nil
+}
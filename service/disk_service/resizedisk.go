@@ -0,0 +1,63 @@
+package disk_service
+
+import (
+	"database/sql"
+	"immortality-demo/pkg/data"
+	"immortality-demo/pkg/db_model"
+	"immortality-demo/pkg/logger"
+	"immortality-demo/service/model"
+	"immortality-demo/service/quota_service"
+)
+
+type ResizeDiskHandler struct {
+}
+
+func (h *ResizeDiskHandler) Handle(diskId string, param model.ResizeDiskParams, userId, requestId string) (result map[string]interface{}, err error) {
+	if param.NewSize == 0 {
+		return nil, data.ErrLackOfRequiredFieldNewSize
+	}
+	if param.NewSize < 0 {
+		return nil, data.ErrFieldNewSizeWrongValue
+	}
+
+	disk, err := transitUserDisk(requestId, diskId, userId, data.DiskStatusResizing, func(disk *db_model.Disk) error {
+		if !statusIn(disk, data.DiskStatusAvailable, data.DiskStatusInUse) {
+			return data.ErrDiskResizingBadStatus
+		}
+		if param.NewSize<<30 < disk.Size {
+			return data.ErrCanNotDownSize
+		}
+		if param.NewSize<<30 == disk.Size {
+			return data.ErrFieldNewSizeWrongValue
+		}
+		if disk.StorageType == data.HPE3PARA && param.NewSize > data.HPE3PAR_MAX_DISK_SIZE {
+			return data.ErrFieldNewSizeWrongValue
+		}
+		return nil
+	}, func(tx *sql.Tx, disk *db_model.Disk) error {
+		return quota_service.CheckResizeDisk(tx, requestId, userId, param.NewSize<<30-disk.Size)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	asyncReq := data.ResizeDiskRequest{
+		RequestId:      requestId,
+		DiskCategory:   data.CategoryMap2[disk.Category],
+		DiskId:         disk.DiskID,
+		OldSize:        disk.Size,
+		NewSize:        param.NewSize << 30,
+		DiskType:       disk.DiskType,
+		OriginalStatus: disk.StatusOrig,
+		StorageType:    disk.StorageType,
+		ScheduleInfo:   disk.ClusterID,
+		UserId:         userId,
+		QoS:            disk.Qos,
+	}
+	if err = publish(requestId, userId, data.ActionResizeDisk, asyncReq, disk); err != nil {
+		return nil, err
+	}
+	logger.Log.Infof(requestId, "ResizeDisk %s to %dG enqueued", diskId, param.NewSize)
+
+	return diskResult(diskId, requestId), nil
+}
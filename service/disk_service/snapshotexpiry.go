@@ -0,0 +1,81 @@
+package disk_service
+
+import (
+	"database/sql"
+	"immortality-demo/pkg/data"
+	"immortality-demo/pkg/db_model"
+	"immortality-demo/pkg/logger"
+	"immortality-demo/service/model"
+	"time"
+)
+
+type SetSnapshotExpiryHandler struct {
+}
+
+// Handle sets, extends or clears the expiry of a snapshot, the Sweeper
+// deletes the snapshot once it is past
+func (h *SetSnapshotExpiryHandler) Handle(snapshotId string, param model.SnapshotExpiryParams, userId, requestId string) (result map[string]interface{}, err error) {
+	if snapshotId == "" {
+		return nil, data.ErrLackOfRequiredFieldSnapshotId
+	}
+	expireAt, err := parseExpireAt(param.ExpireAt)
+	if err != nil {
+		return nil, err
+	}
+
+	// the row is locked so that the Sweeper does not delete the snapshot
+	// between the check and the update
+	tx, err := data.Db.Begin()
+	if err != nil {
+		logger.Log.Error1(requestId, "Begin transaction error:", err)
+		return nil, data.ErrServerInternalDB
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	snapshot, err := db_model.SnapshotBySnapshotIDForUpdate(tx, snapshotId)
+	if err == sql.ErrNoRows {
+		return nil, data.ErrInvalidSnapshotId
+	}
+	if err != nil {
+		logger.Log.Error1(requestId, "SnapshotBySnapshotIDForUpdate error:", err)
+		return nil, data.ErrServerInternalDB
+	}
+	if snapshot.UserID != userId {
+		return nil, data.ErrSnapshotDoesNotBelongToUser
+	}
+	if snapshot.Status == data.SnapshotStatusDeleting {
+		return nil, data.ErrInvalidSnapshotId
+	}
+
+	if err = db_model.SetSnapshotExpireAt(tx, expireAt, snapshotId); err != nil {
+		logger.Log.Error1(requestId, "SetSnapshotExpireAt error:", err)
+		return nil, data.ErrServerInternalDB
+	}
+	if err = tx.Commit(); err != nil {
+		logger.Log.Error1(requestId, "Commit transaction error:", err)
+		return nil, data.ErrServerInternalDB
+	}
+	logger.Log.Infof(requestId, "snapshot %s expires at %q", snapshotId, param.ExpireAt)
+
+	return map[string]interface{}{
+		"SnapshotId": snapshotId,
+		"ExpireAt":   param.ExpireAt,
+		"RequestId":  requestId,
+	}, nil
+}
+
+// parseExpireAt parses a future time in RFC3339, an empty one is no expiry
+func parseExpireAt(value string) (expireAt sql.NullTime, err error) {
+	if value == "" {
+		return expireAt, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil || !t.After(time.Now()) {
+		return expireAt, data.ErrFieldExpireAtWrongValue
+	}
+	return sql.NullTime{Time: t, Valid: true}, nil
+}
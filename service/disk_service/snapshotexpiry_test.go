@@ -0,0 +1,156 @@
+package disk_service
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"immortality-demo/config"
+	"immortality-demo/pkg/data"
+	"immortality-demo/pkg/db_model"
+	"immortality-demo/pkg/gredis"
+	"immortality-demo/pkg/logger"
+	"immortality-demo/service/model"
+	"immortality-demo/service/workerpool"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	_ "github.com/go-sql-driver/mysql"
+	"github.com/gomodule/redigo/redis"
+)
+
+func TestParseExpireAt(t *testing.T) {
+	if expireAt, err := parseExpireAt(""); err != nil || expireAt.Valid {
+		t.Errorf("an empty ExpireAt is no expiry, got %v, %v", expireAt, err)
+	}
+	future := time.Now().Add(time.Hour).Truncate(time.Second)
+	if expireAt, err := parseExpireAt(future.Format(time.RFC3339)); err != nil || !expireAt.Time.Equal(future) {
+		t.Errorf("expected %s, got %v, %v", future, expireAt, err)
+	}
+
+	invalid := []string{
+		time.Now().Add(-time.Minute).Format(time.RFC3339),
+		"2030-01-01",
+		"tomorrow",
+	}
+	for _, value := range invalid {
+		if _, err := parseExpireAt(value); err != data.ErrFieldExpireAtWrongValue {
+			t.Errorf("parseExpireAt(%q): expected ErrFieldExpireAtWrongValue, got %v", value, err)
+		}
+	}
+}
+
+// TestSetSnapshotExpiryInvalid checks the parameters which are refused before
+// the database is needed
+func TestSetSnapshotExpiryInvalid(t *testing.T) {
+	h := &SetSnapshotExpiryHandler{}
+	if _, err := h.Handle("", model.SnapshotExpiryParams{}, "user-1", "req-1"); err != data.ErrLackOfRequiredFieldSnapshotId {
+		t.Errorf("without a snapshot id: %v", err)
+	}
+	past := model.SnapshotExpiryParams{ExpireAt: time.Now().Add(-time.Hour).Format(time.RFC3339)}
+	if _, err := h.Handle("snap-1", past, "user-1", "req-1"); err != data.ErrFieldExpireAtWrongValue {
+		t.Errorf("an expiry in the past: %v", err)
+	}
+}
+
+// setupBackends connects redis and the database of the config, the test is
+// skipped if either is not reachable
+func setupBackends(t *testing.T) {
+	config.LoadConfig()
+	logger.Log = logger.NewStdoutLogger(os.Stdout, "")
+	gredis.Setup()
+	conn := gredis.RedisConn.Get()
+	_, err := conn.Do("PING")
+	conn.Close()
+	if err != nil {
+		t.Skip("redis is not available:", err)
+	}
+
+	u, err := url.Parse(config.Config.DBPath)
+	if err != nil {
+		t.Skip("bad db path:", err)
+	}
+	db, err := sql.Open("mysql", fmt.Sprintf("%s@tcp(%s)%s?%s", u.User.String(), u.Host, u.Path, u.RawQuery))
+	if err == nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+		err = db.PingContext(ctx)
+		cancel()
+	}
+	if err != nil {
+		t.Skip("database is not available:", err)
+	}
+	data.Db = db
+}
+
+// TestSweep sets the expiry of two snapshots through the handler, only the
+// one past its expiry is marked deleting by the sweeper
+func TestSweep(t *testing.T) {
+	setupBackends(t)
+
+	userId := fmt.Sprintf("user-expiry-%d", time.Now().UnixNano())
+	now := time.Now()
+	var ids []string
+	for _, name := range []string{"expired", "kept"} {
+		s := db_model.Snapshot{
+			SnapshotID: userId + "-" + name,
+			Status:     data.SnapshotStatusAvailable,
+			DiskID:     userId + "-disk",
+			UserID:     userId,
+			CreatedAt:  now,
+			UpdatedAt:  now,
+		}
+		if err := s.Insert(data.Db); err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, s.SnapshotID)
+	}
+	defer cleanupSnapshots(userId, ids)
+	expired, kept := ids[0], ids[1]
+
+	h := &SetSnapshotExpiryHandler{}
+	expireAt := now.Add(time.Hour).Format(time.RFC3339)
+	for _, id := range ids {
+		if _, err := h.Handle(id, model.SnapshotExpiryParams{ExpireAt: expireAt}, userId, "req-1"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := h.Handle(expired, model.SnapshotExpiryParams{ExpireAt: expireAt}, "user-other", "req-1"); err != data.ErrSnapshotDoesNotBelongToUser {
+		t.Errorf("set the expiry of another user's snapshot: %v", err)
+	}
+	// the handler refuses an expiry in the past, it is moved back in the database
+	if _, err := data.Db.Exec(`UPDATE snapshot SET expire_at = ? WHERE snapshot_id = ?`, now.Add(-time.Minute), expired); err != nil {
+		t.Fatal(err)
+	}
+
+	NewSweeper(time.Minute).Sweep(now)
+
+	for id, status := range map[string]int8{expired: data.SnapshotStatusDeleting, kept: data.SnapshotStatusAvailable} {
+		s, err := db_model.SnapshotBySnapshotID(data.Db, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if s.Status != status {
+			t.Errorf("snapshot %s: expected status %d, got %d", id, status, s.Status)
+		}
+	}
+}
+
+// cleanupSnapshots drops the snapshots, the jobs of the user and their entries
+// in the queue
+func cleanupSnapshots(userId string, ids []string) {
+	for _, id := range ids {
+		data.Db.Exec(`DELETE FROM snapshot WHERE snapshot_id = ?`, id)
+	}
+	data.Db.Exec(`DELETE FROM job WHERE user_id = ?`, userId)
+
+	conn := gredis.RedisConn.Get()
+	defer conn.Close()
+	items, _ := redis.ByteSlices(conn.Do("LRANGE", workerpool.JobQueue, 0, -1))
+	for _, item := range items {
+		if strings.Contains(string(item), userId) {
+			conn.Do("LREM", workerpool.JobQueue, 0, item)
+		}
+	}
+}
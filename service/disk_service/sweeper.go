@@ -0,0 +1,81 @@
+package disk_service
+
+import (
+	"immortality-demo/pkg/data"
+	"immortality-demo/pkg/db_model"
+	"immortality-demo/pkg/logger"
+	"immortality-demo/pkg/util"
+	"sync"
+	"time"
+)
+
+// sweepBatch bounds the snapshots deleted in a round
+const sweepBatch = 100
+
+// Sweeper deletes the snapshots past their ExpireAt on a timer. Snapshots
+// still backing a disk are left alone and tried again in the next rounds.
+type Sweeper struct {
+	period time.Duration
+	quit   chan struct{}
+	wg     sync.WaitGroup
+}
+
+func NewSweeper(period time.Duration) *Sweeper {
+	return &Sweeper{
+		period: period,
+		quit:   make(chan struct{}),
+	}
+}
+
+// Start sweeps right away and then once every period
+func (s *Sweeper) Start() {
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		ticker := time.NewTicker(s.period)
+		defer ticker.Stop()
+		for {
+			s.Sweep(time.Now())
+			select {
+			case <-s.quit:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Stop waits for the running round to finish
+func (s *Sweeper) Stop() {
+	close(s.quit)
+	s.wg.Wait()
+}
+
+// Sweep enqueues the deletion of the snapshots expired at now, the driver
+// publishes their expiry once they are gone. Each deletion is a job of its
+// own request id, the id of the round is only logged.
+func (s *Sweeper) Sweep(now time.Time) {
+	roundId := "expiry-" + util.GenerateRandomId()
+	snapshots, err := db_model.ExpiredSnapshots(data.Db, now, sweepBatch)
+	if err != nil {
+		logger.Log.Error1(roundId, "ExpiredSnapshots error:", err)
+		return
+	}
+
+	for _, snapshot := range snapshots {
+		requestId := "expiry-" + util.GenerateRandomId()
+		_, err = deleteSnapshot(snapshot.SnapshotID, snapshot.UserID, requestId, now)
+		if err == data.ErrDiskFromSnapshotExists {
+			logger.Log.Debugf(roundId, "expired snapshot %s still backs a disk, kept", snapshot.SnapshotID)
+			continue
+		}
+		if err == errNotExpired {
+			continue
+		}
+		if err != nil {
+			logger.Log.Errorf(roundId, "failed to delete expired snapshot %s: %v", snapshot.SnapshotID, err)
+			continue
+		}
+		logger.Log.Infof(roundId, "expired snapshot %s is deleted by request %s", snapshot.SnapshotID, requestId)
+	}
+}
@@ -0,0 +1,163 @@
+package image_service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"immortality-demo/config"
+	"immortality-demo/pkg/data"
+	e "immortality-demo/pkg/error"
+	"immortality-demo/pkg/logger"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultTimeout bounds one request to the image service
+	DefaultTimeout = 5 * time.Second
+	// DefaultRetries is how many times a failed request is sent again
+	DefaultRetries = 3
+	// DefaultRetryWait is the wait before the first retry, it doubles on
+	// each of the next ones
+	DefaultRetryWait = 200 * time.Millisecond
+)
+
+// response is the envelope of the answers of the image service
+type response struct {
+	Status  string          `json:"Status"`
+	Message string          `json:"Message"`
+	Detail  json.RawMessage `json:"Detail"`
+}
+
+// Client describes images through the API of the image service, with
+// retries. Images are not cached: every caller checks whether the user may
+// use the image, which must not rest on an answer given before the image was
+// deleted or unpublished.
+type Client struct {
+	EndPoint   string
+	HttpClient *http.Client
+	Retries    int
+	RetryWait  time.Duration
+}
+
+func NewClient(endPoint string) *Client {
+	return &Client{
+		EndPoint:   endPoint,
+		HttpClient: &http.Client{Timeout: DefaultTimeout},
+		Retries:    DefaultRetries,
+		RetryWait:  DefaultRetryWait,
+	}
+}
+
+var (
+	defaultLock   sync.Mutex
+	defaultClient *Client
+)
+
+// DefaultClient is the client of config.Config.ImageServiceEndPoint
+func DefaultClient() *Client {
+	defaultLock.Lock()
+	defer defaultLock.Unlock()
+	if defaultClient == nil {
+		defaultClient = NewClient(config.Config.ImageServiceEndPoint)
+	}
+	return defaultClient
+}
+
+// SetDefaultClient replaces the default client, tests point it at a
+// imagetest.Server
+func SetDefaultClient(c *Client) {
+	defaultLock.Lock()
+	defer defaultLock.Unlock()
+	defaultClient = c
+}
+
+// GetImageByImageID describes the image with the default client. It returns
+// data.ErrInvalidImageId if the image does not exist and
+// data.ErrServerInternalImageService if the image service can not tell.
+func GetImageByImageID(requestId, imageId string) (*Image, error) {
+	return DefaultClient().GetImage(context.Background(), requestId, imageId)
+}
+
+// GetImage describes the image through the image service
+func (c *Client) GetImage(ctx context.Context, requestId, imageId string) (*Image, error) {
+	if imageId == "" {
+		return nil, data.ErrInvalidImageId
+	}
+
+	requestUrl := c.EndPoint + "/v1/images/" + url.PathEscape(imageId)
+	var image Image
+	for i := 0; ; i++ {
+		retry, err := c.get(ctx, requestId, requestUrl, &image)
+		if err == nil {
+			break
+		}
+		if err == data.ErrInvalidImageId {
+			return nil, err
+		}
+		if !retry || i >= c.Retries {
+			logger.Log.Errorf(requestId, "failed to describe image %s: %v", imageId, err)
+			return nil, data.ErrServerInternalImageService
+		}
+		wait := c.RetryWait << uint(i)
+		logger.Log.Warnf(requestId, "failed to describe image %s, retrying in %v: %v", imageId, wait, err)
+		if err = sleepContext(ctx, wait); err != nil {
+			logger.Log.Errorf(requestId, "failed to describe image %s: %v", imageId, err)
+			return nil, data.ErrServerInternalImageService
+		}
+	}
+
+	return &image, nil
+}
+
+// get sends one request, retry tells whether the error is worth another one
+func (c *Client) get(ctx context.Context, requestId, requestUrl string, image *Image) (retry bool, err error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", requestUrl, nil)
+	if err != nil {
+		return false, err
+	}
+	if requestId != "" {
+		req.Header.Set("RequestId", requestId)
+	}
+	resp, err := c.HttpClient.Do(req)
+	if err != nil {
+		return ctx.Err() == nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return true, err
+	}
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return false, data.ErrInvalidImageId
+	case resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests:
+		return true, fmt.Errorf("image service answered %d: %s", resp.StatusCode, body)
+	case resp.StatusCode != http.StatusOK:
+		return false, fmt.Errorf("image service answered %d: %s", resp.StatusCode, body)
+	}
+
+	var r response
+	if err = json.Unmarshal(body, &r); err != nil {
+		return false, err
+	}
+	if r.Status != e.SUCCESS {
+		return false, fmt.Errorf("image service answered %s: %s", r.Status, r.Message)
+	}
+	return false, json.Unmarshal(r.Detail, image)
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,102 @@
+package image_service
+
+import (
+	"encoding/json"
+	"fmt"
+	"immortality-demo/pkg/data"
+	"time"
+)
+
+// Image is the metadata of an image. Status, Format, Architecture and OSType
+// are the keys of data.ImageStatusMap, data.ImageFormatMap,
+// data.ImageArchitectureMap and data.OSTypeMap, the image service sends their
+// names.
+type Image struct {
+	ImageId string
+	Name    string
+	// Size is the smallest disk the image fits, in GB
+	Size int64
+	// UserId is the owner, data.SpatialUserPublic for a public image
+	UserId       string
+	ClusterId    string
+	Status       int8
+	Format       int8
+	Architecture int8
+	OSType       int8
+	CreatedAt    time.Time
+}
+
+// IsPublic reports whether every user may create disks from the image
+func (i *Image) IsPublic() bool {
+	return i.UserId == data.SpatialUserPublic
+}
+
+// Known reports whether the status, format, architecture and OS type of the
+// image are all ones this service knows, disks are only created from such
+// images
+func (i *Image) Known() bool {
+	return i.Status != 0 && i.Format != 0 && i.Architecture != 0 && i.OSType != 0
+}
+
+// imageDetail is Image as the image service sends it
+type imageDetail struct {
+	ImageId      string `json:"ImageId"`
+	ImageName    string `json:"ImageName"`
+	Size         int64  `json:"Size"`
+	UserId       string `json:"UserId"`
+	ClusterId    string `json:"ClusterId"`
+	Status       string `json:"Status"`
+	Format       string `json:"Format"`
+	Architecture string `json:"Architecture"`
+	OSType       string `json:"OSType"`
+	CreatedAt    string `json:"CreatedAt"`
+}
+
+func (i Image) MarshalJSON() ([]byte, error) {
+	detail := imageDetail{
+		ImageId:      i.ImageId,
+		ImageName:    i.Name,
+		Size:         i.Size,
+		UserId:       i.UserId,
+		ClusterId:    i.ClusterId,
+		Status:       data.ImageStatusMap[i.Status],
+		Format:       data.ImageFormatMap[i.Format],
+		Architecture: data.ImageArchitectureMap[i.Architecture],
+		OSType:       data.OSTypeMap[i.OSType],
+	}
+	if !i.CreatedAt.IsZero() {
+		detail.CreatedAt = i.CreatedAt.Format(time.RFC3339)
+	}
+	return json.Marshal(detail)
+}
+
+// UnmarshalJSON keeps the names the maps of data do not know, or empty ones,
+// as 0, so that an image of a newer kind can still be described. See Known.
+func (i *Image) UnmarshalJSON(b []byte) error {
+	var detail imageDetail
+	if err := json.Unmarshal(b, &detail); err != nil {
+		return err
+	}
+
+	image := Image{
+		ImageId:      detail.ImageId,
+		Name:         detail.ImageName,
+		Size:         detail.Size,
+		UserId:       detail.UserId,
+		ClusterId:    detail.ClusterId,
+		Status:       data.ImageStatusMap2[detail.Status],
+		Format:       data.ImageFormatMap2[detail.Format],
+		Architecture: data.ImageArchitectureMap2[detail.Architecture],
+		OSType:       data.OSTypeMap2[detail.OSType],
+	}
+	if detail.CreatedAt != "" {
+		t, err := time.Parse(time.RFC3339, detail.CreatedAt)
+		if err != nil {
+			return fmt.Errorf("image %s: %v", detail.ImageId, err)
+		}
+		image.CreatedAt = t
+	}
+
+	*i = image
+	return nil
+}
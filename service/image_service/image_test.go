@@ -0,0 +1,121 @@
+package image_service_test
+
+import (
+	"context"
+	"immortality-demo/pkg/data"
+	"immortality-demo/pkg/logger"
+	"immortality-demo/service/image_service"
+	"immortality-demo/service/image_service/imagetest"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+)
+
+func newServer(t *testing.T) *imagetest.Server {
+	logger.Log = logger.NewStdoutLogger(os.Stdout, "")
+	server := imagetest.NewServer()
+	t.Cleanup(server.Close)
+	server.AddImage(image_service.Image{
+		ImageId:      "img-centos",
+		Name:         "centos-7",
+		Size:         40,
+		UserId:       data.SpatialUserPublic,
+		ClusterId:    "ceph-1",
+		Status:       data.ImageStatusAvailable,
+		Format:       data.ImageFormatQcow2,
+		Architecture: data.ImageArchitectureX8664,
+		OSType:       data.OSTypeLinux,
+		CreatedAt:    time.Date(2020, 5, 1, 8, 0, 0, 0, time.UTC),
+	})
+	return server
+}
+
+func TestClient_GetImage(t *testing.T) {
+	server := newServer(t)
+	client := server.ImageClient()
+
+	image, err := client.GetImage(context.Background(), "req-1", "img-centos")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if image.Size != 40 || !image.IsPublic() || image.ClusterId != "ceph-1" ||
+		image.Format != data.ImageFormatQcow2 || image.Architecture != data.ImageArchitectureX8664 ||
+		image.OSType != data.OSTypeLinux || image.Status != data.ImageStatusAvailable ||
+		!image.CreatedAt.Equal(time.Date(2020, 5, 1, 8, 0, 0, 0, time.UTC)) {
+		t.Errorf("unexpected image %+v", image)
+	}
+
+	// nothing is cached, a deleted image is seen at once
+	server.DeleteImage("img-centos")
+	if _, err = client.GetImage(context.Background(), "req-2", "img-centos"); err != data.ErrInvalidImageId {
+		t.Errorf("deleted image: %v", err)
+	}
+	if n := server.Requests(); n != 2 {
+		t.Errorf("%d requests, want 2", n)
+	}
+
+	if _, err = client.GetImage(context.Background(), "req-4", "img-missing"); err != data.ErrInvalidImageId {
+		t.Errorf("missing image: %v", err)
+	}
+}
+
+func TestClient_Retries(t *testing.T) {
+	server := newServer(t)
+	client := server.ImageClient()
+
+	server.Fail(http.StatusServiceUnavailable, http.StatusBadGateway)
+	if _, err := client.GetImage(context.Background(), "req-1", "img-centos"); err != nil {
+		t.Fatalf("should succeed on the third request: %v", err)
+	}
+	if n := server.Requests(); n != 3 {
+		t.Errorf("%d requests, want 3", n)
+	}
+
+	server.Fail(500, 500, 500, 500)
+	if _, err := client.GetImage(context.Background(), "req-2", "img-centos"); err != data.ErrServerInternalImageService {
+		t.Errorf("should give up after %d retries: %v", client.Retries, err)
+	}
+	if n := server.Requests(); n != 3+1+client.Retries {
+		t.Errorf("%d requests, want %d", n, 3+1+client.Retries)
+	}
+
+	server.Fail(http.StatusBadRequest)
+	if _, err := client.GetImage(context.Background(), "req-3", "img-centos"); err != data.ErrServerInternalImageService {
+		t.Errorf("bad request: %v", err)
+	}
+	if n := server.Requests(); n != 3+1+client.Retries+1 {
+		t.Errorf("a bad request should not be retried, %d requests", n)
+	}
+}
+
+func TestClient_Timeout(t *testing.T) {
+	server := newServer(t)
+	client := server.ImageClient()
+	client.HttpClient.Timeout = 20 * time.Millisecond
+	client.Retries = 1
+	server.Delay(300 * time.Millisecond)
+
+	start := time.Now()
+	if _, err := client.GetImage(context.Background(), "req-1", "img-centos"); err != data.ErrServerInternalImageService {
+		t.Errorf("slow image service: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 200*time.Millisecond {
+		t.Errorf("took %v, the timeout was not applied", elapsed)
+	}
+}
+
+func TestImage_UnknownFormat(t *testing.T) {
+	var image image_service.Image
+	err := image.UnmarshalJSON([]byte(`{"ImageId":"img-1","Status":"Available","Format":"vmdk","Architecture":"x86_64","OSType":""}`))
+	if err != nil {
+		t.Fatal("unknown names should be kept as 0:", err)
+	}
+	if image.Format != 0 || image.OSType != 0 || image.Status != data.ImageStatusAvailable ||
+		image.Architecture != data.ImageArchitectureX8664 {
+		t.Errorf("unexpected image %+v", image)
+	}
+	if image.Known() {
+		t.Error("vmdk should not be known")
+	}
+}
@@ -0,0 +1,117 @@
+// Package imagetest is a stand-in for the image service, so that the disks
+// created from images can be tested offline. It keeps images in memory and
+// tests can make it fail or slow down.
+package imagetest
+
+import (
+	"encoding/json"
+	"immortality-demo/pkg/data"
+	e "immortality-demo/pkg/error"
+	"immortality-demo/service/image_service"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+)
+
+const imagesPrefix = "/v1/images/"
+
+// Server is the simulated image service, see NewServer
+type Server struct {
+	*httptest.Server
+
+	lock     sync.Mutex
+	images   map[string]image_service.Image
+	failures []int
+	delay    time.Duration
+	requests int
+}
+
+func NewServer() *Server {
+	s := &Server{images: make(map[string]image_service.Image)}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+	return s
+}
+
+// ImageClient returns a client of the server which retries without waiting
+func (s *Server) ImageClient() *image_service.Client {
+	c := image_service.NewClient(s.URL)
+	c.RetryWait = time.Millisecond
+	return c
+}
+
+// AddImage adds or replaces an image
+func (s *Server) AddImage(image image_service.Image) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.images[image.ImageId] = image
+}
+
+func (s *Server) DeleteImage(imageId string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	delete(s.images, imageId)
+}
+
+// Fail answers the next requests with the http codes, one request each
+func (s *Server) Fail(codes ...int) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.failures = append(s.failures, codes...)
+}
+
+// Delay holds every answer for d
+func (s *Server) Delay(d time.Duration) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.delay = d
+}
+
+// Requests is the number of requests served
+func (s *Server) Requests() int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.requests
+}
+
+func (s *Server) serve(w http.ResponseWriter, r *http.Request) {
+	s.lock.Lock()
+	s.requests++
+	delay := s.delay
+	code := 0
+	if len(s.failures) > 0 {
+		code, s.failures = s.failures[0], s.failures[1:]
+	}
+	image, ok := s.images[strings.TrimPrefix(r.URL.Path, imagesPrefix)]
+	s.lock.Unlock()
+
+	if delay > 0 {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-time.After(delay):
+		}
+	}
+
+	switch {
+	case code != 0:
+		reply(w, code, e.ERROR, http.StatusText(code), nil)
+	case r.Method != http.MethodGet || !strings.HasPrefix(r.URL.Path, imagesPrefix):
+		reply(w, http.StatusNotFound, e.ERROR, "no such API", nil)
+	case !ok:
+		reply(w, http.StatusNotFound, data.ErrInvalidImageId.ErrorCode, data.ErrInvalidImageId.Message, nil)
+	default:
+		reply(w, http.StatusOK, e.SUCCESS, "", image)
+	}
+}
+
+func reply(w http.ResponseWriter, code int, status, message string, detail interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"Status":  status,
+		"Message": message,
+		"Detail":  detail,
+	})
+}
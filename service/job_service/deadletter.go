@@ -0,0 +1,128 @@
+package job_service
+
+import (
+	"encoding/json"
+	"immortality-demo/pkg/data"
+	"immortality-demo/pkg/db_model"
+	"immortality-demo/pkg/envelope"
+	"immortality-demo/pkg/gredis"
+	"immortality-demo/pkg/logger"
+	"immortality-demo/service/model"
+	"immortality-demo/service/workerpool"
+)
+
+type DescribeDeadLettersHandler struct {
+}
+
+func (h *DescribeDeadLettersHandler) Handle(requestId string) ([]model.DeadLetter, error) {
+	items, err := gredis.DeadLetters(workerpool.JobQueue, 0, -1)
+	if err != nil {
+		logger.Log.Error1(requestId, "DeadLetters error:", err)
+		return nil, data.ErrServerInternalMQ
+	}
+
+	letters := make([]model.DeadLetter, 0, len(items))
+	for _, item := range items {
+		// entries of any version are listed, Decode would reject the unknown ones
+		var letter envelope.Envelope
+		if err := json.Unmarshal(item, &letter); err != nil {
+			logger.Log.Error1(requestId, "malformed dead letter:", string(item))
+			continue
+		}
+		letters = append(letters, model.DeadLetter{
+			RequestId: letter.RequestId,
+			Action:    letter.Action,
+			Attempts:  letter.Attempts,
+			Detail:    letter.Payload,
+		})
+	}
+	return letters, nil
+}
+
+type ReplayDeadLetterHandler struct {
+}
+
+// Handle puts the dead job back to the queue with its attempts reset
+func (h *ReplayDeadLetterHandler) Handle(jobRequestId, requestId string) (map[string]interface{}, error) {
+	item, letter, err := findDeadLetter(jobRequestId, requestId)
+	if err != nil {
+		return nil, err
+	}
+
+	letter.Attempts = 0
+	value, err := envelope.Encode(letter)
+	if err != nil {
+		logger.Log.Error1(requestId, "Encode dead letter error:", err)
+		return nil, data.ErrServerInternal
+	}
+	ok, err := gredis.Replay(workerpool.JobQueue, item, value)
+	if err != nil {
+		logger.Log.Error1(requestId, "Replay error:", err)
+		return nil, data.ErrServerInternalMQ
+	}
+	if !ok {
+		return nil, data.ErrInvalidRequestId
+	}
+	if err = db_model.MarkJobRetrying(data.Db, jobRequestId, ""); err != nil {
+		logger.Log.Error1(requestId, "MarkJobRetrying error:", err)
+	}
+	logger.Log.Infof(requestId, "dead job %s replayed", jobRequestId)
+
+	return map[string]interface{}{
+		"JobRequestId": jobRequestId,
+		"RequestId":    requestId,
+	}, nil
+}
+
+type PurgeDeadLettersHandler struct {
+}
+
+// Handle drops the dead job of jobRequestId, or every dead job if it is empty
+func (h *PurgeDeadLettersHandler) Handle(jobRequestId, requestId string) (map[string]interface{}, error) {
+	var purged int
+	if jobRequestId == "" {
+		n, err := gredis.PurgeDead(workerpool.JobQueue)
+		if err != nil {
+			logger.Log.Error1(requestId, "PurgeDead error:", err)
+			return nil, data.ErrServerInternalMQ
+		}
+		purged = n
+	} else {
+		item, _, err := findDeadLetter(jobRequestId, requestId)
+		if err != nil {
+			return nil, err
+		}
+		ok, err := gredis.RemoveDead(workerpool.JobQueue, item)
+		if err != nil {
+			logger.Log.Error1(requestId, "RemoveDead error:", err)
+			return nil, data.ErrServerInternalMQ
+		}
+		if ok {
+			purged = 1
+		}
+	}
+	logger.Log.Infof(requestId, "%d dead jobs purged", purged)
+
+	return map[string]interface{}{
+		"Purged":    purged,
+		"RequestId": requestId,
+	}, nil
+}
+
+func findDeadLetter(jobRequestId, requestId string) ([]byte, *envelope.Envelope, error) {
+	items, err := gredis.DeadLetters(workerpool.JobQueue, 0, -1)
+	if err != nil {
+		logger.Log.Error1(requestId, "DeadLetters error:", err)
+		return nil, nil, data.ErrServerInternalMQ
+	}
+	for _, item := range items {
+		var e envelope.Envelope
+		if err := json.Unmarshal(item, &e); err != nil {
+			continue
+		}
+		if e.RequestId == jobRequestId {
+			return item, &e, nil
+		}
+	}
+	return nil, nil, data.ErrInvalidRequestId
+}
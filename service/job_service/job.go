@@ -0,0 +1,87 @@
+package job_service
+
+import (
+	"database/sql"
+	"encoding/json"
+	"immortality-demo/pkg/data"
+	"immortality-demo/pkg/db_model"
+	"immortality-demo/pkg/logger"
+	"immortality-demo/service/model"
+	"time"
+)
+
+const (
+	defaultDescribeLimit = 20
+	maxDescribeLimit     = 100
+)
+
+type DescribeJobHandler struct {
+}
+
+func (h *DescribeJobHandler) Handle(jobRequestId, userId, requestId string) (*model.JobInfo, error) {
+	job, err := db_model.JobByRequestID(data.Db, jobRequestId)
+	if err == sql.ErrNoRows {
+		return nil, data.ErrInvalidRequestId
+	}
+	if err != nil {
+		logger.Log.Error1(requestId, "JobByRequestID error:", err)
+		return nil, data.ErrServerInternalDB
+	}
+	if job.UserID != userId {
+		return nil, data.ErrInvalidRequestId
+	}
+	info := jobInfo(job)
+	return &info, nil
+}
+
+type DescribeJobsHandler struct {
+}
+
+func (h *DescribeJobsHandler) Handle(param model.DescribeJobsParams, userId, requestId string) ([]model.JobInfo, error) {
+	var state int8
+	if param.State != "" {
+		var ok bool
+		state, ok = data.JobStateMap2[param.State]
+		if !ok {
+			return nil, data.ErrFieldJobStateWrongValue
+		}
+	}
+	limit := param.Limit
+	if limit == 0 {
+		limit = defaultDescribeLimit
+	}
+	if limit < 0 || limit > maxDescribeLimit {
+		return nil, data.ErrFieldPageSizeWrongValue
+	}
+
+	jobs, err := db_model.JobsByState(data.Db, userId, state, limit)
+	if err != nil {
+		logger.Log.Error1(requestId, "JobsByState error:", err)
+		return nil, data.ErrServerInternalDB
+	}
+	infos := make([]model.JobInfo, 0, len(jobs))
+	for _, job := range jobs {
+		infos = append(infos, jobInfo(job))
+	}
+	return infos, nil
+}
+
+func jobInfo(job *db_model.Job) model.JobInfo {
+	info := model.JobInfo{
+		RequestId: job.RequestID,
+		Action:    job.Action,
+		Payload:   json.RawMessage(job.Payload),
+		State:     data.JobStateMap[job.State],
+		Attempts:  job.Attempts,
+		LastError: job.LastError,
+		CreatedAt: job.CreatedAt.Format(time.RFC3339),
+		UpdatedAt: job.UpdatedAt.Format(time.RFC3339),
+	}
+	if job.StartedAt.Valid {
+		info.StartedAt = job.StartedAt.Time.Format(time.RFC3339)
+	}
+	if job.FinishedAt.Valid {
+		info.FinishedAt = job.FinishedAt.Time.Format(time.RFC3339)
+	}
+	return info
+}
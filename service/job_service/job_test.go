@@ -0,0 +1,102 @@
+package job_service
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"immortality-demo/config"
+	"immortality-demo/pkg/data"
+	"immortality-demo/pkg/db_model"
+	"immortality-demo/pkg/logger"
+	"immortality-demo/service/model"
+	"net/url"
+	"os"
+	"testing"
+	"time"
+
+	_ "github.com/go-sql-driver/mysql"
+)
+
+// TestDescribeJobsInvalid checks the parameters which are refused before the
+// database is needed
+func TestDescribeJobsInvalid(t *testing.T) {
+	cases := []struct {
+		param model.DescribeJobsParams
+		err   error
+	}{
+		{model.DescribeJobsParams{State: "Running"}, data.ErrFieldJobStateWrongValue},
+		{model.DescribeJobsParams{State: "done"}, data.ErrFieldJobStateWrongValue},
+		{model.DescribeJobsParams{Limit: -1}, data.ErrFieldPageSizeWrongValue},
+		{model.DescribeJobsParams{Limit: maxDescribeLimit + 1}, data.ErrFieldPageSizeWrongValue},
+	}
+	for _, c := range cases {
+		if _, err := (&DescribeJobsHandler{}).Handle(c.param, "user-1", "req-1"); err != c.err {
+			t.Errorf("%+v: expected %v, got %v", c.param, c.err, err)
+		}
+	}
+}
+
+// setupDB connects the database of the config, the test is skipped if it is
+// not reachable
+func setupDB(t *testing.T) {
+	config.LoadConfig()
+	logger.Log = logger.NewStdoutLogger(os.Stdout, "")
+	u, err := url.Parse(config.Config.DBPath)
+	if err != nil {
+		t.Skip("bad db path:", err)
+	}
+	db, err := sql.Open("mysql", fmt.Sprintf("%s@tcp(%s)%s?%s", u.User.String(), u.Host, u.Path, u.RawQuery))
+	if err == nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+		err = db.PingContext(ctx)
+		cancel()
+	}
+	if err != nil {
+		t.Skip("database is not available:", err)
+	}
+	data.Db = db
+}
+
+// TestDescribeJobs records a pending and a dead job of a new user, the jobs
+// are only visible to that user
+func TestDescribeJobs(t *testing.T) {
+	setupDB(t)
+
+	userId := fmt.Sprintf("user-job-%d", time.Now().UnixNano())
+	now := time.Now()
+	for i, state := range []int8{data.JobStatePending, data.JobStateDead} {
+		job := db_model.Job{
+			RequestID: fmt.Sprintf("%s-req-%d", userId, i),
+			UserID:    userId,
+			Action:    data.ActionCreateDisk,
+			Payload:   `{}`,
+			State:     state,
+			CreatedAt: now,
+			UpdatedAt: now,
+		}
+		if err := job.Save(data.Db); err != nil {
+			t.Fatal(err)
+		}
+	}
+	defer data.Db.Exec(`DELETE FROM job WHERE user_id = ?`, userId)
+
+	info, err := (&DescribeJobHandler{}).Handle(userId+"-req-1", userId, "req-1")
+	if err != nil || info.State != "dead" || info.Action != data.ActionCreateDisk {
+		t.Errorf("expected a dead CreateDisk job, got %+v, %v", info, err)
+	}
+	if _, err = (&DescribeJobHandler{}).Handle(userId+"-req-1", "user-other", "req-1"); err != data.ErrInvalidRequestId {
+		t.Errorf("describe the job of another user: %v", err)
+	}
+	if _, err = (&DescribeJobHandler{}).Handle(userId+"-req-2", userId, "req-1"); err != data.ErrInvalidRequestId {
+		t.Errorf("describe an unknown job: %v", err)
+	}
+
+	infos, err := (&DescribeJobsHandler{}).Handle(model.DescribeJobsParams{}, userId, "req-1")
+	if err != nil || len(infos) != 2 || infos[0].RequestId != userId+"-req-1" {
+		t.Errorf("expected both jobs, the latest first, got %+v, %v", infos, err)
+	}
+	infos, err = (&DescribeJobsHandler{}).Handle(model.DescribeJobsParams{State: "pending", Limit: 1}, userId, "req-1")
+	if err != nil || len(infos) != 1 || infos[0].RequestId != userId+"-req-0" {
+		t.Errorf("expected the pending job, got %+v, %v", infos, err)
+	}
+}
@@ -0,0 +1,40 @@
+package model
+
+// ArrayInfo is the capacity of a 3PAR array or ceph cluster, sizes are in GiB
+type ArrayInfo struct {
+	ThreeParId         string  `json:"ThreeParId"`
+	PodId              string  `json:"PodId"`
+	StorageType        string  `json:"StorageType"`
+	Category           string  `json:"Category"`
+	HDDUsage           float64 `json:"HDDUsage"`
+	HDDTotal           float64 `json:"HDDTotal"`
+	HDDUsagePercent    float64 `json:"HDDUsagePercent"`
+	SSDUsage           float64 `json:"SSDUsage"`
+	SSDTotal           float64 `json:"SSDTotal"`
+	SSDUsagePercent    float64 `json:"SSDUsagePercent"`
+	HybridUsagePercent float64 `json:"HybridUsagePercent"`
+	Reserve            int     `json:"Reserve"`
+	// Stale is true when the last poll failed, PolledAt is the last successful one
+	Stale    bool   `json:"Stale"`
+	PolledAt string `json:"PolledAt"`
+}
+
+// RegisterArrayParams describes a 3PAR array, LaunchTime is formatted as
+// 2006-01-02 15:04:05. Https reaches the WSAPI over https, its certificate is
+// verified against CaBundle (PEM), the pinned CertSha256 fingerprint or the
+// system roots, unless Insecure is set.
+type RegisterArrayParams struct {
+	ThreeParId         string `json:"ThreeParId"`
+	Ipv4AddrManagement string `json:"Ipv4AddrManagement"`
+	Ipv4AddrSSH        string `json:"Ipv4AddrSSH"`
+	Ipv4AddrController string `json:"Ipv4AddrController"`
+	Username           string `json:"Username"`
+	Password           string `json:"Password"`
+	LaunchTime         string `json:"LaunchTime"`
+	InCharge           string `json:"InCharge"`
+	Contact            string `json:"Contact"`
+	Https              bool   `json:"Https"`
+	CaBundle           string `json:"CaBundle"`
+	CertSha256         string `json:"CertSha256"`
+	Insecure           bool   `json:"Insecure"`
+}
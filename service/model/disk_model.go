@@ -16,4 +16,84 @@ type CreateDiskParams struct {
 	IsShare      string `json:"IsShare"`
 	Qos          string `json:"Qos"`
 	PodId        string `json:"PodId"`
-}
\ No newline at end of file
+}
+type ResizeDiskParams struct {
+	NewSize int64 `json:"NewSize"`
+}
+
+type ResetDiskParams struct {
+	SnapshotId string `json:"SnapshotId"`
+}
+
+type CreateSnapshotParams struct {
+	SnapshotId   string `json:"SnapshotId"`
+	SnapshotName string `json:"SnapshotName"`
+	Description  string `json:"Description"`
+	// ExpireAt is when the snapshot is deleted, in RFC3339, never if empty
+	ExpireAt string `json:"ExpireAt"`
+	// SnapshotType is set by the snapshot strategies, the API takes manual ones
+	SnapshotType int `json:"-"`
+}
+
+// SnapshotExpiryParams sets when the snapshot is deleted, in RFC3339. An
+// empty ExpireAt keeps the snapshot until it is deleted by hand.
+type SnapshotExpiryParams struct {
+	ExpireAt string `json:"ExpireAt"`
+}
+
+type ExportDiskParams struct {
+	CVKName string `json:"CVKName"`
+	Iqn     string `json:"Iqn"`
+}
+
+type DescribeDisksParams struct {
+	Status       string `form:"Status" json:"Status"`
+	DiskCategory string `form:"DiskCategory" json:"DiskCategory"`
+	StorageType  string `form:"StorageType" json:"StorageType"`
+	ClusterId    string `form:"ClusterId" json:"ClusterId"`
+	Marker       string `form:"Marker" json:"Marker"`
+	Limit        int    `form:"Limit" json:"Limit"`
+}
+
+type AttachInfo struct {
+	InstanceId   string `json:"InstanceId"`
+	CvkName      string `json:"CvkName"`
+	AttachStatus int    `json:"AttachStatus"`
+}
+
+type ExportInfo struct {
+	CvkName string `json:"CvkName"`
+	Iqn     string `json:"Iqn"`
+	Lun     int    `json:"Lun"`
+	// Target is how the CVK reaches a disk without a WWN, the rbd map
+	// descriptor of a ceph disk
+	Target string `json:"Target,omitempty"`
+	Status string `json:"Status"`
+}
+
+type DiskInfo struct {
+	DiskId       string       `json:"DiskId"`
+	DiskName     string       `json:"DiskName"`
+	Status       string       `json:"Status"`
+	DiskType     string       `json:"DiskType"`
+	DiskCategory string       `json:"DiskCategory"`
+	Size         int64        `json:"Size"`
+	RegionId     string       `json:"RegionId"`
+	ZoneId       string       `json:"ZoneId"`
+	Description  string       `json:"Description"`
+	SnapshotId   string       `json:"SnapshotId"`
+	ImageId      string       `json:"ImageId"`
+	StorageType  string       `json:"StorageType"`
+	ClusterId    string       `json:"ClusterId"`
+	IsShare      string       `json:"IsShare"`
+	Qos          string       `json:"Qos"`
+	CreatedAt    string       `json:"CreatedAt"`
+	Attach       *AttachInfo  `json:"Attach"`
+	Exports      []ExportInfo `json:"Exports"`
+}
+
+type DescribeDisksResult struct {
+	Disks      []DiskInfo `json:"Disks"`
+	NextMarker string     `json:"NextMarker"`
+	RequestId  string     `json:"RequestId"`
+}
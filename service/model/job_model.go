@@ -0,0 +1,28 @@
+package model
+
+import "encoding/json"
+
+type DescribeJobsParams struct {
+	State string `form:"state" json:"state"`
+	Limit int    `form:"limit" json:"limit"`
+}
+
+type JobInfo struct {
+	RequestId  string          `json:"RequestId"`
+	Action     string          `json:"Action"`
+	Payload    json.RawMessage `json:"Payload"`
+	State      string          `json:"State"`
+	Attempts   int             `json:"Attempts"`
+	LastError  string          `json:"LastError"`
+	CreatedAt  string          `json:"CreatedAt"`
+	UpdatedAt  string          `json:"UpdatedAt"`
+	StartedAt  string          `json:"StartedAt"`
+	FinishedAt string          `json:"FinishedAt"`
+}
+
+type DeadLetter struct {
+	RequestId string          `json:"RequestId"`
+	Action    string          `json:"Action"`
+	Attempts  int             `json:"Attempts"`
+	Detail    json.RawMessage `json:"Detail"`
+}
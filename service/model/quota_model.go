@@ -0,0 +1,43 @@
+package model
+
+// DescribeQuotasParams selects the subject, SubjectType is Project, User,
+// VmInstance or Disk
+type DescribeQuotasParams struct {
+	SubjectType string `form:"SubjectType" json:"SubjectType"`
+	SubjectId   string `form:"SubjectId" json:"SubjectId"`
+}
+
+// SetQuotaParams overrides the limit of ObjectType (Disk, Snapshot, Image or
+// TotalSize in GB) for the subject
+type SetQuotaParams struct {
+	SubjectType string `json:"SubjectType"`
+	SubjectId   string `json:"SubjectId"`
+	ObjectType  string `json:"ObjectType"`
+	Allow       int64  `json:"Allow"`
+}
+
+// ResetQuotaParams drops the override of ObjectType, the default applies again
+type ResetQuotaParams struct {
+	SubjectType string `form:"SubjectType" json:"SubjectType"`
+	SubjectId   string `form:"SubjectId" json:"SubjectId"`
+	ObjectType  string `form:"ObjectType" json:"ObjectType"`
+}
+
+type QuotaInfo struct {
+	ObjectType string `json:"ObjectType"`
+	// Allow is the effective limit, the override if Overridden else Default
+	Allow      int64 `json:"Allow"`
+	Default    int64 `json:"Default"`
+	Overridden bool  `json:"Overridden"`
+	Usage      int64 `json:"Usage"`
+	// Enforced is false for the quotas only described, image and VmInstance
+	// quotas are checked by the services creating images and attaching disks
+	Enforced bool `json:"Enforced"`
+}
+
+type DescribeQuotasResult struct {
+	SubjectType string      `json:"SubjectType"`
+	SubjectId   string      `json:"SubjectId"`
+	Quotas      []QuotaInfo `json:"Quotas"`
+	RequestId   string      `json:"RequestId"`
+}
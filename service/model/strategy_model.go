@@ -0,0 +1,55 @@
+package model
+
+// CreateStrategyParams describes a snapshot strategy. Hours (0-23) and Weeks
+// (1 is Monday, 7 is Sunday) are comma separated lists, Duration is how many
+// days the automatic snapshots are kept (-1 for ever) and SnapshotQuota how
+// many of them a disk keeps at most. Status is Enabled or Disabled.
+type CreateStrategyParams struct {
+	StrategyId    string `json:"StrategyId"`
+	StrategyName  string `json:"StrategyName"`
+	Hours         string `json:"Hours"`
+	Weeks         string `json:"Weeks"`
+	Duration      int32  `json:"Duration"`
+	SnapshotQuota int32  `json:"SnapshotQuota"`
+	Status        string `json:"Status"`
+}
+
+// UpdateStrategyParams changes the fields which are set
+type UpdateStrategyParams struct {
+	StrategyName  string `json:"StrategyName"`
+	Hours         string `json:"Hours"`
+	Weeks         string `json:"Weeks"`
+	Duration      int32  `json:"Duration"`
+	SnapshotQuota int32  `json:"SnapshotQuota"`
+	Status        string `json:"Status"`
+}
+
+type DescribeStrategiesParams struct {
+	StrategyId string `form:"StrategyId" json:"StrategyId"`
+	DiskId     string `form:"DiskId" json:"DiskId"`
+}
+
+type BindStrategyParams struct {
+	DiskIds []string `json:"DiskIds"`
+}
+
+type StrategyInfo struct {
+	StrategyId    string `json:"StrategyId"`
+	StrategyName  string `json:"StrategyName"`
+	Hours         string `json:"Hours"`
+	Weeks         string `json:"Weeks"`
+	Duration      int32  `json:"Duration"`
+	SnapshotQuota int32  `json:"SnapshotQuota"`
+	Status        string `json:"Status"`
+	// DiskCount is the number of disks bound, DiskIds lists them when a
+	// single strategy is described
+	DiskCount int      `json:"DiskCount"`
+	DiskIds   []string `json:"DiskIds,omitempty"`
+	CreatedAt string   `json:"CreatedAt"`
+	UpdatedAt string   `json:"UpdatedAt"`
+}
+
+type DescribeStrategiesResult struct {
+	Strategies []StrategyInfo `json:"Strategies"`
+	RequestId  string         `json:"RequestId"`
+}
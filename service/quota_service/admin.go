@@ -0,0 +1,145 @@
+package quota_service
+
+import (
+	"database/sql"
+	"immortality-demo/pkg/data"
+	"immortality-demo/pkg/db_model"
+	"immortality-demo/pkg/logger"
+	"immortality-demo/service/model"
+)
+
+// objectTypes orders the quotas described
+var objectTypes = []int8{data.QuotaTypeDisk, data.QuotaTypeTotalSize, data.QuotaTypeSnapshot, data.QuotaTypeImage}
+
+type DescribeQuotasHandler struct {
+}
+
+// Handle lists the quotas of the subject with their effective limit and usage
+func (h *DescribeQuotasHandler) Handle(param model.DescribeQuotasParams, requestId string) (result *model.DescribeQuotasResult, err error) {
+	subjectType, err := verifySubject(param.SubjectType, param.SubjectId)
+	if err != nil {
+		return nil, err
+	}
+
+	result = &model.DescribeQuotasResult{
+		SubjectType: param.SubjectType,
+		SubjectId:   param.SubjectId,
+		Quotas:      []model.QuotaInfo{},
+		RequestId:   requestId,
+	}
+	for _, objectType := range objectTypes {
+		if _, ok := Default(subjectType, objectType); !ok {
+			continue
+		}
+		info, err := quotaInfo(requestId, subjectType, param.SubjectId, objectType)
+		if err != nil {
+			return nil, err
+		}
+		result.Quotas = append(result.Quotas, *info)
+	}
+	return result, nil
+}
+
+type SetQuotaHandler struct {
+}
+
+// Handle overrides the default limit of an object type for the subject
+func (h *SetQuotaHandler) Handle(param model.SetQuotaParams, requestId string) (result *model.QuotaInfo, err error) {
+	subjectType, objectType, err := verifyQuota(param.SubjectType, param.SubjectId, param.ObjectType)
+	if err != nil {
+		return nil, err
+	}
+	if param.Allow < 0 {
+		return nil, data.ErrFieldAllowWrongValue
+	}
+
+	quota, err := db_model.QuotaBySubjectIDObjectType(data.Db, param.SubjectId, objectType)
+	if err == sql.ErrNoRows {
+		quota, err = &db_model.Quota{SubjectID: param.SubjectId, ObjectType: objectType}, nil
+	}
+	if err != nil {
+		logger.Log.Error1(requestId, "QuotaBySubjectIDObjectType error:", err)
+		return nil, data.ErrServerInternalDB
+	}
+	quota.SubjectType = subjectType
+	quota.Allow = param.Allow
+	if err = quota.Save(data.Db); err != nil {
+		logger.Log.Error1(requestId, "Save quota error:", err)
+		return nil, data.ErrServerInternalDB
+	}
+	logger.Log.Infof(requestId, "%s quota of %s %s set to %d", param.ObjectType, param.SubjectType, param.SubjectId, param.Allow)
+
+	return quotaInfo(requestId, subjectType, param.SubjectId, objectType)
+}
+
+type ResetQuotaHandler struct {
+}
+
+// Handle drops the override of an object type, the subject gets the default
+// limit back
+func (h *ResetQuotaHandler) Handle(param model.ResetQuotaParams, requestId string) (result *model.QuotaInfo, err error) {
+	subjectType, objectType, err := verifyQuota(param.SubjectType, param.SubjectId, param.ObjectType)
+	if err != nil {
+		return nil, err
+	}
+
+	quota, err := db_model.QuotaBySubjectIDObjectType(data.Db, param.SubjectId, objectType)
+	if err != nil && err != sql.ErrNoRows {
+		logger.Log.Error1(requestId, "QuotaBySubjectIDObjectType error:", err)
+		return nil, data.ErrServerInternalDB
+	}
+	if err == nil {
+		if err = quota.Delete(data.Db); err != nil {
+			logger.Log.Error1(requestId, "Delete quota error:", err)
+			return nil, data.ErrServerInternalDB
+		}
+		logger.Log.Infof(requestId, "%s quota of %s %s reset", param.ObjectType, param.SubjectType, param.SubjectId)
+	}
+
+	return quotaInfo(requestId, subjectType, param.SubjectId, objectType)
+}
+
+func verifySubject(subjectType, subjectId string) (int8, error) {
+	if subjectId == "" {
+		return 0, data.ErrLackOfRequiredFieldSubjectId
+	}
+	t, ok := data.QuotaTypeMap2[subjectType]
+	if _, hasQuotas := defaults[t]; !ok || !hasQuotas {
+		return 0, data.ErrInvalidQuotaSubjectType
+	}
+	return t, nil
+}
+
+func verifyQuota(subjectType, subjectId, objectType string) (int8, int8, error) {
+	s, err := verifySubject(subjectType, subjectId)
+	if err != nil {
+		return 0, 0, err
+	}
+	o, ok := data.QuotaTypeMap2[objectType]
+	if _, hasDefault := Default(s, o); !ok || !hasDefault {
+		return 0, 0, data.ErrInvalidQuotaObjectType
+	}
+	return s, o, nil
+}
+
+func quotaInfo(requestId string, subjectType int8, subjectId string, objectType int8) (*model.QuotaInfo, error) {
+	info := &model.QuotaInfo{ObjectType: data.QuotaTypeMap[objectType]}
+	info.Default, _ = Default(subjectType, objectType)
+	info.Allow = info.Default
+	info.Enforced = Enforced(subjectType, objectType)
+
+	quota, err := db_model.QuotaBySubjectIDObjectType(data.Db, subjectId, objectType)
+	if err != nil && err != sql.ErrNoRows {
+		logger.Log.Error1(requestId, "QuotaBySubjectIDObjectType error:", err)
+		return nil, data.ErrServerInternalDB
+	}
+	if err == nil {
+		info.Allow, info.Overridden = quota.Allow, true
+	}
+
+	if info.Usage, err = Usage(data.Db, subjectType, subjectId, objectType); err != nil {
+		logger.Log.Error1(requestId, "quota Usage error:", err)
+		return nil, data.ErrServerInternalDB
+	}
+	return info, nil
+}
@@ -0,0 +1,155 @@
+package quota_service
+
+import (
+	"database/sql"
+	"immortality-demo/pkg/data"
+	"immortality-demo/pkg/db_model"
+	"immortality-demo/pkg/logger"
+)
+
+// projectDefaults apply to a user as well, a user is the project owning its
+// disks and images
+var projectDefaults = map[int8]int64{
+	data.QuotaTypeDisk:      data.ProjectDiskQuota,
+	data.QuotaTypeTotalSize: data.ProjectDiskTotalSizeQuota,
+	data.QuotaTypeImage:     data.ProjectImageQuota,
+}
+
+// defaults are the limits of the subjects without an override in the quota
+// table, by subject type and then object type
+var defaults = map[int8]map[int8]int64{
+	data.QuotaTypeProject: projectDefaults,
+	data.QuotaTypeUser:    projectDefaults,
+	data.QuotaTypeVmInstance: {
+		data.QuotaTypeDisk: data.VmInstanceDataDiskQuota,
+	},
+	data.QuotaTypeDisk: {
+		data.QuotaTypeSnapshot: data.DiskSnapshotQuota,
+	},
+}
+
+// enforced are the quotas this service checks, by subject type and then
+// object type. Images are created and disks attached by other services, their
+// quotas are only described here.
+var enforced = map[int8]map[int8]bool{
+	data.QuotaTypeProject: {data.QuotaTypeDisk: true, data.QuotaTypeTotalSize: true},
+	data.QuotaTypeUser:    {data.QuotaTypeDisk: true, data.QuotaTypeTotalSize: true},
+	data.QuotaTypeDisk:    {data.QuotaTypeSnapshot: true},
+}
+
+// Enforced tells whether this service rejects the requests exceeding the quota
+func Enforced(subjectType, objectType int8) bool {
+	return enforced[subjectType][objectType]
+}
+
+// Default returns the limit of objectType for the subjects of subjectType
+// without an override, ok is false when no such quota exists
+func Default(subjectType, objectType int8) (allow int64, ok bool) {
+	allow, ok = defaults[subjectType][objectType]
+	return
+}
+
+// Limit returns the effective limit of objectType for the subject: its
+// override in the quota table, else the default
+func Limit(db db_model.XODB, subjectType int8, subjectId string, objectType int8) (allow int64, err error) {
+	quota, err := db_model.QuotaBySubjectIDObjectType(db, subjectId, objectType)
+	if err == nil {
+		return quota.Allow, nil
+	}
+	if err != sql.ErrNoRows {
+		return 0, err
+	}
+	allow, _ = Default(subjectType, objectType)
+	return allow, nil
+}
+
+// LockDisks locks the disks of the user until tx ends, so that the quota
+// checks of the user run one after the other. It must come before any other
+// disk lock of tx.
+func LockDisks(tx *sql.Tx, requestId, userId string) error {
+	_, _, err := lockDisks(tx, requestId, userId)
+	return err
+}
+
+func lockDisks(tx *sql.Tx, requestId, userId string) (count, size int64, err error) {
+	count, size, err = db_model.DiskUsageByUserIdForUpdate(tx, userId)
+	if err != nil {
+		logger.Log.Error1(requestId, "DiskUsageByUserIdForUpdate error:", err)
+		return 0, 0, data.ErrServerInternalDB
+	}
+	return count, size, nil
+}
+
+// CheckCreateDisk checks the user may own count more disks of size GB each.
+// tx must be the one saving the disks and hold no other disk lock, the disks
+// of the user stay locked until it ends so that concurrent creations are
+// counted one after the other.
+func CheckCreateDisk(tx *sql.Tx, requestId, userId string, count, size int64) error {
+	n, total, err := lockDisks(tx, requestId, userId)
+	if err != nil {
+		return err
+	}
+	if err = checkLimit(tx, requestId, userId, data.QuotaTypeDisk, n+count, data.ErrDiskQuotaExceeded); err != nil {
+		return err
+	}
+	return checkLimit(tx, requestId, userId, data.QuotaTypeTotalSize, total>>30+count*size, data.ErrDiskTotalSizeQuotaExceeded)
+}
+
+// CheckResizeDisk checks the disks of the user may grow by grow bytes, tx must
+// be the one resizing the disk and have called LockDisks before locking it
+func CheckResizeDisk(tx *sql.Tx, requestId, userId string, grow int64) error {
+	_, total, err := lockDisks(tx, requestId, userId)
+	if err != nil {
+		return err
+	}
+	return checkLimit(tx, requestId, userId, data.QuotaTypeTotalSize, (total+grow)>>30, data.ErrDiskTotalSizeQuotaExceeded)
+}
+
+// CheckCreateSnapshot checks the disk may have one more snapshot, tx must
+// hold the lock of the disk
+func CheckCreateSnapshot(tx *sql.Tx, requestId, diskId string) error {
+	n, err := db_model.SnapshotCountByDiskId(tx, diskId)
+	if err != nil {
+		logger.Log.Error1(requestId, "SnapshotCountByDiskId error:", err)
+		return data.ErrServerInternalDB
+	}
+	return checkLimitOf(tx, requestId, data.QuotaTypeDisk, diskId, data.QuotaTypeSnapshot, n+1, data.ErrSnapshotQuotaExceeded)
+}
+
+// checkLimit checks the usage of objectType by the project of the user
+func checkLimit(tx *sql.Tx, requestId, userId string, objectType int8, usage int64, exceeded error) error {
+	return checkLimitOf(tx, requestId, data.QuotaTypeProject, userId, objectType, usage, exceeded)
+}
+
+func checkLimitOf(tx *sql.Tx, requestId string, subjectType int8, subjectId string, objectType int8, usage int64, exceeded error) error {
+	allow, err := Limit(tx, subjectType, subjectId, objectType)
+	if err != nil {
+		logger.Log.Error1(requestId, "QuotaBySubjectIDObjectType error:", err)
+		return data.ErrServerInternalDB
+	}
+	if usage > allow {
+		logger.Log.Warnf(requestId, "%s quota of %s %s exceeded: %d > %d",
+			data.QuotaTypeMap[objectType], data.QuotaTypeMap[subjectType], subjectId, usage, allow)
+		return exceeded
+	}
+	return nil
+}
+
+// Usage returns how much of objectType the subject uses, in GB for
+// QuotaTypeTotalSize
+func Usage(db db_model.XODB, subjectType int8, subjectId string, objectType int8) (usage int64, err error) {
+	switch {
+	case subjectType == data.QuotaTypeVmInstance:
+		return db_model.AttachCountByInstanceId(db, subjectId)
+	case subjectType == data.QuotaTypeDisk:
+		return db_model.SnapshotCountByDiskId(db, subjectId)
+	case objectType == data.QuotaTypeImage:
+		return db_model.ImageCountByUserId(db, subjectId)
+	}
+
+	count, size, err := db_model.DiskUsageByUserId(db, subjectId)
+	if objectType == data.QuotaTypeTotalSize {
+		return size >> 30, err
+	}
+	return count, err
+}
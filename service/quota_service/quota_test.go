@@ -0,0 +1,160 @@
+package quota_service
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"immortality-demo/config"
+	"immortality-demo/pkg/data"
+	"immortality-demo/pkg/db_model"
+	"immortality-demo/pkg/logger"
+	"net/url"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	_ "github.com/go-sql-driver/mysql"
+)
+
+func TestVerifyQuota(t *testing.T) {
+	valid := [][2]string{
+		{"Project", "Disk"},
+		{"User", "TotalSize"},
+		{"User", "Image"},
+		{"VmInstance", "Disk"},
+		{"Disk", "Snapshot"},
+	}
+	for _, c := range valid {
+		if _, _, err := verifyQuota(c[0], "subject", c[1]); err != nil {
+			t.Errorf("verifyQuota(%q, %q): %v", c[0], c[1], err)
+		}
+	}
+
+	invalid := [][3]string{
+		{"Project", "", "Disk"},
+		{"Image", "subject", "Disk"},
+		{"project", "subject", "Disk"},
+		{"Disk", "subject", "Disk"},
+		{"VmInstance", "subject", "Snapshot"},
+		{"Project", "subject", "Snapshot"},
+	}
+	for _, c := range invalid {
+		if _, _, err := verifyQuota(c[0], c[1], c[2]); err == nil {
+			t.Errorf("verifyQuota(%q, %q, %q) should fail", c[0], c[1], c[2])
+		}
+	}
+}
+
+func TestDefault(t *testing.T) {
+	cases := []struct {
+		subjectType, objectType int8
+		allow                   int64
+	}{
+		{data.QuotaTypeProject, data.QuotaTypeDisk, data.ProjectDiskQuota},
+		{data.QuotaTypeUser, data.QuotaTypeTotalSize, data.ProjectDiskTotalSizeQuota},
+		{data.QuotaTypeUser, data.QuotaTypeImage, data.ProjectImageQuota},
+		{data.QuotaTypeVmInstance, data.QuotaTypeDisk, data.VmInstanceDataDiskQuota},
+		{data.QuotaTypeDisk, data.QuotaTypeSnapshot, data.DiskSnapshotQuota},
+	}
+	for _, c := range cases {
+		if allow, ok := Default(c.subjectType, c.objectType); !ok || allow != c.allow {
+			t.Errorf("Default(%d, %d) = %d, %v, want %d", c.subjectType, c.objectType, allow, ok, c.allow)
+		}
+	}
+}
+
+func TestEnforced(t *testing.T) {
+	if !Enforced(data.QuotaTypeUser, data.QuotaTypeTotalSize) || !Enforced(data.QuotaTypeDisk, data.QuotaTypeSnapshot) {
+		t.Error("disk quotas should be enforced")
+	}
+	if Enforced(data.QuotaTypeProject, data.QuotaTypeImage) || Enforced(data.QuotaTypeVmInstance, data.QuotaTypeDisk) {
+		t.Error("image and VmInstance quotas are not enforced")
+	}
+}
+
+var (
+	dbOnce sync.Once
+	dbErr  error
+)
+
+// setupDB connects the database of the config once, the test is skipped if
+// it is not reachable
+func setupDB(t *testing.T) {
+	dbOnce.Do(func() {
+		config.LoadConfig()
+		logger.Log = logger.NewStdoutLogger(os.Stdout, "")
+		u, err := url.Parse(config.Config.DBPath)
+		if err != nil {
+			dbErr = err
+			return
+		}
+		db, err := sql.Open("mysql", fmt.Sprintf("%s@tcp(%s)%s?%s", u.User.String(), u.Host, u.Path, u.RawQuery))
+		if err == nil {
+			ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+			err = db.PingContext(ctx)
+			cancel()
+		}
+		dbErr = err
+		data.Db = db
+	})
+	if dbErr != nil {
+		t.Skip("database is not available:", dbErr)
+	}
+}
+
+// withOverrides runs check in a transaction holding a 10GB disk of a new user
+// and the given overrides, the transaction is rolled back afterwards
+func withOverrides(t *testing.T, overrides map[int8]int64, check func(tx *sql.Tx, userId string)) {
+	tx, err := data.Db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+
+	userId := fmt.Sprintf("user-quota-%d", time.Now().UnixNano())
+	now := time.Now()
+	disk := db_model.Disk{DiskID: userId + "-disk", UserID: userId, Size: 10 << 30, CreatedAt: now, UpdatedAt: now}
+	if err = disk.Insert(tx); err != nil {
+		t.Fatal(err)
+	}
+	for objectType, allow := range overrides {
+		q := db_model.Quota{SubjectID: userId, SubjectType: data.QuotaTypeProject, ObjectType: objectType, Allow: allow}
+		if err = q.Insert(tx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	check(tx, userId)
+}
+
+func TestCheckCreateDisk(t *testing.T) {
+	setupDB(t)
+
+	withOverrides(t, map[int8]int64{data.QuotaTypeDisk: 2, data.QuotaTypeTotalSize: 20}, func(tx *sql.Tx, userId string) {
+		if err := CheckCreateDisk(tx, "test", userId, 1, 10); err != nil {
+			t.Errorf("a second 10GB disk is within the overrides: %v", err)
+		}
+		if err := CheckCreateDisk(tx, "test", userId, 2, 1); err != data.ErrDiskQuotaExceeded {
+			t.Errorf("expected ErrDiskQuotaExceeded, got %v", err)
+		}
+		if err := CheckCreateDisk(tx, "test", userId, 1, 11); err != data.ErrDiskTotalSizeQuotaExceeded {
+			t.Errorf("expected ErrDiskTotalSizeQuotaExceeded, got %v", err)
+		}
+	})
+}
+
+func TestCheckResizeDisk(t *testing.T) {
+	setupDB(t)
+
+	withOverrides(t, map[int8]int64{data.QuotaTypeTotalSize: 12}, func(tx *sql.Tx, userId string) {
+		if err := LockDisks(tx, "test", userId); err != nil {
+			t.Fatal(err)
+		}
+		if err := CheckResizeDisk(tx, "test", userId, 2<<30); err != nil {
+			t.Errorf("growing to 12GB is within the override: %v", err)
+		}
+		if err := CheckResizeDisk(tx, "test", userId, 3<<30); err != data.ErrDiskTotalSizeQuotaExceeded {
+			t.Errorf("expected ErrDiskTotalSizeQuotaExceeded, got %v", err)
+		}
+	})
+}
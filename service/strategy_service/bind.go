@@ -0,0 +1,147 @@
+package strategy_service
+
+import (
+	"database/sql"
+	"immortality-demo/pkg/data"
+	"immortality-demo/pkg/db_model"
+	"immortality-demo/pkg/logger"
+	"immortality-demo/service/model"
+	"time"
+)
+
+type BindStrategyHandler struct {
+}
+
+// Handle binds the disks of the user to the strategy, a disk is bound to one
+// strategy at most and a strategy to StrategyDiskQuota disks
+func (h *BindStrategyHandler) Handle(strategyId string, param model.BindStrategyParams, userId, requestId string) (result map[string]interface{}, err error) {
+	diskIds := uniqueIds(param.DiskIds)
+	if len(diskIds) == 0 {
+		return nil, data.ErrLackOfRequiredFieldDiskIds
+	}
+
+	var count int
+	err = withStrategy(requestId, strategyId, userId, func(tx *sql.Tx, s *db_model.Strategy) error {
+		var binds []*db_model.StrategyBind
+		for _, diskId := range diskIds {
+			disk, err := db_model.DiskByDiskID(tx, diskId)
+			if err == sql.ErrNoRows {
+				return data.ErrInvalidDiskId
+			}
+			if err != nil {
+				logger.Log.Error1(requestId, "DiskByDiskID error:", err)
+				return data.ErrServerInternalDB
+			}
+			if disk.UserID != userId {
+				return data.ErrInvalidDiskId
+			}
+
+			bind, err := db_model.BindByDiskId(tx, diskId)
+			if err == nil && bind.StrategyId == s.StrategyId {
+				continue
+			}
+			if err == nil {
+				return data.ErrExistBindOfDisk
+			}
+			if err != sql.ErrNoRows {
+				logger.Log.Error1(requestId, "BindByDiskId error:", err)
+				return data.ErrServerInternalDB
+			}
+			binds = append(binds, &db_model.StrategyBind{
+				DiskId:            diskId,
+				StrategyId:        s.StrategyId,
+				AutoSnapshotQuota: int(s.DiskQuota),
+				BindTime:          time.Now(),
+			})
+		}
+
+		n, err := db_model.GetDiskCountByStrategyId(tx, s.StrategyId)
+		if err != nil {
+			logger.Log.Error1(requestId, "GetDiskCountByStrategyId error:", err)
+			return data.ErrServerInternalDB
+		}
+		if n+len(binds) > data.StrategyDiskQuota {
+			return data.ErrOutOfStrategyDiskQuota
+		}
+		for _, bind := range binds {
+			if err = bind.Insert(tx); err != nil {
+				logger.Log.Error1(requestId, "Insert bind error:", err)
+				return data.ErrServerInternalDB
+			}
+		}
+		count = n + len(binds)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	logger.Log.Infof(requestId, "disks %v bound to strategy %s", diskIds, strategyId)
+
+	return bindResult(strategyId, count, requestId), nil
+}
+
+type UnbindStrategyHandler struct {
+}
+
+func (h *UnbindStrategyHandler) Handle(strategyId string, param model.BindStrategyParams, userId, requestId string) (result map[string]interface{}, err error) {
+	diskIds := uniqueIds(param.DiskIds)
+	if len(diskIds) == 0 {
+		return nil, data.ErrLackOfRequiredFieldDiskIds
+	}
+
+	var count int
+	err = withStrategy(requestId, strategyId, userId, func(tx *sql.Tx, s *db_model.Strategy) error {
+		n, err := db_model.GetDiskCountByStrategyId(tx, s.StrategyId)
+		if err != nil {
+			logger.Log.Error1(requestId, "GetDiskCountByStrategyId error:", err)
+			return data.ErrServerInternalDB
+		}
+		if n == 0 {
+			return data.ErrInExistBindOfStrategy
+		}
+
+		for _, diskId := range diskIds {
+			bind, err := db_model.BindByDiskId(tx, diskId)
+			if err == sql.ErrNoRows || (err == nil && bind.StrategyId != s.StrategyId) {
+				return data.ErrInExistBindOfDisk
+			}
+			if err != nil {
+				logger.Log.Error1(requestId, "BindByDiskId error:", err)
+				return data.ErrServerInternalDB
+			}
+			if err = bind.Delete(tx); err != nil {
+				logger.Log.Error1(requestId, "Delete bind error:", err)
+				return data.ErrServerInternalDB
+			}
+		}
+		count = n - len(diskIds)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	logger.Log.Infof(requestId, "disks %v unbound from strategy %s", diskIds, strategyId)
+
+	return bindResult(strategyId, count, requestId), nil
+}
+
+func uniqueIds(ids []string) []string {
+	seen := make(map[string]bool, len(ids))
+	res := make([]string, 0, len(ids))
+	for _, id := range ids {
+		if id == "" || seen[id] {
+			continue
+		}
+		seen[id] = true
+		res = append(res, id)
+	}
+	return res
+}
+
+func bindResult(strategyId string, diskCount int, requestId string) map[string]interface{} {
+	return map[string]interface{}{
+		"StrategyId": strategyId,
+		"DiskCount":  diskCount,
+		"RequestId":  requestId,
+	}
+}
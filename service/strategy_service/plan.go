@@ -0,0 +1,72 @@
+package strategy_service
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Plan is when a strategy takes snapshots: Hours lists the hours of the day
+// (0-23) and Weeks the days of the week (1 is Monday, 7 is Sunday), either
+// comma separated as "0,12" or as a JSON list such as ["0","12"].
+type Plan struct {
+	hours    [24]bool
+	weekdays [7]bool // indexed by time.Weekday
+}
+
+// maxCatchUp is how far back a missed window is still made up for
+const maxCatchUp = 7 * 24 * time.Hour
+
+func ParsePlan(hours, weeks string) (*Plan, error) {
+	p := &Plan{}
+	hs, err := parseList(hours, 0, 23)
+	if err != nil {
+		return nil, fmt.Errorf("hours: %v", err)
+	}
+	for _, h := range hs {
+		p.hours[h] = true
+	}
+	ws, err := parseList(weeks, 1, 7)
+	if err != nil {
+		return nil, fmt.Errorf("weeks: %v", err)
+	}
+	for _, w := range ws {
+		p.weekdays[w%7] = true
+	}
+	return p, nil
+}
+
+func parseList(s string, min, max int) ([]int, error) {
+	s = strings.Trim(strings.TrimSpace(s), "[]")
+	if s == "" {
+		return nil, errors.New("empty list")
+	}
+	var res []int
+	for _, f := range strings.Split(s, ",") {
+		f = strings.Trim(strings.TrimSpace(f), `"`)
+		n, err := strconv.Atoi(f)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not a number", f)
+		}
+		if n < min || n > max {
+			return nil, fmt.Errorf("%d is out of %d-%d", n, min, max)
+		}
+		res = append(res, n)
+	}
+	return res, nil
+}
+
+// LastSlot returns the start of the latest window at or before now, windows
+// older than maxCatchUp are not returned
+func (p *Plan) LastSlot(now time.Time) (time.Time, bool) {
+	slot := time.Date(now.Year(), now.Month(), now.Day(), now.Hour(), 0, 0, 0, now.Location())
+	for !slot.Before(now.Add(-maxCatchUp)) {
+		if p.hours[slot.Hour()] && p.weekdays[slot.Weekday()] {
+			return slot, true
+		}
+		slot = slot.Add(-time.Hour)
+	}
+	return time.Time{}, false
+}
@@ -0,0 +1,75 @@
+package strategy_service
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParsePlan(t *testing.T) {
+	valid := [][2]string{
+		{"0,12", "1,2,3,4,5,6,7"},
+		{`["0","23"]`, `["7"]`},
+		{" 6 ", "1"},
+	}
+	for _, c := range valid {
+		if _, err := ParsePlan(c[0], c[1]); err != nil {
+			t.Errorf("ParsePlan(%q, %q): %v", c[0], c[1], err)
+		}
+	}
+
+	invalid := [][2]string{
+		{"", "1"},
+		{"24", "1"},
+		{"1", "0"},
+		{"1", "8"},
+		{"a", "1"},
+		{"1,", "1"},
+	}
+	for _, c := range invalid {
+		if _, err := ParsePlan(c[0], c[1]); err == nil {
+			t.Errorf("ParsePlan(%q, %q) should fail", c[0], c[1])
+		}
+	}
+}
+
+func TestPlan_LastSlot(t *testing.T) {
+	// 2020-06-03 is a Wednesday
+	at := func(day, hour, minute int) time.Time {
+		return time.Date(2020, 6, day, hour, minute, 0, 0, time.UTC)
+	}
+	plan, err := ParsePlan("2,14", "3,7")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		now  time.Time
+		slot time.Time
+	}{
+		{now: at(3, 14, 0), slot: at(3, 14, 0)},
+		{now: at(3, 14, 59), slot: at(3, 14, 0)},
+		{now: at(3, 13, 59), slot: at(3, 2, 0)},
+		// Thursday to Saturday fall back on Wednesday afternoon
+		{now: at(6, 23, 0), slot: at(3, 14, 0)},
+		{now: at(7, 3, 0), slot: at(7, 2, 0)},
+		{now: at(9, 1, 0), slot: at(7, 14, 0)},
+	}
+	for _, c := range cases {
+		slot, ok := plan.LastSlot(c.now)
+		if !ok || !slot.Equal(c.slot) {
+			t.Errorf("LastSlot(%v) = %v, %v, expected %v", c.now, slot, ok, c.slot)
+		}
+	}
+
+	// a weekly plan falls back on the previous week
+	plan, err = ParsePlan("2", "3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if slot, ok := plan.LastSlot(at(10, 1, 0)); !ok || !slot.Equal(at(3, 2, 0)) {
+		t.Errorf("expected the window of the previous Wednesday, got %v, %v", slot, ok)
+	}
+	if slot, ok := plan.LastSlot(at(17, 1, 0)); !ok || !slot.Equal(at(10, 2, 0)) {
+		t.Errorf("expected the window of the previous Wednesday, got %v, %v", slot, ok)
+	}
+}
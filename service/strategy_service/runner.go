@@ -0,0 +1,185 @@
+package strategy_service
+
+import (
+	"database/sql"
+	"fmt"
+	"immortality-demo/pkg/data"
+	"immortality-demo/pkg/db_model"
+	"immortality-demo/pkg/gredis"
+	"immortality-demo/pkg/logger"
+	"immortality-demo/pkg/util"
+	"immortality-demo/service/disk_service"
+	"immortality-demo/service/model"
+	"sync"
+	"time"
+)
+
+// slotKey marks the window of a disk as taken, so that agents running side by
+// side do not snapshot the same disk twice for one window
+const slotKey = "IMMORTALITY_STRATEGY_SLOT"
+
+// slotTTL outlives the longest a window can be made up for
+const slotTTL = int(maxCatchUp / time.Second)
+
+// Runner evaluates the enabled strategies at the start of every hour. Each
+// disk bound to a strategy gets an automatic snapshot for the latest window
+// of its plan unless it already has one taken since, so the windows missed
+// while the agent was down are made up for with a single snapshot. The oldest
+// automatic snapshots are then deleted once the disk has more than DiskQuota
+// of them or they are older than Duration days.
+type Runner struct {
+	quit chan struct{}
+	wg   sync.WaitGroup
+}
+
+func NewRunner() *Runner {
+	return &Runner{
+		quit: make(chan struct{}),
+	}
+}
+
+// Start runs the strategies right away and then every hour on the hour
+func (r *Runner) Start() {
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		for {
+			r.Run(time.Now())
+			now := time.Now()
+			timer := time.NewTimer(now.Truncate(time.Hour).Add(time.Hour).Sub(now))
+			select {
+			case <-r.quit:
+				timer.Stop()
+				return
+			case <-timer.C:
+			}
+		}
+	}()
+}
+
+// Stop waits for the running round to finish
+func (r *Runner) Stop() {
+	close(r.quit)
+	r.wg.Wait()
+}
+
+// Run evaluates every enabled strategy once. Every snapshot it creates or
+// deletes is a job of its own request id, the id of the round is only logged.
+func (r *Runner) Run(now time.Time) {
+	roundId := "strategy-" + util.GenerateRandomId()
+	strategies, err := db_model.Strategies(data.Db, data.StrategyStatusEnabled)
+	if err != nil {
+		logger.Log.Error1(roundId, "Strategies error:", err)
+		return
+	}
+	plans := make(map[string]*Plan, len(strategies))
+	enabled := make(map[string]*db_model.Strategy, len(strategies))
+	for _, s := range strategies {
+		plan, err := ParsePlan(s.Hours, s.Weeks)
+		if err != nil {
+			logger.Log.Errorf(roundId, "strategy %s has an invalid plan: %v", s.StrategyId, err)
+			continue
+		}
+		plans[s.StrategyId] = plan
+		enabled[s.StrategyId] = s
+	}
+
+	binds, err := db_model.GetAllBinds(data.Db)
+	if err != nil {
+		logger.Log.Error1(roundId, "GetAllBinds error:", err)
+		return
+	}
+	for _, bind := range binds {
+		s, ok := enabled[bind.StrategyId]
+		if !ok {
+			continue
+		}
+		r.snapshot(roundId, s, plans[s.StrategyId], bind, now)
+		r.retain(roundId, s, bind, now)
+	}
+}
+
+// snapshot takes the automatic snapshot of the latest window if the disk has
+// none since
+func (r *Runner) snapshot(roundId string, s *db_model.Strategy, plan *Plan, bind *db_model.StrategyBind, now time.Time) {
+	slot, ok := plan.LastSlot(now)
+	if !ok || slot.Before(bind.BindTime) {
+		return
+	}
+	last, err := db_model.LastAutoSnapshotByDiskId(data.Db, bind.DiskId)
+	if err != nil && err != sql.ErrNoRows {
+		logger.Log.Error1(roundId, "LastAutoSnapshotByDiskId error:", err)
+		return
+	}
+	if err == nil && !last.CreatedAt.Before(slot) {
+		return
+	}
+
+	disk, err := db_model.DiskByDiskID(data.Db, bind.DiskId)
+	if err == sql.ErrNoRows {
+		logger.Log.Warnf(roundId, "disk %s bound to strategy %s does not exist", bind.DiskId, s.StrategyId)
+		return
+	}
+	if err != nil {
+		logger.Log.Error1(roundId, "DiskByDiskID error:", err)
+		return
+	}
+
+	requestId := "strategy-" + util.GenerateRandomId()
+	key := fmt.Sprintf("%s:%s:%d", slotKey, disk.DiskID, slot.Unix())
+	taken, err := gredis.SetNX(key, requestId, slotTTL)
+	if err != nil {
+		logger.Log.Error1(roundId, "Failed to take the window of disk", disk.DiskID, "err:", err)
+		return
+	}
+	if !taken {
+		return
+	}
+
+	param := model.CreateSnapshotParams{
+		SnapshotId:   "snap-" + util.GenerateRandomId(),
+		SnapshotName: fmt.Sprintf("auto-%s-%s", s.StrategyId, slot.Format("200601021504")),
+		Description:  fmt.Sprintf("taken by strategy %s", s.StrategyName),
+		SnapshotType: data.SnapshotTypeAuto,
+	}
+	if _, err = (&disk_service.CreateSnapshotHandler{}).Handle(disk.DiskID, param, disk.UserID, requestId); err != nil {
+		logger.Log.Errorf(roundId, "strategy %s failed to snapshot disk %s: %v", s.StrategyId, disk.DiskID, err)
+		// let the next round try the window again
+		if _, dErr := gredis.Delete(key); dErr != nil {
+			logger.Log.Error1(roundId, "Failed to release the window of disk", disk.DiskID, "err:", dErr)
+		}
+	}
+}
+
+// retain deletes the oldest automatic snapshots beyond DiskQuota and those
+// older than Duration days
+func (r *Runner) retain(roundId string, s *db_model.Strategy, bind *db_model.StrategyBind, now time.Time) {
+	snapshots, err := db_model.AutoSnapshotsByDiskID(data.Db, bind.DiskId)
+	if err != nil {
+		logger.Log.Error1(roundId, "AutoSnapshotsByDiskID error:", err)
+		return
+	}
+	kept := snapshots[:0]
+	for _, snapshot := range snapshots {
+		if snapshot.Status != data.SnapshotStatusDeleting {
+			kept = append(kept, snapshot)
+		}
+	}
+
+	expiry := now.AddDate(0, 0, -int(s.Duration))
+	for i, snapshot := range kept {
+		excess := s.DiskQuota > 0 && len(kept)-i > int(s.DiskQuota)
+		expired := s.Duration > 0 && snapshot.CreatedAt.Before(expiry)
+		if !excess && !expired {
+			// the rest are newer
+			return
+		}
+		if snapshot.Status == data.SnapshotStatusCreating {
+			continue
+		}
+		requestId := "strategy-" + util.GenerateRandomId()
+		if _, err = (&disk_service.DeleteSnapshotHandler{}).Handle(snapshot.SnapshotID, snapshot.UserID, requestId); err != nil {
+			logger.Log.Errorf(roundId, "strategy %s failed to delete snapshot %s: %v", s.StrategyId, snapshot.SnapshotID, err)
+		}
+	}
+}
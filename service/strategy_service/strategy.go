@@ -0,0 +1,322 @@
+package strategy_service
+
+import (
+	"database/sql"
+	"immortality-demo/pkg/data"
+	"immortality-demo/pkg/db_model"
+	"immortality-demo/pkg/logger"
+	"immortality-demo/service/model"
+	"time"
+)
+
+const (
+	maxStrategyIdLen = 64
+	// automatic snapshots are kept for ever with a Duration of -1, or up to
+	// maxDuration days
+	maxDuration = 65536
+)
+
+type CreateStrategyHandler struct {
+}
+
+func (h *CreateStrategyHandler) Handle(param model.CreateStrategyParams, userId, requestId string) (result *model.StrategyInfo, err error) {
+	switch {
+	case param.StrategyId == "":
+		return nil, data.ErrLackOfRequiredFieldStrategyId
+	case len(param.StrategyId) > maxStrategyIdLen:
+		return nil, data.ErrFieldStrategyIdWrongValue
+	case param.StrategyName == "":
+		return nil, data.ErrLackOfRequiredFieldStrategyName
+	case param.Hours == "":
+		return nil, data.ErrLackOfRequiredFieldHours
+	case param.Weeks == "":
+		return nil, data.ErrLackOfRequiredFieldWeeks
+	case param.Duration == 0:
+		return nil, data.ErrLackOfRequiredFieldDuration
+	case param.SnapshotQuota == 0:
+		return nil, data.ErrLackOfRequiredFieldSnapshotQuota
+	}
+	status := data.StrategyStatusEnabled
+	if param.Status != "" {
+		var ok bool
+		if status, ok = data.StrategyStatusMap2[param.Status]; !ok {
+			return nil, data.ErrInvalidStrategyStatus
+		}
+	}
+	if err = verifyStrategy(param.Hours, param.Weeks, param.Duration, param.SnapshotQuota); err != nil {
+		return nil, err
+	}
+
+	_, err = db_model.StrategyByStrategyId(data.Db, param.StrategyId)
+	if err == nil {
+		return nil, data.ErrConflictStrategyId
+	}
+	if err != sql.ErrNoRows {
+		logger.Log.Error1(requestId, "StrategyByStrategyId error:", err)
+		return nil, data.ErrServerInternalDB
+	}
+
+	now := time.Now()
+	strategy := &db_model.Strategy{
+		StrategyId:   param.StrategyId,
+		StrategyName: param.StrategyName,
+		UserId:       userId,
+		Hours:        param.Hours,
+		Weeks:        param.Weeks,
+		Status:       status,
+		Duration:     param.Duration,
+		DiskQuota:    param.SnapshotQuota,
+		CreateTime:   now,
+		UpdateTime:   now,
+	}
+	if err = strategy.Insert(data.Db); err != nil {
+		logger.Log.Error1(requestId, "Insert strategy error:", err)
+		return nil, data.ErrServerInternalDB
+	}
+	logger.Log.Infof(requestId, "strategy %s created", strategy.StrategyId)
+
+	info := strategyInfo(strategy, 0)
+	return &info, nil
+}
+
+type UpdateStrategyHandler struct {
+}
+
+func (h *UpdateStrategyHandler) Handle(strategyId string, param model.UpdateStrategyParams, userId, requestId string) (result *model.StrategyInfo, err error) {
+	var strategy *db_model.Strategy
+	var count int
+	err = withStrategy(requestId, strategyId, userId, func(tx *sql.Tx, s *db_model.Strategy) error {
+		if param.StrategyName != "" {
+			s.StrategyName = param.StrategyName
+		}
+		if param.Hours != "" {
+			s.Hours = param.Hours
+		}
+		if param.Weeks != "" {
+			s.Weeks = param.Weeks
+		}
+		if param.Duration != 0 {
+			s.Duration = param.Duration
+		}
+		if param.SnapshotQuota != 0 {
+			s.DiskQuota = param.SnapshotQuota
+		}
+		if param.Status != "" {
+			status, ok := data.StrategyStatusMap2[param.Status]
+			if !ok {
+				return data.ErrInvalidStrategyStatus
+			}
+			s.Status = status
+		}
+		if err := verifyStrategy(s.Hours, s.Weeks, s.Duration, s.DiskQuota); err != nil {
+			return err
+		}
+
+		s.UpdateTime = time.Now()
+		if err := s.Update(tx); err != nil {
+			logger.Log.Error1(requestId, "Update strategy error:", err)
+			return data.ErrServerInternalDB
+		}
+		n, err := db_model.GetDiskCountByStrategyId(tx, s.StrategyId)
+		if err != nil {
+			logger.Log.Error1(requestId, "GetDiskCountByStrategyId error:", err)
+			return data.ErrServerInternalDB
+		}
+		strategy, count = s, n
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	logger.Log.Infof(requestId, "strategy %s updated", strategyId)
+
+	info := strategyInfo(strategy, count)
+	return &info, nil
+}
+
+type DeleteStrategyHandler struct {
+}
+
+// Handle deletes a strategy without disks, the automatic snapshots it took
+// are kept
+func (h *DeleteStrategyHandler) Handle(strategyId, userId, requestId string) (result map[string]interface{}, err error) {
+	err = withStrategy(requestId, strategyId, userId, func(tx *sql.Tx, s *db_model.Strategy) error {
+		count, err := db_model.GetDiskCountByStrategyId(tx, s.StrategyId)
+		if err != nil {
+			logger.Log.Error1(requestId, "GetDiskCountByStrategyId error:", err)
+			return data.ErrServerInternalDB
+		}
+		if count > 0 {
+			return data.ErrExistBindOfStrategy
+		}
+		if err = s.Delete(tx); err != nil {
+			logger.Log.Error1(requestId, "Delete strategy error:", err)
+			return data.ErrServerInternalDB
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	logger.Log.Infof(requestId, "strategy %s deleted", strategyId)
+
+	return map[string]interface{}{
+		"StrategyId": strategyId,
+		"RequestId":  requestId,
+	}, nil
+}
+
+type DescribeStrategiesHandler struct {
+}
+
+// Handle lists the strategies of the user, or the one with StrategyId, or the
+// one DiskId is bound to
+func (h *DescribeStrategiesHandler) Handle(param model.DescribeStrategiesParams, userId, requestId string) (result *model.DescribeStrategiesResult, err error) {
+	if param.StrategyId != "" && param.DiskId != "" {
+		return nil, data.ErrConflictParametersOfStrategy
+	}
+
+	var strategies []*db_model.Strategy
+	strategyId := param.StrategyId
+	if param.DiskId != "" {
+		bind, err := db_model.BindByDiskId(data.Db, param.DiskId)
+		if err == sql.ErrNoRows {
+			return nil, data.ErrInExistBindOfDisk
+		}
+		if err != nil {
+			logger.Log.Error1(requestId, "BindByDiskId error:", err)
+			return nil, data.ErrServerInternalDB
+		}
+		strategyId = bind.StrategyId
+	}
+	if strategyId != "" {
+		strategy, err := db_model.StrategyByStrategyId(data.Db, strategyId)
+		if err != nil && err != sql.ErrNoRows {
+			logger.Log.Error1(requestId, "StrategyByStrategyId error:", err)
+			return nil, data.ErrServerInternalDB
+		}
+		if err == nil && strategy.UserId == userId {
+			strategies = append(strategies, strategy)
+		}
+	} else {
+		strategies, err = db_model.StrategiesByUserId(data.Db, userId)
+		if err != nil {
+			logger.Log.Error1(requestId, "StrategiesByUserId error:", err)
+			return nil, data.ErrServerInternalDB
+		}
+	}
+
+	result = &model.DescribeStrategiesResult{
+		Strategies: make([]model.StrategyInfo, 0, len(strategies)),
+		RequestId:  requestId,
+	}
+	for _, strategy := range strategies {
+		count, err := db_model.GetDiskCountByStrategyId(data.Db, strategy.StrategyId)
+		if err != nil {
+			logger.Log.Error1(requestId, "GetDiskCountByStrategyId error:", err)
+			return nil, data.ErrServerInternalDB
+		}
+		result.Strategies = append(result.Strategies, strategyInfo(strategy, count))
+	}
+	return result, nil
+}
+
+type DescribeStrategyHandler struct {
+}
+
+func (h *DescribeStrategyHandler) Handle(strategyId, userId, requestId string) (result *model.StrategyInfo, err error) {
+	strategy, err := db_model.StrategyByStrategyId(data.Db, strategyId)
+	if err == sql.ErrNoRows {
+		return nil, data.ErrInvalidStrategyId
+	}
+	if err != nil {
+		logger.Log.Error1(requestId, "StrategyByStrategyId error:", err)
+		return nil, data.ErrServerInternalDB
+	}
+	if strategy.UserId != userId {
+		return nil, data.ErrInvalidStrategyId
+	}
+
+	binds, err := db_model.BindsByStrategyId(data.Db, strategyId)
+	if err != nil {
+		logger.Log.Error1(requestId, "BindsByStrategyId error:", err)
+		return nil, data.ErrServerInternalDB
+	}
+	info := strategyInfo(strategy, len(binds))
+	info.DiskIds = make([]string, 0, len(binds))
+	for _, bind := range binds {
+		info.DiskIds = append(info.DiskIds, bind.DiskId)
+	}
+	return &info, nil
+}
+
+// withStrategy locks the strategy of the user and runs fn in the same
+// transaction
+func withStrategy(requestId, strategyId, userId string, fn func(tx *sql.Tx, s *db_model.Strategy) error) (err error) {
+	if strategyId == "" {
+		return data.ErrLackOfRequiredFieldStrategyId
+	}
+
+	tx, err := data.Db.Begin()
+	if err != nil {
+		logger.Log.Error1(requestId, "Begin transaction error:", err)
+		return data.ErrServerInternalDB
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	strategy, err := db_model.StrategyByIDForUpdate(tx, strategyId)
+	if err == sql.ErrNoRows {
+		return data.ErrInvalidStrategyId
+	}
+	if err != nil {
+		logger.Log.Error1(requestId, "StrategyByIDForUpdate error:", err)
+		return data.ErrServerInternalDB
+	}
+	if strategy.UserId != userId {
+		return data.ErrInvalidStrategyId
+	}
+
+	if err = fn(tx, strategy); err != nil {
+		return err
+	}
+	if err = tx.Commit(); err != nil {
+		logger.Log.Error1(requestId, "Commit transaction error:", err)
+		return data.ErrServerInternalDB
+	}
+	return nil
+}
+
+func verifyStrategy(hours, weeks string, duration, snapshotQuota int32) error {
+	if _, err := parseList(hours, 0, 23); err != nil {
+		return data.ErrFieldHoursWrongValue
+	}
+	if _, err := parseList(weeks, 1, 7); err != nil {
+		return data.ErrFieldWeeksWrongValue
+	}
+	if duration != -1 && (duration < 1 || duration > maxDuration) {
+		return data.ErrFieldDurationWrongValue
+	}
+	if snapshotQuota < 1 || snapshotQuota > data.DiskSnapshotQuota {
+		return data.ErrFieldSnapshotQuotaWrongValue
+	}
+	return nil
+}
+
+func strategyInfo(s *db_model.Strategy, diskCount int) model.StrategyInfo {
+	return model.StrategyInfo{
+		StrategyId:    s.StrategyId,
+		StrategyName:  s.StrategyName,
+		Hours:         s.Hours,
+		Weeks:         s.Weeks,
+		Duration:      s.Duration,
+		SnapshotQuota: s.DiskQuota,
+		Status:        data.StrategyStatusMap[s.Status],
+		DiskCount:     diskCount,
+		CreatedAt:     s.CreateTime.Format(time.RFC3339),
+		UpdatedAt:     s.UpdateTime.Format(time.RFC3339),
+	}
+}
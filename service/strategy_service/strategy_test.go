@@ -0,0 +1,170 @@
+package strategy_service
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"immortality-demo/config"
+	"immortality-demo/pkg/data"
+	"immortality-demo/pkg/db_model"
+	"immortality-demo/pkg/logger"
+	"immortality-demo/service/model"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	_ "github.com/go-sql-driver/mysql"
+)
+
+func validStrategy() model.CreateStrategyParams {
+	return model.CreateStrategyParams{
+		StrategyId:    "strategy-1",
+		StrategyName:  "nightly",
+		Hours:         "2",
+		Weeks:         "1,2,3,4,5,6,7",
+		Duration:      7,
+		SnapshotQuota: 3,
+	}
+}
+
+// TestCreateStrategyInvalid checks the parameters which are refused before
+// the database is needed
+func TestCreateStrategyInvalid(t *testing.T) {
+	cases := []struct {
+		change func(p *model.CreateStrategyParams)
+		err    error
+	}{
+		{func(p *model.CreateStrategyParams) { p.StrategyId = "" }, data.ErrLackOfRequiredFieldStrategyId},
+		{func(p *model.CreateStrategyParams) { p.StrategyId = strings.Repeat("s", maxStrategyIdLen+1) }, data.ErrFieldStrategyIdWrongValue},
+		{func(p *model.CreateStrategyParams) { p.StrategyName = "" }, data.ErrLackOfRequiredFieldStrategyName},
+		{func(p *model.CreateStrategyParams) { p.Hours = "" }, data.ErrLackOfRequiredFieldHours},
+		{func(p *model.CreateStrategyParams) { p.Weeks = "" }, data.ErrLackOfRequiredFieldWeeks},
+		{func(p *model.CreateStrategyParams) { p.Duration = 0 }, data.ErrLackOfRequiredFieldDuration},
+		{func(p *model.CreateStrategyParams) { p.SnapshotQuota = 0 }, data.ErrLackOfRequiredFieldSnapshotQuota},
+		{func(p *model.CreateStrategyParams) { p.Status = "enabled" }, data.ErrInvalidStrategyStatus},
+		{func(p *model.CreateStrategyParams) { p.Hours = "24" }, data.ErrFieldHoursWrongValue},
+		{func(p *model.CreateStrategyParams) { p.Weeks = "0" }, data.ErrFieldWeeksWrongValue},
+		{func(p *model.CreateStrategyParams) { p.Duration = -2 }, data.ErrFieldDurationWrongValue},
+		{func(p *model.CreateStrategyParams) { p.Duration = maxDuration + 1 }, data.ErrFieldDurationWrongValue},
+		{func(p *model.CreateStrategyParams) { p.SnapshotQuota = data.DiskSnapshotQuota + 1 }, data.ErrFieldSnapshotQuotaWrongValue},
+	}
+	for i, c := range cases {
+		param := validStrategy()
+		c.change(&param)
+		if _, err := (&CreateStrategyHandler{}).Handle(param, "user-1", "req-1"); err != c.err {
+			t.Errorf("case %d: expected %v, got %v", i, c.err, err)
+		}
+	}
+}
+
+func TestStrategyIdRequired(t *testing.T) {
+	if _, err := (&UpdateStrategyHandler{}).Handle("", model.UpdateStrategyParams{}, "user-1", "req-1"); err != data.ErrLackOfRequiredFieldStrategyId {
+		t.Errorf("update: %v", err)
+	}
+	if _, err := (&DeleteStrategyHandler{}).Handle("", "user-1", "req-1"); err != data.ErrLackOfRequiredFieldStrategyId {
+		t.Errorf("delete: %v", err)
+	}
+	disks := model.BindStrategyParams{DiskIds: []string{"disk-1"}}
+	if _, err := (&BindStrategyHandler{}).Handle("", disks, "user-1", "req-1"); err != data.ErrLackOfRequiredFieldStrategyId {
+		t.Errorf("bind: %v", err)
+	}
+	if _, err := (&UnbindStrategyHandler{}).Handle("", disks, "user-1", "req-1"); err != data.ErrLackOfRequiredFieldStrategyId {
+		t.Errorf("unbind: %v", err)
+	}
+
+	empty := model.BindStrategyParams{DiskIds: []string{"", ""}}
+	if _, err := (&BindStrategyHandler{}).Handle("strategy-1", empty, "user-1", "req-1"); err != data.ErrLackOfRequiredFieldDiskIds {
+		t.Errorf("bind without disks: %v", err)
+	}
+	if _, err := (&UnbindStrategyHandler{}).Handle("strategy-1", empty, "user-1", "req-1"); err != data.ErrLackOfRequiredFieldDiskIds {
+		t.Errorf("unbind without disks: %v", err)
+	}
+	both := model.DescribeStrategiesParams{StrategyId: "strategy-1", DiskId: "disk-1"}
+	if _, err := (&DescribeStrategiesHandler{}).Handle(both, "user-1", "req-1"); err != data.ErrConflictParametersOfStrategy {
+		t.Errorf("describe by strategy and disk: %v", err)
+	}
+}
+
+// setupDB connects the database of the config, the test is skipped if it is
+// not reachable
+func setupDB(t *testing.T) {
+	config.LoadConfig()
+	logger.Log = logger.NewStdoutLogger(os.Stdout, "")
+	u, err := url.Parse(config.Config.DBPath)
+	if err != nil {
+		t.Skip("bad db path:", err)
+	}
+	db, err := sql.Open("mysql", fmt.Sprintf("%s@tcp(%s)%s?%s", u.User.String(), u.Host, u.Path, u.RawQuery))
+	if err == nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+		err = db.PingContext(ctx)
+		cancel()
+	}
+	if err != nil {
+		t.Skip("database is not available:", err)
+	}
+	data.Db = db
+}
+
+// TestStrategyLifecycle creates a strategy, binds a disk to it and deletes it
+// again, the strategy of another user is not visible
+func TestStrategyLifecycle(t *testing.T) {
+	setupDB(t)
+
+	userId := fmt.Sprintf("user-strategy-%d", time.Now().UnixNano())
+	param := validStrategy()
+	param.StrategyId = userId + "-strategy"
+	now := time.Now()
+	disk := db_model.Disk{DiskID: userId + "-disk", UserID: userId, Size: 10 << 30, CreatedAt: now, UpdatedAt: now}
+	if err := disk.Insert(data.Db); err != nil {
+		t.Fatal(err)
+	}
+	defer data.Db.Exec(`DELETE FROM disk WHERE disk_id = ?`, disk.DiskID)
+
+	if _, err := (&CreateStrategyHandler{}).Handle(param, userId, "req-1"); err != nil {
+		t.Fatal(err)
+	}
+	defer (&DeleteStrategyHandler{}).Handle(param.StrategyId, userId, "req-1")
+	if _, err := (&CreateStrategyHandler{}).Handle(param, userId, "req-1"); err != data.ErrConflictStrategyId {
+		t.Errorf("a second create: %v", err)
+	}
+	if _, err := (&DescribeStrategyHandler{}).Handle(param.StrategyId, "user-other", "req-1"); err != data.ErrInvalidStrategyId {
+		t.Errorf("describe by another user: %v", err)
+	}
+	if _, err := (&DescribeStrategyHandler{}).Handle(param.StrategyId+"-none", userId, "req-1"); err != data.ErrInvalidStrategyId {
+		t.Errorf("describe an unknown strategy: %v", err)
+	}
+	if _, err := (&UpdateStrategyHandler{}).Handle(param.StrategyId, model.UpdateStrategyParams{Status: "Paused"}, userId, "req-1"); err != data.ErrInvalidStrategyStatus {
+		t.Errorf("update to an unknown status: %v", err)
+	}
+
+	disks := model.BindStrategyParams{DiskIds: []string{disk.DiskID}}
+	if _, err := (&BindStrategyHandler{}).Handle(param.StrategyId, disks, "user-other", "req-1"); err != data.ErrInvalidStrategyId {
+		t.Errorf("bind to the strategy of another user: %v", err)
+	}
+	unknown := model.BindStrategyParams{DiskIds: []string{disk.DiskID + "-none"}}
+	if _, err := (&BindStrategyHandler{}).Handle(param.StrategyId, unknown, userId, "req-1"); err != data.ErrInvalidDiskId {
+		t.Errorf("bind an unknown disk: %v", err)
+	}
+	if _, err := (&BindStrategyHandler{}).Handle(param.StrategyId, disks, userId, "req-1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := (&DeleteStrategyHandler{}).Handle(param.StrategyId, userId, "req-1"); err != data.ErrExistBindOfStrategy {
+		t.Errorf("delete a strategy with disks: %v", err)
+	}
+	info, err := (&DescribeStrategyHandler{}).Handle(param.StrategyId, userId, "req-1")
+	if err != nil || len(info.DiskIds) != 1 || info.DiskIds[0] != disk.DiskID {
+		t.Errorf("expected %s bound, got %+v, %v", disk.DiskID, info, err)
+	}
+	if _, err := (&UnbindStrategyHandler{}).Handle(param.StrategyId, disks, userId, "req-1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := (&UnbindStrategyHandler{}).Handle(param.StrategyId, disks, userId, "req-1"); err != data.ErrInExistBindOfStrategy {
+		t.Errorf("unbind a strategy without disks: %v", err)
+	}
+	if _, err := (&DeleteStrategyHandler{}).Handle(param.StrategyId, userId, "req-1"); err != nil {
+		t.Errorf("delete: %v", err)
+	}
+}
@@ -0,0 +1,99 @@
+package workerpool
+
+import (
+	"immortality-demo/pkg/data"
+	"immortality-demo/pkg/db_model"
+)
+
+// compensate settles the resources of a job which went to the dead-letter
+// list, so that they are not left in a transient status nothing moves them out
+// of. A resource still being created or deleted goes to its error status, an
+// existing disk being changed gets its original status back. Each update only
+// applies to a resource still in the status the job left it in.
+func compensate(job Job) {
+	var err error
+	switch job.Action {
+	case data.ActionCreateDisk:
+		var req data.CreateDiskRequest
+		if err = job.Unmarshal(&req); err == nil {
+			err = db_model.MarkDiskStatusFrom(data.Db, data.DiskStatusError, data.DiskStatusCreating, req.DiskId)
+		}
+	case data.ActionCreateDisks:
+		var req data.CreateDisksRequest
+		if err = job.Unmarshal(&req); err == nil {
+			for _, disk := range req.DisksReq {
+				if err = db_model.MarkDiskStatusFrom(data.Db, data.DiskStatusError, data.DiskStatusCreating, disk.DiskId); err != nil {
+					break
+				}
+			}
+		}
+	case data.ActionDeleteDisk:
+		var req data.DeleteDiskRequest
+		if err = job.Unmarshal(&req); err == nil {
+			err = db_model.MarkDiskStatusFrom(data.Db, data.DiskStatusError, data.DiskStatusDeleting, req.DiskId)
+		}
+	case data.ActionDeleteDisks:
+		var req data.DeleteDisksRequest
+		if err = job.Unmarshal(&req); err == nil {
+			for _, disk := range req.DisksInfo {
+				if err = db_model.MarkDiskStatusFrom(data.Db, data.DiskStatusError, data.DiskStatusDeleting, disk.DiskId); err != nil {
+					break
+				}
+			}
+		}
+	case data.ActionResizeDisk:
+		var req data.ResizeDiskRequest
+		if err = job.Unmarshal(&req); err == nil {
+			err = db_model.RestoreDiskStatusFrom(data.Db, data.DiskStatusResizing, req.DiskId)
+		}
+	case data.ActionResizeDisks:
+		var req data.ResizeDisksRequest
+		if err = job.Unmarshal(&req); err == nil {
+			for _, disk := range req.DisksReq {
+				if err = db_model.RestoreDiskStatusFrom(data.Db, data.DiskStatusResizing, disk.DiskId); err != nil {
+					break
+				}
+			}
+		}
+	case data.ActionResetDisk:
+		var req data.ResetDiskRequest
+		if err = job.Unmarshal(&req); err == nil {
+			err = db_model.RestoreDiskStatusFrom(data.Db, data.DiskStatusResetting, req.DiskId)
+		}
+	case data.ActionReInitDisk:
+		var req data.ReInitDiskRequest
+		if err = job.Unmarshal(&req); err == nil {
+			err = db_model.RestoreDiskStatusFrom(data.Db, data.DiskStatusResetting, req.DiskId)
+		}
+	case data.ActionCreateSnapshot:
+		var req data.CreateSnapshotRequest
+		if err = job.Unmarshal(&req); err == nil {
+			err = db_model.MarkSnapshotStatusFrom(data.Db, data.SnapshotStatusError, data.SnapshotStatusCreating, req.SnapshotId)
+		}
+		if err == nil {
+			err = db_model.RestoreDiskStatusFrom(data.Db, data.DiskStatusCreatingSnapshot, req.DiskId)
+		}
+	case data.ActionDeleteSnapshot:
+		var req data.DeleteSnapshotRequest
+		if err = job.Unmarshal(&req); err == nil {
+			err = db_model.MarkSnapshotStatusFrom(data.Db, data.SnapshotStatusError, data.SnapshotStatusDeleting, req.SnapshotId)
+		}
+	case data.ActionExport:
+		var req data.ExportDiskRequest
+		if err = job.Unmarshal(&req); err == nil {
+			err = db_model.MarkExportStatusFrom(data.Db, data.ExportStatusExportFail, data.ExportStatusExporting, req.DiskId, req.CVKName)
+		}
+	case data.ActionCancelExport:
+		var req data.ExportDiskRequest
+		if err = job.Unmarshal(&req); err == nil {
+			err = db_model.MarkExportStatusFrom(data.Db, data.ExportStatusUnExportFail, data.ExportStatusExported, req.DiskId, req.CVKName)
+		}
+	default:
+		return
+	}
+	if err != nil {
+		ulog.Errorf("[%s] compensate dead %s error: %v", job.RequestId, job.Action, err)
+		return
+	}
+	ulog.Warningf("[%s] resources of dead %s settled", job.RequestId, job.Action)
+}
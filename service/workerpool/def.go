@@ -1,13 +1,39 @@
 package workerpool
 
 import (
+	"context"
+	"immortality-demo/pkg/envelope"
 	"sync"
 	"time"
 )
 
+// JobQueue is the redis list the api pushes jobs into
+const JobQueue = "queue"
+
+const (
+	dequeueTimeout  = 5 * time.Second
+	heartbeatTTL    = 30 * time.Second
+	reapInterval    = 30 * time.Second
+	moveDueInterval = time.Second
+	lockTTL         = 2 * time.Minute
+	wakeInterval    = 30 * time.Second
+	// postponeDelay is the wait before a job whose resources are busy runs again
+	postponeDelay = time.Second
+	// stopGrace is how long Stop waits for cancelled jobs to return
+	stopGrace = 5 * time.Second
+)
+
+// Job is a decoded queue entry
 type Job struct {
-	Action string
-	Detail interface{}
+	*envelope.Envelope
+
+	// raw is the queue entry, needed to ack the job
+	raw []byte
+	// key and token of the lock the job runs under, see serialize.go
+	key   string
+	token int64
+	// others are the tokens of the other keys of the job
+	others map[string]int64
 }
 
 // TaskHandler process .定义函数回调体
@@ -26,4 +52,11 @@ type WorkerPool struct {
 	timeout time.Duration //最大超时时间
 	wg      sync.WaitGroup
 	Job     chan Job
+
+	// ctx is the parent of every job context, it is cancelled when Stop gives up waiting
+	ctx    context.Context
+	cancel context.CancelFunc
+	// quit stops consuming, done stops the heartbeat once the pool is drained
+	quit chan struct{}
+	done chan struct{}
 }
@@ -0,0 +1,96 @@
+package workerpool
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"immortality-demo/config"
+	"immortality-demo/pkg/data"
+	"immortality-demo/pkg/db_model"
+	"immortality-demo/pkg/driver/fake"
+	"immortality-demo/pkg/envelope"
+	"immortality-demo/pkg/gredis"
+	"net/url"
+	"testing"
+	"time"
+
+	_ "github.com/go-sql-driver/mysql"
+)
+
+// setupBackends connects redis and the database of the config, the test is
+// skipped if either is not reachable
+func setupBackends(t *testing.T) {
+	setupRedis(t)
+
+	u, err := url.Parse(config.Config.DBPath)
+	if err != nil {
+		t.Skip("bad db path:", err)
+	}
+	dsn := fmt.Sprintf("%s@tcp(%s)%s?%s", u.User.String(), u.Host, u.Path, u.RawQuery)
+	db, err := sql.Open("mysql", dsn)
+	if err == nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+		err = db.PingContext(ctx)
+		cancel()
+	}
+	if err != nil {
+		t.Skip("database is not available:", err)
+	}
+	data.Db = db
+}
+
+// TestCreateDiskThroughFake runs a CreateDisk job from the queue through the
+// abstract driver into the fake driver, the first attempt fails and is retried.
+func TestCreateDiskThroughFake(t *testing.T) {
+	setupBackends(t)
+
+	diskId := fmt.Sprintf("disk-fake-%d", time.Now().UnixNano())
+	now := time.Now()
+	disk := db_model.Disk{
+		DiskID:      diskId,
+		Status:      data.DiskStatusCreating,
+		Size:        1 << 30,
+		StorageType: fake.StorageType,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	if err := disk.Insert(data.Db); err != nil {
+		t.Fatal(err)
+	}
+	defer data.Db.Exec(`DELETE FROM disk WHERE disk_id = ?`, diskId)
+
+	fake.Default.Reset()
+	fake.Default.Inject("CreateDisk", fake.Fault{Err: errors.New("backend is busy"), Times: 1})
+
+	e, _ := envelope.New(data.ActionCreateDisk, "", data.CreateDiskRequest{
+		DiskId:      diskId,
+		Size:        1 << 30,
+		StorageType: fake.StorageType,
+	})
+	raw, _ := envelope.Encode(e)
+	if err := gredis.Enqueue(JobQueue, raw); err != nil {
+		t.Fatal(err)
+	}
+
+	p := NewWorkPool(2, time.Minute)
+	defer p.Stop(time.Second)
+
+	deadline := time.Now().Add(30 * time.Second)
+	for {
+		d, err := db_model.DiskByDiskID(data.Db, diskId)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if d.Status == data.DiskStatusAvailable {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("disk is still in status %d", d.Status)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	if v, ok := fake.Default.Disk(diskId); !ok || v.Size != 1<<30 {
+		t.Fatalf("expected a 1GiB volume in the fake driver, got %+v", v)
+	}
+}
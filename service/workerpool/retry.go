@@ -0,0 +1,117 @@
+package workerpool
+
+import (
+	"immortality-demo/config"
+	"immortality-demo/pkg/data"
+	"immortality-demo/pkg/db_model"
+	"immortality-demo/pkg/envelope"
+	"immortality-demo/pkg/gredis"
+	"math/rand"
+	"time"
+)
+
+// RetryPolicy decides how often and how fast a failed job is tried again
+type RetryPolicy struct {
+	MaxAttempts int
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+	// Jitter is the fraction of the delay which is randomized, 0.2 means ±20%
+	Jitter float64
+}
+
+// policyFor returns the retries of the action from config.JobRetry and
+// config.JobRetryActions
+func policyFor(action string) RetryPolicy {
+	r := config.RetryOf(action)
+	return RetryPolicy{
+		MaxAttempts: r.MaxAttempts,
+		BaseDelay:   time.Duration(r.BaseDelay) * time.Second,
+		MaxDelay:    time.Duration(r.MaxDelay) * time.Second,
+		Jitter:      r.Jitter,
+	}
+}
+
+// Backoff returns the delay before the attempt following the given one
+func (r RetryPolicy) Backoff(attempt int) time.Duration {
+	delay := r.BaseDelay
+	for i := 1; i < attempt && delay < r.MaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > r.MaxDelay {
+		delay = r.MaxDelay
+	}
+	if r.Jitter > 0 {
+		delay += time.Duration((rand.Float64()*2 - 1) * r.Jitter * float64(delay))
+	}
+	return delay
+}
+
+// retryOrBury schedules the failed job again, or moves it to the dead-letter
+// list when it ran out of attempts. A keyed job keeps its lock until the retry
+// runs so the jobs parked behind it stay behind it.
+func (p *WorkerPool) retryOrBury(job Job, dispatchErr error) (retrying bool, err error) {
+	policy := policyFor(job.Action)
+	if job.Attempts < policy.MaxAttempts {
+		delay := policy.Backoff(job.Attempts)
+		job.Fence, job.Fences = job.token, job.others
+		value, err := envelope.Encode(job.Envelope)
+		if err != nil {
+			ulog.Errorf("[%s] encode job error: %v", job.RequestId, err)
+			return false, err
+		}
+		if job.key != "" {
+			if _, err := gredis.HoldLock(JobQueue, job.key, job.token, delay+lockTTL); err != nil {
+				ulog.Errorf("[%s] hold lock of %s error: %v", job.RequestId, job.key, err)
+			}
+		}
+		for key, token := range job.others {
+			if _, err := gredis.HoldLock(JobQueue, key, token, delay+lockTTL); err != nil {
+				ulog.Errorf("[%s] hold lock of %s error: %v", job.RequestId, key, err)
+			}
+		}
+		if err := gredis.EnqueueAt(JobQueue, value, time.Now().Add(delay)); err != nil {
+			ulog.Errorf("[%s] schedule retry error: %v", job.RequestId, err)
+			return false, err
+		}
+		ulog.Warningf("[%s] %s retries in %s", job.RequestId, job.Action, delay)
+		if job.RequestId != "" {
+			if err := db_model.MarkJobRetrying(data.Db, job.RequestId, dispatchErr.Error()); err != nil {
+				ulog.Errorf("[%s] mark job retrying error: %v", job.RequestId, err)
+			}
+		}
+		return true, nil
+	}
+
+	if err := p.bury(job, data.JobStateDead, dispatchErr); err != nil {
+		return false, err
+	}
+	ulog.Errorf("[%s] %s is dead after %d attempts", job.RequestId, job.Action, job.Attempts)
+	return false, nil
+}
+
+// bury moves the job to the dead-letter list, records it in the given state
+// and settles the resources it left halfway, see compensate
+func (p *WorkerPool) bury(job Job, state int8, cause error) error {
+	job.Fence, job.Fences = 0, nil
+	value, err := envelope.Encode(job.Envelope)
+	if err != nil {
+		ulog.Errorf("[%s] encode job error: %v", job.RequestId, err)
+		return err
+	}
+	if err = gredis.Bury(JobQueue, value); err != nil {
+		ulog.Errorf("[%s] bury job error: %v", job.RequestId, err)
+		return err
+	}
+	markJobFinished(job, state, cause.Error())
+	compensate(job)
+	return nil
+}
+
+// moveDue makes the delayed retries available once they are due
+func (p *WorkerPool) moveDue() {
+	for sleep(p.quit, moveDueInterval) {
+		if _, err := gredis.MoveDue(JobQueue, time.Now()); err != nil {
+			ulog.Errorf("move due jobs error: %v", err)
+		}
+	}
+}
@@ -0,0 +1,317 @@
+package workerpool
+
+import (
+	"context"
+	"encoding/json"
+	"immortality-demo/config"
+	"immortality-demo/pkg/data"
+	"immortality-demo/pkg/db_model"
+	"immortality-demo/pkg/driver"
+	"immortality-demo/pkg/envelope"
+	"immortality-demo/pkg/gredis"
+	"sort"
+	"time"
+)
+
+// lockIds are the resources named in a request payload
+type lockIds struct {
+	DiskId       string `json:"disk_id"`
+	SnapshotId   string `json:"snapshot_id"`
+	ImageId      string `json:"image_id"`
+	SourceDiskId string `json:"source_disk_id"`
+	DiskID       string // data.DiskQoSRequest is not tagged
+}
+
+// jobKeys returns the resources the job must be serialized on, sorted. Jobs
+// sharing a disk, snapshot or image run one after another across all nodes,
+// jobs without such a resource run freely. The batch requests lock every disk
+// of the batch.
+func jobKeys(job Job) []string {
+	var ids struct {
+		lockIds
+		DisksReq  []lockIds // data.CreateDisksRequest and data.ResizeDisksRequest
+		DisksInfo []lockIds // data.DeleteDisksRequest
+	}
+	if err := json.Unmarshal(job.Payload, &ids); err != nil {
+		return nil
+	}
+
+	set := make(map[string]bool)
+	add := func(prefix, id string) {
+		if id != "" {
+			set[prefix+id] = true
+		}
+	}
+	for _, req := range append(append([]lockIds{ids.lockIds}, ids.DisksReq...), ids.DisksInfo...) {
+		add("disk:", req.DiskId)
+		add("disk:", req.DiskID)
+		add("disk:", req.SourceDiskId)
+		add("snapshot:", req.SnapshotId)
+		add("image:", req.ImageId)
+	}
+	keys := make([]string, 0, len(set))
+	for key := range set {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// process runs the job under the lock of its first key, then runs the jobs
+// parked behind it until none is left. Jobs are parked on their first key
+// only, the other keys are taken with lockOthers right before the job runs.
+func (p *WorkerPool) process(job Job) {
+	if job.key == "" {
+		if keys := jobKeys(job); len(keys) > 0 {
+			job.key = keys[0]
+		}
+	}
+	if job.key == "" {
+		p.execute(job)
+		return
+	}
+
+	node := config.Config.NodeID
+	key, token, raw := job.key, job.token, job.raw
+	if token == 0 {
+		var err error
+		token, raw, err = gredis.AcquireOrPark(JobQueue, key, node, lockTTL, job.raw, job.Fence)
+		if err != nil {
+			// left in flight, it is requeued when the node restarts
+			ulog.Errorf("[%s] lock %s error: %v", job.RequestId, key, err)
+			return
+		}
+		if token == 0 {
+			ulog.Infof("[%s] %s parked behind %s", job.RequestId, job.Action, key)
+			return
+		}
+	}
+
+	for raw != nil {
+		if p.stopping() {
+			// raw stays in flight and is requeued by Stop, it runs once the
+			// lock of this node expires
+			return
+		}
+		e, err := envelope.Decode(raw)
+		if err != nil {
+			ulog.Errorf("bury undecodable job %s: %v", raw, err)
+			if err = gredis.Bury(JobQueue, raw); err == nil {
+				p.ack(node, raw)
+			}
+		} else {
+			job := Job{Envelope: e, raw: raw, key: key, token: token}
+			if !p.lockOthers(&job) {
+				p.postpone(job)
+				return
+			}
+			stop := p.renew(job)
+			held := p.execute(job)
+			close(stop)
+			if held {
+				return
+			}
+			p.releaseOthers(job)
+		}
+
+		token, raw, err = gredis.ReleaseOrNext(JobQueue, key, node, token, lockTTL)
+		if err != nil {
+			ulog.Errorf("release %s error: %v", key, err)
+			return
+		}
+	}
+}
+
+// lockOthers takes the keys of the job but its first one, all of them or none
+func (p *WorkerPool) lockOthers(job *Job) bool {
+	var others []string
+	for _, key := range jobKeys(*job) {
+		if key != job.key {
+			others = append(others, key)
+		}
+	}
+	if len(others) == 0 {
+		return true
+	}
+
+	fences := make([]int64, len(others))
+	for i, key := range others {
+		fences[i] = job.Fences[key]
+	}
+	tokens, err := gredis.TryLocks(JobQueue, others, lockTTL, fences)
+	if err != nil {
+		ulog.Errorf("[%s] lock %v error: %v", job.RequestId, others, err)
+		return false
+	}
+	if tokens == nil {
+		return false
+	}
+	job.others = make(map[string]int64, len(others))
+	for i, key := range others {
+		job.others[key] = tokens[i]
+	}
+	return true
+}
+
+// releaseOthers releases the keys taken by lockOthers. The jobs parked
+// behind them are handed over to the pool.
+func (p *WorkerPool) releaseOthers(job Job) {
+	node := config.Config.NodeID
+	for key, token := range job.others {
+		next, raw, err := gredis.ReleaseOrNext(JobQueue, key, node, token, lockTTL)
+		if err != nil {
+			ulog.Errorf("[%s] release %s error: %v", job.RequestId, key, err)
+			continue
+		}
+		if raw == nil {
+			continue
+		}
+		e, err := envelope.Decode(raw)
+		if err != nil {
+			// process buries it and moves on to the next parked job
+			e = &envelope.Envelope{}
+		}
+		// not from this worker, it may be the one the pool is waiting for
+		go p.dispatch(Job{Envelope: e, raw: raw, key: key, token: next})
+	}
+}
+
+// postpone runs the job again shortly, its other keys were taken. It keeps
+// the first key like a retry does so the jobs parked behind it stay behind it.
+func (p *WorkerPool) postpone(job Job) {
+	node := config.Config.NodeID
+	job.Fence, job.Fences = job.token, nil
+	value, err := envelope.Encode(job.Envelope)
+	if err != nil {
+		ulog.Errorf("[%s] encode job error: %v", job.RequestId, err)
+		return
+	}
+	if _, err = gredis.HoldLock(JobQueue, job.key, job.token, postponeDelay+lockTTL); err != nil {
+		ulog.Errorf("[%s] hold lock of %s error: %v", job.RequestId, job.key, err)
+	}
+	if err = gredis.EnqueueAt(JobQueue, value, time.Now().Add(postponeDelay)); err != nil {
+		// left in flight, it is requeued when the node restarts
+		ulog.Errorf("[%s] postpone job error: %v", job.RequestId, err)
+		return
+	}
+	ulog.Infof("[%s] %s postponed, its resources are busy", job.RequestId, job.Action)
+	p.ack(node, job.raw)
+}
+
+// execute dispatches the job and acks it. It returns true if the lock of the
+// job must be kept, because a retry is pending or the job is still in flight.
+func (p *WorkerPool) execute(job Job) (held bool) {
+	node := config.Config.NodeID
+	job.Attempts++
+	markJobRunning(job)
+
+	ctx, cancel := p.jobContext()
+	err := driver.Dispatch(ctx, job.Action, string(job.Payload), job.Attempts)
+	timedOut := ctx.Err() == context.DeadlineExceeded
+	cancel()
+	if err == nil {
+		markJobFinished(job, data.JobStateSucceeded, "")
+		p.ack(node, job.raw)
+		return false
+	}
+
+	if p.ctx.Err() != nil {
+		// cancelled by Stop, the job is requeued and runs again elsewhere
+		ulog.Warningf("[%s] %s interrupted by shutdown: %v", job.RequestId, job.Action, err)
+		if job.RequestId != "" {
+			if err := db_model.MarkJobRetrying(data.Db, job.RequestId, err.Error()); err != nil {
+				ulog.Errorf("[%s] mark job retrying error: %v", job.RequestId, err)
+			}
+		}
+		return true
+	}
+
+	if timedOut {
+		// the driver gave up halfway, running it again blindly may make things
+		// worse, so it is left to the operators in the dead-letter list
+		ulog.Errorf("[%s] %s attempt %d timed out after %s: %v", job.RequestId, job.Action, job.Attempts, p.timeout, err)
+		if err = p.bury(job, data.JobStateTimeout, err); err != nil {
+			return true
+		}
+		p.ack(node, job.raw)
+		return false
+	}
+
+	ulog.Errorf("[%s] %s attempt %d failed: %v", job.RequestId, job.Action, job.Attempts, err)
+	retrying, err := p.retryOrBury(job, err)
+	if err != nil {
+		// keep the job in flight if it can not be rescheduled, it is requeued on restart
+		return true
+	}
+	p.ack(node, job.raw)
+	return retrying
+}
+
+// jobContext bounds a single attempt of a job by the timeout of the pool
+func (p *WorkerPool) jobContext() (context.Context, context.CancelFunc) {
+	if p.timeout > 0 {
+		return context.WithTimeout(p.ctx, p.timeout)
+	}
+	return context.WithCancel(p.ctx)
+}
+
+// renew keeps the locks of a running job alive until stop is closed
+func (p *WorkerPool) renew(job Job) (stop chan struct{}) {
+	locks := map[string]int64{job.key: job.token}
+	for key, token := range job.others {
+		locks[key] = token
+	}
+	stop = make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(lockTTL / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				for key, token := range locks {
+					ok, err := gredis.HoldLock(JobQueue, key, token, lockTTL)
+					if err != nil {
+						ulog.Errorf("renew lock %s error: %v", key, err)
+					} else if !ok {
+						ulog.Errorf("lock %s with token %d is lost", key, token)
+						delete(locks, key)
+					}
+				}
+				if len(locks) == 0 {
+					return
+				}
+			}
+		}
+	}()
+	return stop
+}
+
+// wake runs the parked jobs of keys whose holder died
+func (p *WorkerPool) wake(node string) {
+	for sleep(p.quit, wakeInterval) {
+		keys, err := gredis.ParkedKeys(JobQueue)
+		if err != nil {
+			ulog.Errorf("list parked keys error: %v", err)
+			continue
+		}
+		for _, key := range keys {
+			token, raw, err := gredis.Wake(JobQueue, key, node, lockTTL)
+			if err != nil {
+				ulog.Errorf("wake %s error: %v", key, err)
+				continue
+			}
+			if raw == nil {
+				continue
+			}
+			ulog.Warningf("woke parked jobs of %s", key)
+			e, err := envelope.Decode(raw)
+			if err != nil {
+				// process buries it and moves on to the next parked job
+				e = &envelope.Envelope{}
+			}
+			p.dispatch(Job{Envelope: e, raw: raw, key: key, token: token})
+		}
+	}
+}
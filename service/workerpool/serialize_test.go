@@ -0,0 +1,84 @@
+package workerpool
+
+import (
+	"immortality-demo/pkg/data"
+	"immortality-demo/pkg/envelope"
+	"reflect"
+	"testing"
+)
+
+func newJob(t *testing.T, action string, payload interface{}) Job {
+	e, err := envelope.New(action, "req-"+action, payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return Job{Envelope: e}
+}
+
+// sharesKey tells whether the two jobs are serialized on a common resource
+func sharesKey(a, b Job) bool {
+	for _, x := range jobKeys(a) {
+		for _, y := range jobKeys(b) {
+			if x == y {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func TestJobKeys(t *testing.T) {
+	createFromSnapshot := newJob(t, data.ActionCreateDisk, data.CreateDiskRequest{DiskId: "d-2", SnapshotId: "s-1"})
+	if keys := jobKeys(createFromSnapshot); !reflect.DeepEqual(keys, []string{"disk:d-2", "snapshot:s-1"}) {
+		t.Errorf("CreateDisk from a snapshot locks %v", keys)
+	}
+
+	deleteSnapshot := newJob(t, data.ActionDeleteSnapshot, data.DeleteSnapshotRequest{DiskId: "d-1", SnapshotId: "s-1"})
+	if !sharesKey(createFromSnapshot, deleteSnapshot) {
+		t.Error("CreateDisk from s-1 and DeleteSnapshot s-1 must not run together")
+	}
+
+	createImage := newJob(t, data.ActionCreateImage, data.CreateImageRequest{SourceDiskId: "d-1", ImageId: "img-1"})
+	deleteDisk := newJob(t, data.ActionDeleteDisk, data.DeleteDiskRequest{DiskId: "d-1"})
+	if keys := jobKeys(createImage); !reflect.DeepEqual(keys, []string{"disk:d-1", "image:img-1"}) {
+		t.Errorf("CreateImage locks %v", keys)
+	}
+	if !sharesKey(createImage, deleteDisk) {
+		t.Error("CreateImage of d-1 and DeleteDisk d-1 must not run together")
+	}
+	if sharesKey(createFromSnapshot, deleteDisk) {
+		t.Error("CreateDisk d-2 and DeleteDisk d-1 have nothing in common")
+	}
+
+	qos := newJob(t, data.ActionAddDiskQoS, data.DiskQoSRequest{DiskID: "d-1"})
+	if keys := jobKeys(qos); !reflect.DeepEqual(keys, []string{"disk:d-1"}) {
+		t.Errorf("AddDiskQoS locks %v", keys)
+	}
+	if keys := jobKeys(newJob(t, "Sweep", nil)); len(keys) != 0 {
+		t.Errorf("a job without resources locks %v", keys)
+	}
+}
+
+func TestJobKeys_Batch(t *testing.T) {
+	createDisks := newJob(t, data.ActionCreateDisks, data.CreateDisksRequest{DisksReq: []data.CreateDiskRequest{
+		{DiskId: "d-3"}, {DiskId: "d-1", SnapshotId: "s-1"}, {DiskId: "d-2", ImageId: "img-1"},
+	}})
+	want := []string{"disk:d-1", "disk:d-2", "disk:d-3", "image:img-1", "snapshot:s-1"}
+	if keys := jobKeys(createDisks); !reflect.DeepEqual(keys, want) {
+		t.Errorf("CreateDisks locks %v, want %v", keys, want)
+	}
+
+	deleteDisks := newJob(t, data.ActionDeleteDisks, data.DeleteDisksRequest{DisksInfo: []data.DeleteDiskRequest{
+		{DiskId: "d-5"}, {DiskId: "d-4"}, {DiskId: "d-5"},
+	}})
+	if keys := jobKeys(deleteDisks); !reflect.DeepEqual(keys, []string{"disk:d-4", "disk:d-5"}) {
+		t.Errorf("DeleteDisks locks %v", keys)
+	}
+
+	resizeDisks := newJob(t, data.ActionResizeDisks, data.ResizeDisksRequest{DisksReq: []data.ResizeDiskRequest{
+		{DiskId: "d-2"}, {DiskId: "d-4"},
+	}})
+	if !sharesKey(resizeDisks, createDisks) || !sharesKey(resizeDisks, deleteDisks) {
+		t.Error("ResizeDisks of d-2 and d-4 must not run with the batches touching them")
+	}
+}
@@ -2,55 +2,179 @@ package workerpool
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/coreos/pkg/capnslog"
-	"immortality-demo/pkg/driver"
+	"immortality-demo/config"
+	"immortality-demo/pkg/data"
+	"immortality-demo/pkg/db_model"
+	"immortality-demo/pkg/envelope"
 	"immortality-demo/pkg/gredis"
+	"sync/atomic"
 	"time"
 )
 
 var ulog = capnslog.NewPackageLogger("immortality", "workpool")
 
 //New 注册工作池，并设置最大并发数
-//new workpool and set the max number of concurrencies
-func NewWorkPool(max int) *WorkerPool {
+//new workpool and set the max number of concurrencies. timeout bounds each
+//attempt of a job, 0 means no bound.
+func NewWorkPool(max int, timeout time.Duration) *WorkerPool {
 	if max < 1 {
 		max = 1
 	}
 
+	ctx, cancel := context.WithCancel(context.Background())
 	p := &WorkerPool{
-		Job: make(chan Job, 2*max),
+		timeout: timeout,
+		Job:     make(chan Job, 2*max),
+		ctx:     ctx,
+		cancel:  cancel,
+		quit:    make(chan struct{}),
+		done:    make(chan struct{}),
 	}
 
 	go p.loop(max)
 	return p
 }
 
-//SetTimeout 设置超时时间
-func (p *WorkerPool) SetTimeout(timeout time.Duration) {
-	p.timeout = timeout
+// Stop stops consuming the queue and waits up to timeout for the running jobs
+// to finish. Jobs still running then are cancelled, and whatever is left in
+// flight on this node is put back to the queue for the other nodes.
+func (p *WorkerPool) Stop(timeout time.Duration) {
+	if !atomic.CompareAndSwapInt32(&p.closed, 0, 1) {
+		return
+	}
+	ulog.Info("stopping worker pool...")
+	close(p.quit)
+
+	if !p.wait(timeout) {
+		ulog.Warningf("jobs still running after %s, cancelling them", timeout)
+		p.cancel()
+		if !p.wait(stopGrace) {
+			ulog.Warning("jobs did not return after being cancelled")
+		}
+	}
+	p.cancel()
+
+	node := config.Config.NodeID
+	if n, err := gredis.Requeue(JobQueue, node); err != nil {
+		ulog.Errorf("requeue in-flight jobs of %s error: %v", node, err)
+	} else if n > 0 {
+		ulog.Warningf("requeued %d in-flight jobs of %s", n, node)
+	}
+	close(p.done)
+	ulog.Info("worker pool stopped")
+}
+
+// wait waits for the workers to exit, it returns false on timeout
+func (p *WorkerPool) wait(timeout time.Duration) bool {
+	exited := make(chan struct{})
+	go func() {
+		p.wg.Wait()
+		close(exited)
+	}()
+	select {
+	case <-exited:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+func (p *WorkerPool) stopping() bool {
+	select {
+	case <-p.quit:
+		return true
+	default:
+		return false
+	}
+}
+
+// sleep waits for d, it returns false if ch is closed first
+func sleep(ch chan struct{}, d time.Duration) bool {
+	select {
+	case <-ch:
+		return false
+	case <-time.After(d):
+		return true
+	}
 }
 
 func (p *WorkerPool) startQueue() {
-	for {
-		var job Job
-		tmp, err := gredis.TryPop("queue")
+	node := config.Config.NodeID
+	// whatever this node left in flight before a restart is handled again
+	if n, err := gredis.Requeue(JobQueue, node); err != nil {
+		ulog.Errorf("requeue in-flight jobs of %s error: %v", node, err)
+	} else if n > 0 {
+		ulog.Warningf("requeued %d in-flight jobs of %s", n, node)
+	}
+	go p.heartbeat(node)
+	go p.reap()
+	go p.moveDue()
+	go p.wake(node)
+
+	for !p.stopping() {
+		tmp, err := gredis.Dequeue(JobQueue, node, dequeueTimeout)
 		if err != nil {
-			fmt.Println(err)
+			ulog.Error(err)
+			sleep(p.quit, time.Second)
+			continue
+		}
+		if tmp == nil {
+			continue
 		}
-		if tmp != nil {
-			err = json.Unmarshal(tmp.([]byte), &job)
-			if err != nil {
-				fmt.Println(err)
+		e, err := envelope.Decode(tmp)
+		if err != nil {
+			// nothing this version can run, keep it for the operators
+			ulog.Errorf("bury undecodable job %s: %v", tmp, err)
+			if err = gredis.Bury(JobQueue, tmp); err == nil {
+				p.ack(node, tmp)
 			}
-			p.Job <- job
-		} else {
-			time.Sleep(time.Second)
-			fmt.Println("tmp is nil ")
+			continue
 		}
-		//time.Sleep(time.Second)
+		p.dispatch(Job{Envelope: e, raw: tmp})
+	}
+}
+
+// dispatch hands the job over to the workers. Once the pool is stopping the
+// job is left in the processing list and requeued by Stop.
+func (p *WorkerPool) dispatch(job Job) {
+	select {
+	case p.Job <- job:
+	case <-p.quit:
+	}
+}
+
+// heartbeat keeps the in-flight jobs of node from being reaped while it is alive
+func (p *WorkerPool) heartbeat(node string) {
+	for {
+		if err := gredis.Heartbeat(JobQueue, node, heartbeatTTL); err != nil {
+			ulog.Errorf("heartbeat of %s error: %v", node, err)
+		}
+		if !sleep(p.done, heartbeatTTL/3) {
+			return
+		}
+	}
+}
+
+// reap puts the in-flight jobs of dead nodes back to the queue
+func (p *WorkerPool) reap() {
+	for sleep(p.quit, reapInterval) {
+		n, err := gredis.Reap(JobQueue)
+		if err != nil {
+			ulog.Errorf("reap in-flight jobs error: %v", err)
+			continue
+		}
+		if n > 0 {
+			ulog.Warningf("requeued %d in-flight jobs of dead nodes", n)
+		}
+	}
+}
+
+func (p *WorkerPool) ack(node string, raw []byte) {
+	if err := gredis.Ack(JobQueue, node, raw); err != nil {
+		ulog.Errorf("ack job %s error: %v", raw, err)
 	}
 }
 
@@ -71,39 +195,38 @@ func (p *WorkerPool) loop(maxWorkersCount int) {
 		go func() {
 			defer p.wg.Done()
 			// worker 开始干活
-			for job := range p.Job {
-
-				closed := make(chan struct{}, 1)
-				// Set timeout, priority task timeout.有设置超时,优先task 的超时
-				if p.timeout > 0 {
-					ct, cancel := context.WithTimeout(context.Background(), p.timeout)
-					go func() {
-						select {
-						case <-ct.Done():
-							ulog.Error(ct.Err())
-							cancel()
-						case <-closed:
-						}
-					}()
-				}
-				//Points of Execution.真正执行的点
-				//err := testJob(job)
-				data, err := json.Marshal(job.Detail)
-				if err != nil {
-					fmt.Println(err)
-				}
-				err = driver.Dispatch(job.Action, string(data))
-				if err != nil {
-					fmt.Println(err)
-					ulog.Error(err)
-				}
-				//time.Sleep(time.Second)
-				close(closed)
-				if err != nil {
-					fmt.Println(err)
-					ulog.Error(err)
+			for {
+				select {
+				case <-p.quit:
+					return
+				case job := <-p.Job:
+					if p.stopping() {
+						// left in flight, Stop requeues it
+						return
+					}
+					//Points of Execution.真正执行的点
+					p.process(job)
 				}
 			}
 		}()
 	}
 }
+
+// markJobRunning records a new attempt of the job, jobs without RequestId are not tracked
+func markJobRunning(job Job) {
+	if job.RequestId == "" {
+		return
+	}
+	if err := db_model.MarkJobRunning(data.Db, job.RequestId); err != nil {
+		ulog.Errorf("[%s] mark job running error: %v", job.RequestId, err)
+	}
+}
+
+func markJobFinished(job Job, state int8, lastError string) {
+	if job.RequestId == "" {
+		return
+	}
+	if err := db_model.MarkJobFinished(data.Db, job.RequestId, state, lastError); err != nil {
+		ulog.Errorf("[%s] mark job finished error: %v", job.RequestId, err)
+	}
+}
@@ -3,33 +3,100 @@ package workerpool
 import (
 	"fmt"
 	"immortality-demo/config"
+	"immortality-demo/pkg/envelope"
 	"immortality-demo/pkg/gredis"
+	"strings"
 	"testing"
-)
+	"time"
 
-func TestWorkerPoolError(t *testing.T) {
+	"github.com/gomodule/redigo/redis"
+)
 
+// setupRedis connects redis of the config, the test is skipped if it is not reachable
+func setupRedis(t *testing.T) {
 	config.LoadConfig()
-	config.SetupLogging()
 	gredis.Setup()
-	NewWorkPool(3) //Set the maximum number of threads，设置最大线程数
-	//
-	//
-	var jobs []Job
-
-	for i := 0; i < 10; i++ {
-		job := Job{
-			Action: fmt.Sprintf("CreatDisk%d", i),
-			Detail: nil,
+	conn := gredis.RedisConn.Get()
+	_, err := conn.Do("PING")
+	conn.Close()
+	if err != nil {
+		t.Skip("redis is not available:", err)
+	}
+}
+
+// TestWorkerPoolError queues jobs of an unknown action, each of them fails and
+// is scheduled again instead of being lost. The jobs have no RequestId so no
+// database is needed.
+func TestWorkerPoolError(t *testing.T) {
+	setupRedis(t)
+	config.SetupLogging()
+
+	prefix := fmt.Sprintf("UnknownAction%d-", time.Now().UnixNano())
+	const count = 10
+	for i := 0; i < count; i++ {
+		e, _ := envelope.New(fmt.Sprintf("%s%d", prefix, i), "", nil)
+		job, _ := envelope.Encode(e)
+		if err := gredis.Enqueue(JobQueue, job); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	p := NewWorkPool(3, time.Minute)
+	defer cleanupJobs(prefix)
+	defer p.Stop(time.Second)
+
+	deadline := time.Now().Add(30 * time.Second)
+	for {
+		n, err := countDelayed(prefix)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n == count {
+			break
 		}
-		jobs = append(jobs, job)
+		if time.Now().After(deadline) {
+			t.Fatalf("%d of %d failed jobs are scheduled for a retry", n, count)
+		}
+		time.Sleep(100 * time.Millisecond)
 	}
-	for _, v := range jobs {
-		gredis.Push("queue", v)
+}
+
+// countDelayed counts the delayed jobs whose action starts with prefix
+func countDelayed(prefix string) (int, error) {
+	conn := gredis.RedisConn.Get()
+	defer conn.Close()
+
+	items, err := redis.ByteSlices(conn.Do("ZRANGE", gredis.DelayedKey(JobQueue), 0, -1))
+	if err != nil {
+		return 0, err
 	}
+	n := 0
+	for _, item := range items {
+		if e, err := envelope.Decode(item); err == nil && strings.HasPrefix(e.Action, prefix) {
+			n++
+		}
+	}
+	return n, nil
+}
 
-	var res chan bool
-	<-res
+// cleanupJobs drops the jobs whose action starts with prefix from the queue,
+// its delayed set and its dead-letter list
+func cleanupJobs(prefix string) {
+	conn := gredis.RedisConn.Get()
+	defer conn.Close()
 
-	fmt.Println("down")
+	items, _ := redis.ByteSlices(conn.Do("ZRANGE", gredis.DelayedKey(JobQueue), 0, -1))
+	for _, item := range items {
+		if e, err := envelope.Decode(item); err == nil && strings.HasPrefix(e.Action, prefix) {
+			conn.Do("ZREM", gredis.DelayedKey(JobQueue), item)
+		}
+	}
+	for _, key := range []string{JobQueue, gredis.DeadKey(JobQueue)} {
+		items, _ := redis.ByteSlices(conn.Do("LRANGE", key, 0, -1))
+		for _, item := range items {
+			if e, err := envelope.Decode(item); err == nil && strings.HasPrefix(e.Action, prefix) {
+				conn.Do("LREM", key, 0, item)
+			}
+		}
+	}
 }